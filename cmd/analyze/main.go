@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// stateSpread holds the action-value spread of a single state.
+type stateSpread struct {
+	index  int
+	spread float64
+	best   agent.Action
+}
+
+func main() {
+	qPath := flag.String("q", "data/q_matrix.csv", "model bundle or bare Q-matrix file to analyze")
+	topK := flag.Int("top", 10, "number of states to list by action-value spread")
+	flag.Parse()
+
+	bundle, err := model.Load(*qPath)
+	if err != nil {
+		fmt.Printf("Error loading Q-matrix: %v\n", err)
+		os.Exit(1)
+	}
+	Q := bundle.Q
+	if len(Q) == 0 {
+		fmt.Println("Error: Q-matrix is empty")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Q-matrix: %s\n", *qPath)
+	fmt.Printf("States: %d, actions: %d\n\n", len(Q), len(Q[0]))
+
+	// Greedy action distribution and untrained state count
+	untrained := 0
+	greedyCounts := make([]int, len(Q[0]))
+	spreads := make([]stateSpread, 0, len(Q))
+	for s, row := range Q {
+		if isUntrained(row) {
+			untrained++
+			continue
+		}
+		best := agent.ArgMax(row)
+		greedyCounts[best]++
+		spreads = append(spreads, stateSpread{
+			index:  s,
+			spread: agent.MaxValue(row) - minValue(row),
+			best:   agent.Action(best),
+		})
+	}
+	trained := len(Q) - untrained
+
+	fmt.Printf("Untrained (all-zero) states: %d (%.2f%%)\n", untrained, percent(untrained, len(Q)))
+	fmt.Printf("Trained states: %d (%.2f%%)\n\n", trained, percent(trained, len(Q)))
+
+	fmt.Println("Greedy action distribution over trained states:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  action\tstates\tshare %")
+	for a, count := range greedyCounts {
+		fmt.Fprintf(w, "  %s\t%d\t%.2f\n", agent.Action(a), count, percent(count, trained))
+	}
+	w.Flush()
+
+	// Top-K states by action-value spread
+	sort.Slice(spreads, func(i, j int) bool {
+		return spreads[i].spread > spreads[j].spread
+	})
+	if *topK > len(spreads) {
+		*topK = len(spreads)
+	}
+
+	fmt.Printf("\nTop %d states by action-value spread:\n", *topK)
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  state\tMA ordering\tdivergence\tcash\tshares\tbest action\tspread")
+	for _, sp := range spreads[:*topK] {
+		s := state.FromIndex(sp.index)
+		fmt.Fprintf(w, "  %d\t%v\t%s\t%s\t%s\t%s\t%.6f\n",
+			sp.index, ma.DecodeMAState(s.MAState), state.DivergenceName(s.MADivergence),
+			state.PositionName(s.CashCat), state.PositionName(s.SharesCat), sp.best, sp.spread)
+	}
+	w.Flush()
+}
+
+// isUntrained reports whether all Q-values of a state are still zero.
+func isUntrained(row []float64) bool {
+	for _, v := range row {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// minValue returns the minimum value in a slice.
+func minValue(arr []float64) float64 {
+	if len(arr) == 0 {
+		return 0
+	}
+	min := arr[0]
+	for _, v := range arr[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
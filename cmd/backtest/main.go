@@ -0,0 +1,668 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/eval"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+	"github.com/kasaderos/rLportfolio/pkg/plot"
+)
+
+// Report is the full result of a backtest, written as JSON.
+type Report struct {
+	Model       string          `json:"model"`
+	Data        string          `json:"data"`
+	Dataset     string          `json:"dataset,omitempty"`
+	Split       string          `json:"split,omitempty"`
+	Symbol      string          `json:"symbol"`
+	WarmUp      int             `json:"warm_up_bars"`
+	WarmUpFrom  string          `json:"warm_up_from"`
+	From        string          `json:"from"` // Date of the first decision
+	To          string          `json:"to"`   // Date of the last price
+	Steps       int             `json:"steps"`
+	InitialCash float64         `json:"initial_cash"`
+	Commission  float64         `json:"commission"`
+	Slippage    float64         `json:"slippage,omitempty"`
+	Policy      metrics.Metrics `json:"policy"`
+	BuyAndHold  metrics.Metrics `json:"buy_and_hold"`
+	Excess      float64         `json:"excess_return"` // Policy minus buy-and-hold total return
+	Actions     map[string]int  `json:"actions"`       // Times each action was chosen
+	Commissions float64         `json:"commissions_paid"`
+	// Regret compares every action with the best one in hindsight (with -regret)
+	Regret *eval.RegretSummary `json:"regret,omitempty"`
+	// CostSweep holds the policy at every level of trading costs (with -cost-sweep)
+	CostSweep []eval.CostPoint `json:"cost_sweep,omitempty"`
+	// PeriodsPerYear annualizes volatility and Sharpe ratios: bars per year as measured
+	// from the dates, or metrics.TradingDaysPerYear for undated prices
+	PeriodsPerYear float64 `json:"periods_per_year"`
+	// FX is the series converting the prices into the base currency the portfolio
+	// is valued in, e.g. EURUSD or 1/USDJPY; empty when quoted in it
+	FX string `json:"fx,omitempty"`
+	// Gains are the capital gains of the policy's trades (with -lots)
+	Gains *eval.GainsReport `json:"gains,omitempty"`
+	// InitialShares is the position held at the start besides the cash (with -shares)
+	InitialShares float64 `json:"initial_shares,omitempty"`
+	// MinTradeWeight and MinTradeValue are the minimum trade size below which
+	// trades were held (with -min-trade-weight and -min-trade-value)
+	MinTradeWeight float64 `json:"min_trade_weight,omitempty"`
+	MinTradeValue  float64 `json:"min_trade_value,omitempty"`
+}
+
+func main() {
+	modelPath := flag.String("model", "data/model", "model bundle (directory or .json file), or a bare Q-matrix file")
+	dataPath := flag.String("data", "data/test.csv", "price file to backtest on")
+	dataset := flag.String("dataset", "", "named dataset from the catalog (overrides -data)")
+	splitName := flag.String("split", "test", "split of -dataset; its date range is used unless -from/-to are set")
+	catalogPath := flag.String("catalog", data.DefaultCatalog, "dataset catalog used by -dataset")
+	symbol := flag.String("symbol", "", "series to backtest, by symbol name (overrides -column)")
+	column := flag.Int("column", 0, "series to backtest, by position (Date column excluded)")
+	fromFlag := flag.String("from", "", "first trading date (bars before it are only used for warm-up)")
+	toFlag := flag.String("to", "", "last date (inclusive)")
+	warmUp := flag.Int("warmup", 0, "bars of history before the first decision (0 uses the model encoder's warm-up, the least allowed)")
+	initialCash := flag.Float64("cash", 10000.0, "initial cash")
+	allowOverlap := flag.Bool("allow-overlap", false, "report results even when the traded bars overlap the model's training data")
+	initialShares := flag.Float64("shares", 0, "shares held at the start besides the cash, e.g. to backtest deploying the policy on an existing position")
+	commission := flag.Float64("commission", 0.002, "commission rate")
+	commissionMin := flag.Float64("commission-min", 0, "minimum commission of a trade, e.g. 1 (0 disables); trades whose commission would exceed the trade are skipped")
+	commissionMax := flag.Float64("commission-max", 0, "maximum commission of a trade (0 disables)")
+	feeFXSeries := flag.String("fee-fx-series", "", "FX series of -fx converting the currency -commission-min and -commission-max are quoted in into the base currency, e.g. EURUSD (default: the base currency)")
+	minTradeWeight := flag.Float64("min-trade-weight", 0, "skip trades smaller than this fraction of the portfolio value, e.g. 0.01, holding instead (0 disables)")
+	minTradeValue := flag.Float64("min-trade-value", 0, "skip trades smaller than this value, e.g. 10, holding instead (0 disables)")
+	slippage := flag.Float64("slippage", 0, "fraction of the price lost on every trade: buys fill at price·(1+slippage), sells at price·(1-slippage)")
+	volTarget := flag.Float64("vol-target", 0, "scale buy sizes to this annualized volatility, e.g. 0.2 (0 disables)")
+	volWindow := flag.Int("vol-window", env.DefaultVolWindow, "returns over which -vol-target measures the asset's volatility")
+	sizingFlag := flag.String("sizing", "fixed", "buy sizing: fixed (fractions of cash) or kelly (half and full Kelly weight tiers)")
+	kellyWindow := flag.Int("kelly-window", env.DefaultKellyWindow, "returns from which -sizing kelly estimates the Kelly weight")
+	fxPath := flag.String("fx", "", "price file of the FX series converting the asset's currency into the portfolio's base currency (optional)")
+	fxSeries := flag.String("fx-series", "", "FX series of -fx, by symbol, e.g. EURUSD, or 1/USDJPY for a rate quoted as quote currency per base currency (default: first column)")
+	lotsFlag := flag.String("lots", "none", "tax-lot accounting for a capital-gains report: none, fifo (sell the oldest shares first), or lifo")
+	taxRate := flag.Float64("tax-rate", 0, "capital-gains tax rate of the -lots report, e.g. 0.25")
+	gainsOut := flag.String("gains-out", "", "output for the realized gain of every lot sold, with -lots (.csv, .json, or .parquet; optional)")
+	missing := flag.String("missing", "drop", "policy for missing or invalid prices: drop, ffill, or error")
+	reportOut := flag.String("report", "data/backtest.json", "output for the JSON metrics report")
+	equityOut := flag.String("equity-out", "", "output for the dated equity curve and actions (.csv, .json, or .parquet; optional)")
+	regretOut := flag.String("regret", "", "output for the counterfactual regret series: every step's reward against the best action in hindsight (.csv, .json, or .parquet; optional)")
+	regretTop := flag.Int("regret-top", 10, "states to list by total regret with -regret")
+	costSweep := flag.Bool("cost-sweep", false, "re-run the policy at every combination of -commissions and -slippages to show how fragile it is to trading costs")
+	commissions := flag.String("commissions", formatLevels(eval.DefaultCommissions), "comma-separated commission rates swept by -cost-sweep (positive)")
+	slippages := flag.String("slippages", formatLevels(eval.DefaultSlippages), "comma-separated slippage levels swept by -cost-sweep")
+	costOut := flag.String("cost-out", "data/costs.csv", "output for the -cost-sweep table (.csv, .json, or .parquet; empty to skip)")
+	costPlot := flag.String("cost-plot", "data/costs.png", "output for the -cost-sweep chart of return vs commission (.png or .svg; empty to skip)")
+	flag.Parse()
+	if *minTradeWeight < 0 || *minTradeValue < 0 {
+		fmt.Println("Error: -min-trade-weight and -min-trade-value must not be negative")
+		os.Exit(1)
+	}
+	if *commissionMin < 0 || *commissionMax < 0 || *commissionMax > 0 && *commissionMax < *commissionMin {
+		fmt.Println("Error: -commission-min and -commission-max must not be negative, with -commission-max at least -commission-min")
+		os.Exit(1)
+	}
+	if *feeFXSeries != "" && *fxPath == "" {
+		fmt.Println("Error: -fee-fx-series needs -fx")
+		os.Exit(1)
+	}
+
+	bundle, err := model.Load(*modelPath)
+	if err != nil {
+		fmt.Printf("Error loading model: %v\n", err)
+		os.Exit(1)
+	}
+	encoder, err := bundle.StateEncoder()
+	if err != nil {
+		fmt.Printf("Error: incompatible model: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Loaded model %s (schema version %d)\n", *modelPath, bundle.SchemaVersion)
+	if *warmUp == 0 {
+		*warmUp = encoder.WarmUp()
+	}
+	if *warmUp < encoder.WarmUp() {
+		fmt.Printf("Error: -warmup must be at least %d (the warm-up of the model's encoder)\n", encoder.WarmUp())
+		os.Exit(1)
+	}
+
+	// Resolve the date range: explicit flags win over the dataset split
+	split := data.Split{File: *dataPath, From: *fromFlag, To: *toFlag}
+	if *dataset != "" {
+		catalog, err := data.LoadCatalog(*catalogPath)
+		if err == nil {
+			split, err = catalog.Split(*dataset, *splitName)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *fromFlag != "" {
+			split.From = *fromFlag
+		}
+		if *toFlag != "" {
+			split.To = *toFlag
+		}
+	}
+	from, to, err := split.Range()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var commissionLevels, slippageLevels []float64
+	if *costSweep {
+		commissionLevels, err = parseLevels(*commissions)
+		if err == nil {
+			slippageLevels, err = parseLevels(*slippages)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	lots, err := env.ParseLotMethod(*lotsFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *gainsOut != "" && lots == env.LotNone {
+		fmt.Println("Error: -gains-out needs -lots fifo or lifo")
+		os.Exit(1)
+	}
+	sizing, err := env.ParseSizing(*sizingFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	policy, err := data.ParseMissingPolicy(*missing)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	// Load the whole file: warm-up bars may lie before the split
+	series, _, _, err := data.LoadWithOptions(split.File, data.Options{Missing: policy})
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", split.File, err)
+		os.Exit(1)
+	}
+	selected, err := data.Select(series, *symbol, *column)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	window, startIdx, err := tradingWindow(selected.Bars, from, to, *warmUp)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Refuse to pass in-sample results off as a backtest
+	if !checkOverlap(data.CheckOverlap(bundle.Training.Coverage, selected.Symbol, window[startIdx:]), *allowOverlap) {
+		os.Exit(1)
+	}
+
+	prices := make([]float64, len(window))
+	for i, b := range window {
+		prices[i] = b.Close
+	}
+	var fx []float64
+	var feeFX []float64
+	if *feeFXSeries != "" {
+		if feeFX, err = loadFX(*fxPath, *feeFXSeries, policy, window); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *fxPath != "" {
+		if fx, err = loadFX(*fxPath, *fxSeries, policy, window); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Dated bars set the annualization; undated ones are assumed daily
+	clock, err := data.ClockOf(window)
+	if err != nil {
+		clock = nil
+	}
+	config := eval.Config{InitialCash: *initialCash, MinStartIdx: startIdx, Commission: *commission, Slippage: *slippage, VolTarget: *volTarget, VolWindow: *volWindow,
+		Sizing: sizing, KellyWindow: *kellyWindow, Regret: *regretOut != "", Clock: clock, FX: fx,
+		Lots: lots, TaxRate: *taxRate, InitialShares: *initialShares, MinTradeWeight: *minTradeWeight, MinTradeValue: *minTradeValue,
+		CommissionMin: *commissionMin, CommissionMax: *commissionMax, FeeFX: feeFX}
+	result, err := eval.Evaluate(bundle.Q, encoder, prices, config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Buy and hold is valued like the portfolio, in the base currency
+	values := env.ToBase(prices, fx)
+	report := buildReport(result, window, startIdx, values, config)
+	report.Model = *modelPath
+	report.Data = split.File
+	report.Dataset = *dataset
+	if *dataset != "" {
+		report.Split = *splitName
+	}
+	report.Symbol = selected.Symbol
+	report.WarmUp = startIdx
+	if *fxPath != "" {
+		report.FX = *fxSeries
+		if report.FX == "" {
+			report.FX = *fxPath
+		}
+	}
+	if result.Regret != nil {
+		states := make([]int, len(result.States))
+		for i, s := range result.States {
+			states[i] = s.Index
+		}
+		summary := eval.SummarizeRegret(result.Regret, states)
+		report.Regret = &summary
+	}
+	if lots != env.LotNone {
+		gains := eval.SummarizeGains(lots, result.Realized, result.Lots, values[len(values)-1], *taxRate)
+		report.Gains = &gains
+	}
+	if *costSweep {
+		report.CostSweep, err = eval.CostSweep(bundle.Q, encoder, prices, config, commissionLevels, slippageLevels)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	printReport(report)
+	if report.Regret != nil {
+		printRegret(*report.Regret, bundle, *regretTop)
+	}
+	if report.Gains != nil {
+		printGains(*report.Gains)
+	}
+	if report.CostSweep != nil {
+		printCostSweep(report.CostSweep)
+	}
+
+	if err := writeJSON(*reportOut, report); err != nil {
+		fmt.Printf("Failed to write report: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\nSaved report to %s\n", *reportOut)
+
+	if *regretOut != "" {
+		if err := saveRegret(*regretOut, result, report.Regret.Cumulative, window); err != nil {
+			fmt.Printf("Failed to save regret series: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved regret series to %s\n", *regretOut)
+	}
+
+	if *gainsOut != "" {
+		if err := saveGains(*gainsOut, result.Realized, window); err != nil {
+			fmt.Printf("Failed to save realized gains: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved realized gains to %s\n", *gainsOut)
+	}
+
+	if *costSweep && *costOut != "" {
+		if err := saveCostSweep(*costOut, report.CostSweep); err != nil {
+			fmt.Printf("Failed to save cost sweep: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved cost sweep to %s\n", *costOut)
+	}
+	if *costSweep && *costPlot != "" {
+		if err := plotCostSweep(*costPlot, report.CostSweep, commissionLevels, slippageLevels); err != nil {
+			fmt.Printf("Failed to plot cost sweep: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved cost chart to %s\n", *costPlot)
+	}
+
+	if *equityOut != "" {
+		if err := saveEquity(*equityOut, result, window); err != nil {
+			fmt.Printf("Failed to save equity curve: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved equity curve to %s\n", *equityOut)
+	}
+}
+
+// loadFX loads the FX series named pair from path and returns its rate for every
+// bar of the window.
+func loadFX(path, pair string, policy data.MissingPolicy, window []data.Bar) ([]float64, error) {
+	series, _, _, err := data.LoadWithOptions(path, data.Options{Missing: policy})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load FX series: %w", err)
+	}
+	fxPair := data.ParseFXPair(pair)
+	selected, err := data.Select(series, fxPair.Series, 0)
+	if err != nil {
+		return nil, fmt.Errorf("FX series: %w", err)
+	}
+	return data.FXRates(window, selected.Bars, fxPair.Invert)
+}
+
+// tradingWindow returns the bars to simulate and the index of the first decision within
+// them. The first decision is the first bar on or after from; the warmUp bars before it
+// are kept so every moving average is defined from the first decision on. Without dates
+// or a from bound, trading starts as soon as the warm-up is complete.
+func tradingWindow(bars []data.Bar, from, to time.Time, warmUp int) ([]data.Bar, int, error) {
+	end := len(bars)
+	if !to.IsZero() {
+		end = sort.Search(len(bars), func(i int) bool { return bars[i].Time.After(to) })
+	}
+
+	start := warmUp
+	if !from.IsZero() {
+		start = sort.Search(len(bars), func(i int) bool { return !bars[i].Time.Before(from) })
+		if start < warmUp {
+			if start >= end {
+				return nil, 0, fmt.Errorf("no bars between %s and %s", from.Format("2006-01-02"), to.Format("2006-01-02"))
+			}
+			return nil, 0, fmt.Errorf("only %d bars before %s, need %d for warm-up (first possible start: %s)",
+				start, from.Format("2006-01-02"), warmUp, bars[min(warmUp, len(bars)-1)].Time.Format("2006-01-02"))
+		}
+	}
+	if end-start < 2 {
+		return nil, 0, fmt.Errorf("need at least 2 bars to trade after the warm-up, got %d", max(end-start, 0))
+	}
+	return bars[start-warmUp : end], warmUp, nil
+}
+
+// checkOverlap prints how the test bars overlap the model's training data and
+// reports whether results may still be reported: only with allow.
+func checkOverlap(overlaps []data.Overlap, allow bool) bool {
+	if len(overlaps) == 0 {
+		return true
+	}
+	label := "Error"
+	if allow {
+		label = "Warning"
+	}
+	fmt.Printf("%s: the test data overlaps the model's training data, results are in-sample:\n", label)
+	for _, o := range overlaps {
+		fmt.Printf("  %s\n", o)
+	}
+	if !allow {
+		fmt.Println("Use -allow-overlap to report them anyway")
+	}
+	return allow
+}
+
+// buildReport computes the policy and buy-and-hold metrics over the traded bars.
+func buildReport(result *eval.Result, window []data.Bar, startIdx int, prices []float64, config eval.Config) Report {
+	report := Report{
+		From:        formatDate(window[startIdx].Time),
+		WarmUpFrom:  formatDate(window[0].Time),
+		To:          formatDate(window[len(window)-1].Time),
+		Steps:       len(result.Actions),
+		InitialCash: config.InitialCash,
+		Commission:  config.Commission,
+		Slippage:    config.Slippage,
+		Policy:      result.Metrics,
+		Actions:     make(map[string]int),
+	}
+	report.InitialShares = config.InitialShares
+	report.MinTradeWeight = config.MinTradeWeight
+	report.MinTradeValue = config.MinTradeValue
+	report.PeriodsPerYear = metrics.TradingDaysPerYear
+	if config.Clock != nil {
+		report.PeriodsPerYear = config.Clock.PeriodsPerYear()
+	}
+
+	// Buy and hold: the initial shares kept and all cash invested at the first
+	// decision price, one commission
+	traded := prices[startIdx:]
+	shares := config.InitialShares + config.InitialCash*(1-config.Commission)/(traded[0]*(1+config.Slippage))
+	holdValues := make([]float64, len(traded))
+	for i, p := range traded {
+		holdValues[i] = shares * p
+	}
+	holdValues[0] = result.Equity[0]
+	report.BuyAndHold = metrics.ComputeAnnualized(holdValues, nil, report.PeriodsPerYear)
+	report.BuyAndHold.NumTrades = 1
+	report.Excess = report.Policy.TotalReturn - report.BuyAndHold.TotalReturn
+
+	for _, a := range result.Actions {
+		report.Actions[a.String()]++
+	}
+	report.Commissions = result.Metrics.Commissions
+	return report
+}
+
+func printReport(r Report) {
+	fmt.Printf("\n=== Backtest %s ===\n", r.Symbol)
+	fmt.Printf("Warm-up: %d bars from %s\n", r.WarmUp, r.WarmUpFrom)
+	fmt.Printf("Trading: %s to %s (%d steps, %.1f per year)\n\n", r.From, r.To, r.Steps, r.PeriodsPerYear)
+	fmt.Printf("%-14s %12s %12s\n", "", "policy", "buy & hold")
+	fmt.Printf("%-14s %12.2f %12.2f\n", "final value", r.Policy.FinalValue, r.BuyAndHold.FinalValue)
+	fmt.Printf("%-14s %11.2f%% %11.2f%%\n", "return", r.Policy.TotalReturn*100, r.BuyAndHold.TotalReturn*100)
+	fmt.Printf("%-14s %11.2f%% %11.2f%%\n", "max drawdown", r.Policy.MaxDrawdown*100, r.BuyAndHold.MaxDrawdown*100)
+	fmt.Printf("%-14s %11.2f%% %11.2f%%\n", "volatility", r.Policy.Volatility*100, r.BuyAndHold.Volatility*100)
+	fmt.Printf("%-14s %12.3f %12.3f\n", "sharpe", r.Policy.Sharpe, r.BuyAndHold.Sharpe)
+	fmt.Printf("%-14s %12d %12d\n", "trades", r.Policy.NumTrades, r.BuyAndHold.NumTrades)
+	fmt.Printf("\nExcess return: %.2f%%\n", r.Excess*100)
+	fmt.Printf("Commissions paid: %.2f\n", r.Commissions)
+	fmt.Println("Actions:")
+	for a := agent.Action(0); a < agent.NumActions; a++ {
+		fmt.Printf("  %-10s %d\n", a, r.Actions[a.String()])
+	}
+}
+
+// saveEquity writes the date, price, portfolio value, and action of every traded bar,
+// and the buy size scale with a volatility target.
+func saveEquity(filename string, result *eval.Result, window []data.Bar) error {
+	header := []string{"Date", "Price", "PortfolioValue", "Action"}
+	if result.SizeScales != nil {
+		header = append(header, "SizeScale")
+	}
+	records := [][]string{header}
+	for i, value := range result.Equity {
+		bar := window[result.StartIdx+i]
+		action := ""
+		if i < len(result.Actions) {
+			action = result.Actions[i].String()
+		}
+		record := []string{
+			formatDate(bar.Time),
+			strconv.FormatFloat(bar.Close, 'f', 6, 64),
+			strconv.FormatFloat(value, 'f', 6, 64),
+			action,
+		}
+		if result.SizeScales != nil {
+			scale := ""
+			if i < len(result.SizeScales) {
+				scale = strconv.FormatFloat(result.SizeScales[i], 'f', 4, 64)
+			}
+			record = append(record, scale)
+		}
+		records = append(records, record)
+	}
+	return data.WriteTable(filename, records)
+}
+
+// printRegret prints the regret summary and the states with the largest total regret.
+func printRegret(r eval.RegretSummary, bundle *model.Bundle, top int) {
+	fmt.Printf("\nRegret vs the best action in hindsight: total %.4f, %.6f per step, %.1f%% of steps optimal\n",
+		r.Total, r.Mean, r.Optimal*100)
+	top = min(top, len(r.ByState))
+	if top <= 0 {
+		return
+	}
+	fmt.Printf("States with the largest regret (%d of %d visited):\n", top, r.States)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  state\tvisits\ttotal\tmean\tmistakes\tbest in hindsight\tdescription")
+	for _, s := range r.ByState[:top] {
+		fmt.Fprintf(w, "  %d\t%d\t%.4f\t%.6f\t%d\t%s\t%s\n",
+			s.State, s.Visits, s.Total, s.Mean, s.Mistakes, s.Best, bundle.DescribeState(s.State))
+	}
+	w.Flush()
+}
+
+// saveRegret writes the date, price, action, reward, best action in hindsight, its
+// reward, and the regret of every step, with the cumulative regret.
+func saveRegret(filename string, result *eval.Result, cumulative []float64, window []data.Bar) error {
+	records := [][]string{{"Date", "Price", "Action", "Reward", "Best", "BestReward", "Regret", "CumulativeRegret"}}
+	for i, step := range result.Regret {
+		bar := window[result.StartIdx+i]
+		records = append(records, []string{
+			formatDate(bar.Time),
+			strconv.FormatFloat(bar.Close, 'f', 6, 64),
+			step.Action.String(),
+			strconv.FormatFloat(step.Reward(), 'g', 10, 64),
+			step.Best.String(),
+			strconv.FormatFloat(step.BestReward, 'g', 10, 64),
+			strconv.FormatFloat(step.Regret, 'g', 10, 64),
+			strconv.FormatFloat(cumulative[i], 'g', 10, 64),
+		})
+	}
+	return data.WriteTable(filename, records)
+}
+
+// printGains prints the capital-gains report.
+func printGains(g eval.GainsReport) {
+	fmt.Printf("\nCapital gains (%s, %d lot sales):\n", g.Method, g.Sales)
+	fmt.Printf("  %-16s %12.2f\n", "proceeds", g.Proceeds)
+	fmt.Printf("  %-16s %12.2f\n", "cost basis", g.Cost)
+	fmt.Printf("  %-16s %12.2f\n", "short-term gain", g.ShortTerm)
+	fmt.Printf("  %-16s %12.2f\n", "long-term gain", g.LongTerm)
+	fmt.Printf("  %-16s %12.2f\n", "net realized", g.Net)
+	if g.Tax > 0 {
+		fmt.Printf("  %-16s %12.2f\n", "tax", g.Tax)
+	}
+	fmt.Printf("  %-16s %12.2f (%d open lots)\n", "unrealized gain", g.Unrealized, g.OpenLots)
+}
+
+// saveGains writes the buy and sell dates, shares, proceeds, cost basis, and gain of
+// every lot (or part of a lot) sold.
+func saveGains(filename string, realized []env.Realization, window []data.Bar) error {
+	records := [][]string{{"Opened", "Closed", "Shares", "Proceeds", "Cost", "Gain", "Term"}}
+	for _, r := range realized {
+		term := "short"
+		if r.LongTerm {
+			term = "long"
+		}
+		records = append(records, []string{
+			formatDate(window[r.Opened].Time),
+			formatDate(window[r.Closed].Time),
+			strconv.FormatFloat(r.Shares, 'f', 6, 64),
+			strconv.FormatFloat(r.Proceeds, 'f', 2, 64),
+			strconv.FormatFloat(r.Cost, 'f', 2, 64),
+			strconv.FormatFloat(r.Gain, 'f', 2, 64),
+			term,
+		})
+	}
+	return data.WriteTable(filename, records)
+}
+
+// printCostSweep prints the policy's return at every level of trading costs against
+// buy and hold at the same costs.
+func printCostSweep(points []eval.CostPoint) {
+	fmt.Println("\nReturn vs trading costs:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  commission\tslippage\treturn\tbuy & hold\texcess\tsharpe\ttrades\tcosts paid")
+	for _, p := range points {
+		fmt.Fprintf(w, "  %.2f%%\t%.2f%%\t%.2f%%\t%.2f%%\t%.2f%%\t%.3f\t%d\t%.2f\n",
+			p.Commission*100, p.Slippage*100, p.Metrics.TotalReturn*100, p.BuyAndHold*100,
+			(p.Metrics.TotalReturn-p.BuyAndHold)*100, p.Metrics.Sharpe, p.Metrics.NumTrades, p.Costs)
+	}
+	w.Flush()
+}
+
+// saveCostSweep writes the cost sweep as a table, a row per level of trading costs.
+func saveCostSweep(filename string, points []eval.CostPoint) error {
+	records := [][]string{{"Commission", "Slippage", "Return", "BuyAndHold", "Excess", "MaxDrawdown", "Sharpe", "Trades", "CostsPaid"}}
+	for _, p := range points {
+		records = append(records, []string{
+			strconv.FormatFloat(p.Commission, 'g', -1, 64),
+			strconv.FormatFloat(p.Slippage, 'g', -1, 64),
+			strconv.FormatFloat(p.Metrics.TotalReturn, 'f', 6, 64),
+			strconv.FormatFloat(p.BuyAndHold, 'f', 6, 64),
+			strconv.FormatFloat(p.Metrics.TotalReturn-p.BuyAndHold, 'f', 6, 64),
+			strconv.FormatFloat(p.Metrics.MaxDrawdown, 'f', 6, 64),
+			strconv.FormatFloat(p.Metrics.Sharpe, 'f', 6, 64),
+			strconv.Itoa(p.Metrics.NumTrades),
+			strconv.FormatFloat(p.Costs, 'f', 2, 64),
+		})
+	}
+	return data.WriteTable(filename, records)
+}
+
+// plotCostSweep charts the return against the commission, a line per slippage level.
+func plotCostSweep(filename string, points []eval.CostPoint, commissions, slippages []float64) error {
+	if dir := filepath.Dir(filename); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	returns := make([][]float64, len(slippages))
+	for i := range slippages {
+		for _, p := range points[i*len(commissions) : (i+1)*len(commissions)] {
+			returns[i] = append(returns[i], p.Metrics.TotalReturn)
+		}
+	}
+	return plot.SaveCostSensitivity(commissions, slippages, returns, filename)
+}
+
+// parseLevels parses a comma-separated list of cost levels.
+func parseLevels(s string) ([]float64, error) {
+	var levels []float64
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cost level %q: %w", part, err)
+		}
+		levels = append(levels, v)
+	}
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("no cost levels in %q", s)
+	}
+	return levels, nil
+}
+
+// formatLevels formats cost levels as parseLevels accepts them.
+func formatLevels(levels []float64) string {
+	parts := make([]string, len(levels))
+	for i, v := range levels {
+		parts[i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+func writeJSON(filename string, v any) error {
+	if dir := filepath.Dir(filename); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	return os.WriteFile(filename, append(content, '\n'), 0644)
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/eval"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+	"github.com/kasaderos/rLportfolio/pkg/plot"
+)
+
+// policyRun holds the evaluation result of one Q-matrix.
+type policyRun struct {
+	name    string
+	values  []float64
+	metrics metrics.Metrics
+}
+
+func main() {
+	dataPath := flag.String("data", "data/test.csv", "test price CSV (first column is used)")
+	outPath := flag.String("out", "data/compare.png", "output path for the combined equity-curve chart")
+	initialCash := flag.Float64("cash", 10000.0, "initial cash")
+	commission := flag.Float64("commission", 0.002, "commission rate")
+	crossBaseline := flag.Bool("cross-baseline", false, "also compare with the golden/death cross (MA50/MA200) baseline strategy")
+	protocolPath := flag.String("protocol", "", "compare on the fixed evaluation episodes of a run instead of -data: its model bundle or a protocol JSON file")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run cmd/compare/main.go [flags] <model|q_matrix.csv|run-dir> <model|q_matrix.csv|run-dir> ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	minPolicies := 2
+	if *crossBaseline || *protocolPath != "" {
+		minPolicies = 1
+	}
+	if flag.NArg() < minPolicies {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *protocolPath != "" {
+		if err := compareOnProtocol(*protocolPath, flag.Args()); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	prices, err := loadTestPricesFromCSV(*dataPath)
+	if err != nil {
+		fmt.Printf("Error loading test prices: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Loaded %d test prices from %s\n\n", len(prices), *dataPath)
+
+	config := eval.DefaultConfig()
+	config.InitialCash = *initialCash
+	config.Commission = *commission
+
+	names := make([]string, 0, flag.NArg())
+	jobs := make([]eval.Job, 0, flag.NArg())
+	for _, arg := range flag.Args() {
+		path, name := resolveQMatrixPath(arg)
+		bundle, err := model.Load(path)
+		if err != nil {
+			fmt.Printf("Error loading Q-matrix %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		encoder, err := bundle.StateEncoder()
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		if need := encoder.WarmUp() + 2; len(prices) < need {
+			fmt.Printf("Error: %s needs at least %d prices, got %d\n", name, need, len(prices))
+			os.Exit(1)
+		}
+		names = append(names, name)
+		jobs = append(jobs, eval.Job{Q: bundle.Q, Prices: prices, Encoder: encoder})
+	}
+
+	// Evaluate all policies concurrently on the shared series
+	results, err := eval.NewEngine(config).Batch(jobs)
+	if err != nil {
+		fmt.Printf("Error evaluating policies: %v\n", err)
+		os.Exit(1)
+	}
+	runs := make([]policyRun, len(results))
+	for i, result := range results {
+		runs[i] = policyRun{
+			name:    names[i],
+			values:  result.Equity,
+			metrics: result.Metrics,
+		}
+	}
+	if *crossBaseline {
+		result, err := eval.EvaluateCrossBaseline(prices, config)
+		if err != nil {
+			fmt.Printf("Error evaluating the cross baseline: %v\n", err)
+			os.Exit(1)
+		}
+		names = append(names, "ma-cross")
+		runs = append(runs, policyRun{
+			name:    "ma-cross",
+			values:  result.Equity,
+			metrics: result.Metrics,
+		})
+	}
+
+	printMetricsTable(runs)
+
+	curves := make([][]float64, len(runs))
+	for i, r := range runs {
+		curves[i] = r.values
+	}
+	if err := plot.SaveEquityCurves(names, curves, *outPath); err != nil {
+		fmt.Printf("Failed to save equity curves: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\nSaved equity curves to %s\n", *outPath)
+}
+
+// compareOnProtocol evaluates every model on the fixed episodes of a protocol and
+// prints their outcomes side by side, in the protocol's market rather than the one
+// of -cash and -commission.
+func compareOnProtocol(protocolPath string, args []string) error {
+	protocol, err := model.LoadProtocol(protocolPath)
+	if err != nil {
+		return err
+	}
+	series, fx, err := protocol.Load()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Comparing on %d fixed episodes of %d steps from %s (seed %d)\n\n",
+		len(protocol.Episodes), protocol.Length, protocol.Data.File, protocol.Seed)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "policy\tmean return %\tmedian return %\tworst return %\tmean max DD %\tmean sharpe\tmean trades\t")
+	for _, arg := range args {
+		path, name := resolveQMatrixPath(arg)
+		bundle, err := model.Load(path)
+		if err != nil {
+			return fmt.Errorf("failed to load Q-matrix %s: %w", path, err)
+		}
+		encoder, err := bundle.StateEncoder()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		results, err := protocol.Evaluate(bundle.Q, encoder, series, fx, protocol.Config())
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		s := eval.Summarize(results)
+		fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.3f\t%.1f\t\n", name, s.MeanReturn*100, s.MedianReturn*100,
+			s.WorstReturn*100, s.MeanMaxDrawdown*100, s.MeanSharpe, s.MeanTrades)
+	}
+	return w.Flush()
+}
+
+// resolveQMatrixPath returns the Q-matrix file for an argument and a display name.
+// A model bundle directory is used as is; any other directory is treated as a run directory
+// containing q_matrix.csv (or q_matrix.csv.gz).
+func resolveQMatrixPath(arg string) (path, name string) {
+	if info, err := os.Stat(arg); err == nil && info.IsDir() {
+		if _, err := os.Stat(filepath.Join(arg, model.ManifestFile)); err == nil {
+			return arg, filepath.Base(filepath.Clean(arg))
+		}
+		path = filepath.Join(arg, "q_matrix.csv")
+		if _, err := os.Stat(path); err != nil {
+			if _, gzErr := os.Stat(path + ".gz"); gzErr == nil {
+				path += ".gz"
+			}
+		}
+		return path, filepath.Base(filepath.Clean(arg))
+	}
+	return arg, arg
+}
+
+// printMetricsTable prints the metrics of all runs side by side.
+func printMetricsTable(runs []policyRun) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "policy\tfinal value\treturn %\tmax DD %\tvolatility %\tsharpe\ttrades\t")
+	for _, r := range runs {
+		m := r.metrics
+		fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.3f\t%d\t\n",
+			r.name, m.FinalValue, m.TotalReturn*100, m.MaxDrawdown*100, m.Volatility*100, m.Sharpe, m.NumTrades)
+	}
+	w.Flush()
+}
+
+// loadTestPricesFromCSV loads the close prices of the first series in a CSV file.
+func loadTestPricesFromCSV(filename string) ([]float64, error) {
+	series, _, err := data.Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	return series[0].Closes(), nil
+}
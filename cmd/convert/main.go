@@ -1,127 +1,137 @@
 package main
 
 import (
-	"encoding/csv"
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
-	"strings"
-	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/data"
 )
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: go run cmd/convert/main.go <input.csv> <output.csv>")
+	from := flag.String("from", "auto", "input layout: auto, yahoo, nasdaq, stooq, investing, wide, or binance")
+	to := flag.String("to", "", "output format: csv, json, or parquet (default: from the output extension)")
+	align := flag.String("align", "union", "date alignment of merged inputs: union (empty cells for missing dates) or intersect")
+	missing := flag.String("missing", "drop", "policy for missing or invalid prices: drop, ffill, or error")
+	adjClose := flag.Bool("adj-close", false, "rescale prices to the input's adjusted-close column")
+	resample := flag.String("resample", "", "aggregate bars to a coarser frequency, e.g. 1h, 4h, 1d, 1w, 1mo")
+	actionsPath := flag.String("actions", "", "corporate actions file (Symbol,Date,Type,Value) to back-adjust splits and dividends")
+	flag.Usage = func() {
+		fmt.Println("Usage: go run cmd/convert/main.go [flags] <input.csv>... <output.csv>")
 		fmt.Println("Example: go run cmd/convert/main.go data/tsla.csv data/test.csv")
-		os.Exit(1)
+		fmt.Println("         go run cmd/convert/main.go -align intersect aapl.csv msft.csv btc.json data/train.csv")
+		fmt.Println("Inputs may be Yahoo, Nasdaq, Stooq, investing.com, Binance, or wide exports; they are merged")
+		fmt.Println("into one column per symbol. .json, .parquet, and .gz files are read and written by extension.")
+		flag.PrintDefaults()
 	}
+	flag.Parse()
 
-	inputFile := os.Args[1]
-	outputFile := os.Args[2]
-
-	// Read input CSV
-	file, err := os.Open(inputFile)
-	if err != nil {
-		fmt.Printf("Error opening input file: %v\n", err)
+	if flag.NArg() < 2 {
+		flag.Usage()
 		os.Exit(1)
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		fmt.Printf("Error reading CSV: %v\n", err)
-		os.Exit(1)
-	}
+	inputFiles := flag.Args()[:flag.NArg()-1]
+	outputFile := flag.Arg(flag.NArg() - 1)
 
-	if len(records) < 2 {
-		fmt.Println("Error: CSV must have at least a header and one data row")
+	inputFormat, err := data.ParseFormat(*from)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Find columns
-	header := records[0]
-	dateColIdx := -1
-	priceColIdx := -1
-
-	for i, col := range header {
-		col = strings.ToLower(strings.Trim(col, `"`))
-		if col == "date" {
-			dateColIdx = i
-		} else if col == "close/last" {
-			priceColIdx = i
-		}
-	}
-
-	if dateColIdx < 0 {
-		fmt.Println("Error: Could not find Date column")
-		os.Exit(1)
+	outputFormat := data.TableFormat(outputFile)
+	if *to != "" {
+		outputFormat = *to
 	}
-	if priceColIdx < 0 {
-		fmt.Println("Error: Could not find Close/Last column")
+	if *align != "union" && *align != "intersect" {
+		fmt.Printf("Error: unknown -align %q (use union or intersect)\n", *align)
 		os.Exit(1)
 	}
-
-	// Create output file
-	outFile, err := os.Create(outputFile)
+	policy, err := data.ParseMissingPolicy(*missing)
 	if err != nil {
-		fmt.Printf("Error creating output file: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-	defer outFile.Close()
 
-	writer := csv.NewWriter(outFile)
-	defer writer.Flush()
-
-	// Write header matching train.csv format: "MSFT","IBM","SBUX","AAPL","GSPC","Date"
-	headerRow := []string{`TSLA`, `Date`}
-	if err := writer.Write(headerRow); err != nil {
-		fmt.Printf("Error writing header: %v\n", err)
-		os.Exit(1)
+	// Load and merge all inputs; every symbol becomes one column
+	var series []data.Series
+	sources := make(map[string]string)
+	for _, inputFile := range inputFiles {
+		loaded, reports, format, err := data.LoadWithOptions(inputFile, data.Options{Format: inputFormat, Missing: policy})
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", inputFile, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Read %s (%s format)\n", inputFile, format)
+		for _, r := range reports {
+			fmt.Printf("  Validation: %s\n", r)
+		}
+		for _, s := range loaded {
+			if prev, ok := sources[s.Symbol]; ok {
+				fmt.Printf("Error: symbol %s appears in both %s and %s\n", s.Symbol, prev, inputFile)
+				os.Exit(1)
+			}
+			sources[s.Symbol] = inputFile
+		}
+		series = append(series, loaded...)
 	}
 
-	// Process data rows (skip header, process in reverse to match chronological order)
-	for i := len(records) - 1; i >= 1; i-- {
-		row := records[i]
-		if len(row) <= dateColIdx || len(row) <= priceColIdx {
-			continue
+	if *adjClose {
+		for i := range series {
+			if err := data.AdjustFromAdjClose(&series[i]); err != nil {
+				fmt.Printf("Error adjusting %s: %v\n", series[i].Symbol, err)
+				os.Exit(1)
+			}
 		}
+		fmt.Println("Adjusted prices to the adjusted close")
+	}
 
-		// Parse date (MM/DD/YYYY format)
-		dateStr := strings.Trim(row[dateColIdx], `"`)
-		date, err := time.Parse("01/02/2006", dateStr)
+	if *actionsPath != "" {
+		actions, err := data.LoadCorporateActions(*actionsPath)
 		if err != nil {
-			// Try alternative format
-			date, err = time.Parse("1/2/2006", dateStr)
+			fmt.Printf("Error loading corporate actions: %v\n", err)
+			os.Exit(1)
+		}
+		for i := range series {
+			applied, err := data.AdjustForActions(&series[i], actions)
 			if err != nil {
-				fmt.Printf("Warning: Could not parse date at row %d: %s\n", i+1, dateStr)
-				continue
+				fmt.Printf("Error adjusting %s: %v\n", series[i].Symbol, err)
+				os.Exit(1)
 			}
+			fmt.Printf("Applied %d corporate actions to %s\n", applied, series[i].Symbol)
 		}
-		formattedDate := date.Format("2006-01-02")
+	}
 
-		// Parse price (remove $ and commas)
-		priceStr := strings.Trim(row[priceColIdx], `"`)
-		priceStr = strings.ReplaceAll(priceStr, "$", "")
-		priceStr = strings.ReplaceAll(priceStr, ",", "")
-		price, err := strconv.ParseFloat(priceStr, 64)
+	if *resample != "" {
+		freq, err := data.ParseFrequency(*resample)
 		if err != nil {
-			fmt.Printf("Warning: Could not parse price at row %d: %s\n", i+1, priceStr)
-			continue
-		}
-
-		// Write row: TSLA price repeated 5 times (to match train.csv structure), then date
-		// Format matches train.csv: unquoted numbers for prices, quoted date
-		outputRow := []string{
-			fmt.Sprintf("%.6f", price),
-			fmt.Sprintf("%s", formattedDate),
-		}
-		if err := writer.Write(outputRow); err != nil {
-			fmt.Printf("Error writing row: %v\n", err)
+			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
+		for i := range series {
+			before := series[i].Len()
+			series[i], err = data.Resample(series[i], freq)
+			if err != nil {
+				fmt.Printf("Error resampling %s: %v\n", series[i].Symbol, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Resampled %s from %d to %d bars (%s)\n", series[i].Symbol, before, series[i].Len(), *resample)
+		}
+	}
+
+	if *align == "intersect" && len(series) > 1 {
+		series = data.Intersect(series)
+		fmt.Printf("Kept %d dates common to all symbols\n", series[0].Len())
 	}
 
-	fmt.Printf("Successfully converted %s to %s\n", inputFile, outputFile)
-	fmt.Printf("Converted %d data rows\n", len(records)-1)
+	// Write the train.csv layout: one close-price column per symbol, then Date
+	if err := data.WriteWideAs(outputFile, outputFormat, series); err != nil {
+		fmt.Printf("Error writing %s: %v\n", outputFile, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Successfully converted %d input(s) to %s (%s)\n", len(inputFiles), outputFile, outputFormat)
+	for _, s := range series {
+		fmt.Printf("Converted %d %s rows\n", s.Len(), s.Symbol)
+	}
 }
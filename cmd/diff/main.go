@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+)
+
+// main compares two models of the same encoder, e.g. checkpoints before and after
+// more training: how many states changed their greedy action, the largest Q-value
+// changes, and which market regimes changed most.
+func main() {
+	topK := flag.Int("top", 10, "number of states to list by Q-value change")
+	topRegimes := flag.Int("regimes", 10, "number of market regimes to list by changed states")
+	csvOut := flag.String("csv", "", "output for the change of every trained state (.csv, .json, or .parquet; optional)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run cmd/diff/main.go [flags] <old model|q_matrix.csv> <new model|q_matrix.csv>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *topK < 0 || *topRegimes < 0 {
+		fmt.Println("Error: -top and -regimes must not be negative")
+		os.Exit(1)
+	}
+
+	var bundles [2]*model.Bundle
+	for i, path := range flag.Args() {
+		bundle, err := model.Load(path)
+		if err != nil {
+			fmt.Printf("Error loading model %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		bundles[i] = bundle
+	}
+	old, updated := bundles[0], bundles[1]
+	diff, err := model.Diff(old, updated)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Old: %s\nNew: %s\n\n", flag.Arg(0), flag.Arg(1))
+	fmt.Printf("Trained states: %d (%d newly trained)\n", diff.States, diff.NewlyTrained)
+	fmt.Printf("Greedy action changed: %d states (%.2f%%)\n", diff.Changed, percent(diff.Changed, diff.States))
+
+	n := min(*topK, len(diff.Changes))
+	fmt.Printf("\nTop %d states by Q-value change:\n", n)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  state\taction\told Q\tnew Q\tchange\tgreedy\tdescription")
+	for _, c := range diff.Changes[:n] {
+		fmt.Fprintf(w, "  %d\t%s\t%.6f\t%.6f\t%+.6f\t%s\t%s\n", c.Index, c.Action, c.OldValue, c.NewValue, c.Delta(),
+			greedyChange(c), updated.DescribeState(c.Index))
+	}
+	w.Flush()
+
+	if len(diff.Regimes) > 0 {
+		n := min(*topRegimes, len(diff.Regimes))
+		fmt.Printf("\nTop %d of %d market regimes by changed states:\n", n, len(diff.Regimes))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  states\tchanged\tchanged %\tmax |change|\tregime")
+		for _, r := range diff.Regimes[:n] {
+			fmt.Fprintf(w, "  %d\t%d\t%.2f\t%.6f\t%s\n", r.States, r.Changed, percent(r.Changed, r.States), r.MaxDelta, r.Regime)
+		}
+		w.Flush()
+	}
+
+	if *csvOut != "" {
+		records := [][]string{{"state", "old_action", "new_action", "action", "old_q", "new_q", "change"}}
+		for _, c := range diff.Changes {
+			records = append(records, []string{strconv.Itoa(c.Index), c.OldAction.String(), c.NewAction.String(), c.Action.String(),
+				strconv.FormatFloat(c.OldValue, 'f', 6, 64), strconv.FormatFloat(c.NewValue, 'f', 6, 64),
+				strconv.FormatFloat(c.Delta(), 'f', 6, 64)})
+		}
+		if err := data.WriteTable(*csvOut, records); err != nil {
+			fmt.Printf("Failed to write the changes: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nSaved the changes of %d states to %s\n", len(diff.Changes), *csvOut)
+	}
+}
+
+// greedyChange describes the greedy action of a state in both models.
+func greedyChange(c model.StateChange) string {
+	if c.OldAction == c.NewAction {
+		return c.OldAction.String()
+	}
+	return c.OldAction.String() + " -> " + c.NewAction.String()
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/data"
+)
+
+func main() {
+	symbols := flag.String("symbol", "BTCUSDT", "comma-separated Binance symbols")
+	interval := flag.String("interval", "1h", "kline interval (1m, 5m, 15m, 1h, 4h, 1d, ...)")
+	start := flag.String("start", "2020-01-01", "first kline open time (YYYY-MM-DD)")
+	end := flag.String("end", "", "end of the range, exclusive (YYYY-MM-DD, default now)")
+	out := flag.String("out", "", "output CSV in the train.csv layout (default data/<symbol>_<interval>.csv)")
+	baseURL := flag.String("base-url", data.BinanceBaseURL, "Binance REST API base URL")
+	flag.Parse()
+
+	if _, ok := data.BinanceIntervals[*interval]; !ok {
+		fmt.Printf("Error: unsupported interval %q\n", *interval)
+		os.Exit(1)
+	}
+
+	startTime, err := data.ParseDate(*start)
+	if err != nil {
+		fmt.Printf("Error parsing -start: %v\n", err)
+		os.Exit(1)
+	}
+	endTime := time.Now().UTC()
+	if *end != "" {
+		endTime, err = data.ParseDate(*end)
+		if err != nil {
+			fmt.Printf("Error parsing -end: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	names := strings.Split(*symbols, ",")
+	if *out == "" {
+		*out = fmt.Sprintf("data/%s_%s.csv", strings.ToLower(strings.Join(names, "_")), *interval)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := data.NewBinanceClient()
+	client.BaseURL = *baseURL
+
+	series := make([]data.Series, 0, len(names))
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		fmt.Printf("Downloading %s %s klines from %s to %s...\n",
+			name, *interval, startTime.Format(time.DateTime), endTime.Format(time.DateTime))
+		s, err := client.FetchKlines(ctx, name, *interval, startTime, endTime)
+		if err != nil {
+			fmt.Printf("Error downloading %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		fmt.Printf("  %s: %d klines\n", name, s.Len())
+		series = append(series, s)
+	}
+
+	if err := data.WriteWide(*out, series); err != nil {
+		fmt.Printf("Error writing %s: %v\n", *out, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Saved klines to %s\n", *out)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/forecast"
+)
+
+func main() {
+	dataPath := flag.String("data", "data/test.csv", "price file to forecast")
+	symbol := flag.String("symbol", "", "series to forecast, by symbol name (overrides -column)")
+	column := flag.Int("column", 0, "series to forecast, by position (Date column excluded)")
+	m := flag.Int("m", forecast.DefaultM, "local approximation window, in returns")
+	n := flag.Int("n", forecast.DefaultN, "local approximation neighbors")
+	weightsFlag := flag.String("weights", forecast.DefaultWeights().String(), "ensemble weights of the local approximation, MA trend, and last return")
+	trendPeriod := flag.Int("trend-period", forecast.DefaultTrendPeriod, "moving average whose slope the trend member extrapolates")
+	trendLag := flag.Int("trend-lag", forecast.DefaultTrendLag, "prices over which the trend member measures the slope")
+	out := flag.String("out", "data/forecast.csv", "output for the forecasts (.csv, .json, or .parquet)")
+	reportOut := flag.String("report", "data/forecast_report.json", "output for the JSON accuracy report (empty to skip)")
+	flag.Parse()
+
+	weights, err := forecast.ParseWeights(*weightsFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *m < 1 || *n < 1 || *trendPeriod < 1 || *trendLag < 1 {
+		fmt.Println("Error: -m, -n, -trend-period, and -trend-lag must be positive")
+		os.Exit(1)
+	}
+
+	series, _, err := data.Load(*dataPath)
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", *dataPath, err)
+		os.Exit(1)
+	}
+	selected, err := data.Select(series, *symbol, *column)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ensemble := forecast.Ensemble{Weights: weights, M: *m, N: *n, TrendPeriod: *trendPeriod, TrendLag: *trendLag}
+	prices := selected.Closes()
+	forecasts := ensemble.Series(prices)
+
+	// Each row forecasts the return to the next price, which Actual holds once known
+	records := [][]string{{"Date", "Price", "LAM", "MinDist", "Trend", "Naive", "Ensemble", "Actual"}}
+	for i, f := range forecasts {
+		actual := math.NaN()
+		if i+1 < len(prices) {
+			actual = prices[i+1]/prices[i] - 1
+		}
+		records = append(records, []string{
+			formatDate(selected.Bars[i].Time),
+			strconv.FormatFloat(prices[i], 'f', 6, 64),
+			formatValue(f.LAM),
+			formatValue(f.MinDist),
+			formatValue(f.Trend),
+			formatValue(f.Naive),
+			formatValue(f.Ensemble),
+			formatValue(actual),
+		})
+	}
+	if err := data.WriteTable(*out, records); err != nil {
+		fmt.Printf("Failed to write forecasts: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Saved %d forecasts of %s (%s) to %s\n", len(forecasts), selected.Symbol, weights, *out)
+
+	report := Report{
+		Data:        *dataPath,
+		Symbol:      selected.Symbol,
+		M:           *m,
+		N:           *n,
+		Weights:     weights.String(),
+		TrendPeriod: *trendPeriod,
+		TrendLag:    *trendLag,
+		Evaluation:  forecast.Evaluate(forecasts, prices),
+	}
+	printReport(report)
+	if *reportOut != "" {
+		if err := writeJSON(*reportOut, report); err != nil {
+			fmt.Printf("Failed to write report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nSaved report to %s\n", *reportOut)
+	}
+}
+
+// Report is the out-of-sample accuracy of the forecasts, written as JSON.
+type Report struct {
+	Data        string `json:"data"`
+	Symbol      string `json:"symbol"`
+	M           int    `json:"m"`
+	N           int    `json:"n"`
+	Weights     string `json:"weights"`
+	TrendPeriod int    `json:"trend_period"`
+	TrendLag    int    `json:"trend_lag"`
+	forecast.Evaluation
+}
+
+func printReport(r Report) {
+	fmt.Printf("\n=== Forecast accuracy %s (%d steps) ===\n", r.Symbol, r.Steps)
+	if r.Steps == 0 {
+		fmt.Println("No step has every forecast; the series is too short.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "member\tdirection %\tMAE\tRMSE")
+	for _, a := range r.Members {
+		fmt.Fprintf(w, "%s\t%.2f\t%.6f\t%.6f\n", a.Name, a.Directional*100, a.MAE, a.RMSE)
+	}
+	w.Flush()
+	fmt.Printf("\nBaselines: always up %.2f%% direction, zero forecast MAE %.6f\n", r.UpShare*100, r.ZeroMAE)
+}
+
+func writeJSON(filename string, v any) error {
+	if dir := filepath.Dir(filename); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	return os.WriteFile(filename, append(content, '\n'), 0644)
+}
+
+// formatValue writes a forecast, or an empty cell when it is not available.
+func formatValue(v float64) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', 8, 64)
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
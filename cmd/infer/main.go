@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// output is the JSON form of a decision.
+type output struct {
+	Symbol     string             `json:"symbol,omitempty"`
+	Date       string             `json:"date,omitempty"`
+	Price      float64            `json:"price"`
+	Action     string             `json:"action"`
+	State      int                `json:"state"`
+	MAOrdering []string           `json:"ma_ordering,omitempty"`
+	Market     string             `json:"market,omitempty"` // Market component of encoders other than ma
+	Divergence string             `json:"divergence"`
+	ExpRet     string             `json:"expected_return,omitempty"` // Local approximation forecast, when the model uses one
+	MinDist    string             `json:"nearest_analogue,omitempty"`
+	Regime     string             `json:"regime,omitempty"` // Regime whose Q-table decided, when the model has one per regime
+	Cash       string             `json:"cash_position"`
+	Shares     string             `json:"shares_position"`
+	QValues    map[string]float64 `json:"q_values"`
+	Trained    bool               `json:"trained"`
+}
+
+func main() {
+	modelPath := flag.String("model", "data/model", "model bundle (directory or .json file), or a bare Q-matrix file; several comma-separated models vote as an ensemble")
+	voteFlag := flag.String("vote", "majority", "how an ensemble of models combines their actions: majority (most chosen greedy action) or mean (greedy action of the averaged Q-values)")
+	dataPath := flag.String("data", "-", "recent prices: a price file in any supported format, or - for stdin")
+	symbol := flag.String("symbol", "", "series to use from a multi-symbol file, by symbol name (overrides -column)")
+	column := flag.Int("column", 0, "series to use from a multi-symbol file, by position (Date column excluded)")
+	last := flag.Int("last", 0, fmt.Sprintf("use only the latest N prices (0 uses all; at least %d are needed)", model.MinPrices))
+	cash := flag.Float64("cash", 10000.0, "cash currently held")
+	shares := flag.Float64("shares", 0, "shares currently held")
+	asJSON := flag.Bool("json", false, "print the decision as JSON")
+	mmap := flag.Bool("mmap", false, "memory-map a binary directory bundle's Q-table read-only instead of reading it (the file must not be rewritten while in use)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run cmd/infer/main.go [flags]")
+		fmt.Fprintln(os.Stderr, "Example: tail -n 200 data/test.csv | cut -d, -f1 | go run cmd/infer/main.go -cash 5000 -shares 20")
+		fmt.Fprintln(os.Stderr, "         go run cmd/infer/main.go -data data/train.csv -symbol AAPL -last 250")
+		fmt.Fprintln(os.Stderr, "         go run cmd/infer/main.go -data data/test.csv -model runs/a,runs/b,runs/c -vote mean")
+		fmt.Fprintln(os.Stderr, "Stdin may hold one price per line (oldest first) or a CSV file with a header.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	load := model.Load
+	if *mmap {
+		load = model.LoadMapped
+	}
+	vote, err := model.ParseVote(*voteFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	modelPaths := strings.Split(*modelPath, ",")
+	bundles := make([]*model.Bundle, len(modelPaths))
+	for i, path := range modelPaths {
+		bundles[i], err = load(strings.TrimSpace(path))
+		if err != nil {
+			fmt.Printf("Error loading model: %v\n", err)
+			os.Exit(1)
+		}
+		defer bundles[i].Close()
+	}
+	bundle := bundles[0]
+
+	series, err := loadSeries(*dataPath, *symbol, *column)
+	if err != nil {
+		fmt.Printf("Error loading prices: %v\n", err)
+		os.Exit(1)
+	}
+	if *last > 0 && *last < series.Len() {
+		series.Bars = series.Bars[series.Len()-*last:]
+	}
+
+	prices := series.Closes()
+	if len(bundles) > 1 {
+		if err := inferEnsemble(bundles, modelPaths, vote, series, *cash, *shares, *asJSON); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	decision, err := bundle.Decide(prices, *cash, *shares)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lastBar := series.Bars[series.Len()-1]
+	out := output{
+		Symbol:     series.Symbol,
+		Price:      lastBar.Close,
+		Action:     decision.Action.String(),
+		State:      decision.State.Index,
+		Divergence: state.DivergenceName(decision.State.MADivergence),
+		Cash:       state.PositionName(decision.State.CashCat),
+		Shares:     state.PositionName(decision.State.SharesCat),
+		QValues:    make(map[string]float64, len(decision.QValues)),
+		Trained:    decision.Trained,
+	}
+	if bundle.Encoder.Name == "ma" {
+		out.MAOrdering = ma.OrderingNames(ma.DecodeMAState(decision.State.MAState))
+	} else {
+		out.Market = model.DescribeMarket(bundle.Encoder.Name, decision.State.MAState, nil)
+	}
+	if _, ok := bundle.Encoder.Params["approx_m"]; ok {
+		out.ExpRet = state.ExpRetName(decision.State.ExpRetCat)
+		out.MinDist = state.MinDistName(decision.State.MinDistCat)
+	}
+	if spec, ok := bundle.Encoder.Params["regime_detector"]; ok {
+		if detector, err := state.ParseDetector(spec); err == nil {
+			out.Regime = state.RegimeName(detector, decision.State.Regime)
+		}
+	}
+	if !lastBar.Time.IsZero() {
+		out.Date = lastBar.Time.Format("2006-01-02")
+	}
+	for a, v := range decision.QValues {
+		out.QValues[agent.Action(a).String()] = v
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Printf("Latest price: %.4f", out.Price)
+	if out.Date != "" {
+		fmt.Printf(" (%s)", out.Date)
+	}
+	fmt.Printf(", %d prices used\n\n", len(prices))
+	fmt.Printf("State %d\n", out.State)
+	if out.Market != "" {
+		fmt.Printf("  Market: %s\n", out.Market)
+	} else {
+		fmt.Printf("  MA ordering (high to low): %s\n", strings.Join(out.MAOrdering, " > "))
+	}
+	fmt.Printf("  MA divergence: %s\n", out.Divergence)
+	if out.ExpRet != "" {
+		fmt.Printf("  Forecast return: %s, nearest analogue: %s\n", out.ExpRet, out.MinDist)
+	}
+	if out.Regime != "" {
+		fmt.Printf("  Regime: %s\n", out.Regime)
+	}
+	fmt.Printf("  Cash position: %s, shares position: %s\n\n", out.Cash, out.Shares)
+	fmt.Println("Q-values:")
+	for a, v := range decision.QValues {
+		marker := ""
+		if agent.Action(a) == decision.Action {
+			marker = "  <- best"
+		}
+		fmt.Printf("  %-10s %12.6f%s\n", agent.Action(a), v, marker)
+	}
+	if !decision.Trained {
+		fmt.Println("\nWarning: this state was never visited in training; the action is a default.")
+	}
+	fmt.Printf("\nRecommended action: %s\n", out.Action)
+}
+
+// ensembleOutput is the JSON form of an ensemble decision.
+type ensembleOutput struct {
+	Symbol  string             `json:"symbol,omitempty"`
+	Date    string             `json:"date,omitempty"`
+	Price   float64            `json:"price"`
+	Action  string             `json:"action"`
+	Vote    string             `json:"vote"`
+	Votes   map[string]int     `json:"votes"`
+	QValues map[string]float64 `json:"mean_q_values"`
+	Members []memberOutput     `json:"members"`
+}
+
+// memberOutput is the decision of one model of an ensemble.
+type memberOutput struct {
+	Model   string `json:"model"`
+	Action  string `json:"action"`
+	State   int    `json:"state"`
+	Trained bool   `json:"trained"`
+}
+
+// inferEnsemble prints the combined decision of several models at the latest price.
+func inferEnsemble(bundles []*model.Bundle, paths []string, vote model.Vote, series *data.Series, cash, shares float64, asJSON bool) error {
+	ensemble, err := model.NewEnsemble(bundles, vote)
+	if err != nil {
+		return err
+	}
+	decision, err := ensemble.Decide(series.Closes(), cash, shares)
+	if err != nil {
+		return err
+	}
+
+	lastBar := series.Bars[series.Len()-1]
+	out := ensembleOutput{
+		Symbol:  series.Symbol,
+		Price:   lastBar.Close,
+		Action:  decision.Action.String(),
+		Vote:    vote.String(),
+		Votes:   make(map[string]int, agent.NumActions),
+		QValues: make(map[string]float64, agent.NumActions),
+	}
+	if !lastBar.Time.IsZero() {
+		out.Date = lastBar.Time.Format("2006-01-02")
+	}
+	for a := range decision.Votes {
+		out.Votes[agent.Action(a).String()] = decision.Votes[a]
+		out.QValues[agent.Action(a).String()] = decision.QValues[a]
+	}
+	for i, m := range decision.Members {
+		out.Members = append(out.Members, memberOutput{
+			Model:   strings.TrimSpace(paths[i]),
+			Action:  m.Action.String(),
+			State:   m.State.Index,
+			Trained: m.Trained,
+		})
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	fmt.Printf("Latest price: %.4f", out.Price)
+	if out.Date != "" {
+		fmt.Printf(" (%s)", out.Date)
+	}
+	fmt.Printf(", %d prices used\n\n", series.Len())
+	fmt.Printf("Models (%d):\n", len(out.Members))
+	for _, m := range out.Members {
+		note := ""
+		if !m.Trained {
+			note = "  (untrained state)"
+		}
+		fmt.Printf("  %-30s %-10s state %d%s\n", m.Model, m.Action, m.State, note)
+	}
+	fmt.Printf("\nVotes and mean Q-values (%s vote):\n", out.Vote)
+	for a := range decision.Votes {
+		marker := ""
+		if agent.Action(a) == decision.Action {
+			marker = "  <- chosen"
+		}
+		fmt.Printf("  %-10s %3d %12.6f%s\n", agent.Action(a), decision.Votes[a], decision.QValues[a], marker)
+	}
+	fmt.Printf("\nRecommended action: %s\n", out.Action)
+	return nil
+}
+
+// loadSeries reads the price series from a file or, for "-", from stdin.
+func loadSeries(path, symbol string, column int) (*data.Series, error) {
+	if path != "-" {
+		series, _, err := data.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		return data.Select(series, symbol, column)
+	}
+
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	if prices, ok := parsePriceList(content); ok {
+		s := &data.Series{Symbol: "STDIN", Bars: make([]data.Bar, len(prices))}
+		for i, p := range prices {
+			s.Bars[i] = data.Bar{Open: p, High: p, Low: p, Close: p}
+		}
+		return s, nil
+	}
+	series, _, err := data.ReadCSV(bytes.NewReader(content), "STDIN")
+	if err != nil {
+		return nil, err
+	}
+	return data.Select(series, symbol, column)
+}
+
+// parsePriceList parses one price per line (blank lines ignored). It reports false
+// when any line is not a number, e.g. for CSV input with a header.
+func parsePriceList(content []byte) ([]float64, bool) {
+	var prices []float64
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		p, err := data.ParsePrice(line)
+		if err != nil {
+			return nil, false
+		}
+		prices = append(prices, p)
+	}
+	return prices, len(prices) > 0
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/broker"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/live"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+func main() {
+	modelPath := flag.String("model", "data/model", "model bundle (directory or .json file), or a bare Q-matrix file")
+	symbol := flag.String("symbol", "BTCUSDT", "Binance symbol to trade")
+	interval := flag.String("interval", "1h", "kline interval (1m, 5m, 15m, 1h, 4h, 1d, ...)")
+	baseURL := flag.String("base-url", data.BinanceBaseURL, "Binance REST API base URL")
+	poll := flag.Duration("poll", 15*time.Second, "how often to poll for a newly closed bar")
+	replay := flag.String("replay", "", "replay bars from a price file instead of polling Binance (dry run)")
+	column := flag.Int("column", 0, "series to replay from a multi-symbol file, by position (Date column excluded)")
+	replayDelay := flag.Duration("replay-delay", 0, "pause before each replayed bar")
+	journalPath := flag.String("journal", "data/live_journal.jsonl", "JSON Lines journal of simulated fills")
+	resume := flag.Bool("resume", true, "resume cash and shares from the last journal entry")
+	cash := flag.Float64("cash", 10000.0, "starting cash")
+	shares := flag.Float64("shares", 0, "starting shares")
+	commission := flag.Float64("commission", 0.001, "commission rate on simulated fills")
+	brokerName := flag.String("broker", "", "route orders to a paper-trading broker: alpaca (default: simulate only)")
+	brokerURL := flag.String("broker-url", broker.AlpacaPaperURL, "broker REST API base URL")
+	brokerSymbol := flag.String("broker-symbol", "", "symbol at the broker, e.g. AAPL or BTCUSD (default: -symbol)")
+	brokerTIF := flag.String("broker-tif", "day", "order time in force at the broker (day for stocks, gtc for crypto)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run cmd/live/main.go [flags]")
+		fmt.Fprintln(os.Stderr, "Paper-trades the model's greedy policy on live bars. No orders are sent unless -broker is set;")
+		fmt.Fprintln(os.Stderr, "-broker alpaca reads APCA_API_KEY_ID and APCA_API_SECRET_KEY from the environment.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	bundle, err := model.Load(*modelPath)
+	if err != nil {
+		fmt.Printf("Error loading model: %v\n", err)
+		os.Exit(1)
+	}
+	if err := bundle.CheckCompatible(state.NewMAEncoder()); err != nil {
+		fmt.Printf("Error: incompatible model: %v\n", err)
+		os.Exit(1)
+	}
+
+	var feed live.Feed
+	if *replay != "" {
+		series, _, err := data.Load(*replay)
+		if err != nil {
+			fmt.Printf("Error loading %s: %v\n", *replay, err)
+			os.Exit(1)
+		}
+		s, err := data.Select(series, *symbol, *column)
+		if err != nil {
+			// -symbol defaults to a Binance pair; fall back to -column for replay files
+			s, err = data.Select(series, "", *column)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		*symbol = s.Symbol
+		feed = live.NewReplayFeed(s.Bars, *replayDelay)
+	} else {
+		client := data.NewBinanceClient()
+		client.BaseURL = *baseURL
+		feed, err = live.NewBinanceFeed(client, *symbol, *interval, *poll)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var route *router
+	switch *brokerName {
+	case "":
+	case "alpaca":
+		keyID, secret := os.Getenv("APCA_API_KEY_ID"), os.Getenv("APCA_API_SECRET_KEY")
+		if keyID == "" || secret == "" {
+			fmt.Println("Error: -broker alpaca needs APCA_API_KEY_ID and APCA_API_SECRET_KEY")
+			os.Exit(1)
+		}
+		alpaca := broker.NewAlpacaBroker(keyID, secret)
+		alpaca.BaseURL = *brokerURL
+		alpaca.TimeInForce = *brokerTIF
+		route = &router{broker: alpaca, symbol: *brokerSymbol, since: time.Now().UTC()}
+		if route.symbol == "" {
+			route.symbol = *symbol
+		}
+	default:
+		fmt.Printf("Error: unknown broker %q\n", *brokerName)
+		os.Exit(1)
+	}
+
+	if *resume && route == nil {
+		last, ok, err := live.LastFill(*journalPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if ok {
+			*cash, *shares = last.Cash, last.Shares
+			fmt.Printf("Resuming from %s: cash %.2f, shares %.6f\n", *journalPath, *cash, *shares)
+		}
+	}
+
+	journal, err := live.OpenJournal(*journalPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer journal.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	trader := live.NewTrader(bundle, *symbol, *cash, *shares, *commission)
+	history, err := feed.History(ctx, live.WindowBars-1)
+	if err != nil {
+		fmt.Printf("Error loading history: %v\n", err)
+		os.Exit(1)
+	}
+	trader.WarmUp(history)
+	fmt.Printf("Warmed up with %d bars of %s; trading from the next bar\n", len(history), *symbol)
+
+	if route != nil {
+		if err := route.sync(ctx, trader); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Routing orders for %s to %s: cash %.2f, shares %.6f\n", route.symbol, *brokerURL, trader.Cash(), trader.Shares())
+	}
+
+	if err := run(ctx, feed, trader, journal, route); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		journal.Close()
+		os.Exit(1)
+	}
+	fmt.Printf("Stopped: cash %.2f, shares %.6f, value %.2f\n", trader.Cash(), trader.Shares(), trader.Value())
+}
+
+// run trades each new bar until the feed ends or ctx is cancelled.
+// With a router, holdings are synced from the broker before each decision and trades
+// are submitted as market orders.
+func run(ctx context.Context, feed live.Feed, trader *live.Trader, journal *live.Journal, route *router) error {
+	for {
+		bar, err := feed.Next(ctx)
+		if errors.Is(err, io.EOF) || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			// Transient feed errors are retried on the next poll
+			fmt.Printf("Warning: %v\n", err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		synced := false
+		if route != nil {
+			route.reportFills(ctx)
+			if err := route.sync(ctx, trader); err != nil {
+				fmt.Printf("Warning: not routing this bar: %v\n", err)
+			} else {
+				synced = true
+			}
+		}
+
+		fill, ok, err := trader.OnBar(bar)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if synced && fill.Traded() {
+			order := fill.Order(route.symbol)
+			order.ClientOrderID = fmt.Sprintf("rlp-%d", fill.Time.Unix())
+			id, err := route.broker.SubmitOrder(ctx, order)
+			if err != nil {
+				fmt.Printf("Warning: order not submitted: %v\n", err)
+			} else {
+				fill.OrderID = id
+			}
+		}
+		if err := journal.Record(fill); err != nil {
+			return err
+		}
+		fmt.Printf("%s  %-10s price %.4f  qty %+.6f  value %.2f\n",
+			fill.Time.Format(time.DateTime), fill.Action, fill.Price, fill.Quantity, fill.Value)
+	}
+}
+
+// router sends the trader's actions to a broker.
+type router struct {
+	broker broker.Broker
+	symbol string
+	since  time.Time // Fills up to here have been reported
+}
+
+// sync replaces the trader's holdings with the broker's.
+func (r *router) sync(ctx context.Context, trader *live.Trader) error {
+	positions, err := r.broker.GetPositions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get positions: %w", err)
+	}
+	trader.SetHoldings(positions.Cash, positions.Quantity(r.symbol))
+	return nil
+}
+
+// reportFills prints the broker's executions since the last call.
+func (r *router) reportFills(ctx context.Context) {
+	fills, err := r.broker.GetFills(ctx, r.since)
+	if err != nil {
+		fmt.Printf("Warning: failed to get fills: %v\n", err)
+		return
+	}
+	for _, f := range fills {
+		fmt.Printf("Broker fill: %s %s %.6f %s @ %.4f (order %s)\n",
+			f.Time.Format(time.DateTime), f.Side, f.Quantity, f.Symbol, f.Price, f.OrderID)
+		r.since = f.Time
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+	"github.com/kasaderos/rLportfolio/pkg/trainer"
+)
+
+// main learns a Q-table purely from recorded trajectory files (see train -record-dir),
+// without touching an environment, and saves it as a model bundle.
+func main() {
+	pattern := flag.String("trajectories", "data/trajectories/*.jsonl", "trajectory files to learn from (glob)")
+	initPath := flag.String("init", "", "model bundle to start from; its encoder must be the one the trajectories were recorded with (default: a zero Q-table for -encoder)")
+	encoderName := flag.String("encoder", "ma", "state encoder the trajectories were recorded with, without -init: ma, regime, or slope")
+	slopeLag := flag.Int("slope-lag", state.DefaultSlopeLag, "prices over which the slope encoder measures MA slopes")
+	modelOut := flag.String("model", "data/offline-model", "output for the model bundle (directory, or .json/.json.gz for a single file)")
+	epochs := flag.Int("epochs", 20, "passes over the recorded transitions")
+	alpha := flag.Float64("alpha", 0.1, "learning rate")
+	gamma := flag.Float64("gamma", 0.95, "discount factor")
+	penalty := flag.Float64("penalty", 0.01, "weight of the conservative penalty keeping Q-values of actions absent from the data low, on the scale of the rewards (0 is plain Q-learning)")
+	seed := flag.Int64("seed", 1, "random seed for the order of the transitions")
+	flag.Parse()
+
+	paths, err := filepath.Glob(*pattern)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(paths) == 0 {
+		fmt.Printf("Error: no trajectory files match %s\n", *pattern)
+		os.Exit(1)
+	}
+	sort.Strings(paths)
+
+	var transitions []agent.Transition
+	for _, path := range paths {
+		records, err := env.ReadTrajectory(path)
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		for _, r := range records {
+			transitions = append(transitions, r.Transition())
+		}
+		fmt.Printf("Loaded %d steps in %d episodes from %s\n", len(records), len(env.Episodes(records)), path)
+	}
+
+	var Q [][]float64
+	var encoder state.Encoder
+	if *initPath != "" {
+		bundle, err := model.Load(*initPath)
+		if err != nil {
+			fmt.Printf("Error loading model: %v\n", err)
+			os.Exit(1)
+		}
+		if encoder, err = bundle.StateEncoder(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		Q = bundle.Q
+	} else {
+		switch *encoderName {
+		case "ma":
+			encoder = state.NewMAEncoder()
+		case "regime":
+			encoder = state.NewRegimeEncoder()
+		case "slope":
+			encoder = state.NewSlopeEncoder(*slopeLag)
+		default:
+			fmt.Printf("Error: unknown encoder %q (use ma, regime, or slope, or -init for others)\n", *encoderName)
+			os.Exit(1)
+		}
+		Q = agent.NewQTable(encoder.NumStates(), agent.NumActions).Q
+	}
+
+	config := trainer.OfflineConfig{Epochs: *epochs, Alpha: *alpha, Gamma: *gamma, Penalty: *penalty, Seed: *seed}
+	err = trainer.TrainOffline(Q, transitions, config, func(s trainer.OfflineStats) {
+		if s.Epoch == 1 {
+			fmt.Printf("Learning from %d transitions covering %.2f%% of (state, action) pairs\n", len(transitions), s.Coverage*100)
+		}
+		fmt.Printf("Epoch %d: mean |TD error|=%.6f\n", s.Epoch, s.TDError)
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	bundle := model.New(Q, encoder, model.TrainingConfig{Alpha: *alpha, Gamma: *gamma, Episodes: *epochs, Seed: *seed, Agent: "offline"})
+	if err := bundle.Save(*modelOut); err != nil {
+		fmt.Printf("Failed to save model: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Saved model to %s\n", *modelOut)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/eval"
+	"github.com/kasaderos/rLportfolio/pkg/plot"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+	"github.com/kasaderos/rLportfolio/pkg/trainer"
+)
+
+// main trains an agent whose actions are options (accumulate, hold, distribute,
+// exit) run over several steps by fixed controllers, with SMDP Q-learning, and tests
+// its greedy option choice.
+func main() {
+	trainPath := flag.String("data", "data/train.csv", "training price file (any format supported by pkg/data)")
+	testPath := flag.String("test-data", "data/test.csv", "test price file")
+	symbol := flag.String("symbol", "", "test series, by symbol name (overrides -column)")
+	column := flag.Int("column", 0, "test series, by position (Date column excluded)")
+	missing := flag.String("missing", "drop", "policy for missing or invalid prices: drop, ffill, or error")
+	episodeCount := flag.Int("episode-count", 100, "training episodes, split over the stocks")
+	seed := flag.Int64("seed", 1, "random seed")
+	duration := flag.Int("duration", agent.DefaultOptionDuration, "longest run of an option, in steps")
+	alpha := flag.Float64("alpha", 0.1, "learning rate")
+	gamma := flag.Float64("gamma", 0.95, "discount factor per step")
+	epsilon := flag.Float64("epsilon", 0.1, "exploration rate of the option choice")
+	qOut := flag.String("q-out", "data/options_q.csv", "output for the Q-matrix over options (empty to skip)")
+	flag.Parse()
+
+	if *episodeCount < 1 || *duration < 1 {
+		fmt.Println("Error: -episode-count and -duration must be positive")
+		os.Exit(1)
+	}
+	policy, err := data.ParseMissingPolicy(*missing)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	series, _, err := data.Split{File: *trainPath}.Load(data.Options{Missing: policy})
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", *trainPath, err)
+		os.Exit(1)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Symbol < series[j].Symbol })
+
+	encoder := state.NewMAEncoder()
+	Q := agent.NewQTable(encoder.NumStates(), agent.NumOptions)
+	optionAgent := agent.NewOptionAgent(Q, *epsilon, rand.New(rand.NewSource(*seed)), *alpha, *gamma)
+	optionAgent.Duration = *duration
+
+	episodesPerStock := max(*episodeCount/len(series), 1)
+	fmt.Printf("=== Training options on %d stocks, %d episodes each ===\n", len(series), episodesPerStock)
+	for _, s := range series {
+		prices := s.Closes()
+		if len(prices) < encoder.WarmUp()+2 {
+			fmt.Printf("Skipping %s: Need at least %d prices, got %d\n", s.Symbol, encoder.WarmUp()+2, len(prices))
+			continue
+		}
+		fmt.Printf("Training on %s (%d prices)...\n", s.Symbol, len(prices))
+		stockEnv := env.NewMarketEnv(env.MarketConfig{
+			Prices:      prices,
+			InitialCash: 10000.0,
+			Commission:  0.002,
+			Encoder:     encoder,
+		})
+		trainer.NewTrainer(stockEnv, optionAgent).Run(episodesPerStock, 100)
+	}
+	fmt.Println()
+	printUsage("Training", optionAgent)
+
+	if *qOut != "" {
+		if err := plot.SaveQMatrixDataToFile(Q.Q, *qOut); err != nil {
+			fmt.Printf("Failed to save Q matrix: %v\n", err)
+		} else {
+			fmt.Printf("Saved Q matrix over options to %s\n", *qOut)
+		}
+	}
+
+	// Test the greedy option choice
+	testSeries, _, err := data.Split{File: *testPath}.Load(data.Options{Missing: policy})
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", *testPath, err)
+		os.Exit(1)
+	}
+	selected, err := data.Select(testSeries, *symbol, *column)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	config := eval.DefaultConfig()
+	marketEnv := env.NewMarketEnv(env.MarketConfig{
+		Prices:      selected.Closes(),
+		InitialCash: config.InitialCash,
+		MinStartIdx: config.MinStartIdx,
+		Commission:  config.Commission,
+		Encoder:     encoder,
+	})
+	if selected.Len() < marketEnv.StartIdx()+2 {
+		fmt.Printf("Error: need at least %d test prices, got %d\n", marketEnv.StartIdx()+2, selected.Len())
+		os.Exit(1)
+	}
+	greedy := agent.NewOptionAgent(Q, 0, nil, *alpha, *gamma)
+	greedy.Duration = *duration
+	result := eval.Run(greedy, marketEnv)
+
+	fmt.Printf("\n=== Testing greedy options on %s ===\n", selected.Symbol)
+	fmt.Printf("  Final value: %.2f\n", result.Metrics.FinalValue)
+	fmt.Printf("  Return: %.2f%%\n", result.Metrics.TotalReturn*100)
+	fmt.Printf("  Sharpe: %.2f\n", result.Metrics.Sharpe)
+	fmt.Printf("  Max drawdown: %.2f%%\n", result.Metrics.MaxDrawdown*100)
+	fmt.Printf("  Trades: %d\n\n", result.Metrics.NumTrades)
+	printUsage("Test", greedy)
+}
+
+// printUsage prints how often the agent started every option and how long they ran.
+func printUsage(title string, a *agent.OptionAgent) {
+	fmt.Printf("%s option usage:\n", title)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  option\tstarted\tsteps\tmean length")
+	for o := agent.Option(0); o < agent.NumOptions; o++ {
+		mean := 0.0
+		if a.Started[o] > 0 {
+			mean = float64(a.Steps[o]) / float64(a.Started[o])
+		}
+		fmt.Fprintf(w, "  %s\t%d\t%d\t%.2f\n", o, a.Started[o], a.Steps[o], mean)
+	}
+	w.Flush()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+	"github.com/kasaderos/rLportfolio/pkg/plot"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+	"github.com/kasaderos/rLportfolio/pkg/trainer"
+)
+
+// main trains a Q-learning agent to trade the spread between two stocks and tests
+// its greedy policy on the prices held out at the end.
+func main() {
+	dataPath := flag.String("data", "data/train.csv", "price file holding both stocks (any format supported by pkg/data)")
+	holdout := flag.Float64("holdout", 0.3, "fraction of the prices held out at the end for testing")
+	symbolA := flag.String("a", "MSFT", "first stock of the pair, bought when going long the spread")
+	symbolB := flag.String("b", "IBM", "second stock of the pair, sold short when going long the spread")
+	missing := flag.String("missing", "drop", "policy for missing or invalid prices: drop, ffill, or error")
+	window := flag.Int("window", env.DefaultPairWindow, "prices over which the hedge ratio and the spread's z-score are estimated")
+	commission := flag.Float64("commission", 0.002, "commission rate on the notional of both legs")
+	episodeCount := flag.Int("episode-count", 200, "training episodes")
+	seed := flag.Int64("seed", 1, "random seed")
+	alpha := flag.Float64("alpha", 0.1, "learning rate")
+	gamma := flag.Float64("gamma", 0.95, "discount factor")
+	epsilon := flag.Float64("epsilon", 0.1, "exploration rate")
+	qOut := flag.String("q-out", "data/pairs_q.csv", "output for the Q-matrix over pair states (empty to skip)")
+	flag.Parse()
+
+	if *episodeCount < 1 {
+		fmt.Println("Error: -episode-count must be positive")
+		os.Exit(1)
+	}
+	if *holdout <= 0 || *holdout >= 1 {
+		fmt.Println("Error: -holdout must be in (0, 1)")
+		os.Exit(1)
+	}
+	policy, err := data.ParseMissingPolicy(*missing)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	pricesA, pricesB, err := loadPair(*dataPath, *symbolA, *symbolB, policy)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	// The test prices start with a window of training prices for the first z-score
+	cut := int(float64(len(pricesA)) * (1 - *holdout))
+	testFrom := max(cut-*window+1, 0)
+	config := env.PairConfig{Commission: *commission, Window: *window}
+	trainConfig, testConfig := config, config
+	trainConfig.PricesA, trainConfig.PricesB = pricesA[:cut], pricesB[:cut]
+	testConfig.PricesA, testConfig.PricesB = pricesA[testFrom:], pricesB[testFrom:]
+
+	trainEnv, err := env.NewPairTradingEnv(trainConfig)
+	if err != nil {
+		fmt.Printf("Error: training prices: %v\n", err)
+		os.Exit(1)
+	}
+	testEnv, err := env.NewPairTradingEnv(testConfig)
+	if err != nil {
+		fmt.Printf("Error: test prices: %v\n", err)
+		os.Exit(1)
+	}
+	Q := agent.NewQTable(trainEnv.NumStates(), agent.NumActions)
+	policyRNG := rand.New(rand.NewSource(*seed))
+	pairAgent := agent.NewQLearningAgent(Q, agent.NewEpsilonGreedyPolicy(Q.Q, *epsilon, policyRNG), *alpha, *gamma)
+
+	fmt.Printf("=== Training on the spread of %s and %s (%d prices, %d episodes) ===\n", *symbolA, *symbolB, cut, *episodeCount)
+	t := trainer.NewTrainer(trainEnv, pairAgent)
+	t.OnEpisode = func(stats trainer.EpisodeStats) {
+		if stats.Episode%max(*episodeCount/10, 1) == 0 {
+			fmt.Printf("Episode %d: Final value=%.2f, Return=%.2f%%, Reward=%.4f\n", stats.Episode,
+				trainEnv.PortfolioValue(), (trainEnv.PortfolioValue()/trainEnv.InitialValue()-1)*100, stats.Reward)
+		}
+	}
+	t.Run(*episodeCount, *episodeCount+1)
+
+	if *qOut != "" {
+		if err := plot.SaveQMatrixDataToFile(Q.Q, *qOut); err != nil {
+			fmt.Printf("Failed to save Q matrix: %v\n", err)
+		} else {
+			fmt.Printf("Saved Q matrix over pair states to %s\n", *qOut)
+		}
+	}
+
+	testPair(testEnv, agent.NewGreedyPolicy(Q.Q))
+}
+
+// loadPair loads the closes of both stocks from path, aligned by date.
+func loadPair(path, symbolA, symbolB string, policy data.MissingPolicy) (pricesA, pricesB []float64, err error) {
+	series, _, err := data.Split{File: path}.Load(data.Options{Missing: policy})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	a, err := data.Select(series, symbolA, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := data.Select(series, symbolB, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	pricesA, pricesB = align(a.Bars, b.Bars)
+	return pricesA, pricesB, nil
+}
+
+// align returns the closes of both series on the dates they share, or position by
+// position for undated bars.
+func align(a, b []data.Bar) (closesA, closesB []float64) {
+	if len(a) == 0 || len(b) == 0 || a[0].Time.IsZero() || b[0].Time.IsZero() {
+		n := min(len(a), len(b))
+		for i := 0; i < n; i++ {
+			closesA, closesB = append(closesA, a[i].Close), append(closesB, b[i].Close)
+		}
+		return closesA, closesB
+	}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i].Time.Before(b[j].Time):
+			i++
+		case b[j].Time.Before(a[i].Time):
+			j++
+		default:
+			closesA, closesB = append(closesA, a[i].Close), append(closesB, b[j].Close)
+			i++
+			j++
+		}
+	}
+	return closesA, closesB
+}
+
+// testPair runs the greedy policy over the test pair and prints the outcome.
+func testPair(e *env.PairTradingEnv, policy agent.Actor) {
+	s := e.Reset()
+	values := []float64{e.PortfolioValue()}
+	var actions []int
+	positions := make([]int, state.NumPairPositions)
+	for done := false; !done; {
+		positions[s.PairPosition]++
+		action := policy.Act(s)
+		s, _, done = e.Step(action)
+		values = append(values, e.PortfolioValue())
+		actions = append(actions, int(action))
+	}
+
+	m := metrics.Compute(values, actions)
+	fmt.Printf("\n=== Testing the greedy policy on the held-out prices ===\n")
+	fmt.Printf("  Final value: %.2f\n", m.FinalValue)
+	fmt.Printf("  Return: %.2f%%\n", m.TotalReturn*100)
+	fmt.Printf("  Sharpe: %.2f\n", m.Sharpe)
+	fmt.Printf("  Max drawdown: %.2f%%\n", m.MaxDrawdown*100)
+	fmt.Printf("  Commission paid: %.2f\n", e.CommissionPaid())
+	fmt.Println("  Steps by position:")
+	for p, n := range positions {
+		fmt.Printf("    %+5.1f: %d\n", float64(p-state.PairFlat)/float64(state.PairFlat), n)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+	"github.com/kasaderos/rLportfolio/pkg/plot"
+	"github.com/kasaderos/rLportfolio/pkg/trainer"
+)
+
+// main trains a Q-learning agent to trade a perpetual futures contract with
+// leverage and funding, and tests its greedy policy on the prices held out at the end.
+func main() {
+	dataPath := flag.String("data", "data/btc.csv", "price file of the contract's mark prices (any format supported by pkg/data)")
+	symbol := flag.String("symbol", "", "series of -data to trade (default: first column)")
+	holdout := flag.Float64("holdout", 0.3, "fraction of the prices held out at the end for testing")
+	missing := flag.String("missing", "drop", "policy for missing or invalid prices: drop, ffill, or error")
+	leverage := flag.Float64("leverage", 2, "largest notional of the position as a multiple of the equity")
+	commission := flag.Float64("commission", env.DefaultPerpCommission, "commission rate on the notional traded")
+	fundingRate := flag.Float64("funding-rate", 0.0001, "funding rate paid by longs to shorts every funding interval (negative: shorts pay longs)")
+	fundingInterval := flag.Int("funding-interval", 1, "prices between funding payments")
+	maintenance := flag.Float64("maintenance-margin", env.DefaultMaintenanceMargin, "equity, as a fraction of the notional, below which the position is liquidated")
+	episodeCount := flag.Int("episode-count", 200, "training episodes")
+	seed := flag.Int64("seed", 1, "random seed")
+	alpha := flag.Float64("alpha", 0.1, "learning rate")
+	gamma := flag.Float64("gamma", 0.95, "discount factor")
+	epsilon := flag.Float64("epsilon", 0.1, "exploration rate")
+	qOut := flag.String("q-out", "data/perp_q.csv", "output for the Q-matrix over perpetual futures states (empty to skip)")
+	flag.Parse()
+
+	if *episodeCount < 1 {
+		fmt.Println("Error: -episode-count must be positive")
+		os.Exit(1)
+	}
+	if *holdout <= 0 || *holdout >= 1 {
+		fmt.Println("Error: -holdout must be in (0, 1)")
+		os.Exit(1)
+	}
+	policy, err := data.ParseMissingPolicy(*missing)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	series, _, err := data.Split{File: *dataPath}.Load(data.Options{Missing: policy})
+	if err != nil {
+		fmt.Printf("Error: failed to load %s: %v\n", *dataPath, err)
+		os.Exit(1)
+	}
+	selected, err := data.Select(series, *symbol, 0)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	prices := selected.Closes()
+	funding := make([]float64, len(prices))
+	for i := range funding {
+		funding[i] = *fundingRate
+	}
+
+	config := env.PerpConfig{Leverage: *leverage, Commission: *commission,
+		FundingInterval: *fundingInterval, MaintenanceMargin: *maintenance}
+	// The test prices start with the encoder's warm-up of training prices
+	cut := int(float64(len(prices)) * (1 - *holdout))
+	trainConfig, testConfig := config, config
+	trainConfig.Prices, trainConfig.Funding = prices[:cut], funding[:cut]
+	trainEnv, err := env.NewPerpEnv(trainConfig)
+	if err != nil {
+		fmt.Printf("Error: training prices: %v\n", err)
+		os.Exit(1)
+	}
+	testFrom := max(cut-trainEnv.StartIdx(), 0)
+	testConfig.Prices, testConfig.Funding = prices[testFrom:], funding[testFrom:]
+	testEnv, err := env.NewPerpEnv(testConfig)
+	if err != nil {
+		fmt.Printf("Error: test prices: %v\n", err)
+		os.Exit(1)
+	}
+	Q := agent.NewQTable(trainEnv.NumStates(), agent.NumActions)
+	policyRNG := rand.New(rand.NewSource(*seed))
+	perpAgent := agent.NewQLearningAgent(Q, agent.NewEpsilonGreedyPolicy(Q.Q, *epsilon, policyRNG), *alpha, *gamma)
+
+	fmt.Printf("=== Training on %s at up to %gx leverage (%d prices, %d episodes) ===\n", selected.Symbol, *leverage, cut, *episodeCount)
+	t := trainer.NewTrainer(trainEnv, perpAgent)
+	t.OnEpisode = func(stats trainer.EpisodeStats) {
+		if stats.Episode%max(*episodeCount/10, 1) == 0 {
+			fmt.Printf("Episode %d: Final equity=%.2f, Return=%.2f%%, Reward=%.4f, Liquidated=%v\n", stats.Episode,
+				trainEnv.Equity(), (trainEnv.Equity()/trainEnv.InitialValue()-1)*100, stats.Reward, trainEnv.Liquidated())
+		}
+	}
+	t.Run(*episodeCount, *episodeCount+1)
+
+	if *qOut != "" {
+		if err := plot.SaveQMatrixDataToFile(Q.Q, *qOut); err != nil {
+			fmt.Printf("Failed to save Q matrix: %v\n", err)
+		} else {
+			fmt.Printf("Saved Q matrix over perpetual futures states to %s\n", *qOut)
+		}
+	}
+
+	testPerp(testEnv, agent.NewGreedyPolicy(Q.Q))
+}
+
+// testPerp runs the greedy policy over the test prices and prints the outcome.
+func testPerp(e *env.PerpEnv, policy agent.Actor) {
+	s := e.Reset()
+	values := []float64{e.Equity()}
+	var actions []int
+	var long, short, peakLeverage float64
+	for done := false; !done; {
+		action := policy.Act(s)
+		s, _, done = e.Step(action)
+		values = append(values, e.Equity())
+		actions = append(actions, int(action))
+		switch {
+		case e.Contracts() > 0:
+			long++
+		case e.Contracts() < 0:
+			short++
+		}
+		peakLeverage = max(peakLeverage, e.Leverage())
+	}
+
+	m := metrics.Compute(values, actions)
+	steps := float64(len(actions))
+	fmt.Printf("\n=== Testing the greedy policy on the held-out prices ===\n")
+	fmt.Printf("  Final equity: %.2f\n", m.FinalValue)
+	fmt.Printf("  Return: %.2f%%\n", m.TotalReturn*100)
+	fmt.Printf("  Sharpe: %.2f\n", m.Sharpe)
+	fmt.Printf("  Max drawdown: %.2f%%\n", m.MaxDrawdown*100)
+	fmt.Printf("  Commission paid: %.2f\n", e.CommissionPaid())
+	fmt.Printf("  Funding paid: %.2f\n", e.FundingPaid())
+	fmt.Printf("  Long: %.1f%% of steps, short: %.1f%%\n", long/steps*100, short/steps*100)
+	fmt.Printf("  Peak leverage: %.2fx\n", peakLeverage)
+	if e.Liquidated() {
+		fmt.Printf("  Liquidated at price index %d\n", e.CurrentIdx())
+	}
+}
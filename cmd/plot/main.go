@@ -1,11 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"html/template"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/kasaderos/rLportfolio/pkg/agent"
 	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
@@ -13,49 +26,210 @@ import (
 	"github.com/kasaderos/rLportfolio/pkg/state"
 )
 
-const (
-	// Moving average parameters (must match training parameters)
-	minStartIdx = 120 // Need at least 120 prices for MA120
-)
+// plotlyVersion is the plotly.js release inlined into the pages, embedded from
+// plotly/, and loaded from the CDN with -plotly-cdn.
+const plotlyVersion = "1.58.5"
+
+const plotlyCDN = "https://cdn.plot.ly/plotly-" + plotlyVersion + ".min.js"
+
+//go:generate curl -fsSL -o plotly/plotly-1.58.5.min.js https://cdn.plot.ly/plotly-1.58.5.min.js
+//go:embed plotly
+var plotlyFS embed.FS
+
+// minStartIdx is the first decision index of the plotted series: the warm-up of the
+// MA encoder whose states the plots show.
+var minStartIdx = state.NewMAEncoder().WarmUp()
+
+//go:embed page.html
+var pageHTML string
+
+// pageTemplate renders the interactive report page.
+var pageTemplate = template.Must(template.New("page").Parse(pageHTML))
 
 func main() {
-	// Load series data
-	prices, portfolioSeries, actions, err := plot.LoadSeriesData()
-	if err != nil {
-		log.Fatalf("Failed to load series data: %v", err)
+	addr := flag.String("addr", ":8080", "listen address for the plot server (host:port)")
+	input := flag.String("input", "data/series.csv", "comma-separated series files or run directories (positional arguments take precedence)")
+	htmlPath := flag.String("html", "templates/plot.html", "path where the served page is written")
+	outPath := flag.String("out", "", "write a single self-contained HTML report to this path and exit (no server)")
+	plotlyJS := flag.String("plotly-js", "", "local plotly.min.js to inline into the page instead of the bundled plotly.js "+plotlyVersion)
+	plotlyFromCDN := flag.Bool("plotly-cdn", false, "load plotly.js from the CDN instead of inlining it (smaller pages that need a network connection)")
+	export := flag.String("export", "", "also export every chart as a static image: png or svg")
+	exportDir := flag.String("export-dir", "data/charts", "directory for exported chart images")
+	flag.Parse()
+
+	// Load series data: the first run drives the price panel, all runs are overlaid in the equity panel
+	inputs := flag.Args()
+	if len(inputs) == 0 {
+		inputs = splitList(*input)
+	}
+	if len(inputs) == 0 {
+		log.Fatalf("No input series given")
+	}
+	runs := make([]run, 0, len(inputs))
+	for _, input := range inputs {
+		path, name := resolveSeriesPath(input)
+		series, err := plot.LoadSeriesDataFromFile(path)
+		if err != nil {
+			log.Fatalf("Failed to load series data from %s: %v", path, err)
+		}
+		fmt.Printf("Loaded %d data points from %s (%d non-empty actions)\n",
+			len(series.Prices), path, countNonEmptyActions(series.Actions))
+		runs = append(runs, run{name: name, series: series})
 	}
 
-	fmt.Printf("Loaded %d data points\n", len(prices))
-	fmt.Printf("Actions: %d non-empty actions\n", countNonEmptyActions(actions))
+	if *export != "" {
+		files, err := plot.ExportCharts(chartData(runs), *exportDir, *export)
+		if err != nil {
+			log.Fatalf("Failed to export charts: %v", err)
+		}
+		for _, f := range files {
+			fmt.Printf("Exported %s\n", f)
+		}
+	}
+
+	plotlyScript, err := plotlyScriptTag(*plotlyJS, *plotlyFromCDN)
+	if err != nil {
+		log.Fatalf("Failed to load plotly.js: %v", err)
+	}
 
 	// Create HTML with interactive Plotly chart
-	html := generateInteractivePlot(prices, portfolioSeries, actions)
+	html, err := generateInteractivePlot(runs, plotlyScript)
+	if err != nil {
+		log.Fatalf("Failed to generate plot: %v", err)
+	}
 
-	// Save HTML file
-	htmlPath := "templates/plot.html"
-	if err := os.MkdirAll(filepath.Dir(htmlPath), 0755); err != nil {
-		log.Fatalf("Failed to create directory: %v", err)
+	if *outPath != "" {
+		if err := writeHTML(*outPath, html); err != nil {
+			log.Fatalf("Failed to write HTML file: %v", err)
+		}
+		fmt.Printf("Report saved to %s\n", *outPath)
+		if *plotlyFromCDN {
+			fmt.Printf("Note: the report loads plotly.js from %s and needs a network connection\n", plotlyCDN)
+		}
+		return
 	}
 
-	if err := os.WriteFile(htmlPath, []byte(html), 0644); err != nil {
+	// Save HTML file
+	if err := writeHTML(*htmlPath, html); err != nil {
 		log.Fatalf("Failed to write HTML file: %v", err)
 	}
+	fmt.Printf("Interactive plot saved to %s\n", *htmlPath)
 
-	fmt.Printf("Interactive plot saved to %s\n", htmlPath)
-	fmt.Println("Opening in browser...")
+	if err := serve(*addr, *htmlPath); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
 
-	// Start a simple HTTP server to serve the HTML
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+// serve serves the page at addr until SIGINT/SIGTERM, then shuts down gracefully.
+func serve(addr string, htmlPath string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, htmlPath)
 	})
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
-	fmt.Println("Server running at http://localhost:8080")
-	fmt.Println("Open http://localhost:8080 in your browser")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	fmt.Printf("Server running at %s\n", displayURL(addr))
 	fmt.Println("Press Ctrl+C to stop the server")
 
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Println("\nShutting down server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down: %w", err)
+	}
+	return nil
+}
+
+// displayURL returns a browsable URL for a listen address such as ":8080".
+func displayURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// run is a loaded series file with its display name.
+type run struct {
+	name   string
+	series *plot.Series
+}
+
+// resolveSeriesPath returns the series file for an argument and a display name.
+// A directory argument is treated as a run directory containing series.csv (or series.csv.gz).
+func resolveSeriesPath(arg string) (path, name string) {
+	if info, err := os.Stat(arg); err == nil && info.IsDir() {
+		path = filepath.Join(arg, "series.csv")
+		if _, err := os.Stat(path); err != nil {
+			if _, gzErr := os.Stat(path + ".gz"); gzErr == nil {
+				path += ".gz"
+			}
+		}
+		return path, filepath.Base(filepath.Clean(arg))
+	}
+	return arg, arg
+}
+
+// writeHTML writes the page to path, creating the parent directory if needed.
+func writeHTML(path string, html string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return os.WriteFile(path, []byte(html), 0644)
+}
+
+// plotlyScriptTag returns the script tag that loads plotly.js: from the CDN with
+// fromCDN, otherwise inlined, so the page works offline, from a local file when
+// jsPath is set and from the bundled release if not.
+func plotlyScriptTag(jsPath string, fromCDN bool) (template.HTML, error) {
+	if fromCDN {
+		return template.HTML(fmt.Sprintf(`<script src="%s"></script>`, plotlyCDN)), nil
+	}
+	var js []byte
+	var err error
+	if jsPath != "" {
+		js, err = os.ReadFile(jsPath)
+	} else if js, err = plotlyFS.ReadFile("plotly/plotly-" + plotlyVersion + ".min.js"); err != nil {
+		err = fmt.Errorf("plotly.js %s is not bundled (run go generate ./cmd/plot, or pass -plotly-cdn): %w", plotlyVersion, err)
 	}
+	if err != nil {
+		return "", err
+	}
+	// Keep a literal closing tag inside the library from ending the inline script
+	escaped := strings.ReplaceAll(string(js), "</script", `<\/script`)
+	return template.HTML("<script>" + escaped + "</script>"), nil
 }
 
 func countNonEmptyActions(actions []int) int {
@@ -68,246 +242,229 @@ func countNonEmptyActions(actions []int) int {
 	return count
 }
 
-func generateInteractivePlot(prices []float64, portfolioSeries []float64, actions []int) string {
-	// Prepare data for JavaScript
-	pricesJS := formatFloatArray(prices)
-
-	// Calculate moving averages
-	maDataJS := calculateMAsForPlot(prices)
-
-	// Prepare action markers with state information
-	actionMarkers := prepareActionMarkers(prices, portfolioSeries, actions)
-
-	return fmt.Sprintf(`<!DOCTYPE html>
-<html>
-<head>
-    <title>RL Portfolio Trading - Interactive Plot</title>
-    <script src="https://cdn.plot.ly/plotly-latest.min.js"></script>
-    <style>
-        body {
-            font-family: Arial, sans-serif;
-            margin: 20px;
-            background-color: #f5f5f5;
-        }
-        .container {
-            background-color: white;
-            padding: 20px;
-            border-radius: 8px;
-            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
-        }
-        h1 {
-            color: #333;
-            margin-bottom: 20px;
-        }
-        #plot {
-            width: 100%%;
-            height: 800px;
-        }
-        .info {
-            margin-top: 20px;
-            padding: 10px;
-            background-color: #e8f4f8;
-            border-radius: 4px;
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <h1>RL Portfolio Trading - Interactive Plot</h1>
-        <div id="plot"></div>
-        <div class="info">
-            <h3>Controls:</h3>
-            <ul>
-                <li><strong>Zoom:</strong> Click and drag to select a region, or use mouse wheel</li>
-                <li><strong>Pan:</strong> Click and drag on the plot background</li>
-                <li><strong>Reset:</strong> Double-click on the plot</li>
-                <li><strong>Hover:</strong> Hover over points to see details</li>
-            </ul>
-            <h3>Legend:</h3>
-            <ul>
-                <li><span style="color: #1f77b4;">Blue line:</span> Price series</li>
-                <li><span style="color: #ff7f0e;">Orange dashed:</span> MA5</li>
-                <li><span style="color: #9467bd;">Purple dashed:</span> MA10</li>
-                <li><span style="color: #8c564b;">Brown dashed:</span> MA20</li>
-                <li><span style="color: #e377c2;">Pink dashed:</span> MA40</li>
-                <li><span style="color: #7f7f7f;">Gray dashed:</span> MA80</li>
-                <li><span style="color: #bcbd22;">Olive dashed:</span> MA120</li>
-                <li><span style="color: #2ca02c;">Green markers:</span> Buy actions</li>
-                <li><span style="color: #d62728;">Red markers:</span> Sell actions</li>
-            </ul>
-        </div>
-    </div>
-
-    <script>
-        // Price data
-        var prices = %s;
-        var actionMarkers = %s;
-        var maData = %s;
-        
-        var time = [];
-        for (var i = 0; i < prices.length; i++) {
-            time.push(i);
-        }
-
-        // Create price trace
-        var priceTrace = {
-            x: time,
-            y: prices,
-            type: 'scatter',
-            mode: 'lines',
-            name: 'Price',
-            line: {
-                color: '#1f77b4',
-                width: 2
-            },
-            yaxis: 'y'
-        };
-
-        // Create MA traces
-        var maTraces = [];
-        var maColors = ['#ff7f0e', '#9467bd', '#8c564b', '#e377c2', '#7f7f7f', '#bcbd22'];
-        var maPeriods = [5, 10, 20, 40, 80, 120];
-        
-        for (var i = 0; i < maPeriods.length; i++) {
-            var period = maPeriods[i];
-            var maValues = maData[period];
-            var maTime = [];
-            var maY = [];
-            
-            // MA arrays are shorter, need to offset x values
-            var offset = period - 1;
-            for (var j = 0; j < maValues.length; j++) {
-                maTime.push(offset + j);
-                maY.push(maValues[j]);
-            }
-            
-            maTraces.push({
-                x: maTime,
-                y: maY,
-                type: 'scatter',
-                mode: 'lines',
-                name: 'MA' + period,
-                line: {
-                    color: maColors[i],
-                    width: 1.5,
-                    dash: 'dash'
-                },
-                yaxis: 'y',
-                hovertemplate: 'MA' + period + '<br>Time: %%{x}<br>Value: %%{y:.2f}<extra></extra>'
-            });
-        }
-
-        // Create buy action markers
-        var buyMarkers = {
-            x: actionMarkers.buy.x,
-            y: actionMarkers.buy.y,
-            text: actionMarkers.buy.labels,
-            type: 'scatter',
-            mode: 'markers',
-            name: 'Buy Actions',
-            marker: {
-                color: '#2ca02c',
-                size: 8,
-                symbol: 'triangle-up',
-                line: {
-                    color: '#1f7f1f',
-                    width: 1
-                }
-            },
-            yaxis: 'y',
-            hovertemplate: '<b>%%{text}</b><br>Time: %%{x}<br>Price: %%{y:.2f}<br>State: %%{customdata}<extra></extra>',
-            customdata: actionMarkers.buy.states
-        };
-
-        // Create sell action markers
-        var sellMarkers = {
-            x: actionMarkers.sell.x,
-            y: actionMarkers.sell.y,
-            text: actionMarkers.sell.labels,
-            type: 'scatter',
-            mode: 'markers',
-            name: 'Sell Actions',
-            marker: {
-                color: '#d62728',
-                size: 8,
-                symbol: 'triangle-down',
-                line: {
-                    color: '#7f0f0f',
-                    width: 1
-                }
-            },
-            yaxis: 'y',
-            hovertemplate: '<b>%%{text}</b><br>Time: %%{x}<br>Price: %%{y:.2f}<br>State: %%{customdata}<extra></extra>',
-            customdata: actionMarkers.sell.states
-        };
-
-        var data = [priceTrace].concat(maTraces).concat([buyMarkers, sellMarkers]);
-
-        var layout = {
-            title: {
-                text: 'RL Portfolio Trading - Price and Actions',
-                font: {
-                    size: 18
-                }
-            },
-            xaxis: {
-                title: 'Time',
-                showgrid: true,
-                gridcolor: '#e0e0e0'
-            },
-            yaxis: {
-                title: 'Price',
-                side: 'left',
-                showgrid: true,
-                gridcolor: '#e0e0e0'
-            },
-            hovermode: 'closest',
-            legend: {
-                x: 0,
-                y: 1,
-                bgcolor: 'rgba(255,255,255,0.8)'
-            },
-            plot_bgcolor: 'white',
-            paper_bgcolor: 'white'
-        };
-
-        var config = {
-            responsive: true,
-            displayModeBar: true,
-            modeBarButtonsToRemove: ['lasso2d', 'select2d'],
-            displaylogo: false
-        };
-
-        Plotly.newPlot('plot', data, layout, config);
-    </script>
-</body>
-</html>`, pricesJS, actionMarkers, maDataJS)
+// nullable is a series marshalled to JSON with null for NaN, which JSON cannot represent.
+type nullable []float64
+
+// MarshalJSON implements json.Marshaler.
+func (n nullable) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 0, 2+8*len(n))
+	buf = append(buf, '[')
+	for i, v := range n {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			buf = append(buf, "null"...)
+			continue
+		}
+		buf = strconv.AppendFloat(buf, v, 'g', -1, 64)
+	}
+	return append(buf, ']'), nil
 }
 
-func formatFloatArray(arr []float64) string {
-	if len(arr) == 0 {
-		return "[]"
+// nullableMAs converts aligned MAs for the report.
+func nullableMAs(mas map[int][]float64) map[int]nullable {
+	out := make(map[int]nullable, len(mas))
+	for period, values := range mas {
+		out[period] = values
 	}
-	result := "["
-	for i, v := range arr {
-		if i > 0 {
-			result += ","
+	return out
+}
+
+// reportData is the typed data behind the page; it is marshalled to JSON and read by the page script.
+type reportData struct {
+	Prices    []float64          `json:"prices"`
+	MAPeriods []int              `json:"maPeriods"`
+	MAs       map[int]nullable   `json:"mas"` // Aligned to prices; null during the warm-up
+	Markers   actionMarkers      `json:"markers"`
+	Runs      []runCurve         `json:"runs"`
+	Trades    []tradeRow         `json:"trades"`
+	Timeline  stateTimeline      `json:"timeline"`
+	Regions   []divergenceRegion `json:"divergenceRegions"`
+	BuyHold   []float64          `json:"buyHold"`
+	Drawdown  []float64          `json:"drawdown"`
+	Cash      []float64          `json:"cash"`
+	Equity    []float64          `json:"equity"`
+}
+
+// runCurve is the equity curve of one run in the equity panel.
+type runCurve struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
+}
+
+// tradeRow is one executed trade in the trade log table.
+type tradeRow struct {
+	Time       int     `json:"time"`
+	Action     string  `json:"action"`
+	Size       float64 `json:"size"` // Shares bought (positive) or sold (negative)
+	Price      float64 `json:"price"`
+	Commission float64 `json:"commission"`
+	Cash       float64 `json:"cash"`   // Cash after the trade
+	Shares     float64 `json:"shares"` // Shares after the trade
+	State      string  `json:"state"`
+}
+
+// stateTimeline holds the market state components at every time step for the
+// state strip under the price chart. Entries before the warm-up period are null.
+type stateTimeline struct {
+	MAState    []*int   `json:"maState"`
+	Divergence []*int   `json:"divergence"`
+	Labels     []string `json:"labels"`
+}
+
+// divergenceRegion is a contiguous range of time steps with converging or diverging MAs.
+type divergenceRegion struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"` // Inclusive
+	Kind  string `json:"kind"`
+}
+
+// markerSet holds the action markers of one kind (buy or sell).
+type markerSet struct {
+	X        []int     `json:"x"`
+	Y        []float64 `json:"y"`
+	Labels   []string  `json:"labels"`
+	States   []string  `json:"states"`
+	Notional []float64 `json:"notional"` // Traded value (shares * price), zero if not executed
+}
+
+// actionMarkers holds the buy and sell markers drawn on the price panel.
+type actionMarkers struct {
+	Buy  markerSet `json:"buy"`
+	Sell markerSet `json:"sell"`
+}
+
+// pageData is passed to the page template.
+type pageData struct {
+	PlotlyScript template.HTML
+	Data         template.JS
+}
+
+// generateInteractivePlot renders the page. The first run provides the price panel,
+// markers, allocation, and drawdown; every run's equity curve is overlaid.
+func generateInteractivePlot(runs []run, plotlyScript template.HTML) (string, error) {
+	primary := runs[0].series
+	prices, portfolioSeries := primary.Prices, primary.PortfolioValues
+
+	curves := make([]runCurve, len(runs))
+	for i, r := range runs {
+		curves[i] = runCurve{Name: r.name, Values: r.series.PortfolioValues}
+	}
+	if len(runs) == 1 {
+		curves[0].Name = "Portfolio"
+	}
+
+	cashValues, equityValues := calculateAllocation(prices, primary.ActionData)
+	timeline := prepareStateTimeline(prices)
+	data := reportData{
+		Prices:    prices,
+		MAPeriods: ma.MAPeriods,
+		MAs:       nullableMAs(ma.CalculateAllMAsAligned(prices)),
+		Markers:   prepareActionMarkers(prices, portfolioSeries, primary.Actions, primary.ActionData),
+		Trades:    prepareTradeLog(prices, primary.Actions, primary.ActionData),
+		Timeline:  timeline,
+		Regions:   divergenceRegions(timeline),
+		Runs:      curves,
+		BuyHold:   calculateBuyAndHold(prices, portfolioSeries),
+		Drawdown:  calculateDrawdown(portfolioSeries),
+		Cash:      cashValues,
+		Equity:    equityValues,
+	}
+
+	// encoding/json escapes <, > and & so the data is safe inside a script element
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal plot data: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := pageTemplate.Execute(&buf, pageData{
+		PlotlyScript: plotlyScript,
+		Data:         template.JS(dataJSON),
+	}); err != nil {
+		return "", fmt.Errorf("failed to render page: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// chartData collects the report series for static export with gonum/plot.
+func chartData(runs []run) plot.ChartData {
+	primary := runs[0].series
+	prices, portfolioSeries := primary.Prices, primary.PortfolioValues
+	cashValues, equityValues := calculateAllocation(prices, primary.ActionData)
+
+	data := plot.ChartData{
+		Prices:   prices,
+		MAs:      ma.CalculateAllMAsAligned(prices),
+		Actions:  primary.Actions,
+		BuyHold:  calculateBuyAndHold(prices, portfolioSeries),
+		Cash:     cashValues,
+		Equity:   equityValues,
+		Drawdown: calculateDrawdown(portfolioSeries),
+	}
+	for _, r := range runs {
+		data.RunNames = append(data.RunNames, r.name)
+		data.RunValues = append(data.RunValues, r.series.PortfolioValues)
+	}
+	return data
+}
+
+// calculateBuyAndHold returns the value of investing the initial portfolio value
+// fully at the first decision index and holding until the end.
+func calculateBuyAndHold(prices []float64, portfolioSeries []float64) []float64 {
+	buyHold := make([]float64, len(prices))
+	if len(portfolioSeries) == 0 || len(prices) <= minStartIdx {
+		return buyHold
+	}
+
+	initialValue := portfolioSeries[0]
+	entryPrice := prices[minStartIdx]
+	for i := range prices {
+		if i < minStartIdx || entryPrice <= 0 {
+			buyHold[i] = initialValue
+			continue
 		}
-		result += fmt.Sprintf("%.6f", v)
+		buyHold[i] = initialValue * prices[i] / entryPrice
 	}
-	result += "]"
-	return result
+	return buyHold
 }
 
-func prepareActionMarkers(prices []float64, portfolioSeries []float64, actions []int) string {
-	var buyX []int
-	var buyPrices []float64
-	var buyLabels []string
-	var buyStates []string
-	var sellX []int
-	var sellPrices []float64
-	var sellLabels []string
-	var sellStates []string
+// calculateAllocation returns the cash and equity (shares * price) value at every point.
+func calculateAllocation(prices []float64, actionData []plot.ActionData) ([]float64, []float64) {
+	cashValues := make([]float64, len(actionData))
+	equityValues := make([]float64, len(actionData))
+	for i, data := range actionData {
+		cashValues[i] = data.Cash
+		if i < len(prices) {
+			equityValues[i] = data.Shares * prices[i]
+		}
+	}
+	return cashValues, equityValues
+}
+
+// calculateDrawdown returns the drawdown from the running peak at every point (zero or negative).
+func calculateDrawdown(values []float64) []float64 {
+	drawdown := make([]float64, len(values))
+	peak := 0.0
+	for i, v := range values {
+		if v > peak {
+			peak = v
+		}
+		if peak > 0 {
+			drawdown[i] = v/peak - 1.0
+		}
+	}
+	return drawdown
+}
+
+// prepareActionMarkers collects the buy and sell markers with state information.
+// Action details are stored one row after the action, so the data for action i is at i+1.
+func prepareActionMarkers(prices []float64, portfolioSeries []float64, actions []int, actionData []plot.ActionData) actionMarkers {
+	markers := actionMarkers{
+		Buy:  markerSet{X: []int{}, Y: []float64{}, Labels: []string{}, States: []string{}, Notional: []float64{}},
+		Sell: markerSet{X: []int{}, Y: []float64{}, Labels: []string{}, States: []string{}, Notional: []float64{}},
+	}
 
 	for i, action := range actions {
 		if i >= len(prices) || i >= len(portfolioSeries) {
@@ -317,57 +474,115 @@ func prepareActionMarkers(prices []float64, portfolioSeries []float64, actions [
 			continue
 		}
 
-		// Compute state for this point
-		stateStr := computeStateString(prices, portfolioSeries, i)
-
 		actionType := agent.Action(action)
-		if actionType == agent.ActionBuySmall || actionType == agent.ActionBuyLarge {
-			buyX = append(buyX, i)
-			buyPrices = append(buyPrices, prices[i])
-			buyLabels = append(buyLabels, actionType.String())
-			buyStates = append(buyStates, stateStr)
-		} else if actionType == agent.ActionSellSmall || actionType == agent.ActionSellLarge {
-			sellX = append(sellX, i)
-			sellPrices = append(sellPrices, prices[i])
-			sellLabels = append(sellLabels, actionType.String())
-			sellStates = append(sellStates, stateStr)
+		var set *markerSet
+		if actionType.IsBuy() {
+			set = &markers.Buy
+		} else if actionType.IsSell() {
+			set = &markers.Sell
+		} else {
+			continue
 		}
+
+		set.X = append(set.X, i)
+		set.Y = append(set.Y, prices[i])
+		set.Labels = append(set.Labels, actionType.String())
+		set.States = append(set.States, computeStateString(prices, actionData, i))
+
+		notional := 0.0
+		if i+1 < len(actionData) {
+			notional = (actionData[i+1].AmountBought + actionData[i+1].AmountSold) * prices[i]
+		}
+		set.Notional = append(set.Notional, notional)
 	}
 
-	// Format as JavaScript object
-	buyXJS := formatIntArray(buyX)
-	buyYJS := formatFloatArray(buyPrices)
-	buyLabelsJS := formatStringArray(buyLabels)
-	buyStatesJS := formatStringArray(buyStates)
-	sellXJS := formatIntArray(sellX)
-	sellYJS := formatFloatArray(sellPrices)
-	sellLabelsJS := formatStringArray(sellLabels)
-	sellStatesJS := formatStringArray(sellStates)
-
-	return fmt.Sprintf(`{
-        "buy": {
-            "x": %s,
-            "y": %s,
-            "labels": %s,
-            "states": %s
-        },
-        "sell": {
-            "x": %s,
-            "y": %s,
-            "labels": %s,
-            "states": %s
-        }
-    }`, buyXJS, buyYJS, buyLabelsJS, buyStatesJS, sellXJS, sellYJS, sellLabelsJS, sellStatesJS)
+	return markers
 }
 
-// computeStateString computes the state string for a given point in the series.
-func computeStateString(prices []float64, portfolioSeries []float64, idx int) string {
-	if idx < minStartIdx || idx >= len(prices) {
-		return "N/A"
+// prepareTradeLog collects the executed trades. Action details are stored one row
+// after the action (portfolioSeries indexing), so the data for action i is at i+1.
+func prepareTradeLog(prices []float64, actions []int, actionData []plot.ActionData) []tradeRow {
+	var trades []tradeRow
+	for i, action := range actions {
+		if action < 0 || !agent.Action(action).IsTrade() || i >= len(prices) || i+1 >= len(actionData) {
+			continue
+		}
+		data := actionData[i+1]
+		if data.AmountBought == 0 && data.AmountSold == 0 {
+			// Not executed (e.g. sell with no shares)
+			continue
+		}
+		trades = append(trades, tradeRow{
+			Time:       i,
+			Action:     agent.Action(action).String(),
+			Size:       data.AmountBought - data.AmountSold,
+			Price:      prices[i],
+			Commission: data.Commission,
+			Cash:       data.Cash,
+			Shares:     data.Shares,
+			State:      computeStateString(prices, actionData, i),
+		})
+	}
+	return trades
+}
+
+// prepareStateTimeline computes the MA ordering state and divergence at every time step.
+func prepareStateTimeline(prices []float64) stateTimeline {
+	timeline := stateTimeline{
+		MAState:    make([]*int, len(prices)),
+		Divergence: make([]*int, len(prices)),
+		Labels:     make([]string, len(prices)),
+	}
+	for i := range prices {
+		if i < minStartIdx {
+			timeline.Labels[i] = "N/A"
+			continue
+		}
+		maState := ma.GetMAStateForIndex(prices, i)
+		maDivergence := ma.GetMADivergenceState(prices, i)
+		timeline.MAState[i] = &maState
+		timeline.Divergence[i] = &maDivergence
+		timeline.Labels[i] = fmt.Sprintf("MA:%d %v %s", maState, ma.DecodeMAState(maState), divergenceName(maDivergence))
+	}
+	return timeline
+}
+
+// divergenceRegions groups consecutive converging or diverging steps into regions.
+// Neutral steps and the warm-up period are not shaded.
+func divergenceRegions(timeline stateTimeline) []divergenceRegion {
+	regions := []divergenceRegion{}
+	for i, d := range timeline.Divergence {
+		if d == nil || *d == state.MANeutral {
+			continue
+		}
+		kind := divergenceName(*d)
+		if n := len(regions); n > 0 && regions[n-1].Kind == kind && regions[n-1].End == i-1 {
+			regions[n-1].End = i
+			continue
+		}
+		regions = append(regions, divergenceRegion{Start: i, End: i, Kind: kind})
 	}
+	return regions
+}
 
-	// Need at least 120 prices for all MAs to be available
-	if idx < 120 {
+// divergenceName returns a readable name for an MA divergence category.
+func divergenceName(d int) string {
+	switch d {
+	case state.MAConverging:
+		return "converging"
+	case state.MANeutral:
+		return "neutral"
+	case state.MADiverging:
+		return "diverging"
+	default:
+		return "unknown"
+	}
+}
+
+// computeStateString computes the state string for a given point in the series, from
+// the cash and shares held before the action at idx (actionData[idx]).
+func computeStateString(prices []float64, actionData []plot.ActionData, idx int) string {
+	if idx < minStartIdx || idx >= len(prices) {
 		return "N/A"
 	}
 
@@ -389,79 +604,20 @@ func computeStateString(prices []float64, portfolioSeries []float64, idx int) st
 		divergenceStr = "D" // Diverging
 	}
 
-	// Get portfolio position categories
-	// portfolioSeries now contains portfolio value (cash + price * shares) directly
-	portfolioValue := portfolioSeries[idx]
-
-	// Estimate cash and shares from portfolio value
-	// This is approximate but gives reasonable state categorization
-	// We estimate that cash is roughly proportional to portfolio value
-	// and shares value is the remainder
-	initialPortfolioValue := portfolioSeries[0]
-	if initialPortfolioValue <= 0 {
-		initialPortfolioValue = 10000.0 // Default estimate
+	// Position categories of the holdings the decision was made with
+	cash, shares := 0.0, 0.0
+	if idx < len(actionData) {
+		cash, shares = actionData[idx].Cash, actionData[idx].Shares
 	}
-
-	// Rough estimate: assume cash is a portion of portfolio value
-	// This is an approximation since we don't have exact cash/shares breakdown
-	estimatedCash := portfolioValue * 0.5 // Conservative estimate
-	estimatedSharesValue := portfolioValue - estimatedCash
+	sharesValue := shares * prices[idx]
+	portfolioValue := cash + sharesValue
 	if portfolioValue <= 0 {
 		portfolioValue = 1.0 // Avoid division by zero
 	}
 
-	cashCat := state.GetCashCategory(estimatedCash, portfolioValue)
-	sharesCat := state.GetSharesCategory(estimatedSharesValue, portfolioValue)
+	cashCat := state.GetCashCategory(cash, portfolioValue)
+	sharesCat := state.GetSharesCategory(sharesValue, portfolioValue)
 
 	return fmt.Sprintf("MA:%d %s C:%d S:%d",
 		maState, divergenceStr, cashCat, sharesCat)
 }
-
-func formatIntArray(arr []int) string {
-	if len(arr) == 0 {
-		return "[]"
-	}
-	result := "["
-	for i, v := range arr {
-		if i > 0 {
-			result += ","
-		}
-		result += fmt.Sprintf("%d", v)
-	}
-	result += "]"
-	return result
-}
-
-func formatStringArray(arr []string) string {
-	if len(arr) == 0 {
-		return "[]"
-	}
-	result := "["
-	for i, v := range arr {
-		if i > 0 {
-			result += ","
-		}
-		result += fmt.Sprintf(`"%s"`, v)
-	}
-	result += "]"
-	return result
-}
-
-// calculateMAsForPlot calculates all moving averages for plotting.
-func calculateMAsForPlot(prices []float64) string {
-	mas := ma.CalculateAllMAs(prices)
-
-	// Format as JavaScript object
-	result := "{"
-	first := true
-	for _, period := range ma.MAPeriods {
-		if !first {
-			result += ","
-		}
-		first = false
-		maValues := mas[period]
-		result += fmt.Sprintf(`"%d":%s`, period, formatFloatArray(maValues))
-	}
-	result += "}"
-	return result
-}
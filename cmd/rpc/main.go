@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"google.golang.org/grpc"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+	"github.com/kasaderos/rLportfolio/pkg/rpc"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "listen address")
+	modelPath := flag.String("model", "", "model bundle to serve and continue training (default: a fresh Q-table)")
+	alpha := flag.Float64("alpha", 0.1, "learning rate for Learn")
+	gamma := flag.Float64("gamma", 0.95, "discount factor for Learn")
+	epsilon := flag.Float64("epsilon", 0.1, "exploration rate for Act with explore=true")
+	seed := flag.Int64("seed", 1, "random seed for exploration")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run cmd/rpc/main.go [flags]")
+		fmt.Fprintln(os.Stderr, "Serves the Environment and Agent gRPC services defined in proto/rlportfolio/v1/rlportfolio.proto.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	training := model.TrainingConfig{Alpha: *alpha, Gamma: *gamma, Epsilon: *epsilon, Seed: *seed}
+	Q := agent.NewQTable(state.NumStates, agent.NumActions).Q
+	if *modelPath != "" {
+		bundle, err := model.Load(*modelPath)
+		if err != nil {
+			fmt.Printf("Error loading model: %v\n", err)
+			os.Exit(1)
+		}
+		if err := bundle.CheckCompatible(state.NewMAEncoder()); err != nil {
+			fmt.Printf("Error: incompatible model: %v\n", err)
+			os.Exit(1)
+		}
+		Q = bundle.Q
+		fmt.Printf("Loaded model from %s\n", *modelPath)
+	}
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	srv := grpc.NewServer()
+	rpc.Register(srv, rpc.NewEnvironmentService(), rpc.NewAgentService(Q, training))
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		srv.GracefulStop()
+	}()
+
+	fmt.Printf("Serving gRPC on %s\n", lis.Addr())
+	if err := srv.Serve(lis); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Server stopped")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+)
+
+// main distills a trained model into a rulebook: for every market regime the
+// recommended action and how confidently the Q-table recommends it, for review
+// before deployment.
+func main() {
+	modelPath := flag.String("model", "data/model", "model bundle (directory or .json file), or a bare Q-matrix file")
+	mdOut := flag.String("md", "data/rulebook.md", "output for the Markdown rulebook (empty to skip)")
+	csvOut := flag.String("csv", "data/rulebook.csv", "output for the rulebook table (.csv, .json, or .parquet; empty to skip)")
+	byPosition := flag.Bool("positions", false, "one rule per cash and shares position too, instead of per market regime")
+	minStates := flag.Int("min-states", 1, "leave out rules summarizing fewer trained states")
+	minConfidence := flag.String("min-confidence", "low", "leave out rules below this confidence: low, medium, or high")
+	flag.Parse()
+
+	rank := map[string]int{"low": 0, "medium": 1, "high": 2}
+	floor, ok := rank[*minConfidence]
+	if !ok {
+		fmt.Printf("Error: unknown confidence %q (use low, medium, or high)\n", *minConfidence)
+		os.Exit(1)
+	}
+
+	bundle, err := model.Load(*modelPath)
+	if err != nil {
+		fmt.Printf("Error loading model: %v\n", err)
+		os.Exit(1)
+	}
+	rules, err := bundle.Rulebook(*byPosition)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	trained := 0
+	for _, r := range rules {
+		trained += r.States
+	}
+	kept := rules[:0]
+	for _, r := range rules {
+		if r.States >= *minStates && rank[r.Confidence] >= floor {
+			kept = append(kept, r)
+		}
+	}
+	records := table(kept)
+
+	if *csvOut != "" {
+		if err := data.WriteTable(*csvOut, records); err != nil {
+			fmt.Printf("Failed to write rulebook table: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved %d rules to %s\n", len(kept), *csvOut)
+	}
+	if *mdOut != "" {
+		if err := writeMarkdown(*mdOut, *modelPath, bundle, trained, len(rules), records); err != nil {
+			fmt.Printf("Failed to write rulebook: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved %d rules to %s\n", len(kept), *mdOut)
+	}
+	fmt.Printf("%d trained states distilled into %d rules, %d kept\n", trained, len(rules), len(kept))
+}
+
+// table returns the rules as rows under a header, leaving out the condition columns
+// no rule uses.
+func table(rules []model.Rule) [][]string {
+	columns := []struct {
+		name  string
+		value func(model.Rule) string
+	}{
+		{"Regime", func(r model.Rule) string { return r.Regime }},
+		{"Market", func(r model.Rule) string { return r.Market }},
+		{"Divergence", func(r model.Rule) string { return r.Divergence }},
+		{"Forecast", func(r model.Rule) string { return r.Forecast }},
+		{"Cash", func(r model.Rule) string { return r.Cash }},
+		{"Shares", func(r model.Rule) string { return r.Shares }},
+	}
+	var header []string
+	var used []func(model.Rule) string
+	for _, c := range columns {
+		for _, r := range rules {
+			if c.value(r) != "" {
+				header = append(header, c.name)
+				used = append(used, c.value)
+				break
+			}
+		}
+	}
+	header = append(header, "Action", "Confidence", "Agreement", "States", "Margin")
+
+	records := [][]string{header}
+	for _, r := range rules {
+		row := make([]string, 0, len(header))
+		for _, value := range used {
+			row = append(row, value(r))
+		}
+		row = append(row,
+			r.Action.String(),
+			r.Confidence,
+			strconv.FormatFloat(r.Agreement, 'f', 3, 64),
+			strconv.Itoa(r.States),
+			strconv.FormatFloat(r.Margin, 'g', 6, 64),
+		)
+		records = append(records, row)
+	}
+	return records
+}
+
+// writeMarkdown writes the rulebook as a Markdown document with a summary of the
+// model and the rules as a table.
+func writeMarkdown(path, modelPath string, bundle *model.Bundle, trained, numRules int, records [][]string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Rulebook: %s\n\n", modelPath)
+	fmt.Fprintf(&b, "- Encoder: %s (%d states)\n", bundle.Encoder.Name, bundle.Encoder.NumStates)
+	for _, key := range sortedKeys(bundle.Encoder.Params) {
+		fmt.Fprintf(&b, "  - %s: %s\n", key, bundle.Encoder.Params[key])
+	}
+	if !bundle.CreatedAt.IsZero() {
+		fmt.Fprintf(&b, "- Trained: %s\n", bundle.CreatedAt.Format("2006-01-02 15:04 MST"))
+	}
+	fmt.Fprintf(&b, "- Trained states: %d of %d, distilled into %d rules (%d shown)\n\n", trained, bundle.NumStates(), numRules, len(records)-1)
+	fmt.Fprintf(&b, "Each rule recommends the action with the highest mean Q-value over the trained states of its regime. ")
+	fmt.Fprintf(&b, "Agreement is the share of those states whose own best action it is (confidence high from %.0f%%, medium from %.0f%%), ", model.HighAgreement*100, model.MediumAgreement*100)
+	fmt.Fprintf(&b, "and margin how far its mean Q-value leads the next best action.\n\n")
+
+	for i, row := range records {
+		cells := make([]string, len(row))
+		for j, cell := range row {
+			cells[j] = strings.ReplaceAll(cell, "|", `\|`)
+		}
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+		if i == 0 {
+			fmt.Fprintf(&b, "|%s\n", strings.Repeat(" --- |", len(row)))
+		}
+	}
+
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kasaderos/rLportfolio/pkg/store"
+)
+
+func main() {
+	storePath := flag.String("store", "data/runs.db", "SQLite experiment store")
+	kind := flag.String("kind", "", "only list runs of this kind (train or test)")
+	name := flag.String("name", "", "only list runs whose name matches this SQL LIKE pattern, e.g. sweep-%")
+	orderBy := flag.String("order", "", "order by total_return, sharpe, final_value, num_trades, max_drawdown, or volatility (default: newest first)")
+	limit := flag.Int("n", 20, "maximum number of runs to list (0 lists all)")
+	runID := flag.Int64("run", 0, "show the episodes and trades of one run instead of listing runs")
+	flag.Parse()
+
+	runStore, err := store.Open(*storePath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer runStore.Close()
+
+	if *runID > 0 {
+		if err := printRun(runStore, *runID); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	runs, err := runStore.Runs(store.RunFilter{Kind: *kind, Name: *name, OrderBy: *orderBy, Limit: *limit})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "id\tname\tkind\tcreated\tsymbol\treturn %\tmax DD %\tsharpe\ttrades")
+	for _, r := range runs {
+		m := r.Metrics
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%.2f\t%.2f\t%.3f\t%d\n",
+			r.ID, r.Name, r.Kind, r.CreatedAt.Format("2006-01-02 15:04"), r.Symbol,
+			m.TotalReturn*100, m.MaxDrawdown*100, m.Sharpe, m.NumTrades)
+	}
+	w.Flush()
+}
+
+// printRun prints the per-stock episode summary and the trades of a run.
+func printRun(runStore *store.Store, runID int64) error {
+	episodes, err := runStore.Episodes(runID)
+	if err != nil {
+		return err
+	}
+	trades, err := runStore.Trades(runID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Run %d: %d episodes, %d trades\n", runID, len(episodes), len(trades))
+	if len(episodes) > 0 {
+		fmt.Println("\nLast episode per stock:")
+		last := make(map[string]store.Episode)
+		var stocks []string
+		for _, e := range episodes {
+			if _, ok := last[e.Stock]; !ok {
+				stocks = append(stocks, e.Stock)
+			}
+			last[e.Stock] = e
+		}
+		for _, stock := range stocks {
+			e := last[stock]
+			fmt.Printf("  %s: episode %d, return %.2f%%, reward %.4f\n", stock, e.Episode, e.ReturnPct, e.Reward)
+		}
+	}
+
+	if len(trades) > 0 {
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "step\taction\tprice\tshares\tcommission\tcash after\tshares after")
+		for _, t := range trades {
+			fmt.Fprintf(w, "%d\t%s\t%.2f\t%.4f\t%.2f\t%.2f\t%.4f\n",
+				t.Step, t.Action, t.Price, t.Shares, t.Commission, t.CashAfter, t.SharesAfter)
+		}
+		w.Flush()
+	}
+	return nil
+}
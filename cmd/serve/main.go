@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/model"
+	"github.com/kasaderos/rLportfolio/pkg/server"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	modelPath := flag.String("model", "data/model", "model bundle (directory or .json file), or a bare Q-matrix file")
+	mmap := flag.Bool("mmap", false, "memory-map a binary directory bundle's Q-table read-only instead of reading it (the file must not be rewritten while in use)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run cmd/serve/main.go [flags]")
+		fmt.Fprintln(os.Stderr, "Endpoints:")
+		fmt.Fprintln(os.Stderr, `  POST /act     {"prices": [...], "cash": 10000, "shares": 0} -> recommended action`)
+		fmt.Fprintln(os.Stderr, "  GET  /model   model bundle metadata")
+		fmt.Fprintln(os.Stderr, "  GET  /health  liveness check")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	load := model.Load
+	if *mmap {
+		load = model.LoadMapped
+	}
+	bundle, err := load(*modelPath)
+	if err != nil {
+		fmt.Printf("Error loading model: %v\n", err)
+		os.Exit(1)
+	}
+	defer bundle.Close()
+	if _, err := bundle.StateEncoder(); err != nil {
+		fmt.Printf("Error: incompatible model: %v\n", err)
+		os.Exit(1)
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           server.New(bundle, *modelPath),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Serving %s on %s\n", *modelPath, *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Server stopped")
+}
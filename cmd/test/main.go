@@ -1,73 +1,331 @@
 package main
 
 import (
-	"encoding/csv"
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
-	"strings"
+	"text/tabwriter"
 
 	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
 	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/eval"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+	"github.com/kasaderos/rLportfolio/pkg/model"
 	"github.com/kasaderos/rLportfolio/pkg/plot"
+	"github.com/kasaderos/rLportfolio/pkg/registry"
 	"github.com/kasaderos/rLportfolio/pkg/state"
+	"github.com/kasaderos/rLportfolio/pkg/store"
 )
 
 func main() {
-	// Load Q-matrix from data/q_matrix.csv
-	fmt.Println("Loading Q-matrix from data/q_matrix.csv...")
-	Q, err := plot.LoadQMatrixData()
+	mcPaths := flag.Int("mc-paths", 0, "number of Monte Carlo stress-test paths (0 disables)")
+	mcMethod := flag.String("mc-method", eval.MethodBootstrap, "Monte Carlo path generator: bootstrap or gbm")
+	mcBlock := flag.Int("mc-block", 20, "block size for bootstrapped paths")
+	scenarioList := flag.String("scenarios", "", "worst-case scenarios appended to the test prices to report how the policy behaves in each: comma-separated crash, gap-down, chop, v-recovery, or all (empty disables)")
+	scenarioBars := flag.Int("scenario-bars", eval.DefaultScenarioBars, "synthetic bars of every -scenarios scenario")
+	permRuns := flag.Int("perm-runs", 0, "number of runs for the paired sign-flip test vs a random policy (0 disables)")
+	permIters := flag.Int("perm-iters", 10000, "number of random sign flips for the p-value")
+	seed := flag.Int64("seed", 1, "random seed for Monte Carlo paths and the sign-flip test")
+	dataPath := flag.String("data", "data/test.csv", "test price CSV")
+	missing := flag.String("missing", "drop", "policy for missing or invalid prices: drop, ffill, or error")
+	symbol := flag.String("symbol", "", "price column to test on, by symbol name (overrides -column)")
+	column := flag.Int("column", 0, "price column to test on, by position (Date column excluded)")
+	modelPath := flag.String("model", "data/model", "model bundle (directory or .json file), or a bare Q-matrix file")
+	qPath := flag.String("q", "data/q_matrix.csv", "bare Q-matrix file used when the model bundle does not exist")
+	seriesOut := flag.String("series-out", "data/test_series.csv", "output for the test series (.csv, .json, or .parquet)")
+	dataset := flag.String("dataset", "", "named dataset from the catalog; tests on its -split instead of -data")
+	splitName := flag.String("split", "test", "split of -dataset to test on (e.g. val or test)")
+	catalogPath := flag.String("catalog", data.DefaultCatalog, "dataset catalog used by -dataset")
+	storePath := flag.String("store", "", "SQLite experiment store to record the run and its trades in (optional)")
+	runName := flag.String("run-name", "", "run name in the experiment store")
+	allowOverlap := flag.Bool("allow-overlap", false, "report results even when the test prices overlap the model's training data")
+	cash := flag.Float64("cash", 10000.0, "cash held at the start")
+	shares := flag.Float64("shares", 0, "shares held at the start, e.g. to test deploying the policy on an existing position")
+	commissionMin := flag.Float64("commission-min", 0, "minimum commission of a trade, e.g. 1 (0 disables); trades whose commission would exceed the trade are skipped")
+	commissionMax := flag.Float64("commission-max", 0, "maximum commission of a trade (0 disables)")
+	minTradeWeight := flag.Float64("min-trade-weight", 0, "skip trades smaller than this fraction of the portfolio value, e.g. 0.01, holding instead (0 disables)")
+	configPath := flag.String("config", "", "YAML config whose constraints block (max_equity_weight, min_cash_buffer, max_daily_turnover) is enforced on the test trades, logging every violation (optional)")
+	quotesPath := flag.String("quotes", "", "bid/ask file with Bid and Ask columns by date (optional): the test buys at the ask and sells at the bid instead of the prices the model was trained on, and reports the spread cost")
+	minTradeValue := flag.Float64("min-trade-value", 0, "skip trades smaller than this value, e.g. 10, holding instead (0 disables)")
+	flag.Parse()
+
+	if *cash < 0 || *shares < 0 || *cash == 0 && *shares == 0 {
+		fmt.Println("Error: -cash and -shares must not be negative, and the portfolio must not be empty")
+		return
+	}
+	if *minTradeWeight < 0 || *minTradeValue < 0 {
+		fmt.Println("Error: -min-trade-weight and -min-trade-value must not be negative")
+		return
+	}
+	if *commissionMin < 0 || *commissionMax < 0 || *commissionMax > 0 && *commissionMax < *commissionMin {
+		fmt.Println("Error: -commission-min and -commission-max must not be negative, with -commission-max at least -commission-min")
+		return
+	}
+	var constraints env.Constraints
+	if *configPath != "" {
+		config, err := registry.LoadConfig(*configPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		constraints = config.Constraints
+	}
+	var scenarios []eval.Scenario
+	if *scenarioList != "" {
+		var err error
+		if scenarios, err = eval.ParseScenarios(*scenarioList); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+
+	// Load the model, falling back to a bare Q-matrix from older training runs
+	path := *modelPath
+	if _, err := os.Stat(path); err != nil {
+		path = *qPath
+	}
+	fmt.Printf("Loading model from %s...\n", path)
+	bundle, err := model.Load(path)
+	if err != nil {
+		fmt.Printf("Error loading model: %v\n", err)
+		return
+	}
+	encoder, err := bundle.StateEncoder()
 	if err != nil {
-		fmt.Printf("Error loading Q-matrix: %v\n", err)
+		fmt.Printf("Error: incompatible model: %v\n", err)
 		return
 	}
-	fmt.Printf("Loaded Q-matrix with %d states and %d actions\n", len(Q), len(Q[0]))
+	Q := bundle.Q
+	fmt.Printf("Loaded model (schema version %d) with %d states and %d actions\n", bundle.SchemaVersion, len(Q), len(Q[0]))
 
-	// Load test prices from data/test.csv
-	fmt.Println("\nLoading test prices from data/test.csv...")
-	prices, err := loadTestPricesFromCSV("data/test.csv")
+	// Load test prices
+	split := data.Split{File: *dataPath}
+	if *dataset != "" {
+		catalog, err := data.LoadCatalog(*catalogPath)
+		if err == nil {
+			split, err = catalog.Split(*dataset, *splitName)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		*dataPath = split.File
+		fmt.Printf("\nUsing dataset %s, split %s\n", *dataset, *splitName)
+	}
+	fmt.Printf("\nLoading test prices from %s...\n", *dataPath)
+	policy, err := data.ParseMissingPolicy(*missing)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	selected, err := loadTestPrices(split, *symbol, *column, policy)
 	if err != nil {
 		fmt.Printf("Error loading test prices: %v\n", err)
 		return
 	}
-	if len(prices) < 50 {
-		fmt.Printf("Error: Need at least 50 prices, got %d\n", len(prices))
+	prices, name := selected.Closes(), selected.Symbol
+	if len(prices) < encoder.WarmUp()+2 {
+		fmt.Printf("Error: Need at least %d prices, got %d\n", encoder.WarmUp()+2, len(prices))
+		return
+	}
+	fmt.Printf("Loaded %d test prices for %s\n", len(prices), name)
+
+	// Refuse to pass in-sample results off as test results; the warm-up bars are not traded
+	traded := selected.Bars[encoder.WarmUp():]
+	if !checkOverlap(data.CheckOverlap(bundle.Training.Coverage, name, traded), *allowOverlap) {
 		return
 	}
-	fmt.Printf("Loaded %d test prices\n", len(prices))
+
+	var quotes *data.Quotes
+	if *quotesPath != "" {
+		if quotes, err = data.LoadQuotes(*quotesPath, selected.Bars, data.Options{Missing: policy}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Loaded bid/ask quotes from %s\n", *quotesPath)
+	}
 
 	// Create market environment with test prices
-	marketEnv := env.NewMarketEnv(env.MarketConfig{
-		Prices:      prices,
-		InitialCash: 10000.0,
-		MinStartIdx: 120,   // Need at least 120 for MA120
-		Commission:  0.002, // 2% commission
-	})
+	marketConfig := env.MarketConfig{
+		Prices:         prices,
+		InitialCash:    *cash,
+		InitialShares:  *shares,
+		Commission:     0.002, // 2% commission
+		Encoder:        encoder,
+		MinTradeWeight: *minTradeWeight,
+		MinTradeValue:  *minTradeValue,
+		CommissionMin:  *commissionMin,
+		CommissionMax:  *commissionMax,
+		Halted:         selected.Halts(),
+	}
+	if quotes != nil {
+		marketConfig.Bid, marketConfig.Ask = quotes.Bid, quotes.Ask
+	}
+	marketEnv := env.NewMarketEnv(marketConfig)
+	config := eval.DefaultConfig()
+	config.InitialCash = *cash
+	config.InitialShares = *shares
+	config.MinTradeWeight = *minTradeWeight
+	config.MinTradeValue = *minTradeValue
+	config.CommissionMin = *commissionMin
+	config.CommissionMax = *commissionMax
+	config.Halted = selected.Halts()
+	if quotes != nil {
+		config.Bid, config.Ask = quotes.Bid, quotes.Ask
+	}
 
 	fmt.Printf("Initial portfolio: Cash=%.2f, Shares=%.2f\n\n", marketEnv.Cash(), marketEnv.Shares())
 
 	// Test the learned policy on test data
 	fmt.Println("=== Testing Learned Policy on Test Data ===")
-	portfolioSeries, actions, actionData := testPolicy(Q, prices, marketEnv)
+	var testEnv env.Environment = marketEnv
+	if !constraints.Empty() {
+		testEnv = env.NewEnforcer(marketEnv, constraints, func(v env.Violation) {
+			fmt.Printf("Constraint violation: %s\n", v)
+		})
+	}
+	portfolioSeries, actions, actionData := testPolicy(Q, prices, testEnv)
+	if quotes != nil {
+		printSpreadCost(marketEnv, prices, quotes)
+	}
 
-	// Save test series data to data/test_series.csv
-	fmt.Println("\nSaving test results to data/test_series.csv...")
-	if err := plot.SaveSeriesDataToFile(prices, portfolioSeries, actions, actionData, "data/test_series.csv"); err != nil {
+	// Save test series data
+	fmt.Printf("\nSaving test results to %s...\n", *seriesOut)
+	if err := plot.SaveSeriesDataToFile(prices, portfolioSeries, actions, actionData, *seriesOut); err != nil {
 		fmt.Printf("Failed to save test series: %v\n", err)
 		return
 	}
 
-	fmt.Println("Test series data saved to data/test_series.csv")
+	fmt.Printf("Test series data saved to %s\n", *seriesOut)
+
+	if *storePath != "" {
+		if err := recordRun(*storePath, store.Run{
+			Name:   *runName,
+			Kind:   "test",
+			Data:   *dataPath,
+			Symbol: name,
+			Model:  path,
+			Seed:   *seed,
+		}, Q, encoder, prices, config); err != nil {
+			fmt.Printf("Failed to record run: %v\n", err)
+		}
+	}
+
+	if *mcPaths > 0 {
+		runMonteCarlo(Q, encoder, prices, config, eval.MonteCarloConfig{
+			Paths:     *mcPaths,
+			Method:    *mcMethod,
+			BlockSize: *mcBlock,
+			Seed:      *seed,
+		})
+	}
+
+	if len(scenarios) > 0 {
+		runScenarios(Q, encoder, prices, config, scenarios, *scenarioBars)
+	}
+
+	if *permRuns > 0 {
+		runRandomTest(Q, encoder, prices, config, eval.RandomTestConfig{
+			Runs:         *permRuns,
+			Permutations: *permIters,
+			BlockSize:    *mcBlock,
+			Seed:         *seed,
+		})
+	}
+}
+
+// recordRun evaluates the greedy policy and stores the run with its metrics and trades.
+func recordRun(path string, run store.Run, Q [][]float64, encoder state.Encoder, prices []float64, config eval.Config) error {
+	result, err := eval.Evaluate(Q, encoder, prices, config)
+	if err != nil {
+		return err
+	}
+	runStore, err := store.Open(path)
+	if err != nil {
+		return err
+	}
+	defer runStore.Close()
+
+	runID, err := runStore.CreateRun(run)
+	if err != nil {
+		return err
+	}
+	if err := runStore.RecordResult(runID, result); err != nil {
+		return err
+	}
+	fmt.Printf("Recorded run %d in %s\n", runID, path)
+	return nil
+}
+
+// runRandomTest compares the greedy policy to a random-action policy and prints the p-value.
+func runRandomTest(Q [][]float64, encoder state.Encoder, prices []float64, config eval.Config, rt eval.RandomTestConfig) {
+	fmt.Printf("\n=== Paired Sign-Flip Test vs Random Policy (%d runs) ===\n", rt.Runs)
+	result, err := eval.CompareToRandom(Q, encoder, prices, config, rt)
+	if err != nil {
+		fmt.Printf("Sign-flip test failed: %v\n", err)
+		return
+	}
+
+	fmt.Printf("  Mean return (policy): %.2f%%\n", mean(result.PolicyReturns)*100)
+	fmt.Printf("  Mean return (random): %.2f%%\n", mean(result.RandomReturns)*100)
+	fmt.Printf("  Difference: %.2f%%\n", result.MeanDiff*100)
+	fmt.Printf("  p-value: %.4f\n", result.PValue)
+}
+
+func mean(values []float64) float64 {
+	m, _ := metrics.MeanStd(values)
+	return m
+}
+
+// runMonteCarlo stress-tests the greedy policy on synthetic price paths and prints the outcome distribution.
+func runMonteCarlo(Q [][]float64, encoder state.Encoder, prices []float64, config eval.Config, mc eval.MonteCarloConfig) {
+	fmt.Printf("\n=== Monte Carlo Stress Test (%d %s paths) ===\n", mc.Paths, mc.Method)
+	result, err := eval.MonteCarlo(Q, encoder, prices, config, mc)
+	if err != nil {
+		fmt.Printf("Monte Carlo failed: %v\n", err)
+		return
+	}
+
+	ret := result.ReturnQuantiles()
+	dd := result.DrawdownQuantiles()
+	fmt.Printf("  Return:       P5=%.2f%%  P50=%.2f%%  P95=%.2f%%\n", ret.P5*100, ret.P50*100, ret.P95*100)
+	fmt.Printf("  Max drawdown: P5=%.2f%%  P50=%.2f%%  P95=%.2f%%\n", dd.P5*100, dd.P50*100, dd.P95*100)
+}
+
+// runScenarios appends every worst-case scenario to the test prices and prints how
+// the greedy policy fares over its bars.
+func runScenarios(Q [][]float64, encoder state.Encoder, prices []float64, config eval.Config, scenarios []eval.Scenario, bars int) {
+	fmt.Printf("\n=== Worst-Case Scenarios (%d bars after the test prices) ===\n", bars)
+	results, err := eval.RunScenarios(Q, encoder, prices, config, scenarios, bars)
+	if err != nil {
+		fmt.Printf("Scenarios failed: %v\n", err)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "scenario\treturn\tbuy_and_hold\tmax_drawdown\ttrades\texposure_in\texposure_out\t")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%.2f%%\t%.2f%%\t%.2f%%\t%d\t%.0f%%\t%.0f%%\t\n", r.Scenario, r.Return*100, r.BuyAndHold*100,
+			r.MaxDrawdown*100, r.Trades, r.ExposureStart*100, r.ExposureEnd*100)
+	}
+	w.Flush()
+	for _, s := range scenarios {
+		fmt.Printf("  %s: %s\n", s.Name, s.Description)
+	}
 }
 
 // testPolicy tests the learned policy on the price data and returns portfolio value series, actions, and action data.
-func testPolicy(Q [][]float64, prices []float64, marketEnv *env.MarketEnv) ([]float64, []int, []plot.ActionData) {
+// Trades go through testEnv, the market environment or a wrapper of it.
+func testPolicy(Q [][]float64, prices []float64, testEnv env.Environment) ([]float64, []int, []plot.ActionData) {
 	// Create greedy policy for testing
 	greedyPolicy := agent.NewGreedyPolicy(Q)
 	testAgent := &testAgent{policy: greedyPolicy}
 
+	marketEnv, _ := env.Market(testEnv)
+
 	// Reset environment
-	s := marketEnv.Reset()
+	s := testEnv.Reset()
 	done := false
 	actions := make([]int, len(prices))
 	portfolioSeries := make([]float64, len(prices))
@@ -87,18 +345,14 @@ func testPolicy(Q [][]float64, prices []float64, marketEnv *env.MarketEnv) ([]fl
 		}
 	}
 
-	step := 0
+	// Index by price so the saved series lines up with the prices
+	step := marketEnv.StartIdx()
 	for !done {
 		action := testAgent.Act(s)
-		currentPrice := marketEnv.CurrentPrice()
-		currentCash := marketEnv.Cash()
 		currentShares := marketEnv.Shares()
-		commission := marketEnv.Commission()
+		commissionBefore := marketEnv.CommissionPaid()
 
-		// Calculate buy/sell amounts and commission before executing the action
-		amountBought, amountSold, commissionPaid := calculateActionAmountsAndCommission(action, currentCash, currentShares, currentPrice, commission)
-
-		next, _, d := marketEnv.Step(action)
+		next, _, d := testEnv.Step(action)
 		actions[step] = int(action)
 		portfolioSeries[step+1] = marketEnv.PortfolioValue()
 
@@ -107,14 +361,15 @@ func testPolicy(Q [][]float64, prices []float64, marketEnv *env.MarketEnv) ([]fl
 		afterShares := marketEnv.Shares()
 
 		// Store action data at step+1 to match portfolioSeries indexing
-		// (step 0 is initial state, step+1 is after first action)
+		// (step is the price index of the decision, step+1 is after the action).
+		// Amounts are what was executed, e.g. nothing for a sell without shares.
 		actionData[step+1] = plot.ActionData{
 			ActionName:   action.String(),
-			AmountBought: amountBought,
-			AmountSold:   amountSold,
+			AmountBought: max(afterShares-currentShares, 0),
+			AmountSold:   max(currentShares-afterShares, 0),
 			Cash:         afterCash,
 			Shares:       afterShares,
-			Commission:   commissionPaid,
+			Commission:   marketEnv.CommissionPaid() - commissionBefore,
 		}
 		s = next
 		done = d
@@ -130,10 +385,31 @@ func testPolicy(Q [][]float64, prices []float64, marketEnv *env.MarketEnv) ([]fl
 	fmt.Printf("  Return: %.2f%%\n", returnPct)
 	fmt.Printf("  Final cash: %.2f\n", marketEnv.Cash())
 	fmt.Printf("  Final shares: %.2f\n", marketEnv.Shares())
+	fmt.Printf("  Commission paid: %.2f\n", marketEnv.CommissionPaid())
+	if n := marketEnv.BlockedOrders(); n > 0 {
+		fmt.Printf("  Blocked orders: %d (trading halted)\n", n)
+	}
+	if enforcer, ok := testEnv.(*env.Enforcer); ok {
+		fmt.Printf("  Constraint violations: %d\n", enforcer.Violations())
+	}
 
 	return portfolioSeries, actions, actionData
 }
 
+// printSpreadCost prints what crossing the bid/ask spread cost the test run, and the
+// mean spread quoted over the traded prices.
+func printSpreadCost(marketEnv *env.MarketEnv, prices []float64, quotes *data.Quotes) {
+	var spread float64
+	traded := prices[marketEnv.StartIdx():]
+	for i := range traded {
+		idx := marketEnv.StartIdx() + i
+		spread += (quotes.Ask[idx] - quotes.Bid[idx]) / prices[idx]
+	}
+	cost := marketEnv.SpreadCost()
+	fmt.Printf("  Spread cost: %.2f (%.2f%% of the initial value)\n", cost, cost/marketEnv.InitialValue()*100)
+	fmt.Printf("  Mean quoted spread: %.3f%%\n", spread/float64(len(traded))*100)
+}
+
 // testAgent is a simple agent that only acts (for testing).
 type testAgent struct {
 	policy agent.Actor
@@ -143,89 +419,42 @@ func (a *testAgent) Act(s state.State) agent.Action {
 	return a.policy.Act(s)
 }
 
-// calculateActionAmountsAndCommission calculates the amount of shares bought or sold and commission paid for a given action.
-func calculateActionAmountsAndCommission(action agent.Action, cash, shares, price, commission float64) (amountBought, amountSold, commissionPaid float64) {
-	switch action {
-	case agent.ActionBuySmall:
-		cost := cash * agent.BuySmall
-		commissionPaid = cost * commission
-		amountBought = (cost - commissionPaid) / price
-		amountSold = 0.0
-	case agent.ActionBuyLarge:
-		cost := cash * agent.BuyLarge
-		commissionPaid = cost * commission
-		amountBought = (cost - commissionPaid) / price
-		amountSold = 0.0
-	case agent.ActionSellSmall:
-		if shares <= 0 {
-			return 0.0, 0.0, 0.0
-		}
-		amountBought = 0.0
-		amountSold = shares * agent.SellSmall
-		proceeds := amountSold * price
-		commissionPaid = proceeds * commission
-	case agent.ActionSellLarge:
-		if shares <= 0 {
-			return 0.0, 0.0, 0.0
-		}
-		amountBought = 0.0
-		amountSold = shares * agent.SellLarge
-		proceeds := amountSold * price
-		commissionPaid = proceeds * commission
-	default:
-		amountBought = 0.0
-		amountSold = 0.0
-		commissionPaid = 0.0
-	}
-	return amountBought, amountSold, commissionPaid
-}
-
-// loadTestPricesFromCSV loads price data from test.csv file.
-// The CSV has columns: MSFT, IBM, SBUX, AAPL, GSPC, Date
-// We'll use GSPC (S&P 500 index) column (index 4) as the price series.
-func loadTestPricesFromCSV(filename string) ([]float64, error) {
-	file, err := os.Open(filename)
+// loadTestPrices loads the selected series in a data split (a whole file when no dataset is used).
+// symbol takes precedence over column; it prints the loader's validation report for
+// the selected series.
+func loadTestPrices(split data.Split, symbol string, column int, policy data.MissingPolicy) (*data.Series, error) {
+	series, reports, err := split.Load(data.Options{Missing: policy})
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	selected, err := data.Select(series, symbol, column)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV: %w", err)
+		return nil, err
 	}
-
-	if len(records) < 2 {
-		return nil, fmt.Errorf("CSV file must have at least a header and one data row")
+	for _, r := range reports {
+		if r.Symbol == selected.Symbol {
+			fmt.Printf("Data validation: %s\n", r)
+		}
 	}
+	return selected, nil
+}
 
-	// GSPC is in column index 4 (5th column)
-	colIds := 0
-	if len(records[0]) <= colIds {
-		return nil, fmt.Errorf("CSV file must have at least 5 columns")
+// checkOverlap prints how the test bars overlap the model's training data and
+// reports whether results may still be reported: only with allow.
+func checkOverlap(overlaps []data.Overlap, allow bool) bool {
+	if len(overlaps) == 0 {
+		return true
 	}
-
-	// Skip header row, start from index 1
-	prices := make([]float64, 0, len(records)-1)
-	for i := 1; i < len(records); i++ {
-		if len(records[i]) <= colIds {
-			continue // Skip rows with insufficient columns
-		}
-
-		// Get GSPC price (index 4)
-		priceStr := records[i][colIds]
-		// Remove commas and quotes from the price string
-		priceStr = strings.ReplaceAll(priceStr, ",", "")
-		priceStr = strings.Trim(priceStr, `"`)
-		price, err := strconv.ParseFloat(priceStr, 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse price at row %d: %w", i+1, err)
-		}
-		if price > 0 {
-			prices = append(prices, price)
-		}
+	label := "Error"
+	if allow {
+		label = "Warning"
 	}
-
-	return prices, nil
+	fmt.Printf("%s: the test data overlaps the model's training data, results are in-sample:\n", label)
+	for _, o := range overlaps {
+		fmt.Printf("  %s\n", o)
+	}
+	if !allow {
+		fmt.Println("Use -allow-overlap to report them anyway")
+	}
+	return allow
 }
@@ -1,20 +1,37 @@
 package main
 
 import (
-	"encoding/csv"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"math"
 	"math/rand"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
 	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/eval"
+	"github.com/kasaderos/rLportfolio/pkg/forecast"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+	"github.com/kasaderos/rLportfolio/pkg/notify"
 	"github.com/kasaderos/rLportfolio/pkg/plot"
+	"github.com/kasaderos/rLportfolio/pkg/registry"
 	"github.com/kasaderos/rLportfolio/pkg/state"
+	"github.com/kasaderos/rLportfolio/pkg/store"
 	"github.com/kasaderos/rLportfolio/pkg/trainer"
 )
 
@@ -24,14 +41,58 @@ const (
 	gamma   = 0.95
 	epsilon = 0.1
 
-	episodes  = 1000
-	minPrices = 50 // Minimum prices needed to start training
+	episodes = 1000
 )
 
 func main() {
 	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed")
 	seriesLength := flag.Int("series-length", 1000, "series length")
 	episodeCount := flag.Int("episode-count", 0, "episode count")
+	dataPath := flag.String("data", "data/train.csv", "training price CSV (any format supported by pkg/data)")
+	seriesOut := flag.String("series-out", "data/series.csv", "output for the test series (.csv, .json, or .parquet)")
+	modelOut := flag.String("model", "data/model", "output for the model bundle (directory, or .json/.json.gz for a single file)")
+	qOut := flag.String("q-out", "", "also write the bare Q-matrix (.csv, .json, .parquet, or .bin) for older tools")
+	missing := flag.String("missing", "drop", "policy for missing or invalid prices: drop, ffill, or error")
+	dataset := flag.String("dataset", "", "named dataset from the catalog; trains on its train split instead of -data")
+	catalogPath := flag.String("catalog", data.DefaultCatalog, "dataset catalog used by -dataset")
+	storePath := flag.String("store", "", "SQLite experiment store to record the run, episodes, and test trades in (optional)")
+	runName := flag.String("run-name", "", "run name in the experiment store")
+	metricsAddr := flag.String("metrics-addr", "", "serve Prometheus metrics on this address at /metrics, e.g. :9100 (optional)")
+	valEvery := flag.Int("val-every", 0, "evaluate the greedy policy on the dataset's val split every N episodes, logging the entropy of its actions over the visited states (0 disables)")
+	earlyStop := flag.Int("early-stop", 0, "stop after N validation runs without a new best return (0 disables; needs -val-every)")
+	notifyURL := flag.String("notify-url", "", "webhook to notify on a new best validation return, early stopping, and completion (optional)")
+	configPath := flag.String("config", "", "YAML config selecting the env, agent, policy, and reward by registered name (optional)")
+	plugins := flag.String("plugin", "", "comma-separated Go plugins (.so) that register components (optional)")
+	notifyFormat := flag.String("notify-format", "json", "webhook payload: json (the event) or slack ({\"text\": ...})")
+	encoderName := flag.String("encoder", "ma", "state encoder: ma (MA ordering), regime (MA50/MA200 cross), or slope (MA slope signs)")
+	slopeLag := flag.Int("slope-lag", state.DefaultSlopeLag, "prices over which the slope encoder measures MA slopes")
+	divergenceQuantiles := flag.String("divergence-quantiles", "", "fit the MA divergence thresholds at these quantiles of the ribbon width change, e.g. 0.33,0.67 (default: fixed 1% threshold)")
+	approxM := flag.Int("approx-m", 0, "add a local approximation forecast of the next return to the state, from windows of this many returns (0 disables)")
+	approxN := flag.Int("approx-n", state.DefaultApproxN, "neighbors averaged by the local approximation forecast of -approx-m")
+	forecastWeights := flag.String("forecast-weights", "", "with -approx-m, categorize the forecast of an ensemble weighted like lam=0.5,trend=0.3,naive=0.2 instead of the local approximation alone")
+	regimeDetector := flag.String("regime-detector", "", "train a separate Q-table per market regime, routed by a meta-policy: trend (MA50/MA200) or vol[:window:thresholds], e.g. vol:20:0.15,0.3 (default: one table)")
+	fxPath := flag.String("fx", "", "price file of FX series converting stocks quoted in other currencies into the base currency (optional; see -fx-map)")
+	fxMap := flag.String("fx-map", "", "FX series of -fx per stock quoted in another currency, e.g. SAP=EURUSD,TM=1/USDJPY (1/ inverts a rate quoted per base currency); other stocks are in the base currency")
+	recordDir := flag.String("record-dir", "", "record every training step to a trajectory file per stock in this directory, <stock>.jsonl, for offline learning and replays (optional)")
+	volNormalize := flag.Bool("vol-normalize", false, "scale every stock's rewards by the inverse of its return volatility, relative to the mean over the stocks, so volatile stocks do not dominate the shared Q-table")
+	samplerName := flag.String("sampler", "sequential", "order of the stocks: sequential (all episodes of one stock, then the next), or a stock picked per episode by round-robin, random, or performance (favoring the stocks with the worst recent returns)")
+	holdout := flag.Float64("holdout", 0, "hold out this fraction of every stock's prices from training, e.g. 0.2; the policy is evaluated on them after training (0 evaluates on the training prices)")
+	evalOut := flag.String("eval-out", "", "output for the per-stock evaluation matrix (.csv, .json, or .parquet; optional)")
+	curveOut := flag.String("curve-out", "", "output for the reward of every training episode split into the return and the trade penalty (.csv, .json, or .parquet; optional)")
+	curvePlot := flag.String("curve-plot", "", "output for the learning curve with the return and the trade penalty stacked (.png or .svg; optional)")
+	curveWindow := flag.Int("curve-window", 20, "episodes averaged by every point of -curve-plot")
+	watchStates := flag.String("watch-states", "", "comma-separated state indices whose Q-values are snapshotted every -watch-every episodes, e.g. 3771,40356 (optional)")
+	watchEvery := flag.Int("watch-every", 50, "episodes between the snapshots of -watch-states")
+	watchOut := flag.String("watch-out", "data/q_watch.csv", "output for the Q-value snapshots of -watch-states (.csv, .json, or .parquet)")
+	tdMaxRMS := flag.Float64("td-max-rms", 0, "raise an alarm when the root mean square TD error of an episode exceeds this (0 disables)")
+	tdGrowth := flag.Float64("td-growth", 0, "raise an alarm when the RMS TD error of an episode grows to this multiple of the lowest before it on the stock, e.g. 100 (0 disables)")
+	tdAbort := flag.Bool("td-abort", false, "stop training at the first TD error alarm of -td-max-rms or -td-growth")
+	evalEpisodes := flag.Int("eval-episodes", 0, "draw this many fixed evaluation episodes from the evaluation prices (the held-out tails with -holdout), stored with the model and the run so other models are compared on the same episodes (0 disables)")
+	evalLength := flag.Int("eval-length", 250, "steps of every -eval-episodes episode")
+	evalSeed := flag.Int64("eval-seed", 1, "seed the -eval-episodes episodes are drawn with, fixed so runs share them")
+	evalEpsilon := flag.Float64("eval-epsilon", 0, "exploration rate of the policy on the -eval-episodes episodes, drawn from their fixed seeds (0 evaluates greedily)")
+	evalProtocol := flag.String("eval-protocol", "", "evaluate on the fixed episodes of an earlier run instead of drawing them: its model bundle or a protocol JSON file (optional)")
+	parallel := flag.Bool("parallel", false, "train on all stocks concurrently, sharing one lock-protected Q-table (not reproducible from -seed)")
 	flag.Parse()
 
 	if *episodeCount <= 0 {
@@ -43,31 +104,204 @@ func main() {
 
 	rng := rand.New(rand.NewSource(*seed))
 
-	// Load all stock data from train.csv
-	stockData, err := loadAllStocksFromCSV("data/train.csv")
+	var sampler trainer.Sampler
+	if *samplerName != "sequential" {
+		if *parallel {
+			fmt.Println("Error: -sampler needs sequential training, not -parallel")
+			return
+		}
+		var err error
+		// A stream of its own, so the agent's draws do not depend on the sampler
+		if sampler, err = trainer.NewSampler(*samplerName, rand.New(rand.NewSource(*seed+1))); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+
+	// Load all stock data
+	missingPolicy, err := data.ParseMissingPolicy(*missing)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	split := data.Split{File: *dataPath}
+	if *dataset != "" {
+		split, err = loadSplit(*catalogPath, *dataset, "train")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		*dataPath = split.File
+	}
+	stockData, stockSeries, err := loadAllStocks(split, missingPolicy)
 	if err != nil {
 		fmt.Printf("Error loading stocks from CSV: %v\n", err)
 		return
 	}
+	stockFX, err := loadFX(*fxPath, *fxMap, stockSeries, missingPolicy)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	// Bars flagged as halted or limit-locked block the trades of the episodes, and
+	// dated bars give the calendar time of every step
+	stockHalts := make(map[string][]bool)
+	stockClocks := make(map[string]*data.Clock)
+	for i := range stockSeries {
+		if halts := stockSeries[i].Halts(); halts != nil {
+			stockHalts[stockSeries[i].Symbol] = halts
+		}
+		if clock, err := data.ClockOf(stockSeries[i].Bars); err == nil {
+			stockClocks[stockSeries[i].Symbol] = clock
+		}
+	}
 
 	if len(stockData) == 0 {
 		fmt.Printf("Error: No stock data found\n")
 		return
 	}
 
-	fmt.Printf("Loaded %d stocks from train.csv\n", len(stockData))
+	// Hold out the tail of every stock for evaluation
+	evalData, evalFX := stockData, stockFX
+	cuts := make(map[string]int)
+	if *holdout > 0 {
+		if *holdout >= 1 {
+			fmt.Println("Error: -holdout must be below 1")
+			return
+		}
+		evalData, evalFX = make(map[string][]float64), make(map[string][]float64)
+		for name, prices := range stockData {
+			cut := int(float64(len(prices)) * (1 - *holdout))
+			cuts[name] = cut
+			stockData[name], evalData[name] = prices[:cut], prices
+			if fx := stockFX[name]; fx != nil {
+				stockFX[name], evalFX[name] = fx[:cut], fx
+			}
+			if halts := stockHalts[name]; halts != nil {
+				stockHalts[name] = halts[:cut]
+			}
+			if clock := stockClocks[name]; clock != nil {
+				stockClocks[name] = clock.Slice(0, cut)
+			}
+		}
+	}
+
+	fmt.Printf("Loaded %d stocks from %s%s\n", len(stockData), *dataPath, describeSplit(*dataset, "train", split))
 	for name, prices := range stockData {
 		fmt.Printf("  %s: %d prices\n", name, len(prices))
 	}
 
-	// Create Q-table and policy (shared across all stocks)
-	Q := agent.NewQTable(state.NumStates, agent.NumActions)
-	policy := agent.NewEpsilonGreedyPolicy(Q.Q, epsilon, rng)
+	// Select the components by registered name
+	components, err := loadComponents(*configPath, *plugins)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	newEnv, err := registry.Envs.Lookup(components.Env.Name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	// Select the state encoder, optionally replacing the fixed divergence threshold
+	// with quantiles of the training data
+	var encoder state.Encoder
+	maEncoder := state.NewMAEncoder()
+	switch *encoderName {
+	case "ma":
+		encoder = maEncoder
+	case "regime":
+		encoder = state.NewRegimeEncoder()
+	case "slope":
+		encoder = state.NewSlopeEncoder(*slopeLag)
+	default:
+		fmt.Printf("Error: unknown encoder %q (use ma, regime, or slope)\n", *encoderName)
+		return
+	}
+	if *divergenceQuantiles != "" {
+		if *encoderName != "ma" {
+			fmt.Println("Error: -divergence-quantiles needs -encoder ma")
+			return
+		}
+		quantiles, err := ma.ParseQuantiles(*divergenceQuantiles)
+		if err != nil || len(quantiles) != 2 {
+			fmt.Printf("Error: -divergence-quantiles needs two quantiles, e.g. 0.33,0.67\n")
+			return
+		}
+		series := make([][]float64, 0, len(stockData))
+		for _, prices := range stockData {
+			series = append(series, prices)
+		}
+		buckets, err := ma.FitDivergence(series, quantiles[0], quantiles[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		maEncoder.Divergence = &buckets
+		fmt.Printf("Divergence thresholds on the ribbon width change: %s\n", buckets)
+	}
+	if *approxM > 0 {
+		if *approxN < 1 {
+			fmt.Println("Error: -approx-n must be positive")
+			return
+		}
+		lamEncoder := state.NewLAMEncoder(encoder, *approxM, *approxN)
+		if *forecastWeights != "" {
+			weights, err := forecast.ParseWeights(*forecastWeights)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			lamEncoder.Weights = &weights
+		}
+		encoder = lamEncoder
+	} else if *forecastWeights != "" {
+		fmt.Println("Error: -forecast-weights needs -approx-m")
+		return
+	}
+	numRegimes := 0
+	if *regimeDetector != "" {
+		detector, err := state.ParseDetector(*regimeDetector)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		encoder = state.NewGatedEncoder(encoder, detector)
+		numRegimes = detector.NumRegimes()
+		fmt.Printf("Training %d regime tables (%s)\n", numRegimes, detector)
+	}
+
+	watched, err := parseStates(*watchStates, encoder.NumStates())
+	if err != nil {
+		fmt.Printf("Error: -watch-states: %v\n", err)
+		return
+	}
+	if len(watched) > 0 && *watchEvery < 1 {
+		fmt.Println("Error: -watch-every must be positive")
+		return
+	}
 
-	// Create agent
-	rlAgent := agent.NewQLearningAgent(Q, policy, alpha, gamma)
+	// Create Q-table, policy, and agent (shared across all stocks)
+	Q := agent.NewQTable(encoder.NumStates(), agent.NumActions)
+	var table agent.ValueFunction = Q
+	var shared *agent.ConcurrentQTable
+	if *parallel {
+		shared = agent.NewConcurrentQTable(encoder.NumStates(), agent.NumActions)
+		table = shared
+	}
+	currentQ := func() [][]float64 {
+		if shared != nil {
+			return shared.Snapshot()
+		}
+		return Q.Q
+	}
+	rlAgent, policy, reward, err := buildAgent(components, table, encoder, rng)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
 
-	// Train on each stock sequentially
+	// Train on each stock sequentially, or concurrently with -parallel
 	episodesPerStock := *episodeCount / len(stockData)
 	if episodesPerStock < 1 {
 		episodesPerStock = 1
@@ -83,29 +317,376 @@ func main() {
 	// Sort for consistent ordering
 	sort.Strings(stockNames)
 
-	for _, stockName := range stockNames {
-		prices := stockData[stockName]
-		if len(prices) < minPrices {
-			fmt.Printf("Skipping %s: Need at least %d prices, got %d\n", stockName, minPrices, len(prices))
-			continue
+	training := model.TrainingConfig{
+		Alpha:        paramOr(components.Agent.Params, "alpha", alpha),
+		Gamma:        paramOr(components.Agent.Params, "gamma", gamma),
+		GammaDays:    paramOr(components.Agent.Params, "gamma_days", 0),
+		Epsilon:      paramOr(components.Policy.Params, "epsilon", epsilon),
+		Episodes:     *episodeCount,
+		SeriesLength: *seriesLength,
+		Seed:         *seed,
+		InitialCash:  10000.0,
+		Commission:   0.002,
+		Data:         *dataPath,
+		Dataset:      *dataset,
+		Symbols:      stockNames,
+		Env:          components.Env.Name,
+		Agent:        components.Agent.Name,
+		Policy:       components.Policy.Name,
+		Reward:       components.Reward.Name,
+	}
+	for _, series := range stockSeries {
+		if prices, ok := stockData[series.Symbol]; ok {
+			training.Coverage = append(training.Coverage, data.CoverageOf(series.Symbol, series.Bars[:len(prices)]))
+		}
+	}
+	if *volNormalize {
+		training.RewardScales = rewardScales(stockData, stockFX)
+		for _, name := range stockNames {
+			fmt.Printf("Reward scale of %s: %.3f\n", name, training.RewardScales[name])
+		}
+		fmt.Println()
+	}
+
+	// Optionally validate periodically on the dataset's val split
+	var valData, valFX map[string][]float64
+	if *valEvery > 0 {
+		if *dataset == "" {
+			fmt.Println("Error: -val-every needs -dataset with a val split")
+			return
+		}
+		valSplit, err := loadSplit(*catalogPath, *dataset, "val")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		var valSeries []data.Series
+		valData, valSeries, err = loadAllStocks(valSplit, missingPolicy)
+		if err == nil {
+			valFX, err = loadFX(*fxPath, *fxMap, valSeries, missingPolicy)
 		}
+		if err != nil {
+			fmt.Printf("Error loading validation data: %v\n", err)
+			return
+		}
+	}
 
-		fmt.Printf("Training on %s (%d prices)...\n", stockName, len(prices))
+	if *earlyStop > 0 && *valEvery <= 0 {
+		fmt.Println("Error: -early-stop needs -val-every")
+		return
+	}
+	var notifier notify.Notifier
+	if *notifyURL != "" {
+		notifier, err = notify.NewWebhook(*notifyURL, *notifyFormat)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+
+	// Optionally expose training progress to Prometheus
+	var trainMetrics *trainer.Metrics
+	if *metricsAddr != "" {
+		trainMetrics = trainer.NewMetrics(episodesPerStock * len(stockNames))
+		trainMetrics.SetEpsilon(exploration(policy))
+		if err := serveMetrics(*metricsAddr, trainMetrics); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Serving metrics on %s/metrics\n", *metricsAddr)
+	}
+
+	// Fix the evaluation episodes before training, so the run records them
+	switch {
+	case *evalProtocol != "":
+		protocol, err := model.LoadProtocol(*evalProtocol)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if d := protocol.Data; d.File != split.File || d.From != split.From || d.To != split.To {
+			fmt.Printf("Error: the evaluation protocol indexes into the prices of %s from %q to %q, not the training prices\n", d.File, d.From, d.To)
+			return
+		}
+		if protocol.FX != *fxPath || protocol.FXMap != *fxMap {
+			fmt.Printf("Error: the evaluation protocol converts the prices by %q of %q, not -fx-map and -fx\n", protocol.FXMap, protocol.FX)
+			return
+		}
+		training.EvalProtocol = protocol
+	case *evalEpisodes > 0:
+		protocol, err := eval.NewProtocol(evalData, cuts, *evalEpisodes, *evalLength, encoder.WarmUp(), *evalSeed)
+		if err != nil {
+			fmt.Printf("Error: -eval-episodes: %v\n", err)
+			return
+		}
+		protocol.Data, protocol.Missing, protocol.Epsilon = split, *missing, *evalEpsilon
+		protocol.FX, protocol.FXMap = *fxPath, *fxMap
+		protocol.InitialCash, protocol.Commission = training.InitialCash, training.Commission
+		training.EvalProtocol = protocol
+	}
+
+	// Optionally record the run in the experiment store
+	var runStore *store.Store
+	var runID int64
+	if *storePath != "" {
+		runStore, runID, err = openRun(*storePath, *runName, *modelOut, training)
+		if err != nil {
+			fmt.Printf("Error opening experiment store: %v\n", err)
+			return
+		}
+		defer runStore.Close()
+		fmt.Printf("Recording run %d in %s\n", runID, *storePath)
+	}
+
+	var mu sync.Mutex // guards the state below, shared by parallel trainers
+	trainedEpisodes := 0
+	var curve [][]string // Reward components of every episode, in training order
+	var curveRewards, curvePenalties []float64
+	var watch [][]string // Q-values of the watched states, every -watch-every episodes
+	bestVal, sinceBest, validated := math.Inf(-1), 0, false
+	stoppedEarly := false
+	var trainers []*trainer.Trainer
+	visits := trainer.NewVisits(encoder.NumStates())
+	// prepareStock sets up the trainer of a stock, or returns nil if the stock is too
+	// short; finish records its episodes once it is done training.
+	prepareStock := func(stockName string, rlAgent agent.Agent, policy agent.Policy) (t *trainer.Trainer, finish func(), err error) {
+		prices := stockData[stockName]
+		if len(prices) < encoder.WarmUp()+2 {
+			fmt.Printf("Skipping %s: Need at least %d prices, got %d\n", stockName, encoder.WarmUp()+2, len(prices))
+			return nil, nil, nil
+		}
 
 		// Create environment for this stock
-		marketEnv := env.NewMarketEnv(env.MarketConfig{
+		stockEnv, err := newEnv(registry.EnvConfig{
 			Prices:      prices,
-			InitialCash: 10000.0,
-			MinStartIdx: 120, // Need at least 120 for MA120
-			Commission:  0.002,
+			InitialCash: training.InitialCash,
+			Commission:  training.Commission,
+			Reward:      reward,
+			Encoder:     encoder,
+			FX:          stockFX[stockName],
+			Halted:      stockHalts[stockName],
+			Clock:       stockClocks[stockName],
+			Params:      components.Env.Params,
 		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create environment for %s: %w", stockName, err)
+		}
+		violations := 0
+		if !components.Constraints.Empty() {
+			if _, ok := env.Market(stockEnv); ok {
+				stockEnv = env.NewEnforcer(stockEnv, components.Constraints, func(env.Violation) { violations++ })
+			}
+		}
+
+		if scale, ok := training.RewardScales[stockName]; ok {
+			stockEnv = env.NewRewardScaler(stockEnv, scale)
+		}
+		var recorder *env.Recorder
+		if *recordDir != "" {
+			recorder, err = env.NewRecorder(stockEnv, filepath.Join(*recordDir, stockName+".jsonl"))
+			if err != nil {
+				return nil, nil, err
+			}
+			stockEnv = recorder
+		}
 
 		// Create trainer
-		t := trainer.NewTrainer(marketEnv, rlAgent)
+		t = trainer.NewTrainer(stockEnv, rlAgent)
+		t.Regimes = numRegimes
+		t.Visits = visits
+		alarms := 0
+		if *tdMaxRMS > 0 || *tdGrowth > 0 {
+			t.TDAlarm = &trainer.TDAlarm{MaxRMS: *tdMaxRMS, Growth: *tdGrowth, Abort: *tdAbort, OnAlarm: func(a trainer.Alarm) {
+				mu.Lock()
+				defer mu.Unlock()
+				if alarms++; alarms > 1 {
+					return
+				}
+				sendNotification(notifier, notify.Event{
+					Kind:    notify.TDAlarm,
+					Run:     *runName,
+					Episode: trainedEpisodes,
+					Message: fmt.Sprintf("TD errors blew up on %s at %s", stockName, a),
+					Values:  map[string]float64{"td_mean": a.TDErrors.Mean, "td_std": a.TDErrors.Std()},
+				})
+				if *tdAbort && !stoppedEarly {
+					fmt.Println("Stopping training at the TD error alarm")
+					stoppedEarly = true
+					for _, t := range trainers {
+						t.Stop()
+					}
+				}
+			}}
+		}
+		mu.Lock()
+		trainers = append(trainers, t)
+		if stoppedEarly {
+			t.Stop()
+		}
+		mu.Unlock()
+		var episodes []store.Episode
+		t.OnEpisode = func(e trainer.EpisodeStats) {
+			mu.Lock()
+			defer mu.Unlock()
+			trainedEpisodes++
+			curve = append(curve, []string{stockName, strconv.Itoa(e.Episode), strconv.FormatFloat(e.Reward, 'f', 6, 64),
+				strconv.FormatFloat(e.Reward-e.TradePenalty, 'f', 6, 64), strconv.FormatFloat(e.TradePenalty, 'f', 6, 64)})
+			curveRewards, curvePenalties = append(curveRewards, e.Reward), append(curvePenalties, e.TradePenalty)
+			if len(watched) > 0 && trainedEpisodes%*watchEvery == 0 {
+				watch = append(watch, watchRows(table, watched, trainedEpisodes)...)
+			}
+			if runStore != nil {
+				episodes = append(episodes, store.Episode{
+					Stock:      stockName,
+					Episode:    e.Episode,
+					Reward:     e.Reward,
+					FinalValue: e.FinalValue,
+					ReturnPct:  e.ReturnPct,
+				})
+			}
+			if trainMetrics != nil {
+				trainMetrics.ObserveEpisode(e)
+				trainMetrics.SetEpsilon(exploration(policy))
+			}
+			if *valEvery > 0 && trainedEpisodes%*valEvery == 0 {
+				Q := currentQ()
+				counts, entropy := trainer.GreedyActions(Q, visits)
+				fmt.Printf("Episode %d: greedy action entropy=%.3f bits over %d visited states (%s)\n",
+					trainedEpisodes, entropy, visits.Count(), describeActions(counts))
+				if trainMetrics != nil {
+					trainMetrics.SetGreedyEntropy(entropy)
+				}
+				valReturn, err := validate(Q, encoder, valData, valFX, training)
+				if err != nil {
+					fmt.Printf("Validation failed: %v\n", err)
+					return
+				}
+				fmt.Printf("Episode %d: validation return=%.2f%%\n", trainedEpisodes, valReturn*100)
+				if trainMetrics != nil {
+					trainMetrics.SetValidationReturn(valReturn)
+				}
+				validated = true
+				if valReturn > bestVal {
+					bestVal, sinceBest = valReturn, 0
+					sendNotification(notifier, notify.Event{
+						Kind:    notify.BestValidation,
+						Run:     *runName,
+						Episode: trainedEpisodes,
+						Message: fmt.Sprintf("New best validation return %.2f%%", valReturn*100),
+						Values:  map[string]float64{"validation_return": valReturn},
+					})
+					return
+				}
+				sinceBest++
+				if *earlyStop > 0 && sinceBest >= *earlyStop && !stoppedEarly {
+					fmt.Printf("Stopping early: no new best validation return in %d runs\n", sinceBest)
+					stoppedEarly = true
+					for _, t := range trainers {
+						t.Stop()
+					}
+					sendNotification(notifier, notify.Event{
+						Kind:    notify.EarlyStopped,
+						Run:     *runName,
+						Episode: trainedEpisodes,
+						Message: fmt.Sprintf("Stopped early after %d episodes", trainedEpisodes),
+						Values:  map[string]float64{"best_validation_return": bestVal},
+					})
+				}
+			}
+		}
 
-		// Train on this stock
+		finish = func() {
+			if alarms > 0 {
+				fmt.Printf("TD error alarms: %d episodes of %s\n", alarms, stockName)
+			}
+			if violations > 0 {
+				fmt.Printf("Constraints cut down %d actions on %s\n", violations, stockName)
+			}
+			if recorder != nil {
+				if err := recorder.Close(); err != nil {
+					fmt.Printf("Failed to record %s: %v\n", stockName, err)
+				}
+			}
+			if runStore != nil {
+				mu.Lock()
+				err := runStore.AddEpisodes(runID, episodes)
+				mu.Unlock()
+				if err != nil {
+					fmt.Printf("Failed to record episodes: %v\n", err)
+				}
+			}
+		}
+		return t, finish, nil
+	}
+	trainStock := func(stockName string, rlAgent agent.Agent, policy agent.Policy) error {
+		t, finish, err := prepareStock(stockName, rlAgent, policy)
+		if t == nil {
+			return err
+		}
+		fmt.Printf("Training on %s (%d prices)...\n", stockName, len(stockData[stockName]))
 		t.Run(episodesPerStock, 100)
+		finish()
 		fmt.Printf("Completed training on %s\n\n", stockName)
+		return nil
+	}
+
+	if *parallel {
+		// Each stock gets its own agent and random stream; they share the table
+		errs := make([]error, len(stockNames))
+		var wg sync.WaitGroup
+		for i, stockName := range stockNames {
+			workerRNG := rand.New(rand.NewSource(*seed + int64(i) + 1))
+			workerAgent, workerPolicy, _, err := buildAgent(components, shared, encoder, workerRNG)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = trainStock(stockName, workerAgent, workerPolicy)
+			}()
+		}
+		wg.Wait()
+		if err := errors.Join(errs...); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		Q.Q = shared.Snapshot()
+	} else if *samplerName != "sequential" {
+		var sampled []*trainer.Trainer
+		var finishers []func()
+		for _, stockName := range stockNames {
+			t, finish, err := prepareStock(stockName, rlAgent, policy)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if t != nil {
+				sampled = append(sampled, t)
+				finishers = append(finishers, finish)
+			}
+		}
+		fmt.Printf("Training on %d stocks, picked per episode by the %s sampler...\n", len(sampled), *samplerName)
+		trainer.RunSampled(sampled, sampler, episodesPerStock*len(stockNames), 100)
+		for _, finish := range finishers {
+			finish()
+		}
+		fmt.Printf("Completed training\n\n")
+	} else {
+		for _, stockName := range stockNames {
+			if err := trainStock(stockName, rlAgent, policy); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if stoppedEarly {
+				break
+			}
+		}
+	}
+
+	if counts, entropy := trainer.GreedyActions(Q.Q, visits); visits.Count() > 0 {
+		fmt.Printf("Greedy action entropy: %.3f bits over %d visited states (%s)\n", entropy, visits.Count(), describeActions(counts))
 	}
 
 	// Test the learned policy on the last stock (or first stock if available)
@@ -123,41 +704,448 @@ func main() {
 		}
 	}
 
-	if len(testPrices) >= minPrices {
+	testReturn := math.NaN()
+	if len(testPrices) >= encoder.WarmUp()+2 {
 		fmt.Printf("\n=== Testing Learned Policy on %s ===\n", testStockName)
 		marketEnv := env.NewMarketEnv(env.MarketConfig{
 			Prices:      testPrices,
 			InitialCash: 10000.0,
-			MinStartIdx: 120, // Need at least 120 for MA120
 			Commission:  0.002,
+			Encoder:     encoder,
+			FX:          stockFX[testStockName],
+			Halted:      stockHalts[testStockName],
 		})
 
-		portfolioSeries, actions, actionData := testPolicy(Q.Q, testPrices, marketEnv)
+		var testEnv env.Environment = marketEnv
+		if !components.Constraints.Empty() {
+			testEnv = env.NewEnforcer(marketEnv, components.Constraints, nil)
+		}
+		portfolioSeries, actions, actionData := testPolicy(Q.Q, testPrices, testEnv)
+		testReturn = portfolioSeries[len(portfolioSeries)-1]/portfolioSeries[0] - 1
 
-		// Save series data to data/series.csv
-		if err := plot.SaveSeriesData(testPrices, portfolioSeries, actions, actionData); err != nil {
+		// Save series data
+		if err := plot.SaveSeriesDataToFile(testPrices, portfolioSeries, actions, actionData, *seriesOut); err != nil {
 			fmt.Printf("Failed to save series: %v\n", err)
 		} else {
-			fmt.Println("Saved series data to data/series.csv")
+			fmt.Printf("Saved series data to %s\n", *seriesOut)
+		}
+
+		if runStore != nil {
+			config := eval.DefaultConfig()
+			config.InitialCash = training.InitialCash
+			config.Commission = training.Commission
+			config.FX = stockFX[testStockName]
+			result, err := eval.Evaluate(Q.Q, encoder, testPrices, config)
+			if err == nil {
+				err = runStore.RecordResult(runID, result)
+			}
+			if err != nil {
+				fmt.Printf("Failed to record test results: %v\n", err)
+			}
+		}
+	}
+
+	if protocol := training.EvalProtocol; protocol != nil {
+		results, err := protocol.Evaluate(Q.Q, encoder, evalData, evalFX, protocol.Config())
+		if err != nil {
+			fmt.Printf("Evaluation on the fixed episodes failed: %v\n", err)
+		} else {
+			s := eval.Summarize(results)
+			fmt.Printf("\n=== Evaluation on %d fixed episodes of %d steps (seed %d) ===\n", s.Episodes, protocol.Length, protocol.Seed)
+			fmt.Printf("  Return: mean %.2f%%, median %.2f%%, worst %.2f%%\n", s.MeanReturn*100, s.MedianReturn*100, s.WorstReturn*100)
+			fmt.Printf("  Mean Sharpe: %.3f, mean max drawdown: %.2f%%, mean trades: %.1f\n", s.MeanSharpe, s.MeanMaxDrawdown*100, s.MeanTrades)
+		}
+	}
+
+	// Evaluate the shared policy on every stock, on the held-out tails after the
+	// encoder's warm-up
+	segment := "training prices"
+	if *holdout > 0 {
+		segment = "held-out prices"
+		for name, cut := range cuts {
+			from := max(cut-encoder.WarmUp(), 0)
+			evalData[name] = evalData[name][from:]
+			if fx := evalFX[name]; fx != nil {
+				evalFX[name] = fx[from:]
+			}
+		}
+	}
+	fmt.Printf("\n=== Evaluation on every stock's %s ===\n", segment)
+	if records, err := evaluateStocks(Q.Q, encoder, evalData, evalFX, training); err != nil {
+		fmt.Printf("Evaluation failed: %v\n", err)
+	} else {
+		printTable(records)
+		if *evalOut != "" {
+			if err := data.WriteTable(*evalOut, records); err != nil {
+				fmt.Printf("Failed to save evaluation: %v\n", err)
+			} else {
+				fmt.Printf("Saved evaluation to %s\n", *evalOut)
+			}
+		}
+	}
+
+	if *curveOut != "" {
+		records := append([][]string{{"stock", "episode", "reward", "return", "trade_penalty"}}, curve...)
+		if err := data.WriteTable(*curveOut, records); err != nil {
+			fmt.Printf("Failed to save the reward components: %v\n", err)
+		} else {
+			fmt.Printf("Saved the reward components of every episode to %s\n", *curveOut)
+		}
+	}
+	if *curvePlot != "" && len(curveRewards) > 0 {
+		if err := plot.SaveLearningCurve(curveRewards, curvePenalties, *curveWindow, *curvePlot); err != nil {
+			fmt.Printf("Failed to plot the learning curve: %v\n", err)
+		} else {
+			fmt.Printf("Saved the learning curve to %s\n", *curvePlot)
 		}
 	}
 
-	// Save Q-matrix to data/q_matrix.csv
-	if err := plot.SaveQMatrixData(Q.Q); err != nil {
-		fmt.Printf("Failed to save Q matrix: %v\n", err)
+	if len(watched) > 0 {
+		header := []string{"episode", "state"}
+		for a := agent.Action(0); a < agent.NumActions; a++ {
+			header = append(header, a.String())
+		}
+		records := append([][]string{append(header, "greedy")}, watch...)
+		if err := data.WriteTable(*watchOut, records); err != nil {
+			fmt.Printf("Failed to save the watched Q-values: %v\n", err)
+		} else {
+			fmt.Printf("Saved %d snapshots of %d watched states to %s\n", len(watch)/len(watched), len(watched), *watchOut)
+		}
+	}
+
+	// Save the model bundle
+	bundle := model.New(Q.Q, encoder, training)
+	if err := bundle.Save(*modelOut); err != nil {
+		fmt.Printf("Failed to save model: %v\n", err)
 	} else {
-		fmt.Println("Saved Q matrix to data/q_matrix.csv")
+		fmt.Printf("Saved model to %s\n", *modelOut)
+	}
+
+	if *qOut != "" {
+		if err := plot.SaveQMatrixDataToFile(Q.Q, *qOut); err != nil {
+			fmt.Printf("Failed to save Q matrix: %v\n", err)
+		} else {
+			fmt.Printf("Saved Q matrix to %s\n", *qOut)
+		}
+	}
+
+	values := map[string]float64{"episodes": float64(trainedEpisodes)}
+	if !math.IsNaN(testReturn) {
+		values["test_return"] = testReturn
+	}
+	if validated {
+		values["best_validation_return"] = bestVal
+	}
+	sendNotification(notifier, notify.Event{
+		Kind:    notify.RunCompleted,
+		Run:     *runName,
+		Episode: trainedEpisodes,
+		Message: fmt.Sprintf("Training completed after %d episodes; model saved to %s", trainedEpisodes, *modelOut),
+		Values:  values,
+	})
+}
+
+// sendNotification delivers an event if a notifier is configured; failures only warn.
+func sendNotification(n notify.Notifier, e notify.Event) {
+	if n == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, e); err != nil {
+		fmt.Printf("Warning: notification failed: %v\n", err)
+	}
+}
+
+// openRun opens the experiment store and creates a training run in it.
+func openRun(path, name, modelPath string, training model.TrainingConfig) (*store.Store, int64, error) {
+	runStore, err := store.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	config, err := json.Marshal(training)
+	if err != nil {
+		runStore.Close()
+		return nil, 0, fmt.Errorf("failed to encode training config: %w", err)
+	}
+	runID, err := runStore.CreateRun(store.Run{
+		Name:   name,
+		Kind:   "train",
+		Data:   training.Data,
+		Symbol: strings.Join(training.Symbols, ","),
+		Model:  modelPath,
+		Seed:   training.Seed,
+		Config: string(config),
+	})
+	if err != nil {
+		runStore.Close()
+		return nil, 0, err
+	}
+	return runStore, runID, nil
+}
+
+// loadComponents loads the plugins and the component config; without a config the
+// built-in components are used.
+func loadComponents(configPath, plugins string) (*registry.Config, error) {
+	for _, path := range strings.Split(plugins, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		if err := registry.LoadPlugin(path); err != nil {
+			return nil, err
+		}
+	}
+	if configPath == "" {
+		return registry.DefaultConfig(), nil
+	}
+	return registry.LoadConfig(configPath)
+}
+
+// buildAgent instantiates the configured reward, policy, and agent around Q.
+// With a state.GatedEncoder every regime's table gets its own policy, routed by an
+// agent.MetaPolicy; the agent learns on the stacked tables, so its updates
+// bootstrap across regime changes.
+func buildAgent(c *registry.Config, Q agent.ValueFunction, encoder state.Encoder, rng *rand.Rand) (agent.Agent, agent.Policy, env.RewardFunc, error) {
+	newReward, err := registry.Rewards.Lookup(c.Reward.Name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	reward, err := newReward(c.Reward.Params)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("reward %s: %w", c.Reward.Name, err)
+	}
+	newPolicy, err := registry.Policies.Lookup(c.Policy.Name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var policy agent.Policy
+	if gated, ok := encoder.(*state.GatedEncoder); ok {
+		tables := agent.RegimeTables(Q, gated.Detector.NumRegimes(), gated.TableStates())
+		policies := make([]agent.Actor, len(tables))
+		for r, table := range tables {
+			if policies[r], err = newPolicy(table, rng, c.Policy.Params); err != nil {
+				return nil, nil, nil, fmt.Errorf("policy %s: %w", c.Policy.Name, err)
+			}
+		}
+		policy = agent.NewMetaPolicy(policies, gated.TableStates())
+	} else if policy, err = newPolicy(Q, rng, c.Policy.Params); err != nil {
+		return nil, nil, nil, fmt.Errorf("policy %s: %w", c.Policy.Name, err)
+	}
+	newAgent, err := registry.Agents.Lookup(c.Agent.Name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rlAgent, err := newAgent(registry.AgentConfig{Q: Q, Policy: policy, Params: c.Agent.Params})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("agent %s: %w", c.Agent.Name, err)
 	}
+	return rlAgent, policy, reward, nil
+}
+
+// describeActions formats the share of every action in counts, e.g.
+// "nothing 60%, buy-small 10%, ...".
+func describeActions(counts []int) string {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	parts := make([]string, len(counts))
+	for a, n := range counts {
+		parts[a] = fmt.Sprintf("%s %.0f%%", agent.Action(a), 100*float64(n)/float64(max(total, 1)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseStates parses comma-separated state indices below numStates.
+func parseStates(s string, numStates int) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var states []int
+	for _, field := range strings.Split(s, ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid state index %q", field)
+		}
+		if index < 0 || index >= numStates {
+			return nil, fmt.Errorf("state index %d out of range [0, %d)", index, numStates)
+		}
+		states = append(states, index)
+	}
+	return states, nil
+}
+
+// watchRows returns a row per watched state with its Q-values after the given
+// number of trained episodes and its greedy action.
+func watchRows(table agent.ValueFunction, states []int, episode int) [][]string {
+	rows := make([][]string, 0, len(states))
+	for _, index := range states {
+		s := state.State{Index: index}
+		row := []string{strconv.Itoa(episode), strconv.Itoa(index)}
+		greedy := agent.ActionNothing
+		for a := agent.Action(0); a < agent.NumActions; a++ {
+			q := table.Get(s, a)
+			if q > table.Get(s, greedy) {
+				greedy = a
+			}
+			row = append(row, strconv.FormatFloat(q, 'f', 6, 64))
+		}
+		rows = append(rows, append(row, greedy.String()))
+	}
+	return rows
+}
+
+// paramOr returns a numeric parameter for the manifest, or def when unset or invalid.
+func paramOr(params registry.Params, key string, def float64) float64 {
+	v, err := params.Float(key, def)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// exploration returns the policy's exploration rate, or 0 when it has none.
+func exploration(policy agent.Policy) float64 {
+	if p, ok := policy.(interface{ Exploration() float64 }); ok {
+		return p.Exploration()
+	}
+	return 0
+}
+
+// serveMetrics serves training metrics at /metrics in the background.
+func serveMetrics(addr string, m *trainer.Metrics) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(lis)
+	return nil
+}
+
+// validate returns the mean fractional return of the greedy policy over the
+// validation series long enough to trade.
+func validate(Q [][]float64, encoder state.Encoder, valData, valFX map[string][]float64, training model.TrainingConfig) (float64, error) {
+	config := eval.DefaultConfig()
+	config.InitialCash = training.InitialCash
+	config.Commission = training.Commission
+	var jobs []eval.Job
+	for name, prices := range valData {
+		if len(prices) < encoder.WarmUp()+2 {
+			continue
+		}
+		jobs = append(jobs, eval.Job{Q: Q, Prices: prices, FX: valFX[name]})
+	}
+	if len(jobs) == 0 {
+		return 0, fmt.Errorf("no validation series has at least %d prices", encoder.WarmUp()+2)
+	}
+	engine := eval.NewEngine(config)
+	engine.Encoder = encoder
+	results, err := engine.Batch(jobs)
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for _, result := range results {
+		total += result.Metrics.TotalReturn
+	}
+	return total / float64(len(results)), nil
+}
+
+// evaluateStocks evaluates the greedy policy on every stock and returns its return,
+// Sharpe ratio, drawdown, and trades next to buy-and-hold, as a table with a header
+// and a final row averaging the stocks.
+func evaluateStocks(Q [][]float64, encoder state.Encoder, evalData, evalFX map[string][]float64, training model.TrainingConfig) ([][]string, error) {
+	config := eval.DefaultConfig()
+	config.InitialCash = training.InitialCash
+	config.Commission = training.Commission
+	var names []string
+	var jobs []eval.Job
+	for name, prices := range evalData {
+		if len(prices) >= encoder.WarmUp()+2 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		jobs = append(jobs, eval.Job{Q: Q, Prices: evalData[name], FX: evalFX[name]})
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no stock has at least %d prices", encoder.WarmUp()+2)
+	}
+	engine := eval.NewEngine(config)
+	engine.Encoder = encoder
+	results, err := engine.Batch(jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	records := [][]string{{"stock", "return", "sharpe", "max_drawdown", "trades", "buy_and_hold"}}
+	var mean [5]float64
+	for i, result := range results {
+		values := env.ToBase(evalData[names[i]], evalFX[names[i]])[result.StartIdx:]
+		hold := values[len(values)-1]/values[0] - 1
+		m := result.Metrics
+		row := [5]float64{m.TotalReturn, m.Sharpe, m.MaxDrawdown, float64(m.NumTrades), hold}
+		for j, v := range row {
+			mean[j] += v / float64(len(results))
+		}
+		records = append(records, statsRow(names[i], row))
+	}
+	return append(records, statsRow("mean", mean)), nil
+}
+
+// statsRow formats a row of evaluateStocks.
+func statsRow(name string, v [5]float64) []string {
+	return []string{name,
+		strconv.FormatFloat(v[0], 'f', 4, 64),
+		strconv.FormatFloat(v[1], 'f', 3, 64),
+		strconv.FormatFloat(v[2], 'f', 4, 64),
+		strconv.FormatFloat(v[3], 'f', 1, 64),
+		strconv.FormatFloat(v[4], 'f', 4, 64)}
+}
+
+// printTable prints a table with aligned columns.
+func printTable(records [][]string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, row := range records {
+		fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+	}
+	w.Flush()
+}
+
+// rewardScales returns the factor scaling every stock's rewards to the mean volatility
+// of the stocks' returns, in the base currency.
+func rewardScales(stockData, stockFX map[string][]float64) map[string]float64 {
+	vols := make(map[string]float64, len(stockData))
+	total := 0.0
+	for name, prices := range stockData {
+		_, std := metrics.MeanStd(metrics.StepReturns(env.ToBase(prices, stockFX[name])))
+		if std > 0 {
+			vols[name] = std
+			total += std
+		}
+	}
+	scales := make(map[string]float64, len(vols))
+	for name, vol := range vols {
+		scales[name] = total / float64(len(vols)) / vol
+	}
+	return scales
 }
 
 // testPolicy tests the learned policy on the price data and returns portfolio value series, actions, and action data.
-func testPolicy(Q [][]float64, prices []float64, marketEnv *env.MarketEnv) ([]float64, []int, []plot.ActionData) {
+// Trades go through testEnv, the market environment or a wrapper of it.
+func testPolicy(Q [][]float64, prices []float64, testEnv env.Environment) ([]float64, []int, []plot.ActionData) {
 	// Create greedy policy for testing
 	greedyPolicy := agent.NewGreedyPolicy(Q)
 	testAgent := &testAgent{policy: greedyPolicy}
 
+	marketEnv, _ := env.Market(testEnv)
+
 	// Reset environment
-	s := marketEnv.Reset()
+	s := testEnv.Reset()
 	done := false
 	actions := make([]int, len(prices))
 	portfolioSeries := make([]float64, len(prices))
@@ -177,18 +1165,14 @@ func testPolicy(Q [][]float64, prices []float64, marketEnv *env.MarketEnv) ([]fl
 		}
 	}
 
-	step := 0
+	// Index by price so the saved series lines up with the prices
+	step := marketEnv.StartIdx()
 	for !done {
 		action := testAgent.Act(s)
-		currentPrice := marketEnv.CurrentPrice()
-		currentCash := marketEnv.Cash()
 		currentShares := marketEnv.Shares()
-		commission := marketEnv.Commission()
+		commissionBefore := marketEnv.CommissionPaid()
 
-		// Calculate buy/sell amounts and commission before executing the action
-		amountBought, amountSold, commissionPaid := calculateActionAmountsAndCommission(action, currentCash, currentShares, currentPrice, commission)
-
-		next, _, d := marketEnv.Step(action)
+		next, _, d := testEnv.Step(action)
 		actions[step] = int(action)
 		portfolioSeries[step+1] = marketEnv.PortfolioValue()
 
@@ -197,14 +1181,15 @@ func testPolicy(Q [][]float64, prices []float64, marketEnv *env.MarketEnv) ([]fl
 		afterShares := marketEnv.Shares()
 
 		// Store action data at step+1 to match portfolioSeries indexing
-		// (step 0 is initial state, step+1 is after first action)
+		// (step is the price index of the decision, step+1 is after the action).
+		// Amounts are what was executed, e.g. nothing for a sell without shares.
 		actionData[step+1] = plot.ActionData{
 			ActionName:   action.String(),
-			AmountBought: amountBought,
-			AmountSold:   amountSold,
+			AmountBought: max(afterShares-currentShares, 0),
+			AmountSold:   max(currentShares-afterShares, 0),
 			Cash:         afterCash,
 			Shares:       afterShares,
-			Commission:   commissionPaid,
+			Commission:   marketEnv.CommissionPaid() - commissionBefore,
 		}
 		s = next
 		done = d
@@ -220,47 +1205,17 @@ func testPolicy(Q [][]float64, prices []float64, marketEnv *env.MarketEnv) ([]fl
 	fmt.Printf("  Return: %.2f%%\n", returnPct)
 	fmt.Printf("  Final cash: %.2f\n", marketEnv.Cash())
 	fmt.Printf("  Final shares: %.2f\n", marketEnv.Shares())
+	fmt.Printf("  Commission paid: %.2f\n", marketEnv.CommissionPaid())
+	if n := marketEnv.BlockedOrders(); n > 0 {
+		fmt.Printf("  Blocked orders: %d (trading halted)\n", n)
+	}
+	if enforcer, ok := testEnv.(*env.Enforcer); ok {
+		fmt.Printf("  Constraint violations: %d\n", enforcer.Violations())
+	}
 
 	return portfolioSeries, actions, actionData
 }
 
-// calculateActionAmountsAndCommission calculates the amount of shares bought or sold and commission paid for a given action.
-func calculateActionAmountsAndCommission(action agent.Action, cash, shares, price, commission float64) (amountBought, amountSold, commissionPaid float64) {
-	switch action {
-	case agent.ActionBuySmall:
-		cost := cash * agent.BuySmall
-		commissionPaid = cost * commission
-		amountBought = (cost - commissionPaid) / price
-		amountSold = 0.0
-	case agent.ActionBuyLarge:
-		cost := cash * agent.BuyLarge
-		commissionPaid = cost * commission
-		amountBought = (cost - commissionPaid) / price
-		amountSold = 0.0
-	case agent.ActionSellSmall:
-		if shares <= 0 {
-			return 0.0, 0.0, 0.0
-		}
-		amountBought = 0.0
-		amountSold = shares * agent.SellSmall
-		proceeds := amountSold * price
-		commissionPaid = proceeds * commission
-	case agent.ActionSellLarge:
-		if shares <= 0 {
-			return 0.0, 0.0, 0.0
-		}
-		amountBought = 0.0
-		amountSold = shares * agent.SellLarge
-		proceeds := amountSold * price
-		commissionPaid = proceeds * commission
-	default:
-		amountBought = 0.0
-		amountSold = 0.0
-		commissionPaid = 0.0
-	}
-	return amountBought, amountSold, commissionPaid
-}
-
 // testAgent is a simple agent that only acts (for testing).
 type testAgent struct {
 	policy agent.Actor
@@ -270,75 +1225,77 @@ func (a *testAgent) Act(s state.State) agent.Action {
 	return a.policy.Act(s)
 }
 
-// loadAllStocksFromCSV loads all stock price data from a CSV file.
-// Returns a map where keys are stock names and values are price arrays.
-// The CSV should have a header row with stock names (excluding Date column).
-func loadAllStocksFromCSV(filename string) (map[string][]float64, error) {
-	file, err := os.Open(filename)
+// loadAllStocks loads the close prices of every stock in a data split (a whole file
+// when no dataset is used), with the series they came from, and prints the loader's
+// validation report.
+func loadAllStocks(split data.Split, policy data.MissingPolicy) (map[string][]float64, []data.Series, error) {
+	series, reports, err := split.Load(data.Options{Missing: policy})
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, nil, err
 	}
-	defer file.Close()
+	printReports(reports)
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	stockData := make(map[string][]float64, len(series))
+	for i := range series {
+		stockData[series[i].Symbol] = series[i].Closes()
 	}
+	return stockData, series, nil
+}
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("CSV file must have at least a header and one data row")
-	}
-
-	// Parse header to find stock columns (exclude Date column)
-	header := records[0]
-	stockIndices := make(map[string]int)
-
-	for i, colName := range header {
-		colName = strings.Trim(colName, `"`)
-		// Skip Date column
-		if strings.ToLower(colName) == "date" {
-			continue
+// loadFX returns the FX rates converting the stocks assigned an FX series in fxMap
+// into the base currency, by symbol, from the FX file at path. Without a path it
+// returns nil: all stocks are in the base currency.
+func loadFX(path, fxMap string, stocks []data.Series, policy data.MissingPolicy) (map[string][]float64, error) {
+	if path == "" {
+		if fxMap != "" {
+			return nil, fmt.Errorf("-fx-map needs -fx")
 		}
-		stockIndices[colName] = i
+		return nil, nil
 	}
-
-	if len(stockIndices) == 0 {
-		return nil, fmt.Errorf("no stock columns found in CSV header")
+	pairs, err := data.ParseFXMap(fxMap)
+	if err != nil {
+		return nil, err
 	}
-
-	// Initialize price arrays for each stock
-	stockData := make(map[string][]float64)
-	for stockName := range stockIndices {
-		stockData[stockName] = make([]float64, 0, len(records)-1)
+	rates, err := data.LoadFXRates(path, pairs, stocks, data.Options{Missing: policy})
+	if err != nil {
+		return nil, err
 	}
-
-	// Parse data rows
-	for i := 1; i < len(records); i++ {
-		row := records[i]
-		if len(row) == 0 {
-			continue
+	for _, stock := range stocks {
+		if _, ok := rates[stock.Symbol]; ok {
+			fmt.Printf("  %s: converted by %s\n", stock.Symbol, pairs[stock.Symbol])
 		}
+	}
+	return rates, nil
+}
 
-		for stockName, colIdx := range stockIndices {
-			if colIdx >= len(row) {
-				continue
-			}
+// loadSplit looks up a split of a named dataset in the catalog.
+func loadSplit(catalogPath, dataset, name string) (data.Split, error) {
+	catalog, err := data.LoadCatalog(catalogPath)
+	if err != nil {
+		return data.Split{}, err
+	}
+	return catalog.Split(dataset, name)
+}
 
-			priceStr := row[colIdx]
-			// Remove commas and quotes from the price string
-			priceStr = strings.ReplaceAll(priceStr, ",", "")
-			priceStr = strings.Trim(priceStr, `"`)
-			price, err := strconv.ParseFloat(priceStr, 64)
-			if err != nil {
-				// Skip invalid prices for this row/stock
-				continue
-			}
-			if price > 0 {
-				stockData[stockName] = append(stockData[stockName], price)
-			}
-		}
+// describeSplit returns " (dataset/split, from .. to)" for a catalog split, or "" without a dataset.
+func describeSplit(dataset, name string, split data.Split) string {
+	if dataset == "" {
+		return ""
 	}
+	from, to := split.From, split.To
+	if from == "" {
+		from = "start"
+	}
+	if to == "" {
+		to = "end"
+	}
+	return fmt.Sprintf(" (%s/%s, %s to %s)", dataset, name, from, to)
+}
 
-	return stockData, nil
+// printReports prints the data validation report of every series.
+func printReports(reports []data.Report) {
+	fmt.Println("Data validation:")
+	for _, r := range reports {
+		fmt.Printf("  %s\n", r)
+	}
 }
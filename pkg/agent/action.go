@@ -1,5 +1,7 @@
 package agent
 
+import "fmt"
+
 // Action represents a portfolio rebalancing action.
 type Action int
 
@@ -58,3 +60,25 @@ func (a Action) String() string {
 		return "unknown"
 	}
 }
+
+// ParseAction returns the action with the given String name.
+func ParseAction(name string) (Action, error) {
+	for a := Action(0); a < NumActions; a++ {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+	return ActionNothing, fmt.Errorf("unknown action %q", name)
+}
+
+// ContinuousAction is a target weight of the asset in the portfolio, in [0, 1]: the
+// environment trades to hold that share of the portfolio value in the asset, 0
+// selling everything and 1 investing all the cash. It is the action of agents
+// with fine-grained sizing, e.g. policy-gradient ones; see Action for the discrete
+// actions of the Q-learning agents.
+type ContinuousAction float64
+
+// Clamp returns the action bounded to [0, 1].
+func (a ContinuousAction) Clamp() ContinuousAction {
+	return min(max(a, 0), 1)
+}
@@ -0,0 +1,185 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// Binary formats start with a 4-byte magic and a version, followed by little-endian
+// fields. Values are written as raw float64 bits, so a round trip is exact.
+const (
+	qTableMagic      = "RLQT"
+	transitionsMagic = "RLTR"
+	binaryVersion    = 1
+)
+
+// WriteQTableBinary writes a Q-matrix as: magic, version (uint16), states (uint32),
+// actions (uint32), then states*actions float64 values in row-major order.
+func WriteQTableBinary(w io.Writer, Q [][]float64) error {
+	numActions := 0
+	if len(Q) > 0 {
+		numActions = len(Q[0])
+	}
+
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 0, qTableHeaderSize)
+	header = append(header, qTableMagic...)
+	header = binary.LittleEndian.AppendUint16(header, binaryVersion)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(Q)))
+	header = binary.LittleEndian.AppendUint32(header, uint32(numActions))
+	if _, err := bw.Write(header); err != nil {
+		return fmt.Errorf("failed to write Q-table header: %w", err)
+	}
+
+	buf := make([]byte, 8*numActions)
+	for s, row := range Q {
+		if len(row) != numActions {
+			return fmt.Errorf("Q-table row %d has %d actions, expected %d", s, len(row), numActions)
+		}
+		for a, v := range row {
+			binary.LittleEndian.PutUint64(buf[8*a:], math.Float64bits(v))
+		}
+		if _, err := bw.Write(buf); err != nil {
+			return fmt.Errorf("failed to write Q-table: %w", err)
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadQTableBinary reads a Q-matrix written by WriteQTableBinary.
+func ReadQTableBinary(r io.Reader) ([][]float64, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, qTableHeaderSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("failed to read Q-table header: %w", err)
+	}
+	numStates, numActions, err := parseQTableHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	// One backing array keeps large tables cheap to allocate
+	values := make([]float64, numStates*numActions)
+	buf := make([]byte, 8*numActions)
+	Q := make([][]float64, numStates)
+	for s := range Q {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, fmt.Errorf("failed to read Q-table row %d: %w", s, err)
+		}
+		row := values[s*numActions : (s+1)*numActions : (s+1)*numActions]
+		for a := range row {
+			row[a] = math.Float64frombits(binary.LittleEndian.Uint64(buf[8*a:]))
+		}
+		Q[s] = row
+	}
+	return Q, nil
+}
+
+// qTableHeaderSize is the size of the binary Q-table header.
+const qTableHeaderSize = 14
+
+// parseQTableHeader checks the header of a binary Q-table and returns its dimensions.
+func parseQTableHeader(header []byte) (numStates, numActions int, err error) {
+	if len(header) < qTableHeaderSize {
+		return 0, 0, fmt.Errorf("binary Q-table header is %d bytes, expected %d", len(header), qTableHeaderSize)
+	}
+	if string(header[:4]) != qTableMagic {
+		return 0, 0, fmt.Errorf("not a binary Q-table (magic %q)", header[:4])
+	}
+	if v := binary.LittleEndian.Uint16(header[4:]); v != binaryVersion {
+		return 0, 0, fmt.Errorf("unsupported binary Q-table version %d", v)
+	}
+	numStates = int(binary.LittleEndian.Uint32(header[6:]))
+	numActions = int(binary.LittleEndian.Uint32(header[10:]))
+	return numStates, numActions, nil
+}
+
+// transitionSize is the encoded size of one transition: two states of five int32
+// fields, the action, the reward, and the done flag.
+const transitionSize = 2*5*4 + 1 + 8 + 1
+
+// WriteTransitions writes transitions as: magic, version (uint16), count (uint32),
+// then one fixed-size record per transition.
+func WriteTransitions(w io.Writer, transitions []Transition) error {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 0, 10)
+	header = append(header, transitionsMagic...)
+	header = binary.LittleEndian.AppendUint16(header, binaryVersion)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(transitions)))
+	if _, err := bw.Write(header); err != nil {
+		return fmt.Errorf("failed to write transitions header: %w", err)
+	}
+
+	buf := make([]byte, 0, transitionSize)
+	for _, t := range transitions {
+		buf = appendState(buf[:0], t.State)
+		buf = append(buf, byte(t.Action))
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(t.Reward))
+		buf = appendState(buf, t.NextState)
+		if t.Done {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+		if _, err := bw.Write(buf); err != nil {
+			return fmt.Errorf("failed to write transitions: %w", err)
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadTransitions reads transitions written by WriteTransitions.
+func ReadTransitions(r io.Reader) ([]Transition, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("failed to read transitions header: %w", err)
+	}
+	if string(header[:4]) != transitionsMagic {
+		return nil, fmt.Errorf("not a binary transitions file (magic %q)", header[:4])
+	}
+	if v := binary.LittleEndian.Uint16(header[4:]); v != binaryVersion {
+		return nil, fmt.Errorf("unsupported binary transitions version %d", v)
+	}
+	count := int(binary.LittleEndian.Uint32(header[6:]))
+
+	transitions := make([]Transition, count)
+	buf := make([]byte, transitionSize)
+	for i := range transitions {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, fmt.Errorf("failed to read transition %d: %w", i, err)
+		}
+		t := &transitions[i]
+		t.State = readState(buf[0:20])
+		t.Action = Action(buf[20])
+		t.Reward = math.Float64frombits(binary.LittleEndian.Uint64(buf[21:]))
+		t.NextState = readState(buf[29:49])
+		t.Done = buf[49] != 0
+	}
+	return transitions, nil
+}
+
+func appendState(buf []byte, s state.State) []byte {
+	for _, v := range [5]int{s.Index, s.MAState, s.MADivergence, s.CashCat, s.SharesCat} {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(int32(v)))
+	}
+	return buf
+}
+
+func readState(buf []byte) state.State {
+	field := func(i int) int {
+		return int(int32(binary.LittleEndian.Uint32(buf[4*i:])))
+	}
+	return state.State{
+		Index:        field(0),
+		MAState:      field(1),
+		MADivergence: field(2),
+		CashCat:      field(3),
+		SharesCat:    field(4),
+	}
+}
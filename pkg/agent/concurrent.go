@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"sync"
+
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// numShards is the number of locks of a ConcurrentQTable; states are spread over
+// them by index.
+const numShards = 64
+
+// ConcurrentQTable is a tabular Q-function that is safe for concurrent use, so
+// several trainers can share one table. Values live in one flat slice guarded by
+// sharded locks.
+type ConcurrentQTable struct {
+	numActions int
+	values     []float64
+	shards     [numShards]sync.RWMutex
+}
+
+// NewConcurrentQTable creates a zeroed table.
+func NewConcurrentQTable(numStates, numActions int) *ConcurrentQTable {
+	return &ConcurrentQTable{numActions: numActions, values: make([]float64, numStates*numActions)}
+}
+
+// NewConcurrentQTableFrom copies a dense Q-table.
+func NewConcurrentQTableFrom(Q [][]float64) *ConcurrentQTable {
+	numActions := 0
+	if len(Q) > 0 {
+		numActions = len(Q[0])
+	}
+	t := NewConcurrentQTable(len(Q), numActions)
+	for s, row := range Q {
+		copy(t.values[s*numActions:], row)
+	}
+	return t
+}
+
+func (q *ConcurrentQTable) shard(index int) *sync.RWMutex {
+	return &q.shards[index%numShards]
+}
+
+// Get returns the Q-value for a state-action pair.
+func (q *ConcurrentQTable) Get(s state.State, a Action) float64 {
+	mu := q.shard(s.Index)
+	mu.RLock()
+	defer mu.RUnlock()
+	return q.values[s.Index*q.numActions+int(a)]
+}
+
+// Set sets the Q-value for a state-action pair.
+func (q *ConcurrentQTable) Set(s state.State, a Action, value float64) {
+	mu := q.shard(s.Index)
+	mu.Lock()
+	defer mu.Unlock()
+	q.values[s.Index*q.numActions+int(a)] = value
+}
+
+// Update replaces the Q-value for a state-action pair with fn of the current one,
+// atomically with respect to other updates.
+func (q *ConcurrentQTable) Update(s state.State, a Action, fn func(old float64) float64) {
+	mu := q.shard(s.Index)
+	mu.Lock()
+	defer mu.Unlock()
+	i := s.Index*q.numActions + int(a)
+	q.values[i] = fn(q.values[i])
+}
+
+// Max returns the maximum Q-value over actions for a given state.
+func (q *ConcurrentQTable) Max(s state.State) float64 {
+	mu := q.shard(s.Index)
+	mu.RLock()
+	defer mu.RUnlock()
+	return MaxValue(q.values[s.Index*q.numActions : (s.Index+1)*q.numActions])
+}
+
+// Snapshot returns a dense copy of the table.
+func (q *ConcurrentQTable) Snapshot() [][]float64 {
+	numStates := 0
+	if q.numActions > 0 {
+		numStates = len(q.values) / q.numActions
+	}
+	Q := make([][]float64, numStates)
+	for s := range Q {
+		mu := q.shard(s)
+		mu.RLock()
+		Q[s] = append([]float64(nil), q.values[s*q.numActions:(s+1)*q.numActions]...)
+		mu.RUnlock()
+	}
+	return Q
+}
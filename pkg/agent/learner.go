@@ -1,6 +1,11 @@
 package agent
 
-import "github.com/kasaderos/rLportfolio/pkg/state"
+import (
+	"math"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
 
 // Learner defines the interface for learning from transitions.
 type Learner interface {
@@ -13,12 +18,26 @@ type Agent interface {
 	Learner
 }
 
+// Updater is a ValueFunction that can apply a read-modify-write atomically, such as
+// ConcurrentQTable. QLearningAgent uses it so concurrent updates are not lost.
+type Updater interface {
+	Update(s state.State, a Action, fn func(old float64) float64)
+}
+
 // QLearningAgent implements Q-learning algorithm.
 type QLearningAgent struct {
 	Q      ValueFunction
 	Policy Policy
 	Alpha  float64 // Learning rate
 	Gamma  float64 // Discount factor
+	// Period, if positive, makes Gamma the discount per Period of calendar time
+	// rather than per step: a transition spanning Elapsed is discounted by
+	// Gamma^(Elapsed/Period), so a step over a weekend or a holiday counts for
+	// more than one within a session, and series of mixed frequencies discount
+	// alike. Transitions without an Elapsed time are discounted by Gamma.
+	Period time.Duration
+
+	td TDStats // TD errors since the last ResetTDErrors
 }
 
 // NewQLearningAgent creates a new Q-learning agent.
@@ -36,8 +55,31 @@ func (a *QLearningAgent) Act(s state.State) Action {
 	return a.Policy.Act(s)
 }
 
+// discount returns the discount of the transition's next value.
+func (a *QLearningAgent) discount(t Transition) float64 {
+	if a.Period <= 0 || t.Elapsed <= 0 {
+		return a.Gamma
+	}
+	return math.Pow(a.Gamma, float64(t.Elapsed)/float64(a.Period))
+}
+
 // Learn updates the Q-function using Q-learning TD update.
 func (a *QLearningAgent) Learn(t Transition) {
+	if u, ok := a.Q.(Updater); ok {
+		var qNext float64
+		if !t.Done {
+			qNext = a.Q.Max(t.NextState)
+		}
+		tdTarget := t.Reward + a.discount(t)*qNext
+		var tdError float64
+		u.Update(t.State, t.Action, func(qCurrent float64) float64 {
+			tdError = tdTarget - qCurrent
+			return qCurrent + a.Alpha*(tdTarget-qCurrent)
+		})
+		a.td.Add(tdError)
+		return
+	}
+
 	// Current Q-value
 	qCurrent := a.Q.Get(t.State, t.Action)
 
@@ -46,12 +88,23 @@ func (a *QLearningAgent) Learn(t Transition) {
 	if !t.Done {
 		qNext = a.Q.Max(t.NextState)
 	}
-	tdTarget := t.Reward + a.Gamma*qNext
+	tdTarget := t.Reward + a.discount(t)*qNext
 
 	// TD error
 	tdError := tdTarget - qCurrent
+	a.td.Add(tdError)
 
 	// Q-learning update: Q(s,a) = Q(s,a) + alpha * (tdTarget - Q(s,a))
 	newValue := qCurrent + a.Alpha*tdError
 	a.Q.Set(t.State, t.Action, newValue)
 }
+
+// TDErrors returns the statistics of the TD errors since the last ResetTDErrors.
+func (a *QLearningAgent) TDErrors() TDStats {
+	return a.td
+}
+
+// ResetTDErrors starts new TD error statistics, e.g. every episode.
+func (a *QLearningAgent) ResetTDErrors() {
+	a.td = TDStats{}
+}
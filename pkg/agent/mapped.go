@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// MappedQTable is a read-only Q-table backed by a memory-mapped binary Q-table file
+// (see WriteQTableBinary). Opening it does not read the values, and the pages are
+// shared by every process that maps the same file, which suits serving large tables.
+type MappedQTable struct {
+	values     []byte // Row-major float64 values after the header
+	numStates  int
+	numActions int
+	unmap      func() error
+}
+
+// OpenMappedQTable maps an uncompressed binary Q-table file. Close unmaps it.
+func OpenMappedQTable(path string) (*MappedQTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Q-table: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat Q-table: %w", err)
+	}
+	size := info.Size()
+	if size < qTableHeaderSize {
+		return nil, fmt.Errorf("binary Q-table %s is too short (%d bytes)", path, size)
+	}
+	content, unmap, err := mapFile(file, int(size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to map Q-table: %w", err)
+	}
+
+	numStates, numActions, err := parseQTableHeader(content)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	if want := int64(qTableHeaderSize) + 8*int64(numStates)*int64(numActions); size != want {
+		unmap()
+		return nil, fmt.Errorf("binary Q-table %s has %d bytes, expected %d for %dx%d values", path, size, want, numStates, numActions)
+	}
+	return &MappedQTable{
+		values:     content[qTableHeaderSize:],
+		numStates:  numStates,
+		numActions: numActions,
+		unmap:      unmap,
+	}, nil
+}
+
+// NumStates returns the number of states.
+func (q *MappedQTable) NumStates() int {
+	return q.numStates
+}
+
+// NumActions returns the number of actions.
+func (q *MappedQTable) NumActions() int {
+	return q.numActions
+}
+
+// value returns Q[s][a]. Values are decoded from their bytes because the header
+// leaves them unaligned for direct float64 access.
+func (q *MappedQTable) value(s, a int) float64 {
+	i := 8 * (s*q.numActions + a)
+	return math.Float64frombits(binary.LittleEndian.Uint64(q.values[i:]))
+}
+
+// Get returns the Q-value for a state-action pair.
+func (q *MappedQTable) Get(s state.State, a Action) float64 {
+	return q.value(s.Index, int(a))
+}
+
+// Set panics: a mapped table is read-only.
+func (q *MappedQTable) Set(s state.State, a Action, value float64) {
+	panic("agent: MappedQTable is read-only")
+}
+
+// Max returns the maximum Q-value over actions for a given state.
+func (q *MappedQTable) Max(s state.State) float64 {
+	maxVal := q.value(s.Index, 0)
+	for a := 1; a < q.numActions; a++ {
+		if v := q.value(s.Index, a); v > maxVal {
+			maxVal = v
+		}
+	}
+	return maxVal
+}
+
+// Row returns a copy of the Q-values of state index s.
+func (q *MappedQTable) Row(s int) []float64 {
+	row := make([]float64, q.numActions)
+	for a := range row {
+		row[a] = q.value(s, a)
+	}
+	return row
+}
+
+// Close unmaps the file. The table must not be used afterwards.
+func (q *MappedQTable) Close() error {
+	if q.unmap == nil {
+		return nil
+	}
+	err := q.unmap()
+	q.unmap, q.values = nil, nil
+	return err
+}
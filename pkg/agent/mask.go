@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"math/rand"
+
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// MaskedActor is an Actor that can restrict its choice to the allowed actions, such
+// as those an environment's position limits permit. allowed has NumActions entries;
+// with none allowed the actor acts unrestricted.
+type MaskedActor interface {
+	ActMasked(s state.State, allowed []bool) Action
+}
+
+// ActWith asks actor for an action among the allowed ones when it supports masks,
+// and for any action when it does not or allowed is nil.
+func ActWith(actor Actor, s state.State, allowed []bool) Action {
+	if m, ok := actor.(MaskedActor); ok && allowed != nil {
+		return m.ActMasked(s, allowed)
+	}
+	return actor.Act(s)
+}
+
+// ArgMaxAllowed returns the index of the maximum allowed value, ties going to the
+// lowest index; it is ArgMax when nothing is allowed.
+func ArgMaxAllowed(arr []float64, allowed []bool) int {
+	best := -1
+	for i, v := range arr {
+		if i < len(allowed) && allowed[i] && (best < 0 || v > arr[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ArgMax(arr)
+	}
+	return best
+}
+
+// randomAllowed returns a uniformly random allowed action, or any action when
+// nothing is allowed.
+func randomAllowed(rng *rand.Rand, allowed []bool) Action {
+	var actions []Action
+	for a := Action(0); a < NumActions; a++ {
+		if int(a) < len(allowed) && allowed[a] {
+			actions = append(actions, a)
+		}
+	}
+	if len(actions) == 0 {
+		return Action(rng.Intn(int(NumActions)))
+	}
+	return actions[rng.Intn(len(actions))]
+}
+
+// ActMasked selects an allowed action using epsilon-greedy strategy.
+func (p *EpsilonGreedyPolicy) ActMasked(s state.State, allowed []bool) Action {
+	if p.RNG.Float64() < p.Epsilon {
+		return randomAllowed(p.RNG, allowed)
+	}
+	return Action(ArgMaxAllowed(p.Q[s.Index], allowed))
+}
+
+// ActMasked selects an allowed action using epsilon-greedy strategy.
+func (p *EpsilonGreedyValuePolicy) ActMasked(s state.State, allowed []bool) Action {
+	if p.RNG.Float64() < p.Epsilon {
+		return randomAllowed(p.RNG, allowed)
+	}
+	values := make([]float64, NumActions)
+	for a := range values {
+		values[a] = p.V.Get(s, Action(a))
+	}
+	return Action(ArgMaxAllowed(values, allowed))
+}
+
+// ActMasked selects the best allowed action according to the Q-table.
+func (p *GreedyPolicy) ActMasked(s state.State, allowed []bool) Action {
+	return Action(ArgMaxAllowed(p.Q[s.Index], allowed))
+}
+
+// ActMasked selects a uniformly random allowed action.
+func (p *RandomPolicy) ActMasked(s state.State, allowed []bool) Action {
+	return randomAllowed(p.RNG, allowed)
+}
+
+// ActMasked selects an allowed action using the policy, if it supports masks.
+func (a *QLearningAgent) ActMasked(s state.State, allowed []bool) Action {
+	return ActWith(a.Policy, s, allowed)
+}
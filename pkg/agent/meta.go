@@ -0,0 +1,113 @@
+package agent
+
+import "github.com/kasaderos/rLportfolio/pkg/state"
+
+// MetaPolicy routes every decision to the policy of the state's regime (see
+// state.GatedEncoder), so each market regime is handled by its own Q-table instead of
+// one global table covering all conditions. The routed policy sees the state with its
+// index local to the regime's table.
+type MetaPolicy struct {
+	Policies    []Actor // Policy of every regime
+	TableStates int     // States of each regime's table
+}
+
+// NewMetaPolicy creates a policy routing regime r to policies[r]; the regimes'
+// tables have tableStates states each.
+func NewMetaPolicy(policies []Actor, tableStates int) *MetaPolicy {
+	return &MetaPolicy{Policies: policies, TableStates: tableStates}
+}
+
+// route returns the policy of the state's regime and the state local to its table.
+// Regimes without a policy go to the first one.
+func (p *MetaPolicy) route(s state.State) (Actor, state.State) {
+	regime := s.Regime
+	if regime < 0 || regime >= len(p.Policies) {
+		regime = 0
+	}
+	s.Index -= s.Regime * p.TableStates
+	return p.Policies[regime], s
+}
+
+// Act selects an action with the policy of the state's regime.
+func (p *MetaPolicy) Act(s state.State) Action {
+	policy, local := p.route(s)
+	return policy.Act(local)
+}
+
+// ActMasked selects an allowed action with the policy of the state's regime, if it
+// supports masks.
+func (p *MetaPolicy) ActMasked(s state.State, allowed []bool) Action {
+	policy, local := p.route(s)
+	return ActWith(policy, local, allowed)
+}
+
+// SetExploration sets the exploration rate of every regime's policy.
+func (p *MetaPolicy) SetExploration(epsilon float64) {
+	for _, policy := range p.Policies {
+		if policy, ok := policy.(Policy); ok {
+			policy.SetExploration(epsilon)
+		}
+	}
+}
+
+// Exploration returns the exploration rate of the first regime's policy that has one.
+func (p *MetaPolicy) Exploration() float64 {
+	for _, policy := range p.Policies {
+		if policy, ok := policy.(interface{ Exploration() float64 }); ok {
+			return policy.Exploration()
+		}
+	}
+	return 0
+}
+
+// RegimeTables splits a value function over the tables of a state.GatedEncoder,
+// stacked in regime order, into one table per regime sharing its values. A QTable
+// is split into QTables over its rows; other value functions into RegimeViews.
+func RegimeTables(V ValueFunction, regimes, tableStates int) []ValueFunction {
+	tables := make([]ValueFunction, regimes)
+	for r := range tables {
+		if dense, ok := V.(*QTable); ok {
+			tables[r] = &QTable{Q: dense.Q[r*tableStates : (r+1)*tableStates]}
+			continue
+		}
+		tables[r] = &RegimeView{V: V, Offset: r * tableStates}
+	}
+	return tables
+}
+
+// RegimeView is the table of one regime within a value function over stacked
+// regime tables: state indices are local to the regime and shifted by Offset.
+type RegimeView struct {
+	V      ValueFunction
+	Offset int
+}
+
+// global returns the state with its index in the stacked tables.
+func (v *RegimeView) global(s state.State) state.State {
+	s.Index += v.Offset
+	return s
+}
+
+// Get returns the value for a state-action pair.
+func (v *RegimeView) Get(s state.State, a Action) float64 {
+	return v.V.Get(v.global(s), a)
+}
+
+// Set sets the value for a state-action pair.
+func (v *RegimeView) Set(s state.State, a Action, value float64) {
+	v.V.Set(v.global(s), a, value)
+}
+
+// Max returns the maximum value over actions for a given state.
+func (v *RegimeView) Max(s state.State) float64 {
+	return v.V.Max(v.global(s))
+}
+
+// Update applies fn atomically if the underlying value function is an Updater.
+func (v *RegimeView) Update(s state.State, a Action, fn func(old float64) float64) {
+	if u, ok := v.V.(Updater); ok {
+		u.Update(v.global(s), a, fn)
+		return
+	}
+	v.Set(s, a, fn(v.Get(s, a)))
+}
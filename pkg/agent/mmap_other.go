@@ -0,0 +1,17 @@
+//go:build !unix
+
+package agent
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the file into memory where mmap is unavailable.
+func mapFile(file *os.File, size int) ([]byte, func() error, error) {
+	content := make([]byte, size)
+	if _, err := io.ReadFull(file, content); err != nil {
+		return nil, nil, err
+	}
+	return content, func() error { return nil }, nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package agent
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps size bytes of file read-only and shared.
+func mapFile(file *os.File, size int) ([]byte, func() error, error) {
+	content, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, func() error { return syscall.Munmap(content) }, nil
+}
@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// Option is a macro-behavior executed over several steps by a fixed low-level
+// controller, the high-level action of OptionAgent.
+type Option int
+
+const (
+	// OptionAccumulate buys small amounts until cash runs out
+	OptionAccumulate Option = iota
+	// OptionHold does nothing
+	OptionHold
+	// OptionDistribute sells small amounts until the shares run out
+	OptionDistribute
+	// OptionExit sells large amounts until the shares run out
+	OptionExit
+)
+
+const NumOptions = 4
+
+// DefaultOptionDuration is the longest an option runs by default, in steps.
+const DefaultOptionDuration = 5
+
+// String returns a human-readable name for the option.
+func (o Option) String() string {
+	switch o {
+	case OptionAccumulate:
+		return "accumulate"
+	case OptionHold:
+		return "hold"
+	case OptionDistribute:
+		return "distribute"
+	case OptionExit:
+		return "exit"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseOption returns the option with the given String name.
+func ParseOption(name string) (Option, error) {
+	for o := Option(0); o < NumOptions; o++ {
+		if o.String() == name {
+			return o, nil
+		}
+	}
+	return OptionHold, fmt.Errorf("unknown option %q", name)
+}
+
+// Control returns the primitive action of the option's controller at step (from 0)
+// of the option in state s, and whether the option terminates after it. Every option
+// terminates after duration steps at the latest; accumulating also ends when cash
+// runs low, and distributing and exiting when the shares do.
+func (o Option) Control(s state.State, step, duration int) (Action, bool) {
+	last := step+1 >= duration
+	switch o {
+	case OptionAccumulate:
+		return ActionBuySmall, last || s.CashCat == state.PosNone
+	case OptionDistribute:
+		return ActionSellSmall, last || s.SharesCat == state.PosNone
+	case OptionExit:
+		return ActionSellLarge, last || s.SharesCat == state.PosNone
+	default:
+		return ActionNothing, last
+	}
+}
+
+// OptionAgent chooses options epsilon-greedily from a value function over options
+// (Q(s, Action(o))) and learns it with SMDP Q-learning: when an option started in s
+// ends after k steps in s', Q(s,o) moves towards R + gamma^k max Q(s',·), where R is
+// the discounted reward collected while it ran. It acts and learns on primitive
+// actions, so it trains with trainer.Trainer on any environment.
+type OptionAgent struct {
+	Q        ValueFunction // Q[state][option]
+	Epsilon  float64
+	RNG      *rand.Rand
+	Alpha    float64
+	Gamma    float64
+	Duration int // Longest option run; 0 means DefaultOptionDuration
+
+	// Started and Steps count the options started and the steps they ran.
+	Started [NumOptions]int
+	Steps   [NumOptions]int
+
+	running  bool
+	option   Option
+	start    state.State
+	step     int
+	ending   bool // The running option terminates after its current step
+	reward   float64
+	discount float64
+}
+
+// NewOptionAgent creates an SMDP Q-learning agent over Q, which needs NumOptions
+// values per state.
+func NewOptionAgent(Q ValueFunction, epsilon float64, rng *rand.Rand, alpha, gamma float64) *OptionAgent {
+	return &OptionAgent{Q: Q, Epsilon: epsilon, RNG: rng, Alpha: alpha, Gamma: gamma}
+}
+
+// Option returns the running option and whether one is running.
+func (a *OptionAgent) Option() (Option, bool) {
+	return a.option, a.running
+}
+
+// Reset abandons the running option without learning from it, e.g. before acting on
+// a new episode without Learn.
+func (a *OptionAgent) Reset() {
+	a.running = false
+}
+
+// Act returns the primitive action of the running option, first choosing a new
+// option when none is running or the last one ended.
+func (a *OptionAgent) Act(s state.State) Action {
+	if !a.running || a.ending {
+		a.begin(s, a.choose(s))
+	}
+	duration := a.Duration
+	if duration < 1 {
+		duration = DefaultOptionDuration
+	}
+	action, last := a.option.Control(s, a.step, duration)
+	a.ending = last
+	a.step++
+	a.Steps[a.option]++
+	return action
+}
+
+// choose selects an option epsilon-greedily.
+func (a *OptionAgent) choose(s state.State) Option {
+	if a.RNG != nil && a.RNG.Float64() < a.Epsilon {
+		return Option(a.RNG.Intn(NumOptions))
+	}
+	values := make([]float64, NumOptions)
+	for o := range values {
+		values[o] = a.Q.Get(s, Action(o))
+	}
+	return Option(ArgMax(values))
+}
+
+// begin starts option o in state s.
+func (a *OptionAgent) begin(s state.State, o Option) {
+	a.running, a.option, a.start = true, o, s
+	a.step, a.ending = 0, false
+	a.reward, a.discount = 0, 1
+	a.Started[o]++
+}
+
+// Learn adds the step's reward to the running option and, when the option or the
+// episode ends, applies the SMDP Q-learning update to the state it started in.
+func (a *OptionAgent) Learn(t Transition) {
+	if !a.running {
+		return
+	}
+	a.reward += a.discount * t.Reward
+	a.discount *= a.Gamma
+	if !a.ending && !t.Done {
+		return
+	}
+
+	var qNext float64
+	if !t.Done {
+		qNext = a.Q.Max(t.NextState)
+	}
+	target := a.reward + a.discount*qNext
+	update := func(qCurrent float64) float64 {
+		return qCurrent + a.Alpha*(target-qCurrent)
+	}
+	if u, ok := a.Q.(Updater); ok {
+		u.Update(a.start, Action(a.option), update)
+	} else {
+		a.Q.Set(a.start, Action(a.option), update(a.Q.Get(a.start, Action(a.option))))
+	}
+	a.running = false
+}
+
+// SetExploration sets the exploration rate of the option choice.
+func (a *OptionAgent) SetExploration(epsilon float64) {
+	a.Epsilon = epsilon
+}
+
+// Exploration returns the exploration rate of the option choice.
+func (a *OptionAgent) Exploration() float64 {
+	return a.Epsilon
+}
@@ -49,6 +49,11 @@ func (p *EpsilonGreedyPolicy) SetExploration(epsilon float64) {
 	p.Epsilon = epsilon
 }
 
+// Exploration returns the exploration rate.
+func (p *EpsilonGreedyPolicy) Exploration() float64 {
+	return p.Epsilon
+}
+
 // greedyAction returns the action with highest Q-value for the state.
 func (p *EpsilonGreedyPolicy) greedyAction(s state.State) Action {
 	return Action(ArgMax(p.Q[s.Index]))
@@ -85,3 +90,21 @@ func (p *GreedyPolicy) Act(s state.State) Action {
 
 // SetExploration is a no-op for greedy policy.
 func (p *GreedyPolicy) SetExploration(epsilon float64) {}
+
+// RandomPolicy selects actions uniformly at random (baseline for evaluation).
+type RandomPolicy struct {
+	RNG *rand.Rand
+}
+
+// NewRandomPolicy creates a new random policy.
+func NewRandomPolicy(rng *rand.Rand) *RandomPolicy {
+	return &RandomPolicy{RNG: rng}
+}
+
+// Act selects a uniformly random action.
+func (p *RandomPolicy) Act(s state.State) Action {
+	return Action(p.RNG.Intn(NumActions))
+}
+
+// SetExploration is a no-op for random policy.
+func (p *RandomPolicy) SetExploration(epsilon float64) {}
@@ -0,0 +1,57 @@
+package agent
+
+import "math/rand"
+
+// ReplayBuffer stores the most recent transitions up to a fixed capacity.
+type ReplayBuffer struct {
+	capacity int
+	items    []Transition
+	next     int // Slot overwritten by the next Add once the buffer is full
+}
+
+// NewReplayBuffer creates an empty replay buffer holding at most capacity transitions.
+func NewReplayBuffer(capacity int) *ReplayBuffer {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &ReplayBuffer{capacity: capacity, items: make([]Transition, 0, capacity)}
+}
+
+// Add stores a transition, replacing the oldest one when the buffer is full.
+func (b *ReplayBuffer) Add(t Transition) {
+	if len(b.items) < b.capacity {
+		b.items = append(b.items, t)
+		return
+	}
+	b.items[b.next] = t
+	b.next = (b.next + 1) % b.capacity
+}
+
+// Len returns the number of stored transitions.
+func (b *ReplayBuffer) Len() int {
+	return len(b.items)
+}
+
+// Capacity returns the maximum number of stored transitions.
+func (b *ReplayBuffer) Capacity() int {
+	return b.capacity
+}
+
+// Sample returns n transitions drawn uniformly with replacement.
+func (b *ReplayBuffer) Sample(n int, rng *rand.Rand) []Transition {
+	if len(b.items) == 0 {
+		return nil
+	}
+	out := make([]Transition, n)
+	for i := range out {
+		out[i] = b.items[rng.Intn(len(b.items))]
+	}
+	return out
+}
+
+// Transitions returns the stored transitions from oldest to newest.
+func (b *ReplayBuffer) Transitions() []Transition {
+	out := make([]Transition, 0, len(b.items))
+	out = append(out, b.items[b.next:]...)
+	return append(out, b.items[:b.next]...)
+}
@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// SparseQTable implements a tabular Q-function that stores only visited states,
+// for state spaces too large to allocate densely. Unvisited state-action pairs have
+// the Default value.
+type SparseQTable struct {
+	Default    float64
+	numActions int
+	rows       map[int][]float64
+}
+
+// NewSparseQTable creates an empty sparse Q-table.
+func NewSparseQTable(numActions int, defaultValue float64) *SparseQTable {
+	return &SparseQTable{Default: defaultValue, numActions: numActions, rows: make(map[int][]float64)}
+}
+
+// NewSparseQTableFrom copies the rows of a dense Q-table that differ from defaultValue.
+func NewSparseQTableFrom(Q [][]float64, defaultValue float64) *SparseQTable {
+	numActions := 0
+	if len(Q) > 0 {
+		numActions = len(Q[0])
+	}
+	t := NewSparseQTable(numActions, defaultValue)
+	for s, row := range Q {
+		for _, v := range row {
+			if v != defaultValue {
+				t.rows[s] = append([]float64(nil), row...)
+				break
+			}
+		}
+	}
+	return t
+}
+
+// Get returns the Q-value for a state-action pair.
+func (q *SparseQTable) Get(s state.State, a Action) float64 {
+	if row, ok := q.rows[s.Index]; ok {
+		return row[int(a)]
+	}
+	return q.Default
+}
+
+// Set sets the Q-value for a state-action pair.
+func (q *SparseQTable) Set(s state.State, a Action, value float64) {
+	row, ok := q.rows[s.Index]
+	if !ok {
+		row = make([]float64, q.numActions)
+		for i := range row {
+			row[i] = q.Default
+		}
+		q.rows[s.Index] = row
+	}
+	row[int(a)] = value
+}
+
+// Max returns the maximum Q-value over actions for a given state.
+func (q *SparseQTable) Max(s state.State) float64 {
+	if row, ok := q.rows[s.Index]; ok {
+		return MaxValue(row)
+	}
+	return q.Default
+}
+
+// Row returns the Q-values of a state; the slice is shared with the table. It
+// returns nil for unvisited states.
+func (q *SparseQTable) Row(index int) []float64 {
+	return q.rows[index]
+}
+
+// Len returns the number of stored states.
+func (q *SparseQTable) Len() int {
+	return len(q.rows)
+}
+
+// States returns the indices of the stored states in increasing order.
+func (q *SparseQTable) States() []int {
+	states := make([]int, 0, len(q.rows))
+	for s := range q.rows {
+		states = append(states, s)
+	}
+	sort.Ints(states)
+	return states
+}
+
+// Range calls fn for every stored state in increasing order until fn returns false.
+func (q *SparseQTable) Range(fn func(index int, values []float64) bool) {
+	for _, s := range q.States() {
+		if !fn(s, q.rows[s]) {
+			return
+		}
+	}
+}
+
+// Dense returns the table as a dense Q-matrix of numStates rows, e.g. to save it as
+// a model bundle.
+func (q *SparseQTable) Dense(numStates int) [][]float64 {
+	Q := make([][]float64, numStates)
+	for s := range Q {
+		if row, ok := q.rows[s]; ok {
+			Q[s] = append([]float64(nil), row...)
+			continue
+		}
+		Q[s] = make([]float64, q.numActions)
+		for i := range Q[s] {
+			Q[s][i] = q.Default
+		}
+	}
+	return Q
+}
+
+// EpsilonGreedyValuePolicy is EpsilonGreedyPolicy over any ValueFunction, e.g. a
+// SparseQTable.
+type EpsilonGreedyValuePolicy struct {
+	V       ValueFunction
+	Epsilon float64
+	RNG     *rand.Rand
+}
+
+// NewEpsilonGreedyValuePolicy creates an epsilon-greedy policy over a value function.
+func NewEpsilonGreedyValuePolicy(v ValueFunction, epsilon float64, rng *rand.Rand) *EpsilonGreedyValuePolicy {
+	return &EpsilonGreedyValuePolicy{V: v, Epsilon: epsilon, RNG: rng}
+}
+
+// Act selects an action using epsilon-greedy strategy.
+func (p *EpsilonGreedyValuePolicy) Act(s state.State) Action {
+	if p.RNG.Float64() < p.Epsilon {
+		return Action(p.RNG.Intn(int(NumActions)))
+	}
+	return GreedyAction(p.V, s)
+}
+
+// SetExploration sets the exploration rate.
+func (p *EpsilonGreedyValuePolicy) SetExploration(epsilon float64) {
+	p.Epsilon = epsilon
+}
+
+// Exploration returns the exploration rate.
+func (p *EpsilonGreedyValuePolicy) Exploration() float64 {
+	return p.Epsilon
+}
+
+// GreedyAction returns the action with the highest value in s; ties go to the
+// lowest action, as in ArgMax.
+func GreedyAction(v ValueFunction, s state.State) Action {
+	best := Action(0)
+	bestValue := v.Get(s, 0)
+	for a := Action(1); a < NumActions; a++ {
+		if value := v.Get(s, a); value > bestValue {
+			best, bestValue = a, value
+		}
+	}
+	return best
+}
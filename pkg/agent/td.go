@@ -0,0 +1,46 @@
+package agent
+
+import "math"
+
+// TDTracker is an agent that keeps statistics of the TD errors of its updates, such
+// as QLearningAgent.
+type TDTracker interface {
+	// TDErrors returns the statistics of the TD errors since the last reset.
+	TDErrors() TDStats
+	// ResetTDErrors starts new statistics.
+	ResetTDErrors()
+}
+
+// TDStats is the running mean and variance of TD errors (Welford's algorithm).
+type TDStats struct {
+	Count int
+	Mean  float64
+	m2    float64 // Sum of squared deviations from the mean
+}
+
+// Add records a TD error.
+func (s *TDStats) Add(tdError float64) {
+	s.Count++
+	delta := tdError - s.Mean
+	s.Mean += delta / float64(s.Count)
+	s.m2 += delta * (tdError - s.Mean)
+}
+
+// Variance returns the population variance of the TD errors, or 0 without any.
+func (s TDStats) Variance() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.m2 / float64(s.Count)
+}
+
+// Std returns the standard deviation of the TD errors.
+func (s TDStats) Std() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// RMS returns the root mean square of the TD errors, their typical size whether
+// they are biased or noisy.
+func (s TDStats) RMS() float64 {
+	return math.Sqrt(s.Variance() + s.Mean*s.Mean)
+}
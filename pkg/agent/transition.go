@@ -1,6 +1,10 @@
 package agent
 
-import "github.com/kasaderos/rLportfolio/pkg/state"
+import (
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
 
 // Transition represents a state-action-reward-nextState transition.
 type Transition struct {
@@ -9,4 +13,7 @@ type Transition struct {
 	Reward    float64
 	NextState state.State
 	Done      bool
+	// Elapsed is the calendar time from State to NextState, or 0 when the bars
+	// are undated.
+	Elapsed time.Duration
 }
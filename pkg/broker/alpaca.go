@@ -0,0 +1,218 @@
+package broker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// AlpacaPaperURL is the Alpaca paper-trading REST API endpoint.
+const AlpacaPaperURL = "https://paper-api.alpaca.markets"
+
+// AlpacaBroker trades an Alpaca account through the v2 REST API.
+type AlpacaBroker struct {
+	BaseURL     string
+	KeyID       string
+	SecretKey   string
+	TimeInForce string // "day" for stocks, "gtc" for crypto
+	HTTPClient  *http.Client
+}
+
+// NewAlpacaBroker creates a broker for the Alpaca paper-trading API.
+func NewAlpacaBroker(keyID, secretKey string) *AlpacaBroker {
+	return &AlpacaBroker{
+		BaseURL:     AlpacaPaperURL,
+		KeyID:       keyID,
+		SecretKey:   secretKey,
+		TimeInForce: "day",
+		HTTPClient:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// alpacaAccount is the subset of GET /v2/account used here.
+type alpacaAccount struct {
+	Cash string `json:"cash"`
+}
+
+// alpacaPosition is the subset of GET /v2/positions used here.
+type alpacaPosition struct {
+	Symbol        string `json:"symbol"`
+	Qty           string `json:"qty"`
+	AvgEntryPrice string `json:"avg_entry_price"`
+	MarketValue   string `json:"market_value"`
+}
+
+// alpacaOrder is the body of POST /v2/orders.
+type alpacaOrder struct {
+	Symbol        string `json:"symbol"`
+	Qty           string `json:"qty,omitempty"`
+	Notional      string `json:"notional,omitempty"`
+	Side          string `json:"side"`
+	Type          string `json:"type"`
+	TimeInForce   string `json:"time_in_force"`
+	ClientOrderID string `json:"client_order_id,omitempty"`
+}
+
+// alpacaActivity is a FILL entry of GET /v2/account/activities.
+type alpacaActivity struct {
+	OrderID         string    `json:"order_id"`
+	Symbol          string    `json:"symbol"`
+	Side            string    `json:"side"`
+	Qty             string    `json:"qty"`
+	Price           string    `json:"price"`
+	TransactionTime time.Time `json:"transaction_time"`
+}
+
+// GetPositions implements Broker.
+func (b *AlpacaBroker) GetPositions(ctx context.Context) (Positions, error) {
+	var account alpacaAccount
+	if err := b.do(ctx, http.MethodGet, "/v2/account", nil, &account); err != nil {
+		return Positions{}, err
+	}
+	cash, err := parseNumber("cash", account.Cash)
+	if err != nil {
+		return Positions{}, err
+	}
+
+	var raw []alpacaPosition
+	if err := b.do(ctx, http.MethodGet, "/v2/positions", nil, &raw); err != nil {
+		return Positions{}, err
+	}
+	p := Positions{Cash: cash, Assets: make([]Position, 0, len(raw))}
+	for _, r := range raw {
+		pos := Position{Symbol: r.Symbol}
+		if pos.Quantity, err = parseNumber("qty", r.Qty); err != nil {
+			return Positions{}, err
+		}
+		if pos.AvgEntryPrice, err = parseNumber("avg_entry_price", r.AvgEntryPrice); err != nil {
+			return Positions{}, err
+		}
+		if pos.MarketValue, err = parseNumber("market_value", r.MarketValue); err != nil {
+			return Positions{}, err
+		}
+		p.Assets = append(p.Assets, pos)
+	}
+	return p, nil
+}
+
+// SubmitOrder implements Broker.
+func (b *AlpacaBroker) SubmitOrder(ctx context.Context, order Order) (string, error) {
+	if err := order.Validate(); err != nil {
+		return "", err
+	}
+	body := alpacaOrder{
+		Symbol:        order.Symbol,
+		Side:          string(order.Side),
+		Type:          "market",
+		TimeInForce:   b.TimeInForce,
+		ClientOrderID: order.ClientOrderID,
+	}
+	if order.Side == Buy {
+		// Alpaca accepts at most two decimals for notional amounts
+		body.Notional = strconv.FormatFloat(order.Notional, 'f', 2, 64)
+	} else {
+		body.Qty = strconv.FormatFloat(order.Quantity, 'f', -1, 64)
+	}
+
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := b.do(ctx, http.MethodPost, "/v2/orders", body, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// GetFills implements Broker.
+func (b *AlpacaBroker) GetFills(ctx context.Context, since time.Time) ([]Fill, error) {
+	params := url.Values{}
+	params.Set("direction", "asc")
+	if !since.IsZero() {
+		params.Set("after", since.UTC().Format(time.RFC3339))
+	}
+	var raw []alpacaActivity
+	if err := b.do(ctx, http.MethodGet, "/v2/account/activities/FILL?"+params.Encode(), nil, &raw); err != nil {
+		return nil, err
+	}
+
+	fills := make([]Fill, 0, len(raw))
+	for _, r := range raw {
+		qty, err := parseNumber("qty", r.Qty)
+		if err != nil {
+			return nil, err
+		}
+		price, err := parseNumber("price", r.Price)
+		if err != nil {
+			return nil, err
+		}
+		side := Buy
+		if r.Side != "buy" {
+			side = Sell
+		}
+		fills = append(fills, Fill{
+			OrderID:  r.OrderID,
+			Symbol:   r.Symbol,
+			Side:     side,
+			Quantity: qty,
+			Price:    price,
+			Time:     r.TransactionTime,
+		})
+	}
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].Time.Before(fills[j].Time) })
+	return fills, nil
+}
+
+// do sends an authenticated request and decodes the JSON response into out.
+func (b *AlpacaBroker) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.BaseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("APCA-API-KEY-ID", b.KeyID)
+	req.Header.Set("APCA-API-SECRET-KEY", b.SecretKey)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call alpaca: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("alpaca returned %s: %s", resp.Status, msg)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode alpaca response: %w", err)
+	}
+	return nil
+}
+
+// parseNumber parses a decimal string field; Alpaca sends numbers as strings.
+func parseNumber(field, s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", field, s, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,89 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Side is the direction of an order.
+type Side string
+
+const (
+	Buy  Side = "buy"
+	Sell Side = "sell"
+)
+
+// Order is a market order. Buys are sized by Notional (cash to spend) and sells by
+// Quantity (shares to sell), matching how the policy's actions are defined.
+type Order struct {
+	Symbol        string
+	Side          Side
+	Quantity      float64
+	Notional      float64
+	ClientOrderID string // Optional idempotency key
+}
+
+// Validate checks that an order is sized for its side.
+func (o Order) Validate() error {
+	if o.Symbol == "" {
+		return fmt.Errorf("order has no symbol")
+	}
+	switch o.Side {
+	case Buy:
+		if o.Notional <= 0 {
+			return fmt.Errorf("buy order needs a positive notional, got %g", o.Notional)
+		}
+	case Sell:
+		if o.Quantity <= 0 {
+			return fmt.Errorf("sell order needs a positive quantity, got %g", o.Quantity)
+		}
+	default:
+		return fmt.Errorf("unknown order side %q", o.Side)
+	}
+	return nil
+}
+
+// Position is the holding of one symbol.
+type Position struct {
+	Symbol        string
+	Quantity      float64
+	AvgEntryPrice float64
+	MarketValue   float64
+}
+
+// Positions is the account cash and its holdings.
+type Positions struct {
+	Cash   float64
+	Assets []Position
+}
+
+// Quantity returns the shares held of symbol, or 0.
+func (p Positions) Quantity(symbol string) float64 {
+	for _, a := range p.Assets {
+		if a.Symbol == symbol {
+			return a.Quantity
+		}
+	}
+	return 0
+}
+
+// Fill is an execution reported by the broker.
+type Fill struct {
+	OrderID  string
+	Symbol   string
+	Side     Side
+	Quantity float64
+	Price    float64
+	Time     time.Time
+}
+
+// Broker routes orders to a brokerage account.
+type Broker interface {
+	// GetPositions returns the account cash and holdings.
+	GetPositions(ctx context.Context) (Positions, error)
+	// SubmitOrder submits a market order and returns the broker's order ID.
+	SubmitOrder(ctx context.Context, order Order) (string, error)
+	// GetFills returns the executions after since, oldest first.
+	GetFills(ctx context.Context, since time.Time) ([]Fill, error)
+}
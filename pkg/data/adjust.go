@@ -0,0 +1,194 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Corporate action types.
+const (
+	ActionSplit    = "split"
+	ActionDividend = "dividend"
+)
+
+// CorporateAction is a split or cash dividend taking effect on its ex-date.
+type CorporateAction struct {
+	Symbol string // Empty applies the action to every series
+	Date   time.Time
+	Type   string  // ActionSplit or ActionDividend
+	Value  float64 // New shares per old share for splits, cash per share for dividends
+}
+
+// LoadCorporateActions loads corporate actions from a CSV, JSON, or Parquet table with
+// columns Date, Type, Value and an optional Symbol. Split values may be written as a
+// ratio ("2:1", "1/10") or as new shares per old share ("2").
+func LoadCorporateActions(filename string) ([]CorporateAction, error) {
+	records, err := ReadTable(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("corporate actions file must have a header and at least one row")
+	}
+
+	symbolCol, dateCol, typeCol, valueCol := -1, -1, -1, -1
+	for i, name := range records[0] {
+		switch normalizeHeader(name) {
+		case "symbol", "ticker":
+			symbolCol = i
+		case "date", "ex-date", "ex_date":
+			dateCol = i
+		case "type", "action":
+			typeCol = i
+		case "value", "ratio", "amount":
+			valueCol = i
+		}
+	}
+	if dateCol < 0 || typeCol < 0 || valueCol < 0 {
+		return nil, fmt.Errorf("corporate actions header must have Date, Type, and Value columns, got %v", records[0])
+	}
+
+	actions := make([]CorporateAction, 0, len(records)-1)
+	for i := 1; i < len(records); i++ {
+		row := records[i]
+		date, err := ParseDate(field(row, dateCol))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		action := CorporateAction{
+			Symbol: strings.TrimSpace(field(row, symbolCol)),
+			Date:   date,
+			Type:   strings.ToLower(strings.TrimSpace(field(row, typeCol))),
+		}
+		switch action.Type {
+		case ActionSplit:
+			action.Value, err = parseSplitRatio(field(row, valueCol))
+		case ActionDividend:
+			action.Value, err = ParsePrice(field(row, valueCol))
+		default:
+			err = fmt.Errorf("unknown action type %q (use split or dividend)", action.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		if action.Value <= 0 {
+			return nil, fmt.Errorf("row %d: %s value must be positive", i+1, action.Type)
+		}
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
+
+// parseSplitRatio parses "2:1", "1/10", or "2" as new shares per old share.
+func parseSplitRatio(s string) (float64, error) {
+	s = strings.TrimSpace(strings.Trim(s, `"`))
+	for _, sep := range []string{":", "/", "-for-"} {
+		if parts := strings.SplitN(s, sep, 2); len(parts) == 2 {
+			newShares, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+			oldShares, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+			if err1 != nil || err2 != nil || oldShares == 0 {
+				return 0, fmt.Errorf("invalid split ratio %q", s)
+			}
+			return newShares / oldShares, nil
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid split ratio %q", s)
+	}
+	return v, nil
+}
+
+// AdjustForActions back-adjusts a series for the actions of its symbol, so prices before
+// each ex-date are comparable with the latest prices. Splits divide earlier prices by
+// the ratio (and multiply volumes); dividends scale earlier prices by 1 - D/C, where C
+// is the close before the ex-date. It returns the number of actions applied.
+func AdjustForActions(s *Series, actions []CorporateAction) (int, error) {
+	if !hasTimes(s.Bars) {
+		return 0, fmt.Errorf("series %s has bars without dates", s.Symbol)
+	}
+
+	sorted := make([]CorporateAction, 0, len(actions))
+	for _, a := range actions {
+		if a.Symbol == "" || a.Symbol == s.Symbol {
+			sorted = append(sorted, a)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	// Factors are computed from the raw prices first, then applied together
+	priceFactors := make([]float64, len(s.Bars))
+	volumeFactors := make([]float64, len(s.Bars))
+	for i := range priceFactors {
+		priceFactors[i] = 1
+		volumeFactors[i] = 1
+	}
+
+	applied := 0
+	for _, a := range sorted {
+		// First bar on or after the ex-date; bars before it get adjusted
+		exIdx := sort.Search(len(s.Bars), func(i int) bool {
+			return !s.Bars[i].Time.Before(a.Date)
+		})
+		if exIdx == 0 {
+			continue
+		}
+
+		priceFactor, volumeFactor := 1.0, 1.0
+		switch a.Type {
+		case ActionSplit:
+			priceFactor = 1 / a.Value
+			volumeFactor = a.Value
+		case ActionDividend:
+			prevClose := s.Bars[exIdx-1].Close
+			if a.Value >= prevClose {
+				return applied, fmt.Errorf("dividend %.4f on %s is not below the previous close %.4f",
+					a.Value, a.Date.Format("2006-01-02"), prevClose)
+			}
+			priceFactor = 1 - a.Value/prevClose
+		default:
+			return applied, fmt.Errorf("unknown action type %q", a.Type)
+		}
+
+		for i := 0; i < exIdx; i++ {
+			priceFactors[i] *= priceFactor
+			volumeFactors[i] *= volumeFactor
+		}
+		applied++
+	}
+
+	for i := range s.Bars {
+		scaleBar(&s.Bars[i], priceFactors[i], volumeFactors[i])
+	}
+	return applied, nil
+}
+
+// AdjustFromAdjClose rescales every bar so its close equals the vendor's adjusted close
+// (e.g. Yahoo "Adj Close"), applying the same factor to open, high, and low.
+func AdjustFromAdjClose(s *Series) error {
+	for i, b := range s.Bars {
+		if b.AdjClose <= 0 {
+			return fmt.Errorf("series %s has no adjusted close at bar %d", s.Symbol, i)
+		}
+	}
+	for i := range s.Bars {
+		factor := s.Bars[i].AdjClose / s.Bars[i].Close
+		scaleBar(&s.Bars[i], factor, 1/factor)
+	}
+	return nil
+}
+
+// scaleBar multiplies the prices of a bar by priceFactor and its volume by volumeFactor.
+func scaleBar(b *Bar, priceFactor, volumeFactor float64) {
+	b.Open *= priceFactor
+	b.High *= priceFactor
+	b.Low *= priceFactor
+	b.Close *= priceFactor
+	b.Volume *= volumeFactor
+}
@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// BinanceBaseURL is the Binance spot REST API endpoint.
+const BinanceBaseURL = "https://api.binance.com"
+
+// binanceKlineLimit is the maximum number of klines Binance returns per request.
+const binanceKlineLimit = 1000
+
+// BinanceIntervals maps the supported kline intervals to their durations.
+var BinanceIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+	"3d":  3 * 24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+// BinanceClient downloads klines from the Binance REST API.
+type BinanceClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewBinanceClient creates a client for the public Binance spot API.
+func NewBinanceClient() *BinanceClient {
+	return &BinanceClient{
+		BaseURL:    BinanceBaseURL,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// FetchKlines downloads all klines of symbol (e.g. "BTCUSDT") at the given interval
+// with open times in [start, end), paging through the API as needed.
+func (c *BinanceClient) FetchKlines(ctx context.Context, symbol, interval string, start, end time.Time) (Series, error) {
+	step, ok := BinanceIntervals[interval]
+	if !ok {
+		return Series{}, fmt.Errorf("unsupported interval %q", interval)
+	}
+	if !start.Before(end) {
+		return Series{}, fmt.Errorf("start %s must be before end %s", start, end)
+	}
+
+	s := Series{Symbol: symbol}
+	for from := start; from.Before(end); {
+		bars, err := c.fetchPage(ctx, symbol, interval, from, end)
+		if err != nil {
+			return Series{}, err
+		}
+		if len(bars) == 0 {
+			break
+		}
+		s.Bars = append(s.Bars, bars...)
+		from = bars[len(bars)-1].Time.Add(step)
+		if len(bars) < binanceKlineLimit {
+			break
+		}
+	}
+
+	return s, nil
+}
+
+// fetchPage requests one page of klines starting at from.
+func (c *BinanceClient) fetchPage(ctx context.Context, symbol, interval string, from, end time.Time) ([]Bar, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("interval", interval)
+	params.Set("startTime", strconv.FormatInt(from.UnixMilli(), 10))
+	params.Set("endTime", strconv.FormatInt(end.UnixMilli()-1, 10))
+	params.Set("limit", strconv.Itoa(binanceKlineLimit))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/api/v3/klines?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch klines: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("binance returned %s: %s", resp.Status, body)
+	}
+
+	return ParseBinanceKlines(resp.Body)
+}
+
+// ParseBinanceKlines decodes the JSON array returned by /api/v3/klines.
+// Each kline is [openTime, open, high, low, close, volume, closeTime, ...] with prices as strings.
+func ParseBinanceKlines(r io.Reader) ([]Bar, error) {
+	var raw [][]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode klines: %w", err)
+	}
+
+	bars := make([]Bar, 0, len(raw))
+	for i, k := range raw {
+		if len(k) < 6 {
+			return nil, fmt.Errorf("kline %d has %d fields, want at least 6", i, len(k))
+		}
+		fields := make([]string, 6)
+		for j := range fields {
+			var v interface{}
+			if err := json.Unmarshal(k[j], &v); err != nil {
+				return nil, fmt.Errorf("kline %d field %d: %w", i, j, err)
+			}
+			fields[j] = fmt.Sprint(v)
+		}
+		bar, err := parseBinanceFields(fields)
+		if err != nil {
+			return nil, fmt.Errorf("kline %d: %w", i, err)
+		}
+		bars = append(bars, bar)
+	}
+
+	return bars, nil
+}
+
+// parseBinanceFields parses openTime (ms or µs), open, high, low, close, volume.
+func parseBinanceFields(fields []string) (Bar, error) {
+	openTime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Bar{}, fmt.Errorf("invalid open time %q: %w", fields[0], err)
+	}
+	if openTime > 1e14 {
+		// Newer Binance dumps use microseconds
+		openTime /= 1000
+	}
+	values := make([]float64, 5)
+	for i := range values {
+		values[i], err = strconv.ParseFloat(fields[i+1], 64)
+		if err != nil {
+			return Bar{}, fmt.Errorf("invalid value %q: %w", fields[i+1], err)
+		}
+	}
+	return Bar{
+		Time:   time.UnixMilli(int64(openTime)).UTC(),
+		Open:   values[0],
+		High:   values[1],
+		Low:    values[2],
+		Close:  values[3],
+		Volume: values[4],
+	}, nil
+}
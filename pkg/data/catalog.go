@@ -0,0 +1,172 @@
+package data
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultCatalog is the dataset catalog the commands read by default.
+const DefaultCatalog = "data/datasets.yaml"
+
+// Catalog declares named datasets and their splits, e.g.
+//
+//	datasets:
+//	  stocks:
+//	    file: data/train.csv
+//	    splits:
+//	      train: {to: 2013-12-31}
+//	      val:   {from: 2014-01-01}
+//	      test:  {file: data/test.csv}
+type Catalog struct {
+	Datasets map[string]Dataset `yaml:"datasets"`
+	dir      string             // Directory of the catalog file, for relative paths
+}
+
+// Dataset is one named dataset. Splits inherit File and Symbols unless they set their own.
+type Dataset struct {
+	Description string           `yaml:"description"`
+	File        string           `yaml:"file"`
+	Symbols     []string         `yaml:"symbols"`
+	Splits      map[string]Split `yaml:"splits"`
+}
+
+// Split is a named date range (inclusive) of a dataset file.
+type Split struct {
+	File    string   `yaml:"file" json:"file"`
+	From    string   `yaml:"from" json:"from,omitempty"`
+	To      string   `yaml:"to" json:"to,omitempty"`
+	Symbols []string `yaml:"symbols" json:"symbols,omitempty"`
+}
+
+// Range returns the parsed bounds of the split. A date without a time of day makes
+// To cover that whole day. Missing bounds are zero.
+func (s Split) Range() (from, to time.Time, err error) {
+	if s.From != "" {
+		if from, err = ParseDate(s.From); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if s.To != "" {
+		if to, err = ParseDate(s.To); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+		}
+		if to.Equal(to.Truncate(24 * time.Hour)) {
+			to = to.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+	return from, to, nil
+}
+
+// Load loads the split's file and trims every series to the split's date range and
+// symbols. A split with only File set loads the whole file.
+func (s Split) Load(opts Options) ([]Series, []Report, error) {
+	from, to, err := s.Range()
+	if err != nil {
+		return nil, nil, err
+	}
+	series, reports, _, err := LoadWithOptions(s.File, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(s.Symbols) > 0 {
+		kept := make([]Series, 0, len(s.Symbols))
+		for _, symbol := range s.Symbols {
+			found, ok := Find(series, symbol)
+			if !ok {
+				return nil, nil, fmt.Errorf("symbol %s not found in %s (available: %s)", symbol, s.File, strings.Join(Symbols(series), ", "))
+			}
+			kept = append(kept, *found)
+		}
+		series = kept
+	}
+	for i := range series {
+		series[i] = Between(series[i], from, to)
+		if series[i].Len() == 0 {
+			return nil, nil, fmt.Errorf("no %s bars in %s between %s and %s", series[i].Symbol, s.File, s.From, s.To)
+		}
+	}
+	return series, reports, nil
+}
+
+// LoadCatalog reads a dataset catalog. Relative file paths are resolved against the
+// working directory first and then against the catalog's directory.
+func LoadCatalog(filename string) (*Catalog, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	catalog := &Catalog{dir: filepath.Dir(filename)}
+	if err := yaml.Unmarshal(content, catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog %s: %w", filename, err)
+	}
+	if len(catalog.Datasets) == 0 {
+		return nil, fmt.Errorf("catalog %s declares no datasets", filename)
+	}
+	return catalog, nil
+}
+
+// Names returns the dataset names in sorted order.
+func (c *Catalog) Names() []string {
+	names := make([]string, 0, len(c.Datasets))
+	for name := range c.Datasets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Split returns a split of a dataset with the dataset's file and symbols filled in
+// and validates its date range.
+func (c *Catalog) Split(dataset, split string) (Split, error) {
+	ds, ok := c.Datasets[dataset]
+	if !ok {
+		return Split{}, fmt.Errorf("unknown dataset %q (available: %s)", dataset, strings.Join(c.Names(), ", "))
+	}
+	s, ok := ds.Splits[split]
+	if !ok {
+		names := make([]string, 0, len(ds.Splits))
+		for name := range ds.Splits {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return Split{}, fmt.Errorf("dataset %q has no %q split (available: %s)", dataset, split, strings.Join(names, ", "))
+	}
+
+	if s.File == "" {
+		s.File = ds.File
+	}
+	if s.File == "" {
+		return Split{}, fmt.Errorf("dataset %q split %q has no file", dataset, split)
+	}
+	s.File = c.resolve(s.File)
+	if len(s.Symbols) == 0 {
+		s.Symbols = ds.Symbols
+	}
+
+	from, to, err := s.Range()
+	if err != nil {
+		return Split{}, fmt.Errorf("dataset %q split %q: %w", dataset, split, err)
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return Split{}, fmt.Errorf("dataset %q split %q ends before it starts", dataset, split)
+	}
+	return s, nil
+}
+
+// resolve returns a usable path for a file named in the catalog.
+func (c *Catalog) resolve(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	if _, err := os.Stat(file); err == nil {
+		return file
+	}
+	return filepath.Join(c.dir, file)
+}
@@ -0,0 +1,115 @@
+package data
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultTradingDays is the number of trading sessions per year assumed when a clock
+// spans too little time to measure it (the same as metrics.TradingDaysPerYear).
+const DefaultTradingDays = 252
+
+// minClockSpan is the calendar time a clock needs to measure sessions per year;
+// shorter series are assumed to trade DefaultTradingDays sessions.
+const minClockSpan = 90 * 24 * time.Hour
+
+// Clock maps simulation steps to the timestamps of their bars. Steps need not be a
+// day apart: a daily series skips weekends and market holidays, and an intraday one
+// has gaps between sessions, so annualization factors are measured from the
+// timestamps instead of assuming one step per trading day.
+type Clock struct {
+	times []time.Time
+}
+
+// NewClock creates a clock of strictly increasing timestamps, one per step.
+func NewClock(times []time.Time) (*Clock, error) {
+	for i, t := range times {
+		if t.IsZero() {
+			return nil, fmt.Errorf("step %d has no timestamp", i)
+		}
+		if i > 0 && !t.After(times[i-1]) {
+			return nil, fmt.Errorf("timestamps not increasing at step %d: %s after %s",
+				i, t.Format(time.RFC3339), times[i-1].Format(time.RFC3339))
+		}
+	}
+	return &Clock{times: times}, nil
+}
+
+// ClockOf creates the clock of chronologically sorted bars.
+func ClockOf(bars []Bar) (*Clock, error) {
+	times := make([]time.Time, len(bars))
+	for i, b := range bars {
+		times[i] = b.Time
+	}
+	return NewClock(times)
+}
+
+// Len returns the number of steps.
+func (c *Clock) Len() int {
+	return len(c.times)
+}
+
+// Time returns the timestamp of step i.
+func (c *Clock) Time(i int) time.Time {
+	return c.times[i]
+}
+
+// Elapsed returns the time from step i-1 to step i, or 0 for the first step.
+func (c *Clock) Elapsed(i int) time.Duration {
+	if i <= 0 {
+		return 0
+	}
+	return c.times[i].Sub(c.times[i-1])
+}
+
+// Slice returns the clock of steps from to to (exclusive), like a slice expression.
+func (c *Clock) Slice(from, to int) *Clock {
+	return &Clock{times: c.times[from:to]}
+}
+
+// BarsPerSession returns the mean number of steps per calendar day with any step:
+// 1 for daily bars, e.g. 390 for a full session of minute bars.
+func (c *Clock) BarsPerSession() float64 {
+	if len(c.times) == 0 {
+		return 1
+	}
+	return float64(len(c.times)) / float64(c.sessions())
+}
+
+// SessionsPerYear returns the number of calendar days with steps per year, e.g.
+// about 252 for a stock and 365 for a cryptocurrency, measured over the clock's
+// span. Clocks spanning less than 90 days assume DefaultTradingDays.
+func (c *Clock) SessionsPerYear() float64 {
+	if len(c.times) < 2 {
+		return DefaultTradingDays
+	}
+	span := c.times[len(c.times)-1].Sub(c.times[0])
+	if span < minClockSpan {
+		return DefaultTradingDays
+	}
+	years := span.Hours() / (365.25 * 24)
+	// The span covers the gaps between the sessions, one fewer than their number
+	return float64(c.sessions()-1) / years
+}
+
+// PeriodsPerYear returns the number of steps per year, which annualizes per-step
+// statistics: about 252 for daily stock bars, 52 for weekly bars, or 252·390 for
+// minute bars of full sessions.
+func (c *Clock) PeriodsPerYear() float64 {
+	return c.BarsPerSession() * c.SessionsPerYear()
+}
+
+// sessions returns the number of distinct calendar days with steps.
+func (c *Clock) sessions() int {
+	n := 0
+	var last time.Time
+	for _, t := range c.times {
+		y, m, d := t.Date()
+		day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		if n == 0 || !day.Equal(last) {
+			n++
+			last = day
+		}
+	}
+	return n
+}
@@ -0,0 +1,448 @@
+package data
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format identifies a CSV layout.
+type Format string
+
+const (
+	// FormatYahoo is Date,Open,High,Low,Close,Adj Close,Volume.
+	FormatYahoo Format = "yahoo"
+	// FormatNasdaq is Date,Close/Last,Volume,Open,High,Low with $-prefixed prices, newest first.
+	FormatNasdaq Format = "nasdaq"
+	// FormatStooq is Date,Open,High,Low,Close,Volume.
+	FormatStooq Format = "stooq"
+	// FormatInvesting is Date,Price,Open,High,Low,Vol.,Change % (investing.com export).
+	FormatInvesting Format = "investing"
+	// FormatWide has one close-price column per symbol plus a Date column (train.csv layout).
+	FormatWide Format = "wide"
+	// FormatBinance is the headerless Binance klines dump (openTime,open,high,low,close,volume,...).
+	FormatBinance Format = "binance"
+)
+
+// dateLayouts are tried in order when parsing dates.
+var dateLayouts = []string{
+	"2006-01-02",
+	"01/02/2006",
+	"1/2/2006",
+	"2006/01/02",
+	"20060102",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"Jan 02, 2006",
+}
+
+// columns holds the column indices of an OHLCV layout (-1 if absent).
+type columns struct {
+	date, open, high, low, close, adjClose, volume, halted int
+}
+
+// normalizeHeader lowercases and trims quotes and spaces from a column name.
+func normalizeHeader(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff") // UTF-8 BOM
+	return strings.ToLower(strings.TrimSpace(strings.Trim(name, `" `)))
+}
+
+// findColumns locates the known OHLCV columns in a header.
+func findColumns(header []string) columns {
+	cols := columns{date: -1, open: -1, high: -1, low: -1, close: -1, adjClose: -1, volume: -1, halted: -1}
+	for i, name := range header {
+		switch normalizeHeader(name) {
+		case "date", "time", "timestamp", "datetime":
+			cols.date = i
+		case "open":
+			cols.open = i
+		case "high":
+			cols.high = i
+		case "low":
+			cols.low = i
+		case "close", "close/last", "price":
+			cols.close = i
+		case "adj close", "adj_close", "adjclose", "adjusted close":
+			cols.adjClose = i
+		case "volume", "vol.", "vol":
+			cols.volume = i
+		case "halted", "halt", "status", "trading status":
+			cols.halted = i
+		}
+	}
+	return cols
+}
+
+// ParseFormat parses a layout name; "" and "auto" mean detection from the header.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case "", "auto":
+		return "", nil
+	case FormatYahoo, FormatNasdaq, FormatStooq, FormatInvesting, FormatWide, FormatBinance:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown input format %q (use auto, yahoo, nasdaq, stooq, investing, wide, or binance)", name)
+	}
+}
+
+// DetectFormat identifies the layout of a CSV file from its header row.
+func DetectFormat(header []string) Format {
+	if len(header) >= 6 {
+		if _, err := strconv.ParseInt(strings.TrimSpace(header[0]), 10, 64); err == nil {
+			return FormatBinance
+		}
+	}
+
+	names := make(map[string]bool, len(header))
+	for _, name := range header {
+		names[normalizeHeader(name)] = true
+	}
+
+	switch {
+	case names["close/last"]:
+		return FormatNasdaq
+	case names["price"] && (names["change %"] || names["open"]):
+		return FormatInvesting
+	case names["close"] && names["adj close"]:
+		return FormatYahoo
+	case names["close"] && names["open"]:
+		return FormatStooq
+	default:
+		return FormatWide
+	}
+}
+
+// Load loads all series from a CSV, JSON, or Parquet file (selected by extension),
+// detecting the column layout from the header. Single-symbol layouts are named after
+// the file (e.g. data/tsla.csv -> TSLA). Rows with a missing or invalid price are dropped.
+func Load(filename string) ([]Series, Format, error) {
+	series, _, format, err := LoadWithOptions(filename, Options{})
+	return series, format, err
+}
+
+// LoadWithOptions is Load with a configurable missing-data policy. It also returns a
+// validation report for every series.
+func LoadWithOptions(filename string, opts Options) ([]Series, []Report, Format, error) {
+	records, err := ReadTable(filename)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return parseRecords(records, SymbolFromPath(filename), opts)
+}
+
+// SymbolFromPath derives a symbol name from a file name.
+func SymbolFromPath(filename string) string {
+	base := filepath.Base(filename)
+	for ext := filepath.Ext(base); ext != ""; ext = filepath.Ext(base) {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return strings.ToUpper(base)
+}
+
+// ReadCSV reads all series from CSV data. symbol names the series of single-symbol formats.
+// Rows with a missing, invalid, or non-positive close price are skipped.
+func ReadCSV(r io.Reader, symbol string) ([]Series, Format, error) {
+	records, err := readCSVTable(r)
+	if err != nil {
+		return nil, "", err
+	}
+	series, _, format, err := parseRecords(records, symbol, Options{})
+	return series, format, err
+}
+
+// parseRecords converts table records into series according to the detected format.
+func parseRecords(records [][]string, symbol string, opts Options) ([]Series, []Report, Format, error) {
+	if len(records) < 1 || (len(records) < 2 && DetectFormat(records[0]) != FormatBinance && opts.Format != FormatBinance) {
+		return nil, nil, "", fmt.Errorf("CSV file must have at least a header and one data row")
+	}
+	if opts.Missing == "" {
+		opts.Missing = MissingDrop
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = DetectFormat(records[0])
+	}
+	var collectors []*collector
+	var err error
+	switch format {
+	case FormatWide:
+		collectors, err = readWide(records, opts.Missing)
+	case FormatBinance:
+		var c *collector
+		c, err = readBinance(records, symbol, opts.Missing)
+		collectors = []*collector{c}
+	default:
+		var c *collector
+		c, err = readOHLCV(records, symbol, opts.Missing)
+		collectors = []*collector{c}
+	}
+	if err != nil {
+		return nil, nil, format, err
+	}
+
+	series := make([]Series, len(collectors))
+	reports := make([]Report, len(collectors))
+	for i, c := range collectors {
+		series[i], reports[i] = c.finish(opts.GapFactor)
+	}
+	return series, reports, format, nil
+}
+
+// readOHLCV parses a single-symbol OHLCV layout.
+func readOHLCV(records [][]string, symbol string, policy MissingPolicy) (*collector, error) {
+	cols := findColumns(records[0])
+	if cols.close < 0 {
+		return nil, fmt.Errorf("no close price column found in header %v", records[0])
+	}
+
+	c := newCollector(symbol, len(records)-1, policy)
+	for i := 1; i < len(records); i++ {
+		row := records[i]
+		if len(row) == 0 {
+			continue
+		}
+		closePrice, err := ParsePrice(field(row, cols.close))
+		bar := Bar{Close: closePrice, Open: closePrice, High: closePrice, Low: closePrice}
+		bar.Time = parseRowDate(row, cols.date, &c.report)
+		if v, err := ParsePrice(field(row, cols.open)); validPrice(v, err) {
+			bar.Open = v
+		}
+		if v, err := ParsePrice(field(row, cols.high)); validPrice(v, err) {
+			bar.High = v
+		}
+		if v, err := ParsePrice(field(row, cols.low)); validPrice(v, err) {
+			bar.Low = v
+		}
+		if v, err := ParsePrice(field(row, cols.adjClose)); validPrice(v, err) {
+			bar.AdjClose = v
+		}
+		if v, err := ParseVolume(field(row, cols.volume)); err == nil {
+			bar.Volume = v
+		}
+		bar.Halted = ParseHalted(field(row, cols.halted))
+		if err := c.add(i+1, bar, validPrice(closePrice, err)); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// readBinance parses a headerless Binance klines dump.
+func readBinance(records [][]string, symbol string, policy MissingPolicy) (*collector, error) {
+	c := newCollector(symbol, len(records), policy)
+	for i, row := range records {
+		if len(row) < 6 {
+			continue
+		}
+		bar, err := parseBinanceFields(row[:6])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		if err := c.add(i+1, bar, validPrice(bar.Close, nil)); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// readWide parses the one-column-per-symbol layout.
+func readWide(records [][]string, policy MissingPolicy) ([]*collector, error) {
+	header := records[0]
+	dateCol := -1
+	var collectors []*collector
+	var seriesCols []int
+	for i, name := range header {
+		normalized := normalizeHeader(name)
+		if normalized == "date" || normalized == "time" || normalized == "timestamp" {
+			dateCol = i
+			continue
+		}
+		if normalized == "" {
+			continue
+		}
+		symbol := strings.TrimSpace(strings.Trim(name, `"`))
+		collectors = append(collectors, newCollector(symbol, len(records)-1, policy))
+		seriesCols = append(seriesCols, i)
+	}
+
+	if len(collectors) == 0 {
+		return nil, fmt.Errorf("no price columns found in CSV header")
+	}
+
+	var dateReport Report
+	for i := 1; i < len(records); i++ {
+		row := records[i]
+		if len(row) == 0 {
+			continue
+		}
+		t := parseRowDate(row, dateCol, &dateReport)
+		for j, col := range seriesCols {
+			price, err := ParsePrice(field(row, col))
+			bar := Bar{Time: t, Open: price, High: price, Low: price, Close: price}
+			if err := collectors[j].add(i+1, bar, validPrice(price, err)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, c := range collectors {
+		c.report.InvalidDates = dateReport.InvalidDates
+	}
+
+	return collectors, nil
+}
+
+// parseRowDate parses the date column of a row, counting unparseable dates in report.
+// It returns the zero time when the layout has no date column.
+func parseRowDate(row []string, col int, report *Report) time.Time {
+	if col < 0 {
+		return time.Time{}
+	}
+	t, err := ParseDate(field(row, col))
+	if err != nil {
+		report.InvalidDates++
+		return time.Time{}
+	}
+	return t
+}
+
+// field returns the value at col, or "" if the column is absent.
+func field(row []string, col int) string {
+	if col < 0 || col >= len(row) {
+		return ""
+	}
+	return row[col]
+}
+
+// hasTimes reports whether every bar has a timestamp.
+func hasTimes(bars []Bar) bool {
+	for _, b := range bars {
+		if b.Time.IsZero() {
+			return false
+		}
+	}
+	return len(bars) > 0
+}
+
+// ParsePrice parses a price, accepting quotes, currency symbols, and thousands separators.
+func ParsePrice(s string) (float64, error) {
+	s = strings.TrimSpace(strings.Trim(s, `"`))
+	s = strings.NewReplacer("$", "", ",", "", " ", "").Replace(s)
+	if s == "" || s == "-" {
+		return 0, fmt.Errorf("empty price")
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// ParseHalted reports whether a halt column flags its bar as untradeable: a true
+// boolean, or a status naming a halt or a limit-up/limit-down lock (e.g. "halted",
+// "limit_up", "LULD"). Empty and other values are tradeable.
+func ParseHalted(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(strings.Trim(s, `"`)))
+	s = strings.NewReplacer("_", " ", "-", " ").Replace(s)
+	switch s {
+	case "1", "true", "yes", "y", "halt", "halted", "suspended", "locked",
+		"limit up", "limit down", "limit locked", "luld":
+		return true
+	}
+	return false
+}
+
+// ParseVolume parses a volume, accepting K/M/B suffixes (e.g. "92.04K").
+func ParseVolume(s string) (float64, error) {
+	s = strings.TrimSpace(strings.Trim(s, `"`))
+	s = strings.ReplaceAll(s, ",", "")
+	if s == "" || s == "-" {
+		return 0, fmt.Errorf("empty volume")
+	}
+	multiplier := 1.0
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		multiplier = 1e3
+	case "M":
+		multiplier = 1e6
+	case "B":
+		multiplier = 1e9
+	}
+	if multiplier != 1 {
+		s = s[:len(s)-1]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return v * multiplier, nil
+}
+
+// ParseDate parses a date in any of the common vendor layouts.
+func ParseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(strings.Trim(s, `"`))
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", s)
+}
+
+// WriteWide writes close prices of all series in the wide layout (one column per
+// symbol followed by a Date column) as CSV, JSON, or Parquet, selected by extension.
+// Rows are aligned by date; a symbol without a bar on a date gets an empty cell.
+// Intraday series keep the time of day.
+func WriteWide(filename string, series []Series) error {
+	return WriteWideAs(filename, TableFormat(filename), series)
+}
+
+// WriteWideAs is WriteWide with an explicit table format (TableCSV, TableJSON, or TableParquet).
+func WriteWideAs(filename, tableFormat string, series []Series) error {
+	if len(series) == 0 {
+		return fmt.Errorf("no series to write")
+	}
+
+	header := append(Symbols(series), "Date")
+
+	// Union of all dates, each mapped to the close of every series
+	closes := make(map[time.Time][]string)
+	for j, s := range series {
+		for _, b := range s.Bars {
+			row, ok := closes[b.Time]
+			if !ok {
+				row = make([]string, len(series))
+				closes[b.Time] = row
+			}
+			row[j] = strconv.FormatFloat(b.Close, 'f', 6, 64)
+		}
+	}
+	dates := make([]time.Time, 0, len(closes))
+	for t := range closes {
+		dates = append(dates, t)
+	}
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+
+	layout := "2006-01-02"
+	for _, t := range dates {
+		if !t.Equal(t.Truncate(24 * time.Hour)) {
+			layout = "2006-01-02 15:04:05"
+			break
+		}
+	}
+
+	records := make([][]string, 0, len(dates)+1)
+	records = append(records, header)
+	for _, t := range dates {
+		records = append(records, append(closes[t], t.Format(layout)))
+	}
+
+	return WriteTableAs(filename, tableFormat, records)
+}
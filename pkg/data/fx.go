@@ -0,0 +1,103 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FXRates returns, for every bar of an asset, the value of one unit of its quote
+// currency in the base currency: the close of the last FX bar at or before the bar,
+// so FX series with other holidays than the asset's market are forward-filled.
+// With invert the FX series is quoted the other way round (base currency per quote
+// currency is 1/close), e.g. USDJPY for a Japanese stock in a USD portfolio.
+func FXRates(bars, fx []Bar, invert bool) ([]float64, error) {
+	if len(fx) == 0 {
+		return nil, fmt.Errorf("FX series is empty")
+	}
+	rates := make([]float64, len(bars))
+	for i, b := range bars {
+		// First FX bar after b, so the one before it is the latest known rate
+		j := sort.Search(len(fx), func(j int) bool { return fx[j].Time.After(b.Time) })
+		if j == 0 {
+			return nil, fmt.Errorf("no FX rate on or before %s (FX series starts %s)",
+				b.Time.Format("2006-01-02"), fx[0].Time.Format("2006-01-02"))
+		}
+		rate := fx[j-1].Close
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid FX rate %g on %s", rate, fx[j-1].Time.Format("2006-01-02"))
+		}
+		if invert {
+			rate = 1 / rate
+		}
+		rates[i] = rate
+	}
+	return rates, nil
+}
+
+// FXPair names the FX series converting one asset into the base currency: a series
+// (column) of an FX file, inverted when written as "1/" + series.
+type FXPair struct {
+	Series string
+	Invert bool
+}
+
+// String returns the pair as ParseFXPair accepts it.
+func (p FXPair) String() string {
+	if p.Invert {
+		return "1/" + p.Series
+	}
+	return p.Series
+}
+
+// ParseFXPair parses a series name, inverted with a "1/" prefix.
+func ParseFXPair(s string) FXPair {
+	if rest, inverted := strings.CutPrefix(s, "1/"); inverted {
+		return FXPair{Series: rest, Invert: true}
+	}
+	return FXPair{Series: s}
+}
+
+// ParseFXMap parses a comma-separated list of SYMBOL=SERIES assignments, e.g.
+// "SAP=EURUSD,TM=1/USDJPY", naming the FX series of every asset not quoted in the
+// base currency.
+func ParseFXMap(s string) (map[string]FXPair, error) {
+	pairs := make(map[string]FXPair)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		symbol, series, ok := strings.Cut(part, "=")
+		symbol, series = strings.TrimSpace(symbol), strings.TrimSpace(series)
+		if !ok || symbol == "" || series == "" {
+			return nil, fmt.Errorf("invalid FX assignment %q (want SYMBOL=SERIES or SYMBOL=1/SERIES)", part)
+		}
+		pairs[symbol] = ParseFXPair(series)
+	}
+	return pairs, nil
+}
+
+// LoadFXRates returns the FX rates (see FXRates) of the stocks assigned an FX
+// series in pairs, by symbol, from the FX series of the file at path.
+func LoadFXRates(path string, pairs map[string]FXPair, stocks []Series, opts Options) (map[string][]float64, error) {
+	fxSeries, _, _, err := LoadWithOptions(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load FX series: %w", err)
+	}
+	rates := make(map[string][]float64, len(pairs))
+	for _, stock := range stocks {
+		pair, ok := pairs[stock.Symbol]
+		if !ok {
+			continue
+		}
+		fx, ok := Find(fxSeries, pair.Series)
+		if !ok {
+			return nil, fmt.Errorf("FX series %s of %s not found in %s", pair.Series, stock.Symbol, path)
+		}
+		if rates[stock.Symbol], err = FXRates(stock.Bars, fx.Bars, pair.Invert); err != nil {
+			return nil, fmt.Errorf("%s: %w", stock.Symbol, err)
+		}
+	}
+	return rates, nil
+}
@@ -0,0 +1,109 @@
+package data
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// FingerprintWindow is the number of consecutive prices hashed into one fingerprint.
+// Test data sharing at least 2*FingerprintWindow-1 consecutive prices with the
+// training data is always caught.
+const FingerprintWindow = 20
+
+// Coverage records what a model was trained on, to detect test data overlapping it.
+type Coverage struct {
+	Symbol string `json:"symbol"`
+	From   string `json:"from,omitempty"` // First training date, empty for undated prices
+	To     string `json:"to,omitempty"`   // Last training date
+	// Fingerprints hashes consecutive, non-overlapping windows of the training
+	// prices, catching overlaps under another symbol or without dates.
+	Fingerprints []uint64 `json:"fingerprints,omitempty"`
+}
+
+// CoverageOf returns the coverage of training on bars of symbol.
+func CoverageOf(symbol string, bars []Bar) Coverage {
+	c := Coverage{Symbol: symbol}
+	if len(bars) > 0 && !bars[0].Time.IsZero() {
+		c.From = bars[0].Time.Format(time.DateOnly)
+		c.To = bars[len(bars)-1].Time.Format(time.DateOnly)
+	}
+	for i := 0; i+FingerprintWindow <= len(bars); i += FingerprintWindow {
+		c.Fingerprints = append(c.Fingerprints, fingerprint(bars[i:i+FingerprintWindow]))
+	}
+	return c
+}
+
+// fingerprint hashes the closes of bars, rounded to 8 significant digits so the
+// parsing of the same file always agrees.
+func fingerprint(bars []Bar) uint64 {
+	h := fnv.New64a()
+	for _, b := range bars {
+		fmt.Fprintf(h, "%.8g,", b.Close)
+	}
+	return h.Sum64()
+}
+
+// Overlap describes how test data overlaps a model's training data.
+type Overlap struct {
+	Symbol   string // Training series overlapped
+	From, To string // Dates of the test bars inside the training range of the same symbol
+	Windows  int    // Windows of test prices found in the training prices
+}
+
+// String describes the overlap.
+func (o Overlap) String() string {
+	var parts []string
+	if o.From != "" {
+		parts = append(parts, fmt.Sprintf("dates %s to %s", o.From, o.To))
+	}
+	if o.Windows > 0 {
+		parts = append(parts, fmt.Sprintf("%d price windows of %d", o.Windows, FingerprintWindow))
+	}
+	return fmt.Sprintf("%s: %s", o.Symbol, strings.Join(parts, ", "))
+}
+
+// CheckOverlap returns how test bars of symbol overlap the training coverage, one
+// entry per overlapped training series: by dates for the same symbol, and by the
+// fingerprints of the prices for any symbol.
+func CheckOverlap(coverage []Coverage, symbol string, bars []Bar) []Overlap {
+	seen := make(map[uint64]bool)
+	for i := 0; i+FingerprintWindow <= len(bars); i++ {
+		seen[fingerprint(bars[i:i+FingerprintWindow])] = true
+	}
+
+	var overlaps []Overlap
+	for _, c := range coverage {
+		o := Overlap{Symbol: c.Symbol}
+		if c.From != "" && strings.EqualFold(c.Symbol, symbol) {
+			o.From, o.To = dateOverlap(c.From, c.To, bars)
+		}
+		for _, f := range c.Fingerprints {
+			if seen[f] {
+				o.Windows++
+			}
+		}
+		if o.From != "" || o.Windows > 0 {
+			overlaps = append(overlaps, o)
+		}
+	}
+	return overlaps
+}
+
+// dateOverlap returns the first and last dates of bars within [from, to].
+func dateOverlap(from, to string, bars []Bar) (first, last string) {
+	for _, b := range bars {
+		if b.Time.IsZero() {
+			continue
+		}
+		date := b.Time.Format(time.DateOnly)
+		if date >= from && date <= to {
+			if first == "" {
+				first = date
+			}
+			last = date
+		}
+	}
+	return first, last
+}
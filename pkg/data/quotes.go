@@ -0,0 +1,73 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Quotes holds the best bid and ask for every bar of a price series.
+type Quotes struct {
+	Bid []float64
+	Ask []float64
+}
+
+// LoadQuotes loads a bid/ask file, with Bid and Ask columns (any case) besides the
+// date, and returns the quotes for every bar of bars: the latest quotes on or before
+// each bar, or position by position when either side is undated.
+func LoadQuotes(filename string, bars []Bar, opts Options) (*Quotes, error) {
+	series, _, _, err := LoadWithOptions(filename, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quotes: %w", err)
+	}
+	var bid, ask *Series
+	for i := range series {
+		switch strings.ToLower(series[i].Symbol) {
+		case "bid":
+			bid = &series[i]
+		case "ask":
+			ask = &series[i]
+		}
+	}
+	if bid == nil || ask == nil {
+		return nil, fmt.Errorf("quotes need Bid and Ask columns; available columns: %s", describeColumns(series))
+	}
+
+	quotes := &Quotes{}
+	if len(bars) > 0 && !bars[0].Time.IsZero() && len(bid.Bars) > 0 && !bid.Bars[0].Time.IsZero() {
+		if quotes.Bid, err = quotesAsOf(bars, bid.Bars, "bid"); err != nil {
+			return nil, err
+		}
+		if quotes.Ask, err = quotesAsOf(bars, ask.Bars, "ask"); err != nil {
+			return nil, err
+		}
+	} else {
+		if len(bid.Bars) < len(bars) || len(ask.Bars) < len(bars) {
+			return nil, fmt.Errorf("%d bids and %d asks for %d undated bars", len(bid.Bars), len(ask.Bars), len(bars))
+		}
+		quotes.Bid, quotes.Ask = bid.Closes()[:len(bars)], ask.Closes()[:len(bars)]
+	}
+	for i := range bars {
+		if quotes.Bid[i] > quotes.Ask[i] {
+			return nil, fmt.Errorf("bid %g above ask %g at bar %d", quotes.Bid[i], quotes.Ask[i], i)
+		}
+	}
+	return quotes, nil
+}
+
+// quotesAsOf returns, for every bar, the close of the last quote bar at or before it.
+func quotesAsOf(bars, quotes []Bar, side string) ([]float64, error) {
+	if len(quotes) == 0 {
+		return nil, fmt.Errorf("no %s quotes", side)
+	}
+	prices := make([]float64, len(bars))
+	for i, b := range bars {
+		j := sort.Search(len(quotes), func(j int) bool { return quotes[j].Time.After(b.Time) })
+		if j == 0 {
+			return nil, fmt.Errorf("no %s on or before %s (quotes start %s)",
+				side, b.Time.Format("2006-01-02"), quotes[0].Time.Format("2006-01-02"))
+		}
+		prices[i] = quotes[j-1].Close
+	}
+	return prices, nil
+}
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is a bar frequency such as 1h, 4h, 1d, 1w, or 1mo.
+type Frequency struct {
+	Duration time.Duration // Fixed-length buckets (minutes, hours, days)
+	Weeks    int           // Calendar weeks starting on Monday
+	Months   int           // Calendar months
+}
+
+// ParseFrequency parses "<n><unit>" with unit m (minutes), h, d, w, or mo.
+func ParseFrequency(s string) (Frequency, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	unitStart := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if unitStart < 0 {
+		return Frequency{}, fmt.Errorf("frequency %q has no unit (use m, h, d, w, or mo)", s)
+	}
+	n := 1
+	if unitStart > 0 {
+		var err error
+		n, err = strconv.Atoi(s[:unitStart])
+		if err != nil || n <= 0 {
+			return Frequency{}, fmt.Errorf("invalid frequency %q", s)
+		}
+	}
+
+	switch s[unitStart:] {
+	case "m", "min":
+		return Frequency{Duration: time.Duration(n) * time.Minute}, nil
+	case "h":
+		return Frequency{Duration: time.Duration(n) * time.Hour}, nil
+	case "d":
+		return Frequency{Duration: time.Duration(n) * 24 * time.Hour}, nil
+	case "w":
+		return Frequency{Weeks: n}, nil
+	case "mo":
+		return Frequency{Months: n}, nil
+	default:
+		return Frequency{}, fmt.Errorf("unknown frequency unit in %q (use m, h, d, w, or mo)", s)
+	}
+}
+
+// bucket returns the start of the period containing t.
+func (f Frequency) bucket(t time.Time) time.Time {
+	t = t.UTC()
+	switch {
+	case f.Months > 0:
+		months := (t.Year()*12 + int(t.Month()) - 1) / f.Months * f.Months
+		return time.Date(months/12, time.Month(months%12+1), 1, 0, 0, 0, 0, time.UTC)
+	case f.Weeks > 0:
+		// The zero time (January 1, year 1) is a Monday, so weeks counted from it start on Monday
+		day := t.Truncate(24 * time.Hour)
+		return day.Truncate(time.Duration(f.Weeks) * 7 * 24 * time.Hour)
+	default:
+		return t.Truncate(f.Duration)
+	}
+}
+
+// Resample aggregates the bars of a chronologically sorted series into coarser periods:
+// open is the first open, high the maximum, low the minimum, close and adjusted close the
+// last values, and volume the sum. Each bar is stamped with the start of its period.
+func Resample(s Series, freq Frequency) (Series, error) {
+	if freq.Duration <= 0 && freq.Weeks <= 0 && freq.Months <= 0 {
+		return Series{}, fmt.Errorf("resample frequency must be positive")
+	}
+	if !hasTimes(s.Bars) {
+		return Series{}, fmt.Errorf("series %s has bars without dates", s.Symbol)
+	}
+
+	out := Series{Symbol: s.Symbol}
+	for i, b := range s.Bars {
+		if i > 0 && b.Time.Before(s.Bars[i-1].Time) {
+			return Series{}, fmt.Errorf("series %s is not sorted by date at bar %d", s.Symbol, i)
+		}
+		start := freq.bucket(b.Time)
+		if n := len(out.Bars); n > 0 && out.Bars[n-1].Time.Equal(start) {
+			agg := &out.Bars[n-1]
+			if b.High > agg.High {
+				agg.High = b.High
+			}
+			if b.Low < agg.Low {
+				agg.Low = b.Low
+			}
+			agg.Close = b.Close
+			agg.AdjClose = b.AdjClose
+			agg.Volume += b.Volume
+			continue
+		}
+		b.Time = start
+		out.Bars = append(out.Bars, b)
+	}
+
+	return out, nil
+}
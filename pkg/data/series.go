@@ -0,0 +1,158 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bar is one OHLCV observation.
+type Bar struct {
+	Time     time.Time
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	AdjClose float64 // Vendor split/dividend-adjusted close; 0 if not provided
+	Volume   float64
+	// Halted flags a bar the asset could not be traded at, e.g. a trading halt or a
+	// limit-up/limit-down lock, from a Halted or Status column of OHLCV files.
+	Halted bool
+}
+
+// Series is a single-symbol price series in chronological order.
+type Series struct {
+	Symbol string
+	Bars   []Bar
+}
+
+// Len returns the number of bars.
+func (s *Series) Len() int {
+	return len(s.Bars)
+}
+
+// Closes returns the close prices.
+func (s *Series) Closes() []float64 {
+	closes := make([]float64, len(s.Bars))
+	for i, b := range s.Bars {
+		closes[i] = b.Close
+	}
+	return closes
+}
+
+// Halts returns which bars are halted, or nil when none is.
+func (s *Series) Halts() []bool {
+	var halts []bool
+	for i, b := range s.Bars {
+		if b.Halted {
+			if halts == nil {
+				halts = make([]bool, len(s.Bars))
+			}
+			halts[i] = true
+		}
+	}
+	return halts
+}
+
+// Times returns the bar timestamps.
+func (s *Series) Times() []time.Time {
+	times := make([]time.Time, len(s.Bars))
+	for i, b := range s.Bars {
+		times[i] = b.Time
+	}
+	return times
+}
+
+// SortByTime orders the bars chronologically (vendors such as Nasdaq export newest first).
+// Bars without a timestamp keep their relative order.
+func (s *Series) SortByTime() {
+	sort.SliceStable(s.Bars, func(i, j int) bool {
+		return s.Bars[i].Time.Before(s.Bars[j].Time)
+	})
+}
+
+// Find returns the series with the given symbol.
+func Find(series []Series, symbol string) (*Series, bool) {
+	for i := range series {
+		if series[i].Symbol == symbol {
+			return &series[i], true
+		}
+	}
+	return nil, false
+}
+
+// Symbols returns the symbols of all series in order.
+func Symbols(series []Series) []string {
+	symbols := make([]string, len(series))
+	for i, s := range series {
+		symbols[i] = s.Symbol
+	}
+	return symbols
+}
+
+// Select returns the series named symbol, or the series at position column if symbol is empty.
+// The error lists the available columns.
+func Select(series []Series, symbol string, column int) (*Series, error) {
+	if symbol != "" {
+		if s, ok := Find(series, symbol); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("symbol %q not found; available columns: %s", symbol, describeColumns(series))
+	}
+	if column < 0 || column >= len(series) {
+		return nil, fmt.Errorf("column %d out of range; available columns: %s", column, describeColumns(series))
+	}
+	return &series[column], nil
+}
+
+// describeColumns lists the series as "0=MSFT, 1=IBM, ...".
+func describeColumns(series []Series) string {
+	parts := make([]string, len(series))
+	for i, s := range series {
+		parts[i] = fmt.Sprintf("%d=%s", i, s.Symbol)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Intersect trims every series to the dates present in all of them, so the series
+// line up row by row. Bars without a date are dropped.
+func Intersect(series []Series) []Series {
+	counts := make(map[time.Time]int)
+	for _, s := range series {
+		seen := make(map[time.Time]bool, len(s.Bars))
+		for _, b := range s.Bars {
+			if !b.Time.IsZero() && !seen[b.Time] {
+				seen[b.Time] = true
+				counts[b.Time]++
+			}
+		}
+	}
+
+	out := make([]Series, len(series))
+	for i, s := range series {
+		out[i] = Series{Symbol: s.Symbol, Bars: make([]Bar, 0, len(s.Bars))}
+		for _, b := range s.Bars {
+			if counts[b.Time] == len(series) {
+				out[i].Bars = append(out[i].Bars, b)
+			}
+		}
+	}
+	return out
+}
+
+// Between returns the bars of s dated within [from, to]. A zero from or to leaves
+// that side open; bars without a date are kept only when both sides are open.
+func Between(s Series, from, to time.Time) Series {
+	if from.IsZero() && to.IsZero() {
+		return s
+	}
+	out := Series{Symbol: s.Symbol}
+	for _, b := range s.Bars {
+		if b.Time.IsZero() || (!from.IsZero() && b.Time.Before(from)) || (!to.IsZero() && b.Time.After(to)) {
+			continue
+		}
+		out.Bars = append(out.Bars, b)
+	}
+	return out
+}
@@ -0,0 +1,505 @@
+package data
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Table file formats, selected by file extension.
+const (
+	TableCSV     = "csv"
+	TableJSON    = "json"
+	TableParquet = "parquet"
+)
+
+// TableFormat returns the table format of a file from its extension (CSV by default).
+// A trailing .gz is ignored, so data.csv.gz is CSV.
+func TableFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(strings.TrimSuffix(filename, ".gz"))) {
+	case ".json":
+		return TableJSON
+	case ".parquet", ".pq":
+		return TableParquet
+	default:
+		return TableCSV
+	}
+}
+
+// IsGzip reports whether a file name has the .gz extension.
+func IsGzip(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ".gz")
+}
+
+// ReadTable reads a table (header row followed by data rows) from a CSV, JSON, or
+// Parquet file, selected by extension. JSON files hold an array of row objects
+// (pandas orient="records") or {"columns": [...], "data": [[...]]} (orient="split").
+// Files ending in .gz are decompressed transparently.
+func ReadTable(filename string) ([][]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if IsGzip(filename) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	switch TableFormat(filename) {
+	case TableJSON:
+		return readJSONTable(r)
+	case TableParquet:
+		if !IsGzip(filename) {
+			info, err := file.Stat()
+			if err != nil {
+				return nil, fmt.Errorf("failed to stat file: %w", err)
+			}
+			return readParquetTable(file, info.Size())
+		}
+		// Parquet needs random access, so decompress into memory
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress file: %w", err)
+		}
+		return readParquetTable(bytes.NewReader(content), int64(len(content)))
+	default:
+		return readCSVTable(r)
+	}
+}
+
+// WriteTable writes a table (header row followed by data rows) as CSV, JSON, or
+// Parquet, selected by extension. Files ending in .gz are gzip-compressed.
+// The parent directory is created if needed.
+func WriteTable(filename string, records [][]string) error {
+	return WriteTableAs(filename, TableFormat(filename), records)
+}
+
+// WriteTableAs is WriteTable with an explicit table format (TableCSV, TableJSON, or TableParquet).
+func WriteTableAs(filename, tableFormat string, records [][]string) error {
+	if len(records) == 0 {
+		return fmt.Errorf("table has no header")
+	}
+	if dir := filepath.Dir(filename); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	var w io.Writer = file
+	var gz *gzip.Writer
+	if IsGzip(filename) {
+		gz = gzip.NewWriter(file)
+		w = gz
+	}
+
+	switch tableFormat {
+	case TableJSON:
+		err = writeJSONTable(w, records)
+	case TableParquet:
+		err = writeParquetTable(w, records)
+	case TableCSV:
+		err = writeCSVTable(w, records)
+	default:
+		err = fmt.Errorf("unknown table format %q (use csv, json, or parquet)", tableFormat)
+	}
+	if err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to finish gzip stream: %w", err)
+		}
+	}
+	return file.Close()
+}
+
+func readCSVTable(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	return records, nil
+}
+
+func writeCSVTable(w io.Writer, records [][]string) error {
+	writer := csv.NewWriter(w)
+	for i, record := range records {
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write record %d: %w", i, err)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// readJSONTable reads the records or split orientation, keeping the column order of the file.
+func readJSONTable(r io.Reader) ([][]string, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON: %w", err)
+	}
+	content = bytes.TrimSpace(content)
+
+	if len(content) > 0 && content[0] == '{' {
+		var split struct {
+			Columns []string        `json:"columns"`
+			Data    [][]interface{} `json:"data"`
+		}
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.UseNumber()
+		if err := dec.Decode(&split); err != nil {
+			return nil, fmt.Errorf("failed to decode JSON: %w", err)
+		}
+		if len(split.Columns) == 0 {
+			return nil, fmt.Errorf("JSON object must have \"columns\" and \"data\"")
+		}
+		records := [][]string{split.Columns}
+		for _, row := range split.Data {
+			record := make([]string, len(row))
+			for i, v := range row {
+				record[i] = jsonString(v)
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	if err := expectDelim(dec, '['); err != nil {
+		return nil, err
+	}
+
+	var header []string
+	columns := make(map[string]int)
+	var rows []map[string]string
+	for dec.More() {
+		if err := expectDelim(dec, '{'); err != nil {
+			return nil, err
+		}
+		row := make(map[string]string)
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode JSON: %w", err)
+			}
+			key, ok := tok.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected JSON token %v", tok)
+			}
+			var value interface{}
+			if err := dec.Decode(&value); err != nil {
+				return nil, fmt.Errorf("failed to decode JSON value of %q: %w", key, err)
+			}
+			if _, ok := columns[key]; !ok {
+				columns[key] = len(header)
+				header = append(header, key)
+			}
+			row[key] = jsonString(value)
+		}
+		if err := expectDelim(dec, '}'); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+
+	records := make([][]string, 0, len(rows)+1)
+	records = append(records, header)
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for key, value := range row {
+			record[columns[key]] = value
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("expected %q in JSON, got %v", want, tok)
+	}
+	return nil
+}
+
+// jsonString converts a decoded JSON value to its table cell text.
+func jsonString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// writeJSONTable writes an array of row objects in column order (pandas orient="records").
+// Numeric cells are written as numbers and empty cells as null.
+func writeJSONTable(w io.Writer, records [][]string) error {
+	header := records[0]
+	keys := make([][]byte, len(header))
+	for i, name := range header {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return fmt.Errorf("failed to encode column name %q: %w", name, err)
+		}
+		keys[i] = key
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for r, record := range records[1:] {
+		if r > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n{")
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.Write(key)
+			buf.WriteString(":")
+			cell := ""
+			if i < len(record) {
+				cell = record[i]
+			}
+			writeJSONCell(&buf, cell)
+		}
+		buf.WriteString("}")
+		if buf.Len() > 1<<16 {
+			if _, err := w.Write(buf.Bytes()); err != nil {
+				return fmt.Errorf("failed to write JSON: %w", err)
+			}
+			buf.Reset()
+		}
+	}
+	buf.WriteString("\n]\n")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
+}
+
+func writeJSONCell(buf *bytes.Buffer, cell string) {
+	if cell == "" {
+		buf.WriteString("null")
+		return
+	}
+	if v, err := strconv.ParseFloat(cell, 64); err == nil && !math.IsNaN(v) && !math.IsInf(v, 0) && json.Valid([]byte(cell)) {
+		buf.WriteString(cell)
+		return
+	}
+	s, _ := json.Marshal(cell)
+	buf.Write(s)
+}
+
+// Parquet column kinds chosen from the cell values of a column.
+const (
+	parquetInt = iota
+	parquetDouble
+	parquetString
+)
+
+// parquetKind returns the narrowest kind that holds every non-empty cell of column col.
+func parquetKind(records [][]string, col int) int {
+	kind := parquetInt
+	for _, record := range records[1:] {
+		if col >= len(record) || record[col] == "" {
+			continue
+		}
+		if kind == parquetInt {
+			if _, err := strconv.ParseInt(record[col], 10, 64); err == nil {
+				continue
+			}
+			kind = parquetDouble
+		}
+		if _, err := strconv.ParseFloat(record[col], 64); err != nil {
+			return parquetString
+		}
+	}
+	return kind
+}
+
+// writeParquetTable writes one optional column per header entry, in header order.
+// Integer and numeric columns are typed; everything else is stored as UTF-8 strings.
+func writeParquetTable(w io.Writer, records [][]string) error {
+	header := records[0]
+	kinds := make([]int, len(header))
+	fields := make([]reflect.StructField, len(header))
+	for i, name := range header {
+		if strings.ContainsAny(name, ",\"") {
+			return fmt.Errorf("column name %q cannot be stored in Parquet", name)
+		}
+		kinds[i] = parquetKind(records, i)
+		var typ reflect.Type
+		switch kinds[i] {
+		case parquetInt:
+			typ = reflect.TypeOf((*int64)(nil))
+		case parquetDouble:
+			typ = reflect.TypeOf((*float64)(nil))
+		default:
+			typ = reflect.TypeOf((*string)(nil))
+		}
+		fields[i] = reflect.StructField{
+			Name: "F" + strconv.Itoa(i),
+			Type: typ,
+			Tag:  reflect.StructTag(fmt.Sprintf(`parquet:"%s,optional"`, name)),
+		}
+	}
+	rowType := reflect.StructOf(fields)
+	schema := parquet.SchemaOf(reflect.New(rowType).Interface())
+
+	writer := parquet.NewWriter(w, schema)
+	for r, record := range records[1:] {
+		row := reflect.New(rowType)
+		for i := range header {
+			if i >= len(record) || record[i] == "" {
+				continue
+			}
+			field := row.Elem().Field(i)
+			switch kinds[i] {
+			case parquetInt:
+				v, _ := strconv.ParseInt(record[i], 10, 64)
+				field.Set(reflect.ValueOf(&v))
+			case parquetDouble:
+				v, _ := strconv.ParseFloat(record[i], 64)
+				field.Set(reflect.ValueOf(&v))
+			default:
+				v := record[i]
+				field.Set(reflect.ValueOf(&v))
+			}
+		}
+		if err := writer.Write(row.Interface()); err != nil {
+			return fmt.Errorf("failed to write Parquet row %d: %w", r+1, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write Parquet: %w", err)
+	}
+	return nil
+}
+
+// readParquetTable reads a flat Parquet file. Timestamp and date columns are
+// formatted as text so they parse like CSV dates.
+func readParquetTable(r io.ReaderAt, size int64) ([][]string, error) {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Parquet: %w", err)
+	}
+
+	fields := file.Schema().Fields()
+	if len(file.Schema().Columns()) != len(fields) {
+		return nil, fmt.Errorf("nested Parquet schemas are not supported")
+	}
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.Name()
+	}
+
+	records := [][]string{header}
+	buf := make([]parquet.Row, 256)
+	for _, rowGroup := range file.RowGroups() {
+		rows := rowGroup.Rows()
+		for {
+			n, err := rows.ReadRows(buf)
+			for _, row := range buf[:n] {
+				record := make([]string, len(fields))
+				for _, v := range row {
+					if c := v.Column(); c >= 0 && c < len(fields) {
+						record[c] = parquetCell(v, fields[c])
+					}
+				}
+				records = append(records, record)
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to read Parquet rows: %w", err)
+			}
+		}
+		rows.Close()
+	}
+
+	return records, nil
+}
+
+// parquetCell formats a Parquet value as table cell text.
+func parquetCell(v parquet.Value, field parquet.Field) string {
+	if v.IsNull() {
+		return ""
+	}
+	logical := field.Type().LogicalType()
+	switch v.Kind() {
+	case parquet.Boolean:
+		return strconv.FormatBool(v.Boolean())
+	case parquet.Int32:
+		if logical != nil && logical.Date != nil {
+			return time.Unix(int64(v.Int32())*86400, 0).UTC().Format("2006-01-02")
+		}
+		return strconv.FormatInt(int64(v.Int32()), 10)
+	case parquet.Int64:
+		if logical != nil && logical.Timestamp != nil {
+			var t time.Time
+			unit := logical.Timestamp.Unit
+			switch {
+			case unit.Millis != nil:
+				t = time.UnixMilli(v.Int64())
+			case unit.Micros != nil:
+				t = time.UnixMicro(v.Int64())
+			default:
+				t = time.Unix(0, v.Int64())
+			}
+			return t.UTC().Format("2006-01-02 15:04:05")
+		}
+		return strconv.FormatInt(v.Int64(), 10)
+	case parquet.Float:
+		return strconv.FormatFloat(float64(v.Float()), 'g', -1, 32)
+	case parquet.Double:
+		return strconv.FormatFloat(v.Double(), 'g', -1, 64)
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		return string(v.ByteArray())
+	default:
+		return v.String()
+	}
+}
@@ -0,0 +1,190 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MissingPolicy selects how loaders treat missing, NaN, zero, or negative prices.
+type MissingPolicy string
+
+const (
+	MissingDrop        MissingPolicy = "drop"  // Skip the row for that symbol (default)
+	MissingForwardFill MissingPolicy = "ffill" // Repeat the previous close; leading gaps are dropped
+	MissingError       MissingPolicy = "error" // Fail the load
+)
+
+// ParseMissingPolicy parses a policy name ("" means drop).
+func ParseMissingPolicy(name string) (MissingPolicy, error) {
+	switch MissingPolicy(strings.ToLower(name)) {
+	case "", MissingDrop:
+		return MissingDrop, nil
+	case MissingForwardFill, "forward-fill":
+		return MissingForwardFill, nil
+	case MissingError:
+		return MissingError, nil
+	default:
+		return "", fmt.Errorf("unknown missing-data policy %q (use drop, ffill, or error)", name)
+	}
+}
+
+// Options configures loading and validation.
+type Options struct {
+	// Format forces the input layout instead of detecting it from the header.
+	Format  Format
+	Missing MissingPolicy
+	// GapFactor flags a date gap when consecutive bars are more than GapFactor times
+	// the median spacing apart (default 5, which ignores weekends in daily data).
+	GapFactor float64
+}
+
+// Gap is a stretch between two consecutive bars that is unusually long.
+type Gap struct {
+	From time.Time
+	To   time.Time
+}
+
+// Report summarizes what a loader found in one series.
+type Report struct {
+	Symbol       string
+	Rows         int // Data rows read
+	Kept         int // Bars in the loaded series
+	Dropped      int // Rows skipped because of a missing or invalid price
+	Filled       int // Rows forward-filled from the previous close
+	InvalidDates int // Rows whose date could not be parsed
+	OutOfOrder   int // Consecutive rows going against the file's date order
+	Duplicates   int // Bars sharing a date with the previous bar
+	Gaps         []Gap
+}
+
+// String returns a one-line summary of the report.
+func (r Report) String() string {
+	s := fmt.Sprintf("%s: %d rows, %d kept, %d dropped, %d filled, %d invalid dates, %d out of order, %d duplicate dates, %d gaps",
+		r.Symbol, r.Rows, r.Kept, r.Dropped, r.Filled, r.InvalidDates, r.OutOfOrder, r.Duplicates, len(r.Gaps))
+	if g, ok := r.LargestGap(); ok {
+		s += fmt.Sprintf(" (largest %s to %s)", g.From.Format("2006-01-02"), g.To.Format("2006-01-02"))
+	}
+	return s
+}
+
+// LargestGap returns the longest gap, if any.
+func (r Report) LargestGap() (Gap, bool) {
+	if len(r.Gaps) == 0 {
+		return Gap{}, false
+	}
+	largest := r.Gaps[0]
+	for _, g := range r.Gaps[1:] {
+		if g.To.Sub(g.From) > largest.To.Sub(largest.From) {
+			largest = g
+		}
+	}
+	return largest, true
+}
+
+// validPrice reports whether a parsed price can be used.
+func validPrice(v float64, err error) bool {
+	return err == nil && v > 0 && !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// collector builds one series row by row, applying the missing-data policy.
+type collector struct {
+	series  Series
+	report  Report
+	policy  MissingPolicy
+	times   []time.Time // Dates in file order, for the order check
+	hasLast bool
+	last    float64
+}
+
+func newCollector(symbol string, capacity int, policy MissingPolicy) *collector {
+	return &collector{
+		series: Series{Symbol: symbol, Bars: make([]Bar, 0, capacity)},
+		report: Report{Symbol: symbol},
+		policy: policy,
+	}
+}
+
+// add records one row. valid tells whether bar holds a usable close price;
+// row is the 1-based row number used in errors.
+func (c *collector) add(row int, bar Bar, valid bool) error {
+	c.report.Rows++
+	if !valid {
+		switch {
+		case c.policy == MissingError:
+			return fmt.Errorf("row %d: missing or invalid %s price", row, c.series.Symbol)
+		case c.policy == MissingForwardFill && c.hasLast:
+			bar = Bar{Time: bar.Time, Open: c.last, High: c.last, Low: c.last, Close: c.last}
+			c.report.Filled++
+		default:
+			c.report.Dropped++
+			return nil
+		}
+	}
+	c.hasLast = true
+	c.last = bar.Close
+	if !bar.Time.IsZero() {
+		c.times = append(c.times, bar.Time)
+	}
+	c.series.Bars = append(c.series.Bars, bar)
+	return nil
+}
+
+// finish sorts the series by date when every bar has one and completes the report.
+func (c *collector) finish(gapFactor float64) (Series, Report) {
+	ascending, descending := 0, 0
+	for i := 1; i < len(c.times); i++ {
+		switch {
+		case c.times[i].After(c.times[i-1]):
+			ascending++
+		case c.times[i].Before(c.times[i-1]):
+			descending++
+		}
+	}
+	c.report.OutOfOrder = ascending
+	if descending < ascending {
+		c.report.OutOfOrder = descending
+	}
+
+	if hasTimes(c.series.Bars) {
+		c.series.SortByTime()
+		c.report.Duplicates, c.report.Gaps = checkSpacing(c.series.Bars, gapFactor)
+	}
+	c.report.Kept = len(c.series.Bars)
+	return c.series, c.report
+}
+
+// checkSpacing counts duplicate dates and finds gaps in chronologically sorted bars.
+func checkSpacing(bars []Bar, gapFactor float64) (int, []Gap) {
+	if gapFactor <= 0 {
+		gapFactor = 5
+	}
+
+	duplicates := 0
+	spacings := make([]time.Duration, 0, len(bars))
+	for i := 1; i < len(bars); i++ {
+		d := bars[i].Time.Sub(bars[i-1].Time)
+		if d == 0 {
+			duplicates++
+			continue
+		}
+		spacings = append(spacings, d)
+	}
+	if len(spacings) == 0 {
+		return duplicates, nil
+	}
+
+	sorted := append([]time.Duration(nil), spacings...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	limit := time.Duration(float64(sorted[len(sorted)/2]) * gapFactor)
+
+	var gaps []Gap
+	for i := 1; i < len(bars); i++ {
+		if bars[i].Time.Sub(bars[i-1].Time) > limit {
+			gaps = append(gaps, Gap{From: bars[i-1].Time, To: bars[i].Time})
+		}
+	}
+	return duplicates, gaps
+}
@@ -0,0 +1,181 @@
+package env
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// Names of the rules of Constraints, as reported in violations.
+const (
+	RuleMaxEquityWeight  = "max_equity_weight"
+	RuleMinCashBuffer    = "min_cash_buffer"
+	RuleMaxDailyTurnover = "max_daily_turnover"
+)
+
+// Constraints are portfolio rules enforced at execution, e.g. from the constraints
+// block of a training config:
+//
+//	constraints:
+//	  max_equity_weight: 0.8   # at most 80% of the portfolio in the asset
+//	  min_cash_buffer: 0.05    # keep at least 5% in cash
+//	  max_daily_turnover: 0.5  # trade at most half the portfolio value a day
+//
+// Zero disables a rule.
+type Constraints struct {
+	MaxEquityWeight  float64 `yaml:"max_equity_weight"`
+	MinCashBuffer    float64 `yaml:"min_cash_buffer"`
+	MaxDailyTurnover float64 `yaml:"max_daily_turnover"`
+}
+
+// Empty reports whether no rule is set.
+func (c Constraints) Empty() bool {
+	return c == Constraints{}
+}
+
+// Validate checks that the rules are within their ranges.
+func (c Constraints) Validate() error {
+	if c.MaxEquityWeight < 0 || c.MaxEquityWeight > 1 {
+		return fmt.Errorf("%s must be in [0, 1], got %g", RuleMaxEquityWeight, c.MaxEquityWeight)
+	}
+	if c.MinCashBuffer < 0 || c.MinCashBuffer >= 1 {
+		return fmt.Errorf("%s must be in [0, 1), got %g", RuleMinCashBuffer, c.MinCashBuffer)
+	}
+	if c.MaxDailyTurnover < 0 {
+		return fmt.Errorf("%s must not be negative, got %g", RuleMaxDailyTurnover, c.MaxDailyTurnover)
+	}
+	return nil
+}
+
+// Violation is an action the Enforcer cut down because it would have broken a rule.
+type Violation struct {
+	PriceIdx int
+	Time     time.Time // Zero without a clock
+	Rule     string    // One of the Rule constants
+	Value    float64   // What the action would have reached
+	Limit    float64
+	Action   agent.Action // As chosen
+	Executed agent.Action // As cut down
+}
+
+// String describes the violation on one line.
+func (v Violation) String() string {
+	when := fmt.Sprintf("index %d", v.PriceIdx)
+	if !v.Time.IsZero() {
+		when = v.Time.Format("2006-01-02 15:04")
+	}
+	return fmt.Sprintf("%s: %s would reach %s %.4f (limit %.4f), executed %s", when, v.Action, v.Rule, v.Value, v.Limit, v.Executed)
+}
+
+// Enforcer applies Constraints to the trades of the MarketEnv at the bottom of the
+// wrapped environment. An action that would break a rule is cut down, a large trade
+// to a small one and a small one to holding, until it complies; trades reducing a
+// breach already there are allowed. Turnover is the notional traded over the
+// portfolio value, summed over the calendar day with a clock, or per step without.
+type Enforcer struct {
+	Wrapper
+	Constraints Constraints
+	// Log, if set, is called with every violation.
+	Log func(Violation)
+
+	market     *MarketEnv
+	violations int
+	day        time.Time
+	turnover   float64 // Traded today, as a fraction of the portfolio value
+}
+
+// NewEnforcer wraps e with constraints, logging violations with log (nil to only
+// count them). It panics if e has no MarketEnv at the bottom.
+func NewEnforcer(e Environment, constraints Constraints, log func(Violation)) *Enforcer {
+	market, ok := Market(e)
+	if !ok {
+		panic("env: Enforcer needs a MarketEnv")
+	}
+	return &Enforcer{Wrapper: Wrapper{Env: e}, Constraints: constraints, Log: log, market: market}
+}
+
+// Reset resets the inner environment and the episode's violations and turnover.
+func (f *Enforcer) Reset() state.State {
+	f.violations = 0
+	f.day, f.turnover = time.Time{}, 0
+	return f.Env.Reset()
+}
+
+// Step cuts action down until it complies and steps the inner environment with it.
+func (f *Enforcer) Step(action agent.Action) (next state.State, reward float64, done bool) {
+	m := f.market
+	t := m.Time()
+	if day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()); day.IsZero() || !day.Equal(f.day) {
+		f.day, f.turnover = day, 0
+	}
+
+	executed := action
+	var violation *Violation
+	for executed.IsTrade() {
+		rule, value, limit := f.check(executed)
+		if rule == "" {
+			break
+		}
+		if violation == nil {
+			violation = &Violation{PriceIdx: m.CurrentIdx(), Time: m.Time(), Rule: rule, Value: value, Limit: limit, Action: action}
+		}
+		executed = weaker(executed)
+	}
+	if violation != nil {
+		violation.Executed = executed
+		f.violations++
+		if f.Log != nil {
+			f.Log(*violation)
+		}
+	}
+
+	price, sharesBefore, valueBefore := m.CurrentPrice(), m.Shares(), m.PortfolioValue()
+	next, reward, done = f.Env.Step(executed)
+	if valueBefore > 0 {
+		traded := m.Shares() - sharesBefore
+		f.turnover += max(traded, -traded) * price / valueBefore
+	}
+	return next, reward, done
+}
+
+// check returns the first rule action would break, with the value it would reach
+// and the rule's limit, or "" if it complies.
+func (f *Enforcer) check(action agent.Action) (rule string, value, limit float64) {
+	m, c := f.market, f.Constraints
+	price := m.CurrentPrice()
+	valueBefore := m.PortfolioValue()
+	cash, shares := m.holdingsAfter(action)
+	valueAfter := cash + shares*price
+	if valueBefore <= 0 || valueAfter <= 0 {
+		return "", 0, 0
+	}
+	if weight := shares * price / valueAfter; c.MaxEquityWeight > 0 && weight > c.MaxEquityWeight && shares > m.Shares() {
+		return RuleMaxEquityWeight, weight, c.MaxEquityWeight
+	}
+	if buffer := cash / valueAfter; c.MinCashBuffer > 0 && buffer < c.MinCashBuffer && cash < m.Cash() {
+		return RuleMinCashBuffer, buffer, c.MinCashBuffer
+	}
+	traded := shares - m.Shares()
+	if turnover := f.turnover + max(traded, -traded)*price/valueBefore; c.MaxDailyTurnover > 0 && turnover > c.MaxDailyTurnover {
+		return RuleMaxDailyTurnover, turnover, c.MaxDailyTurnover
+	}
+	return "", 0, 0
+}
+
+// weaker returns the next smaller trade of the same direction, or holding.
+func weaker(action agent.Action) agent.Action {
+	switch action {
+	case agent.ActionBuyLarge:
+		return agent.ActionBuySmall
+	case agent.ActionSellLarge:
+		return agent.ActionSellSmall
+	}
+	return agent.ActionNothing
+}
+
+// Violations returns the number of actions cut down since the start of the episode.
+func (f *Enforcer) Violations() int {
+	return f.violations
+}
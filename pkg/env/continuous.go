@@ -0,0 +1,120 @@
+package env
+
+import (
+	"math"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// ContinuousEnvironment is an environment acting on target weights rather than the
+// discrete actions of Environment.
+type ContinuousEnvironment interface {
+	// Reset resets the environment and returns the initial state.
+	Reset() state.State
+	// StepContinuous trades to the target weight and returns the next state,
+	// reward, and done flag.
+	StepContinuous(target agent.ContinuousAction) (next state.State, reward float64, done bool)
+}
+
+// StepContinuous trades to hold the target weight of the portfolio value in the
+// asset, within the position limit, then moves to the next price like Step. The
+// trade is sized before commission and slippage, so the weight reached is slightly
+// below a higher target; the volatility target and Sizing do not apply. Halts block
+// the trade like the discrete ones.
+func (e *MarketEnv) StepContinuous(target agent.ContinuousAction) (next state.State, reward float64, done bool) {
+	if e.currentIdx >= len(e.prices)-1 {
+		return e.getState(), 0.0, true
+	}
+
+	reward = e.settle(func(price float64) { e.rebalance(float64(target.Clamp()), price) })
+	e.positions.update(e)
+	e.currentIdx++
+	done = e.currentIdx >= len(e.prices)-1
+	return e.getState(), reward, done
+}
+
+// rebalance trades at price towards holding weight of the portfolio in the asset.
+func (e *MarketEnv) rebalance(weight, price float64) {
+	if e.limited() {
+		weight = min(weight, e.maxWeight)
+	}
+	value := e.cash + e.shares*price
+	delta := weight*value - e.shares*price
+	if delta != 0 && e.blocked() {
+		return
+	}
+	switch {
+	case delta > 0:
+		fill := e.fillPrice(agent.ActionBuyLarge, price)
+		e.buyShares(min(delta/(1-e.commission), e.cash), fill, value)
+	case delta < 0:
+		fill := e.fillPrice(agent.ActionSellLarge, price)
+		e.sellShares(min(-delta/price, e.shares), fill, value)
+	}
+}
+
+// Weight returns the share of the portfolio value held in the asset.
+func (e *MarketEnv) Weight() float64 {
+	value := e.PortfolioValue()
+	if value <= 0 {
+		return 0
+	}
+	return e.shares * e.CurrentPrice() / value
+}
+
+// Discretizer adapts an environment of discrete actions to target weights: every
+// target is replaced by the allowed discrete action whose trade comes closest to
+// it, so agents with continuous actions run on any stack of wrappers. It needs a
+// MarketEnv at the bottom of the wrappers.
+type Discretizer struct {
+	Wrapper
+
+	market *MarketEnv
+}
+
+// NewDiscretizer wraps e to take target weights. It panics if e has no MarketEnv
+// at the bottom.
+func NewDiscretizer(e Environment) *Discretizer {
+	market, ok := Market(e)
+	if !ok {
+		panic("env: Discretizer needs a MarketEnv")
+	}
+	return &Discretizer{Wrapper: Wrapper{Env: e}, market: market}
+}
+
+// StepContinuous steps the inner environment with the discrete action closest to
+// target.
+func (d *Discretizer) StepContinuous(target agent.ContinuousAction) (next state.State, reward float64, done bool) {
+	return d.Env.Step(d.Discretize(target))
+}
+
+// Discretize returns the allowed action whose trade leaves the weight closest to
+// target, preferring holding, then the smaller trades, on ties.
+func (d *Discretizer) Discretize(target agent.ContinuousAction) agent.Action {
+	allowed := d.ActionMask()
+	best, bestGap := agent.ActionNothing, math.Inf(1)
+	for _, a := range []agent.Action{agent.ActionNothing, agent.ActionBuySmall, agent.ActionSellSmall, agent.ActionBuyLarge, agent.ActionSellLarge} {
+		if allowed != nil && !allowed[a] {
+			continue
+		}
+		if gap := math.Abs(d.market.weightAfter(a) - float64(target.Clamp())); gap < bestGap {
+			best, bestGap = a, gap
+		}
+	}
+	return best
+}
+
+// weightAfter returns the weight of the asset action would leave at the current
+// price, without taking it.
+func (e *MarketEnv) weightAfter(action agent.Action) float64 {
+	if e.currentIdx >= len(e.prices) {
+		return 0
+	}
+	cash, shares := e.holdingsAfter(action)
+	value := cash + shares*e.values[e.currentIdx]
+	if value <= 0 {
+		return 0
+	}
+	return shares * e.values[e.currentIdx] / value
+}
@@ -12,3 +12,10 @@ type Environment interface {
 	// Step executes an action and returns the next state, reward, and done flag.
 	Step(action agent.Action) (next state.State, reward float64, done bool)
 }
+
+// Masker is implemented by environments that restrict the actions allowed at the
+// current step, e.g. by position limits.
+type Masker interface {
+	// ActionMask returns the allowed actions indexed by action, or nil if all are.
+	ActionMask() []bool
+}
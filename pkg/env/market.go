@@ -1,35 +1,167 @@
 package env
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/kasaderos/rLportfolio/pkg/agent"
-	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
 	"github.com/kasaderos/rLportfolio/pkg/state"
 )
 
 // MarketEnv implements a market trading environment for portfolio optimization.
 type MarketEnv struct {
 	prices       []float64
+	values       []float64 // Prices in the base currency; prices itself without FX
+	fx           []float64
 	returns      []float64
 	currentIdx   int
 	cash         float64
 	shares       float64
+	startCash    float64
+	startShares  float64
+	startCost    float64 // Cost basis of startShares for the tax lots
 	initialValue float64
 	startIdx     int
 	commission   float64
+	slippage     float64
+	encoder      state.Encoder
+	reward       RewardFunc
+	maxWeight    float64
+	volTarget    float64
+	volWindow    int
+	sizeScale    float64 // Factor applied to buy sizes at the current step
+	sizing       Sizing
+	kellyWindow  int
+	clock        *data.Clock
+	periods      float64 // Steps per year annualizing the volatility target
+	ledger       *ledger // Tax lots, nil without tax-lot accounting
+	taxRate      float64
+	// Trades below minTradeWeight of the portfolio value or minTradeValue are held
+	minTradeWeight float64
+	minTradeValue  float64
+	// Commissions are clamped to [commissionMin, commissionMax] in the fee
+	// currency, converted by feeFX
+	commissionMin float64
+	commissionMax float64
+	feeFX         []float64
+	feesPaid      float64 // Commission paid in the episode, in the base currency
+	// Buys fill at ask and sells at bid, in the base currency, when set
+	bid           []float64
+	ask           []float64
+	spreadPaid    float64 // Cost of the fills against the prices in the episode
+	halted        []bool  // Bars on which no trade executes, nil without halts
+	blockedOrders int     // Trades blocked by halts in the episode
+	positions     *Positions
+	tradePenalty  float64 // Part of the last step's reward lost to trading costs
 }
 
 // MarketConfig holds configuration for the market environment.
 type MarketConfig struct {
 	Prices      []float64
 	InitialCash float64
-	MinStartIdx int
+	MinStartIdx int // Index of the first decision, at least the encoder's WarmUp
 	Commission  float64
+	Encoder     state.Encoder // Defaults to state.MAEncoder
+	Reward      RewardFunc    // Defaults to CalculateReward (log return)
+	// Slippage is the fraction of the price trades lose to the spread and market
+	// impact: buys fill at price·(1+Slippage), sells at price·(1-Slippage).
+	Slippage float64
+	// ApproxM, when positive, adds a local approximation forecast of the next return
+	// to the state: the encoder is wrapped in a state.LAMEncoder with windows of
+	// ApproxM returns and ApproxN neighbors (0 means state.DefaultApproxN).
+	ApproxM int
+	ApproxN int
+	// MaxWeight, when in (0, 1), caps the share of the portfolio value held in the
+	// asset: buys are cut down to the cap at execution, and ActionMask disallows
+	// them once it is reached.
+	MaxWeight float64
+	// VolTarget, when positive, is an annualized volatility the buy sizes are scaled
+	// to: by VolTarget over the asset's volatility in the last VolWindow returns
+	// (default DefaultVolWindow), at most MaxVolScale.
+	VolTarget float64
+	VolWindow int
+	// Sizing selects how buy actions are sized; SizingKelly buys up to a fraction
+	// of the Kelly weight estimated over the last KellyWindow returns (default
+	// DefaultKellyWindow).
+	Sizing      Sizing
+	KellyWindow int
+	// Clock, if set, holds the timestamp of every price. Steps are then bars of the
+	// clock rather than trading days: the volatility target is annualized by the
+	// clock's PeriodsPerYear, and Time reports the bar of the current step.
+	Clock *data.Clock
+	// FX, if set, holds for every price the value of one unit of the price's
+	// currency in the base currency (see data.FXRates). Cash, trades, portfolio
+	// values, and rewards are then in the base currency, while the encoder still
+	// sees the prices in their own currency.
+	FX []float64
+	// Lots, unless LotNone, tracks the tax lots of the position and the gains sells
+	// realize (see Realized), closing lots in FIFO or LIFO order. TaxRate, when
+	// positive, subtracts the tax on the net gain a step realizes from the portfolio
+	// value the reward is computed from, a credit for a net loss; the tax is not
+	// deducted from cash.
+	Lots    LotMethod
+	TaxRate float64
+	// InitialShares starts every episode with an existing position besides the
+	// InitialCash, e.g. to deploy the policy on a portfolio mid-stream; InitialCash
+	// then defaults to 0 instead of 10000. InitialCost is the shares' cost basis for
+	// the tax lots; 0 means their value at the start.
+	InitialShares float64
+	InitialCost   float64
+	// MinTradeWeight and MinTradeValue, when positive, throttle dust trades: a trade
+	// whose notional is below MinTradeWeight of the portfolio value (e.g. 0.01) or
+	// below MinTradeValue in the base currency (e.g. 10) is not executed, and the
+	// step holds instead, paying no commission.
+	MinTradeWeight float64
+	MinTradeValue  float64
+	// CommissionMin and CommissionMax, when positive, are a floor and a cap on the
+	// commission of a trade, e.g. a broker's $1 minimum. They are in the fee
+	// currency: FeeFX, if set, holds for every price the value of one unit of it in
+	// the base currency; without it they are in the base currency. A trade whose
+	// commission would eat all of it is not executed. Position limits still assume
+	// a proportional commission.
+	CommissionMin float64
+	CommissionMax float64
+	FeeFX         []float64
+	// Bid and Ask, if set, hold the quotes of every price in the prices' currency
+	// (see data.LoadQuotes): buys fill at the ask and sells at the bid, before
+	// Slippage, while the portfolio is still valued at the prices. This evaluates a
+	// policy trained on mid prices with realistic fills; SpreadCost reports what
+	// crossing the spread cost.
+	Bid []float64
+	Ask []float64
+	// Halted, if set, flags the prices at which the asset cannot be traded, e.g.
+	// trading halts or limit-up/limit-down locks (see data.Series.Halts): trades
+	// there are not executed, the step holds instead, and BlockedOrders counts
+	// them.
+	Halted []bool
+	// Positions, if set, is shared by the MarketEnvs of the assets of one portfolio
+	// and caps how many of them hold shares at once: once Positions.Max assets are
+	// held, buys of the others are not executed and ActionMask disallows them.
+	Positions *Positions
 }
 
-// NewMarketEnv creates a new market environment.
+// NewMarketEnv creates a new market environment. It panics if FeeFX, Bid, Ask, or
+// Halted are set but not aligned with Prices.
 func NewMarketEnv(config MarketConfig) *MarketEnv {
+	for _, aligned := range []struct {
+		name   string
+		series []float64
+	}{{"fee FX rates", config.FeeFX}, {"bids", config.Bid}, {"asks", config.Ask}} {
+		if aligned.series != nil && len(aligned.series) != len(config.Prices) {
+			panic(fmt.Sprintf("env: %d %s for %d prices", len(aligned.series), aligned.name, len(config.Prices)))
+		}
+	}
+	if config.Halted != nil && len(config.Halted) != len(config.Prices) {
+		panic(fmt.Sprintf("env: %d halt flags for %d prices", len(config.Halted), len(config.Prices)))
+	}
+	config.InitialShares = max(config.InitialShares, 0)
 	if config.InitialCash <= 0 {
-		config.InitialCash = 10000.0
+		config.InitialCash = 0
+		if config.InitialShares == 0 {
+			config.InitialCash = 10000.0
+		}
 	}
 	if config.MinStartIdx < 1 {
 		config.MinStartIdx = 20
@@ -37,33 +169,104 @@ func NewMarketEnv(config MarketConfig) *MarketEnv {
 	if config.Commission <= 0 {
 		config.Commission = 0.002 // Default 0.2% commission
 	}
+	if config.Encoder == nil {
+		config.Encoder = state.NewMAEncoder()
+	}
+	if config.ApproxM > 0 {
+		config.Encoder = state.NewLAMEncoder(config.Encoder, config.ApproxM, config.ApproxN)
+	}
+	if series, ok := config.Encoder.(state.SeriesEncoder); ok {
+		// Precompute per-series data (e.g. MA prefix sums) once instead of every step
+		config.Encoder = series.ForSeries(config.Prices)
+	}
+	if config.Reward == nil {
+		config.Reward = CalculateReward
+	}
+	if config.VolWindow < 2 {
+		config.VolWindow = DefaultVolWindow
+	}
+	if config.KellyWindow < 2 {
+		config.KellyWindow = DefaultKellyWindow
+	}
+
+	periods := float64(metrics.TradingDaysPerYear)
+	if config.Clock != nil {
+		periods = config.Clock.PeriodsPerYear()
+	}
 
 	// Calculate returns (still used for other purposes if needed)
 	returns := simpleReturns(config.Prices)
 
-	// Determine start index (need at least 120 prices for all MAs to be available)
-	startIdx := 120 // Need MA120 which requires 120 prices
-	if startIdx < config.MinStartIdx {
-		startIdx = config.MinStartIdx
-	}
+	// Start once the encoder has the history for a full state
+	startIdx := max(config.Encoder.WarmUp(), config.MinStartIdx)
 
-	return &MarketEnv{
+	marketEnv := &MarketEnv{
 		prices:       config.Prices,
+		values:       ToBase(config.Prices, config.FX),
+		fx:           config.FX,
 		returns:      returns,
 		currentIdx:   startIdx,
 		cash:         config.InitialCash,
-		shares:       0.0,
+		shares:       config.InitialShares,
+		startCash:    config.InitialCash,
+		startShares:  config.InitialShares,
+		startCost:    config.InitialCost,
 		initialValue: config.InitialCash,
 		startIdx:     startIdx,
 		commission:   config.Commission,
+		slippage:     max(config.Slippage, 0),
+		encoder:      config.Encoder,
+		reward:       config.Reward,
+		maxWeight:    config.MaxWeight,
+		volTarget:    config.VolTarget,
+		volWindow:    config.VolWindow,
+		sizeScale:    1,
+		sizing:       config.Sizing,
+		kellyWindow:  config.KellyWindow,
+		clock:        config.Clock,
+		periods:      periods,
+	}
+	marketEnv.minTradeWeight = max(config.MinTradeWeight, 0)
+	marketEnv.minTradeValue = max(config.MinTradeValue, 0)
+	marketEnv.commissionMin = max(config.CommissionMin, 0)
+	marketEnv.commissionMax = max(config.CommissionMax, 0)
+	marketEnv.feeFX = config.FeeFX
+	marketEnv.halted = config.Halted
+	marketEnv.positions = config.Positions
+	if config.Bid != nil && config.Ask != nil {
+		marketEnv.bid, marketEnv.ask = ToBase(config.Bid, config.FX), ToBase(config.Ask, config.FX)
 	}
+	if startIdx < len(config.Prices) {
+		marketEnv.initialValue += config.InitialShares * marketEnv.values[startIdx]
+	}
+	if config.Lots != LotNone {
+		marketEnv.ledger = &ledger{method: config.Lots, longTerm: marketEnv.longTerm}
+		marketEnv.taxRate = max(config.TaxRate, 0)
+	}
+	return marketEnv
 }
 
 // Reset resets the environment to the initial state.
 func (e *MarketEnv) Reset() state.State {
 	e.currentIdx = e.startIdx
-	e.cash = e.initialValue
-	e.shares = 0.0
+	e.cash = e.startCash
+	e.shares = e.startShares
+	e.sizeScale = 1
+	e.feesPaid = 0
+	e.spreadPaid = 0
+	e.blockedOrders = 0
+	e.tradePenalty = 0
+	if e.ledger != nil {
+		e.ledger.reset()
+		if e.startShares > 0 {
+			cost := e.startCost
+			if cost <= 0 {
+				cost = e.initialValue - e.startCash
+			}
+			e.ledger.lots = append(e.ledger.lots, Lot{Opened: e.startIdx, Shares: e.startShares, Cost: cost})
+		}
+	}
+	e.positions.update(e)
 	return e.getState()
 }
 
@@ -73,14 +276,8 @@ func (e *MarketEnv) Step(action agent.Action) (next state.State, reward float64,
 		return e.getState(), 0.0, true
 	}
 
-	currentPrice := e.prices[e.currentIdx]
-	nextPrice := e.prices[e.currentIdx+1]
-
-	// Execute action and calculate reward
-	portfolioValueBefore := e.cash + e.shares*currentPrice
-	e.executeAction(action, currentPrice)
-	portfolioValueAfter := e.cash + e.shares*nextPrice
-	reward = CalculateReward(portfolioValueBefore, portfolioValueAfter)
+	reward = e.stepReward(action)
+	e.positions.update(e)
 
 	// Move to next time step
 	e.currentIdx++
@@ -94,70 +291,253 @@ func (e *MarketEnv) Step(action agent.Action) (next state.State, reward float64,
 	return next, reward, done
 }
 
-// getState computes the current state using moving average ordering, convergence/divergence, and portfolio position.
+// WhatIf returns the reward Step would give for action at the current step, without
+// taking it, to compare the chosen action with the alternatives in hindsight.
+func (e *MarketEnv) WhatIf(action agent.Action) float64 {
+	if e.currentIdx >= len(e.prices)-1 {
+		return 0
+	}
+	cash, shares, sizeScale, feesPaid, spreadPaid, blocked := e.cash, e.shares, e.sizeScale, e.feesPaid, e.spreadPaid, e.blockedOrders
+	tradePenalty := e.tradePenalty
+	var lots ledgerState
+	if e.ledger != nil {
+		lots = e.ledger.save()
+	}
+	reward := e.stepReward(action)
+	e.cash, e.shares, e.sizeScale, e.feesPaid, e.spreadPaid, e.blockedOrders = cash, shares, sizeScale, feesPaid, spreadPaid, blocked
+	e.tradePenalty = tradePenalty
+	if e.ledger != nil {
+		e.ledger.restore(lots)
+	}
+	return reward
+}
+
+// holdingsAfter returns the cash and shares action would leave at the current
+// price, without taking it.
+func (e *MarketEnv) holdingsAfter(action agent.Action) (cash, shares float64) {
+	cashBefore, sharesBefore, feesPaid, spreadPaid := e.cash, e.shares, e.feesPaid, e.spreadPaid
+	e.executeAction(action, e.values[e.currentIdx])
+	cash, shares = e.cash, e.shares
+	e.cash, e.shares, e.feesPaid, e.spreadPaid = cashBefore, sharesBefore, feesPaid, spreadPaid
+	return cash, shares
+}
+
+// stepReward executes action at the current price and returns the reward of the
+// move to the next price; the caller advances the index.
+func (e *MarketEnv) stepReward(action agent.Action) float64 {
+	if action.IsTrade() && e.blocked() {
+		action = agent.ActionNothing
+	}
+	return e.settle(func(price float64) { e.executeAction(action, price) })
+}
+
+// settle trades with execute at the current price and returns the reward of the
+// move to the next price.
+func (e *MarketEnv) settle(execute func(price float64)) float64 {
+	currentPrice := e.values[e.currentIdx]
+	nextPrice := e.values[e.currentIdx+1]
+
+	if e.volTarget > 0 {
+		e.sizeScale = e.volScale()
+	}
+
+	// Execute action and calculate reward
+	portfolioValueBefore := e.cash + e.shares*currentPrice
+	cashBefore, sharesBefore := e.cash, e.shares
+	execute(currentPrice)
+	portfolioValueAfter := e.cash + e.shares*nextPrice
+	// Commission, slippage, and tax are what the trade cost on top of the price move
+	costs := portfolioValueBefore - (e.cash + e.shares*currentPrice)
+	if e.ledger != nil {
+		gain := e.ledger.record(e.currentIdx, e.shares-sharesBefore, e.cash-cashBefore)
+		portfolioValueAfter -= e.taxRate * gain
+		costs += e.taxRate * gain
+	}
+	reward := e.reward(portfolioValueBefore, portfolioValueAfter)
+	e.tradePenalty = 0
+	if costs != 0 {
+		e.tradePenalty = reward - e.reward(portfolioValueBefore, portfolioValueAfter+costs)
+	}
+	return reward
+}
+
+// blocked reports whether trading is halted at the current price, counting the
+// order it blocks.
+func (e *MarketEnv) blocked() bool {
+	if e.halted == nil || !e.halted[e.currentIdx] {
+		return false
+	}
+	e.blockedOrders++
+	return true
+}
+
+// getState computes the current state using the configured state encoder.
 func (e *MarketEnv) getState() state.State {
 	if e.currentIdx < e.startIdx || e.currentIdx >= len(e.prices) {
 		// Return a default state if we don't have enough data
 		return state.NewState(0, 1, 0, 0) // Neutral divergence
 	}
+	cash := e.cash
+	if e.fx != nil {
+		// Positions are categorized by value, so the cash is converted into the price's currency
+		cash /= e.fx[e.currentIdx]
+	}
+	return e.encoder.Encode(e.prices, e.currentIdx, cash, e.shares)
+}
 
-	// Need at least 120 prices for all MAs to be available
-	if e.currentIdx < 120 {
-		return state.NewState(0, 1, 0, 0) // Neutral divergence
+// executeAction executes the action and updates cash and shares. Trades below the
+// minimum trade size, or whose commission exceeds them, are not executed.
+func (e *MarketEnv) executeAction(action agent.Action, price float64) {
+	value := e.cash + e.shares*price
+	fill := e.fillPrice(action, price)
+	switch {
+	case action.IsBuy():
+		e.buyShares(e.buyCost(action, fill), fill, value)
+	case action.IsSell():
+		fraction := agent.SellSmall
+		if action == agent.ActionSellLarge {
+			fraction = agent.SellLarge
+		}
+		e.sellShares(e.shares*fraction, fill, value)
 	}
+}
 
-	// Get moving average ordering state
-	maState := ma.GetMAStateForIndex(e.prices, e.currentIdx)
+// buyShares spends cost, commission included, on shares filled at price, for a
+// portfolio worth value.
+func (e *MarketEnv) buyShares(cost, price, value float64) {
+	fee := e.fee(cost)
+	bought := (cost - fee) / price
+	if cost <= 0 || fee >= cost || e.dust(bought*price, value) || e.positions.full(e) {
+		return
+	}
+	e.cash, e.shares = e.cash-cost, e.shares+bought
+	e.feesPaid += fee
+	if e.ask != nil {
+		e.spreadPaid += bought * (e.ask[e.currentIdx] - e.values[e.currentIdx])
+	}
+}
 
-	// Get MA convergence/divergence state
-	maDivergence := ma.GetMADivergenceState(e.prices, e.currentIdx)
+// sellShares sells shares filled at price, for a portfolio worth value.
+func (e *MarketEnv) sellShares(shares, price, value float64) {
+	notional := shares * price
+	fee := e.fee(notional)
+	if notional <= 0 || fee >= notional || e.dust(notional, value) {
+		return
+	}
+	e.cash, e.shares = e.cash+(notional-fee), e.shares-shares
+	e.feesPaid += fee
+	if e.bid != nil {
+		e.spreadPaid += shares * (e.values[e.currentIdx] - e.bid[e.currentIdx])
+	}
+}
+
+// fee returns the commission of a trade of notional in the base currency.
+func (e *MarketEnv) fee(notional float64) float64 {
+	fee := notional * e.commission
+	if e.commissionMin <= 0 && e.commissionMax <= 0 {
+		return fee
+	}
+	rate := 1.0
+	if e.feeFX != nil {
+		rate = e.feeFX[e.currentIdx]
+	}
+	fee = max(fee, e.commissionMin*rate)
+	if e.commissionMax > 0 {
+		fee = min(fee, e.commissionMax*rate)
+	}
+	return fee
+}
 
-	// Get portfolio position categories
-	currentPrice := e.prices[e.currentIdx]
-	portfolioValue := e.cash + e.shares*currentPrice
-	sharesValue := e.shares * currentPrice
-	cashCat := state.GetCashCategory(e.cash, portfolioValue)
-	sharesCat := state.GetSharesCategory(sharesValue, portfolioValue)
+// dust reports whether a trade of notional is below the minimum trade size, for a
+// portfolio worth value.
+func (e *MarketEnv) dust(notional, value float64) bool {
+	if notional == 0 {
+		return false
+	}
+	return notional < e.minTradeValue || notional < e.minTradeWeight*value
+}
 
-	return state.NewState(maState, maDivergence, cashCat, sharesCat)
+// fillPrice returns the price a trade of the action fills at, at the quote with
+// quotes, after slippage.
+func (e *MarketEnv) fillPrice(action agent.Action, price float64) float64 {
+	switch {
+	case action.IsBuy():
+		if e.ask != nil {
+			price = e.ask[e.currentIdx]
+		}
+		return price * (1 + e.slippage)
+	case action.IsSell():
+		if e.bid != nil {
+			price = e.bid[e.currentIdx]
+		}
+		return price * (1 - e.slippage)
+	}
+	return price
 }
 
-// executeAction executes the action and updates cash and shares.
-func (e *MarketEnv) executeAction(action agent.Action, price float64) {
+// limited reports whether a weight limit is set.
+func (e *MarketEnv) limited() bool {
+	return e.maxWeight > 0 && e.maxWeight < 1
+}
+
+// buyLimit returns the most cash a buy at price may spend without the asset's weight
+// exceeding the maximum after commission.
+func (e *MarketEnv) buyLimit(price float64) float64 {
+	value := e.cash + e.shares*price
+	// Spending c leaves shares worth sharesValue + c(1-k) out of value - c·k
+	limit := (e.maxWeight*value - e.shares*price) / (1 - e.commission + e.maxWeight*e.commission)
+	return max(limit, 0)
+}
+
+// ActionMask returns the actions allowed at the current step, indexed by action,
+// or nil when no position limit is set and every action is allowed.
+func (e *MarketEnv) ActionMask() []bool {
+	if !e.limited() && e.positions == nil {
+		return nil
+	}
+	allowed := make([]bool, agent.NumActions)
+	for a := range allowed {
+		allowed[a] = true
+	}
+	if price := e.CurrentPrice(); e.positions.full(e) || e.limited() && price > 0 && e.buyLimit(price) < 1e-9*(e.cash+e.shares*price) {
+		allowed[agent.ActionBuySmall] = false
+		allowed[agent.ActionBuyLarge] = false
+	}
+	return allowed
+}
+
+// ApplyAction trades a fraction of cash (buys) or shares (sells) at price and returns
+// the new holdings and the commission paid. Sells are skipped without shares.
+func ApplyAction(action agent.Action, cash, shares, price, commission float64) (newCash, newShares, fee float64) {
 	switch action {
-	case agent.ActionNothing:
-		// No action
-	case agent.ActionBuySmall:
-		cost := e.cash * agent.BuySmall
-		commissionCost := cost * e.commission
-		e.cash -= cost
-		e.shares += (cost - commissionCost) / price
-	case agent.ActionBuyLarge:
-		cost := e.cash * agent.BuyLarge
-		commissionCost := cost * e.commission
-		e.cash -= cost
-		e.shares += (cost - commissionCost) / price
-	case agent.ActionSellSmall:
-		if e.shares <= 0 {
-			// Cannot sell if no shares available
-			return
+	case agent.ActionBuySmall, agent.ActionBuyLarge:
+		fraction := agent.BuySmall
+		if action == agent.ActionBuyLarge {
+			fraction = agent.BuyLarge
 		}
-		sellShares := e.shares * agent.SellSmall
-		proceeds := sellShares * price
-		commissionCost := proceeds * e.commission
-		e.cash += proceeds - commissionCost
-		e.shares -= sellShares
-	case agent.ActionSellLarge:
-		if e.shares <= 0 {
+		return buy(cash, shares, price, cash*fraction, commission)
+	case agent.ActionSellSmall, agent.ActionSellLarge:
+		if shares <= 0 {
 			// Cannot sell if no shares available
-			return
+			return cash, shares, 0
 		}
-		sellShares := e.shares * agent.SellLarge
+		fraction := agent.SellSmall
+		if action == agent.ActionSellLarge {
+			fraction = agent.SellLarge
+		}
+		sellShares := shares * fraction
 		proceeds := sellShares * price
-		commissionCost := proceeds * e.commission
-		e.cash += proceeds - commissionCost
-		e.shares -= sellShares
+		fee = proceeds * commission
+		cash += proceeds - fee
+		shares -= sellShares
 	}
+	return cash, shares, fee
+}
+
+// buy spends cost, commission included, on shares at price.
+func buy(cash, shares, price, cost, commission float64) (newCash, newShares, fee float64) {
+	fee = cost * commission
+	return cash - cost, shares + (cost-fee)/price, fee
 }
 
 // PortfolioValue returns the current portfolio value.
@@ -165,7 +545,7 @@ func (e *MarketEnv) PortfolioValue() float64 {
 	if e.currentIdx >= len(e.prices) {
 		return e.cash
 	}
-	return e.cash + e.shares*e.prices[e.currentIdx]
+	return e.cash + e.shares*e.values[e.currentIdx]
 }
 
 // Cash returns the current cash amount.
@@ -178,12 +558,12 @@ func (e *MarketEnv) Shares() float64 {
 	return e.shares
 }
 
-// CurrentPrice returns the current price.
+// CurrentPrice returns the current price, in the base currency with FX.
 func (e *MarketEnv) CurrentPrice() float64 {
 	if e.currentIdx >= len(e.prices) {
 		return 0.0
 	}
-	return e.prices[e.currentIdx]
+	return e.values[e.currentIdx]
 }
 
 // CurrentIdx returns the current price index.
@@ -191,16 +571,99 @@ func (e *MarketEnv) CurrentIdx() int {
 	return e.currentIdx
 }
 
+// StartIdx returns the price index at which episodes start.
+func (e *MarketEnv) StartIdx() int {
+	return e.startIdx
+}
+
 // Commission returns the commission rate.
 func (e *MarketEnv) Commission() float64 {
 	return e.commission
 }
 
+// CommissionPaid returns the commission paid since the start of the episode, in the
+// base currency. Slippage is not included.
+func (e *MarketEnv) CommissionPaid() float64 {
+	return e.feesPaid
+}
+
+// SpreadCost returns what filling at the bid and ask rather than the prices cost
+// since the start of the episode, in the base currency, or 0 without quotes.
+// Slippage is not included.
+func (e *MarketEnv) SpreadCost() float64 {
+	return e.spreadPaid
+}
+
+// TradePenalty returns the part of the last step's reward lost to trading costs:
+// the reward minus the reward the step would have earned had its trade been free of
+// commission, slippage, and tax. It is zero or negative, except for a tax credit,
+// and the rest of the reward is the return of the position over the step.
+func (e *MarketEnv) TradePenalty() float64 {
+	return e.tradePenalty
+}
+
+// BlockedOrders returns the number of trades blocked by halts since the start of the
+// episode.
+func (e *MarketEnv) BlockedOrders() int {
+	return e.blockedOrders
+}
+
+// Halted reports whether trading is halted at the current price.
+func (e *MarketEnv) Halted() bool {
+	return e.halted != nil && e.currentIdx < len(e.halted) && e.halted[e.currentIdx]
+}
+
+// Clock returns the timestamps of the prices, or nil without them.
+func (e *MarketEnv) Clock() *data.Clock {
+	return e.clock
+}
+
+// Time returns the timestamp of the current price, or the zero time without a clock.
+func (e *MarketEnv) Time() time.Time {
+	if e.clock == nil || e.currentIdx >= e.clock.Len() {
+		return time.Time{}
+	}
+	return e.clock.Time(e.currentIdx)
+}
+
+// Elapsed returns the calendar time from the previous price to the current one,
+// the span of the last step, or 0 without a clock.
+func (e *MarketEnv) Elapsed() time.Duration {
+	if e.clock == nil || e.currentIdx >= e.clock.Len() {
+		return 0
+	}
+	return e.clock.Elapsed(e.currentIdx)
+}
+
+// PeriodsPerYear returns the number of steps per year: metrics.TradingDaysPerYear,
+// or as measured by the clock.
+func (e *MarketEnv) PeriodsPerYear() float64 {
+	return e.periods
+}
+
+// Slippage returns the fraction of the price trades lose to slippage.
+func (e *MarketEnv) Slippage() float64 {
+	return e.slippage
+}
+
 // InitialValue returns the initial portfolio value.
 func (e *MarketEnv) InitialValue() float64 {
 	return e.initialValue
 }
 
+// ToBase converts prices into the base currency by their FX rates (see
+// MarketConfig.FX). Without rates it returns prices itself.
+func ToBase(prices, fx []float64) []float64 {
+	if fx == nil {
+		return prices
+	}
+	values := make([]float64, len(prices))
+	for i, p := range prices {
+		values[i] = p * fx[i]
+	}
+	return values
+}
+
 // simpleReturns calculates simple returns from price series.
 func simpleReturns(prices []float64) []float64 {
 	if len(prices) < 2 {
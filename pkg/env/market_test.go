@@ -0,0 +1,266 @@
+package env
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// randomWalk returns n deterministic prices.
+func randomWalk(n int) []float64 {
+	rng := rand.New(rand.NewSource(1))
+	prices := make([]float64, n)
+	p := 100.0
+	for i := range prices {
+		p *= 1 + rng.NormFloat64()*0.01
+		prices[i] = p
+	}
+	return prices
+}
+
+func newBenchEnv(encoder state.Encoder) *MarketEnv {
+	return NewMarketEnv(MarketConfig{
+		Prices:      randomWalk(5000),
+		InitialCash: 10000,
+		MinStartIdx: 120,
+		Commission:  0.002,
+		Encoder:     encoder,
+	})
+}
+
+// stepAll runs one episode cycling through the actions.
+func stepAll(e *MarketEnv) {
+	e.Reset()
+	for i := 0; ; i++ {
+		if _, _, done := e.Step(agent.Action(i % agent.NumActions)); done {
+			return
+		}
+	}
+}
+
+// plainEncoder hides MAEncoder's SeriesEncoder method so every step encodes from prices.
+type plainEncoder struct{ enc *state.MAEncoder }
+
+func (p plainEncoder) Encode(prices []float64, idx int, cash, shares float64) state.State {
+	return p.enc.Encode(prices, idx, cash, shares)
+}
+
+func (p plainEncoder) NumStates() int { return p.enc.NumStates() }
+
+func (p plainEncoder) WarmUp() int { return p.enc.WarmUp() }
+
+func TestStepDoesNotAllocate(t *testing.T) {
+	for name, encoder := range map[string]state.Encoder{
+		"precomputed": state.NewMAEncoder(),
+		"plain":       plainEncoder{state.NewMAEncoder()},
+	} {
+		e := newBenchEnv(encoder)
+		if allocs := testing.AllocsPerRun(5, func() { stepAll(e) }); allocs != 0 {
+			t.Errorf("%s: %.1f allocations per episode, want 0", name, allocs)
+		}
+	}
+}
+
+func BenchmarkStep(b *testing.B) {
+	e := newBenchEnv(nil)
+	e.Reset()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, done := e.Step(agent.Action(i % agent.NumActions)); done {
+			e.Reset()
+		}
+	}
+}
+
+func BenchmarkStepPlainEncoder(b *testing.B) {
+	e := newBenchEnv(plainEncoder{state.NewMAEncoder()})
+	e.Reset()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, done := e.Step(agent.Action(i % agent.NumActions)); done {
+			e.Reset()
+		}
+	}
+}
+
+func TestMinTradeSizeHoldsDustTrades(t *testing.T) {
+	for name, config := range map[string]MarketConfig{
+		"weight": {MinTradeWeight: 0.2},
+		"value":  {MinTradeValue: 2000},
+	} {
+		config.Prices = randomWalk(200)
+		config.InitialCash = 10000
+		e := NewMarketEnv(config)
+		e.Reset()
+
+		// 10% of the cash is below the minimum, 50% above
+		e.Step(agent.ActionBuySmall)
+		if e.Cash() != 10000 || e.Shares() != 0 {
+			t.Errorf("%s: small buy executed: cash %.2f, shares %.4f", name, e.Cash(), e.Shares())
+		}
+		e.Step(agent.ActionBuyLarge)
+		if e.Shares() == 0 {
+			t.Fatalf("%s: large buy held", name)
+		}
+		shares := e.Shares()
+		e.Step(agent.ActionSellSmall)
+		if e.Shares() != shares {
+			t.Errorf("%s: small sell executed: shares %.4f, want %.4f", name, e.Shares(), shares)
+		}
+	}
+}
+
+func TestCommissionFloorAndCap(t *testing.T) {
+	prices := randomWalk(200)
+	for name, tc := range map[string]struct {
+		config MarketConfig
+		want   float64
+	}{
+		"floor":         {MarketConfig{CommissionMin: 25}, 25},
+		"cap":           {MarketConfig{CommissionMax: 1}, 1},
+		"fee currency":  {MarketConfig{CommissionMin: 25, FeeFX: constant(len(prices), 1.2)}, 30},
+		"proportional":  {MarketConfig{CommissionMin: 1, CommissionMax: 100}, 10000 * agent.BuySmall * 0.002},
+		"too expensive": {MarketConfig{CommissionMin: 2000}, 0},
+	} {
+		tc.config.Prices = prices
+		tc.config.InitialCash = 10000
+		e := NewMarketEnv(tc.config)
+		e.Reset()
+		e.Step(agent.ActionBuySmall)
+		if got := e.CommissionPaid(); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s: commission %.4f, want %.4f", name, got, tc.want)
+		}
+		if tc.want == 0 && e.Shares() != 0 {
+			t.Errorf("%s: trade executed", name)
+		}
+	}
+}
+
+func TestMisalignedSeriesPanic(t *testing.T) {
+	prices := randomWalk(200)
+	for name, config := range map[string]MarketConfig{
+		"fee FX": {Prices: prices, CommissionMin: 1, FeeFX: constant(100, 1)},
+		"bid":    {Prices: prices, Bid: constant(100, 1), Ask: prices},
+		"ask":    {Prices: prices, Bid: prices, Ask: constant(100, 1)},
+		"halted": {Prices: prices, Halted: make([]bool, 100)},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic for 100 values of 200 prices", name)
+				}
+			}()
+			NewMarketEnv(config)
+		}()
+	}
+}
+
+func constant(n int, v float64) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = v
+	}
+	return values
+}
+
+func TestQuotesFillAtBidAndAsk(t *testing.T) {
+	prices := randomWalk(200)
+	bid, ask := make([]float64, len(prices)), make([]float64, len(prices))
+	for i, p := range prices {
+		bid[i], ask[i] = p*0.99, p*1.01
+	}
+	mid := NewMarketEnv(MarketConfig{Prices: prices, InitialCash: 10000})
+	quoted := NewMarketEnv(MarketConfig{Prices: prices, InitialCash: 10000, Bid: bid, Ask: ask})
+	for _, e := range []*MarketEnv{mid, quoted} {
+		e.Reset()
+		e.Step(agent.ActionBuyLarge)
+	}
+	if want := mid.Shares() / 1.01; math.Abs(quoted.Shares()-want) > 1e-9 {
+		t.Errorf("bought %.6f shares at the ask, want %.6f", quoted.Shares(), want)
+	}
+	bought := quoted.Shares()
+	idx := quoted.CurrentIdx()
+	quoted.Step(agent.ActionSellLarge)
+	want := bought*(ask[idx-1]-prices[idx-1]) + bought*agent.SellLarge*(prices[idx]-bid[idx])
+	if math.Abs(quoted.SpreadCost()-want) > 1e-9 {
+		t.Errorf("spread cost %.4f, want %.4f", quoted.SpreadCost(), want)
+	}
+	if mid.SpreadCost() != 0 {
+		t.Errorf("spread cost %.4f without quotes", mid.SpreadCost())
+	}
+}
+
+func TestHaltsBlockTrades(t *testing.T) {
+	prices := randomWalk(200)
+	halted := make([]bool, len(prices))
+	e := NewMarketEnv(MarketConfig{Prices: prices, InitialCash: 10000, Halted: halted})
+	e.Reset()
+	halted[e.CurrentIdx()], halted[e.CurrentIdx()+1] = true, true
+	e.Step(agent.ActionBuyLarge)
+	e.Step(agent.ActionNothing)
+	if e.Shares() != 0 || e.BlockedOrders() != 1 {
+		t.Errorf("shares %.4f, blocked orders %d; want 0 and 1", e.Shares(), e.BlockedOrders())
+	}
+	e.Step(agent.ActionBuyLarge)
+	if e.Shares() == 0 || e.BlockedOrders() != 1 {
+		t.Errorf("trade after the halt: shares %.4f, blocked orders %d", e.Shares(), e.BlockedOrders())
+	}
+	if e.Reset(); e.BlockedOrders() != 0 {
+		t.Errorf("blocked orders %d after reset", e.BlockedOrders())
+	}
+}
+
+func TestTradePenaltySplitsReward(t *testing.T) {
+	e := NewMarketEnv(MarketConfig{Prices: randomWalk(200), InitialCash: 10000, Commission: 0.002})
+	e.Reset()
+	if _, _, _ = e.Step(agent.ActionBuyLarge); e.TradePenalty() >= 0 {
+		t.Errorf("trade penalty %.6f after a trade with commission", e.TradePenalty())
+	}
+	if _, _, _ = e.Step(agent.ActionNothing); e.TradePenalty() != 0 {
+		t.Errorf("trade penalty %.6f while holding", e.TradePenalty())
+	}
+}
+
+func TestStepContinuousReachesTarget(t *testing.T) {
+	e := NewMarketEnv(MarketConfig{Prices: randomWalk(200), InitialCash: 10000})
+	e.Reset()
+	for _, target := range []agent.ContinuousAction{0.6, 0.25, 0, 1.5} {
+		e.StepContinuous(target)
+		// Weights drift with the price after the trade, so compare at the traded price
+		price := e.prices[e.currentIdx-1]
+		weight := e.shares * price / (e.cash + e.shares*price)
+		if want := float64(target.Clamp()); math.Abs(weight-want) > 0.01 {
+			t.Errorf("target %.2f: weight %.4f", target, weight)
+		}
+	}
+}
+
+func TestPositionsCapSimultaneousHoldings(t *testing.T) {
+	positions := NewPositions(2)
+	envs := make([]*MarketEnv, 3)
+	for i := range envs {
+		envs[i] = NewMarketEnv(MarketConfig{Prices: randomWalk(200), InitialCash: 10000, Positions: positions})
+		envs[i].Reset()
+	}
+	envs[0].Step(agent.ActionBuyLarge)
+	envs[1].Step(agent.ActionBuySmall)
+	if mask := envs[2].ActionMask(); mask[agent.ActionBuySmall] || mask[agent.ActionBuyLarge] {
+		t.Errorf("buys allowed with %d of %d positions open", positions.Open(), positions.Max)
+	}
+	if mask := envs[0].ActionMask(); !mask[agent.ActionBuySmall] {
+		t.Error("adding to an open position disallowed")
+	}
+	envs[2].Step(agent.ActionBuyLarge)
+	if envs[2].Shares() != 0 || positions.Open() != 2 {
+		t.Errorf("third position opened: shares %.4f, %d open", envs[2].Shares(), positions.Open())
+	}
+	// Closing a position frees its slot
+	envs[1].StepContinuous(0)
+	envs[2].Step(agent.ActionBuyLarge)
+	if envs[2].Shares() == 0 || positions.Open() != 2 {
+		t.Errorf("position not opened after another closed: shares %.4f, %d open", envs[2].Shares(), positions.Open())
+	}
+}
@@ -0,0 +1,232 @@
+package env
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// DefaultPairWindow is the number of prices over which PairTradingEnv estimates the
+// hedge ratio and the z-score of the spread.
+const DefaultPairWindow = 60
+
+// PairConfig holds configuration for the pair trading environment.
+type PairConfig struct {
+	PricesA     []float64
+	PricesB     []float64 // Aligned with PricesA
+	InitialCash float64
+	Commission  float64    // Rate on the notional of both legs
+	Reward      RewardFunc // Defaults to CalculateReward (log return)
+	// Window is the number of prices of the rolling hedge ratio and z-score
+	// (default DefaultPairWindow); episodes start once it is filled.
+	Window int
+}
+
+// PairTradingEnv trades the spread between two assets, log A - β·log B with β the
+// rolling least-squares hedge ratio. States combine the z-score of the spread over
+// the window with the position held (see state.NewPairState), so the discrete
+// agents and the trainer work on it unchanged. Actions move the position between
+// state.NumPairPositions levels, from fully short to fully long the spread: buys go
+// longer (small by one level, large to fully long) and sells shorter (small by one
+// level, large to fully short). Long the spread is long A and short B, dollar
+// neutral, with a gross exposure of the portfolio value when fully in.
+type PairTradingEnv struct {
+	pricesA      []float64
+	pricesB      []float64
+	zScores      []float64 // Z-score of the spread at every price index
+	currentIdx   int
+	startIdx     int
+	cash         float64
+	sharesA      float64 // Negative when short
+	sharesB      float64
+	position     int // Level, state.PairFlat when flat
+	startCash    float64
+	commission   float64
+	reward       RewardFunc
+	feesPaid     float64
+	initialValue float64
+}
+
+// NewPairTradingEnv creates a new pair trading environment.
+func NewPairTradingEnv(config PairConfig) (*PairTradingEnv, error) {
+	if len(config.PricesA) != len(config.PricesB) {
+		return nil, fmt.Errorf("%d prices of A for %d prices of B", len(config.PricesA), len(config.PricesB))
+	}
+	for i := range config.PricesA {
+		if config.PricesA[i] <= 0 || config.PricesB[i] <= 0 {
+			return nil, fmt.Errorf("non-positive price at index %d", i)
+		}
+	}
+	if config.InitialCash <= 0 {
+		config.InitialCash = 10000.0
+	}
+	if config.Commission <= 0 {
+		config.Commission = 0.002
+	}
+	if config.Reward == nil {
+		config.Reward = CalculateReward
+	}
+	if config.Window < 2 {
+		config.Window = DefaultPairWindow
+	}
+	startIdx := config.Window - 1
+	if len(config.PricesA) < startIdx+2 {
+		return nil, fmt.Errorf("need at least %d prices, got %d", startIdx+2, len(config.PricesA))
+	}
+
+	e := &PairTradingEnv{
+		pricesA:      config.PricesA,
+		pricesB:      config.PricesB,
+		zScores:      spreadZScores(config.PricesA, config.PricesB, config.Window),
+		startIdx:     startIdx,
+		startCash:    config.InitialCash,
+		commission:   config.Commission,
+		reward:       config.Reward,
+		initialValue: config.InitialCash,
+	}
+	e.Reset()
+	return e, nil
+}
+
+// spreadZScores returns, for every index from window-1 on, the z-score of the spread
+// over the last window prices, with the hedge ratio fitted over them.
+func spreadZScores(pricesA, pricesB []float64, window int) []float64 {
+	logA := make([]float64, len(pricesA))
+	logB := make([]float64, len(pricesB))
+	for i := range pricesA {
+		logA[i], logB[i] = math.Log(pricesA[i]), math.Log(pricesB[i])
+	}
+	z := make([]float64, len(pricesA))
+	spread := make([]float64, window)
+	for idx := window - 1; idx < len(z); idx++ {
+		a, b := logA[idx-window+1:idx+1], logB[idx-window+1:idx+1]
+		beta := hedgeRatio(a, b)
+		for i := range spread {
+			spread[i] = a[i] - beta*b[i]
+		}
+		if mean, std := metrics.MeanStd(spread); std > 0 {
+			z[idx] = (spread[window-1] - mean) / std
+		}
+	}
+	return z
+}
+
+// hedgeRatio returns the least-squares slope of a on b.
+func hedgeRatio(a, b []float64) float64 {
+	meanA, _ := metrics.MeanStd(a)
+	meanB, _ := metrics.MeanStd(b)
+	var cov, variance float64
+	for i := range a {
+		cov += (a[i] - meanA) * (b[i] - meanB)
+		variance += (b[i] - meanB) * (b[i] - meanB)
+	}
+	if variance == 0 {
+		return 0
+	}
+	return cov / variance
+}
+
+// Reset resets the environment to the initial state, flat.
+func (e *PairTradingEnv) Reset() state.State {
+	e.currentIdx = e.startIdx
+	e.cash = e.startCash
+	e.sharesA, e.sharesB = 0, 0
+	e.position = state.PairFlat
+	e.feesPaid = 0
+	return e.getState()
+}
+
+// Step moves the spread position by action and returns the next state, reward, and
+// done flag.
+func (e *PairTradingEnv) Step(action agent.Action) (next state.State, reward float64, done bool) {
+	if e.currentIdx >= len(e.pricesA)-1 {
+		return e.getState(), 0.0, true
+	}
+
+	valueBefore := e.PortfolioValue()
+	position := e.position
+	switch action {
+	case agent.ActionBuySmall:
+		e.position = min(e.position+1, state.NumPairPositions-1)
+	case agent.ActionBuyLarge:
+		e.position = state.NumPairPositions - 1
+	case agent.ActionSellSmall:
+		e.position = max(e.position-1, 0)
+	case agent.ActionSellLarge:
+		e.position = 0
+	}
+	if e.position != position {
+		e.rebalance()
+	}
+
+	e.currentIdx++
+	reward = e.reward(valueBefore, e.PortfolioValue())
+	done = e.currentIdx >= len(e.pricesA)-1
+	return e.getState(), reward, done
+}
+
+// rebalance trades both legs to the position at the current prices.
+func (e *PairTradingEnv) rebalance() {
+	priceA, priceB := e.pricesA[e.currentIdx], e.pricesB[e.currentIdx]
+	// Each leg holds half the gross exposure
+	notional := e.Exposure() * e.PortfolioValue() / 2
+	deltaA := notional/priceA - e.sharesA
+	deltaB := -notional/priceB - e.sharesB
+	traded := math.Abs(deltaA)*priceA + math.Abs(deltaB)*priceB
+	if traded == 0 {
+		return
+	}
+	fee := traded * e.commission
+	e.cash -= deltaA*priceA + deltaB*priceB + fee
+	e.sharesA += deltaA
+	e.sharesB += deltaB
+	e.feesPaid += fee
+}
+
+func (e *PairTradingEnv) getState() state.State {
+	return state.NewPairState(state.SpreadCategory(e.zScores[e.currentIdx]), e.position)
+}
+
+// NumStates returns the size of the state space, the rows of the Q-table.
+func (e *PairTradingEnv) NumStates() int {
+	return state.NumPairStates
+}
+
+// Exposure returns the signed gross exposure of the position as a fraction of the
+// portfolio value: 1 fully long the spread, -1 fully short.
+func (e *PairTradingEnv) Exposure() float64 {
+	return float64(e.position-state.PairFlat) / float64(state.PairFlat)
+}
+
+// ZScore returns the z-score of the spread at the current step.
+func (e *PairTradingEnv) ZScore() float64 {
+	return e.zScores[e.currentIdx]
+}
+
+// PortfolioValue returns the cash plus the value of both legs.
+func (e *PairTradingEnv) PortfolioValue() float64 {
+	return e.cash + e.sharesA*e.pricesA[e.currentIdx] + e.sharesB*e.pricesB[e.currentIdx]
+}
+
+// InitialValue returns the initial portfolio value.
+func (e *PairTradingEnv) InitialValue() float64 {
+	return e.initialValue
+}
+
+// CommissionPaid returns the commission paid since the start of the episode.
+func (e *PairTradingEnv) CommissionPaid() float64 {
+	return e.feesPaid
+}
+
+// CurrentIdx returns the current price index.
+func (e *PairTradingEnv) CurrentIdx() int {
+	return e.currentIdx
+}
+
+// StartIdx returns the price index at which episodes start.
+func (e *PairTradingEnv) StartIdx() int {
+	return e.startIdx
+}
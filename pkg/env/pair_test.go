@@ -0,0 +1,79 @@
+package env
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// cointegratedPair returns n prices of two assets whose log spread mean-reverts.
+func cointegratedPair(n int) (a, b []float64) {
+	rng := rand.New(rand.NewSource(1))
+	a, b = make([]float64, n), make([]float64, n)
+	pb, spread := 50.0, 0.0
+	for i := range a {
+		pb *= 1 + rng.NormFloat64()*0.01
+		spread = 0.8*spread + rng.NormFloat64()*0.02
+		a[i], b[i] = pb*math.Exp(spread), pb
+	}
+	return a, b
+}
+
+func TestPairTradingMeanReversion(t *testing.T) {
+	pricesA, pricesB := cointegratedPair(1000)
+	e, err := NewPairTradingEnv(PairConfig{PricesA: pricesA, PricesB: pricesB, Commission: 0.0005})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Long the spread when it is low, short when high, flat near the mean
+	s := e.Reset()
+	for done := false; !done; {
+		if s.Index < 0 || s.Index >= e.NumStates() {
+			t.Fatalf("state %d outside %d states", s.Index, e.NumStates())
+		}
+		action := agent.ActionNothing
+		switch {
+		case s.SpreadCat <= 1 && s.PairPosition != state.NumPairPositions-1:
+			action = agent.ActionBuyLarge
+		case s.SpreadCat >= state.NumSpreadCategories-2 && s.PairPosition != 0:
+			action = agent.ActionSellLarge
+		case s.SpreadCat == 3 && s.PairPosition > state.PairFlat:
+			action = agent.ActionSellSmall
+		case s.SpreadCat == 3 && s.PairPosition < state.PairFlat:
+			action = agent.ActionBuySmall
+		}
+		s, _, done = e.Step(action)
+	}
+	if e.PortfolioValue() <= e.InitialValue() {
+		t.Errorf("mean reversion lost money: %.2f from %.2f", e.PortfolioValue(), e.InitialValue())
+	}
+	if e.CommissionPaid() == 0 {
+		t.Error("no commission paid")
+	}
+}
+
+func TestPairTradingFlatHoldsValue(t *testing.T) {
+	pricesA, pricesB := cointegratedPair(200)
+	e, err := NewPairTradingEnv(PairConfig{PricesA: pricesA, PricesB: pricesB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Reset()
+	e.Step(agent.ActionBuyLarge)
+	e.Step(agent.ActionSellSmall)
+	e.Step(agent.ActionSellSmall)
+	if e.Exposure() != 0 || e.sharesA != 0 || e.sharesB != 0 {
+		t.Fatalf("not flat: exposure %.2f, shares %.4f and %.4f", e.Exposure(), e.sharesA, e.sharesB)
+	}
+	value := e.PortfolioValue()
+	for i := 0; i < 10; i++ {
+		e.Step(agent.ActionNothing)
+	}
+	if e.PortfolioValue() != value {
+		t.Errorf("flat value moved from %.4f to %.4f", value, e.PortfolioValue())
+	}
+}
@@ -0,0 +1,295 @@
+package env
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// Defaults of the perpetual futures environment, typical of crypto exchanges.
+const (
+	DefaultPerpCommission    = 0.0005 // Taker fee
+	DefaultMaintenanceMargin = 0.005  // Of the position's notional
+	DefaultLiquidationFee    = 0.005  // Of the notional closed by a liquidation
+	NumPerpSides             = 3      // Short, flat, and long
+)
+
+// Sides of the position in the perpetual futures states.
+const (
+	perpShort = iota
+	perpFlat
+	perpLong
+)
+
+// PerpConfig holds configuration for the perpetual futures environment.
+type PerpConfig struct {
+	Prices      []float64 // Mark prices
+	InitialCash float64   // Collateral, default 10000
+	// Leverage is the largest notional of the position as a multiple of the equity
+	// (default 1).
+	Leverage   float64
+	Commission float64 // Rate on the notional traded, default DefaultPerpCommission
+	// Funding holds the funding rate of every price index, paid at every
+	// FundingInterval-th index (default 1: every step): longs pay shorts the rate
+	// times the notional when it is positive, shorts pay longs when negative.
+	// Nil pays no funding.
+	Funding         []float64
+	FundingInterval int
+	// MaintenanceMargin is the equity, as a fraction of the notional, below which
+	// the position is liquidated (default DefaultMaintenanceMargin), and
+	// LiquidationFee the share of the notional a liquidation costs on top (default
+	// DefaultLiquidationFee).
+	MaintenanceMargin float64
+	LiquidationFee    float64
+	MinStartIdx       int
+	Encoder           state.Encoder // Defaults to state.MAEncoder
+	Reward            RewardFunc    // Defaults to CalculateReward (log return)
+}
+
+// PerpEnv trades a perpetual futures contract with leverage, long or short. Buys
+// add to the position (small: 10% of the largest notional the equity allows, large:
+// 50%) and sells take from it, going short below zero. The position pays or earns
+// funding at every funding index, and is liquidated when the equity falls to the
+// maintenance margin, which ends the episode. States extend the encoder's states
+// with the side of the position: Index is the encoder's index times NumPerpSides
+// plus 0 for short, 1 for flat, or 2 for long, so the Q-table needs NumStates rows.
+type PerpEnv struct {
+	prices            []float64
+	funding           []float64
+	fundingInterval   int
+	currentIdx        int
+	startIdx          int
+	cash              float64 // Equity is cash + contracts·price
+	contracts         float64 // Negative when short
+	startCash         float64
+	leverage          float64
+	commission        float64
+	maintenanceMargin float64
+	liquidationFee    float64
+	encoder           state.Encoder
+	reward            RewardFunc
+	fundingPaid       float64
+	feesPaid          float64
+	liquidated        bool
+}
+
+// NewPerpEnv creates a new perpetual futures environment.
+func NewPerpEnv(config PerpConfig) (*PerpEnv, error) {
+	if config.Funding != nil && len(config.Funding) != len(config.Prices) {
+		return nil, fmt.Errorf("%d funding rates for %d prices", len(config.Funding), len(config.Prices))
+	}
+	if config.InitialCash <= 0 {
+		config.InitialCash = 10000.0
+	}
+	if config.Leverage <= 0 {
+		config.Leverage = 1
+	}
+	if config.Commission <= 0 {
+		config.Commission = DefaultPerpCommission
+	}
+	if config.FundingInterval < 1 {
+		config.FundingInterval = 1
+	}
+	if config.MaintenanceMargin <= 0 {
+		config.MaintenanceMargin = DefaultMaintenanceMargin
+	}
+	if config.LiquidationFee <= 0 {
+		config.LiquidationFee = DefaultLiquidationFee
+	}
+	if config.MaintenanceMargin >= 1/config.Leverage {
+		return nil, fmt.Errorf("maintenance margin %g leaves no room at leverage %g", config.MaintenanceMargin, config.Leverage)
+	}
+	if config.Encoder == nil {
+		config.Encoder = state.NewMAEncoder()
+	}
+	if series, ok := config.Encoder.(state.SeriesEncoder); ok {
+		config.Encoder = series.ForSeries(config.Prices)
+	}
+	if config.Reward == nil {
+		config.Reward = CalculateReward
+	}
+	startIdx := max(config.Encoder.WarmUp(), config.MinStartIdx)
+	if len(config.Prices) < startIdx+2 {
+		return nil, fmt.Errorf("need at least %d prices, got %d", startIdx+2, len(config.Prices))
+	}
+
+	e := &PerpEnv{
+		prices:            config.Prices,
+		funding:           config.Funding,
+		fundingInterval:   config.FundingInterval,
+		startIdx:          startIdx,
+		startCash:         config.InitialCash,
+		leverage:          config.Leverage,
+		commission:        config.Commission,
+		maintenanceMargin: config.MaintenanceMargin,
+		liquidationFee:    config.LiquidationFee,
+		encoder:           config.Encoder,
+		reward:            config.Reward,
+	}
+	e.Reset()
+	return e, nil
+}
+
+// Reset resets the environment to the initial state, flat.
+func (e *PerpEnv) Reset() state.State {
+	e.currentIdx = e.startIdx
+	e.cash = e.startCash
+	e.contracts = 0
+	e.fundingPaid = 0
+	e.feesPaid = 0
+	e.liquidated = false
+	return e.getState()
+}
+
+// Step trades the action at the current price, moves to the next price, pays the
+// funding, and liquidates the position if the margin is breached.
+func (e *PerpEnv) Step(action agent.Action) (next state.State, reward float64, done bool) {
+	if e.liquidated || e.currentIdx >= len(e.prices)-1 {
+		return e.getState(), 0.0, true
+	}
+
+	equityBefore := e.Equity()
+	e.trade(action)
+	e.currentIdx++
+
+	// A move through the liquidation price closes the position there
+	if liq := e.LiquidationPrice(); e.contracts != 0 && e.breached(e.prices[e.currentIdx]) {
+		notional := math.Abs(e.contracts) * liq
+		e.cash += e.contracts * liq
+		e.cash -= notional * e.liquidationFee
+		e.feesPaid += notional * e.liquidationFee
+		e.contracts = 0
+		// The exchange's insurance fund covers any loss beyond the collateral
+		e.cash = max(e.cash, 0)
+		e.liquidated = true
+	}
+	if e.funding != nil && e.currentIdx%e.fundingInterval == 0 {
+		payment := e.contracts * e.prices[e.currentIdx] * e.funding[e.currentIdx]
+		e.cash -= payment
+		e.fundingPaid += payment
+	}
+
+	reward = e.reward(equityBefore, max(e.Equity(), equityBefore*1e-9))
+	done = e.liquidated || e.currentIdx >= len(e.prices)-1
+	return e.getState(), reward, done
+}
+
+// trade changes the position by the action's share of the largest notional.
+func (e *PerpEnv) trade(action agent.Action) {
+	if !action.IsTrade() {
+		return
+	}
+	price := e.prices[e.currentIdx]
+	maxContracts := e.leverage * e.Equity() / price
+	if maxContracts <= 0 {
+		return
+	}
+	fraction := agent.BuySmall
+	switch action {
+	case agent.ActionBuyLarge:
+		fraction = agent.BuyLarge
+	case agent.ActionSellSmall:
+		fraction = -agent.SellSmall
+	case agent.ActionSellLarge:
+		fraction = -agent.SellLarge
+	}
+	target := min(max(e.contracts+fraction*maxContracts, -maxContracts), maxContracts)
+	delta := target - e.contracts
+	fee := math.Abs(delta) * price * e.commission
+	e.cash -= delta*price + fee
+	e.contracts = target
+	e.feesPaid += fee
+}
+
+// breached reports whether the equity at price is at or below the maintenance margin.
+func (e *PerpEnv) breached(price float64) bool {
+	return e.cash+e.contracts*price <= e.maintenanceMargin*math.Abs(e.contracts)*price
+}
+
+// LiquidationPrice returns the price at which the position is liquidated, or 0 when
+// flat or when no positive price liquidates it.
+func (e *PerpEnv) LiquidationPrice() float64 {
+	if e.contracts == 0 {
+		return 0
+	}
+	// cash + contracts·p = mm·|contracts|·p
+	price := -e.cash / (e.contracts - e.maintenanceMargin*math.Abs(e.contracts))
+	return max(price, 0)
+}
+
+func (e *PerpEnv) getState() state.State {
+	free := max(e.Equity()-math.Abs(e.contracts)*e.prices[e.currentIdx], 0)
+	s := e.encoder.Encode(e.prices, e.currentIdx, free, math.Abs(e.contracts))
+	side := perpFlat
+	switch {
+	case e.contracts > 0:
+		side = perpLong
+	case e.contracts < 0:
+		side = perpShort
+	}
+	s.Index = s.Index*NumPerpSides + side
+	return s
+}
+
+// NumStates returns the size of the state space, the rows of the Q-table.
+func (e *PerpEnv) NumStates() int {
+	return e.encoder.NumStates() * NumPerpSides
+}
+
+// Equity returns the collateral plus the unrealized profit of the position.
+func (e *PerpEnv) Equity() float64 {
+	return e.cash + e.contracts*e.prices[e.currentIdx]
+}
+
+// PortfolioValue returns the equity.
+func (e *PerpEnv) PortfolioValue() float64 {
+	return e.Equity()
+}
+
+// InitialValue returns the initial collateral.
+func (e *PerpEnv) InitialValue() float64 {
+	return e.startCash
+}
+
+// Contracts returns the position, negative when short.
+func (e *PerpEnv) Contracts() float64 {
+	return e.contracts
+}
+
+// Leverage returns the notional of the position as a multiple of the equity.
+func (e *PerpEnv) Leverage() float64 {
+	equity := e.Equity()
+	if equity <= 0 {
+		return 0
+	}
+	return math.Abs(e.contracts) * e.prices[e.currentIdx] / equity
+}
+
+// FundingPaid returns the net funding paid since the start of the episode, negative
+// when earned.
+func (e *PerpEnv) FundingPaid() float64 {
+	return e.fundingPaid
+}
+
+// CommissionPaid returns the trading and liquidation fees paid since the start of
+// the episode.
+func (e *PerpEnv) CommissionPaid() float64 {
+	return e.feesPaid
+}
+
+// Liquidated reports whether the position was liquidated in the episode.
+func (e *PerpEnv) Liquidated() bool {
+	return e.liquidated
+}
+
+// CurrentIdx returns the current price index.
+func (e *PerpEnv) CurrentIdx() int {
+	return e.currentIdx
+}
+
+// StartIdx returns the price index at which episodes start.
+func (e *PerpEnv) StartIdx() int {
+	return e.startIdx
+}
@@ -0,0 +1,77 @@
+package env
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+)
+
+// flatThenFalling returns flat prices of 100 up to the MA warm-up, then falling by
+// drop per step.
+func flatThenFalling(n int, drop float64) []float64 {
+	prices := make([]float64, n)
+	p := 100.0
+	for i := range prices {
+		if i > 130 {
+			p *= 1 - drop
+		}
+		prices[i] = p
+	}
+	return prices
+}
+
+func TestPerpPaysFunding(t *testing.T) {
+	prices := flatThenFalling(130, 0)
+	funding := make([]float64, len(prices))
+	for i := range funding {
+		funding[i] = 0.001
+	}
+	e, err := NewPerpEnv(PerpConfig{Prices: prices, Funding: funding, FundingInterval: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Reset()
+	e.Step(agent.ActionSellLarge) // Short half the equity, earning positive funding
+	for i := 0; i < 7; i++ {
+		e.Step(agent.ActionNothing)
+	}
+	// Eight steps cross four funding indices
+	notional := math.Abs(e.Contracts()) * 100
+	if want := -4 * notional * 0.001; math.Abs(e.FundingPaid()-want) > 1e-9 {
+		t.Errorf("funding paid %.4f, want %.4f", e.FundingPaid(), want)
+	}
+	if want := e.InitialValue() - e.CommissionPaid() - e.FundingPaid(); math.Abs(e.Equity()-want) > 1e-9 {
+		t.Errorf("equity %.4f, want %.4f", e.Equity(), want)
+	}
+}
+
+func TestPerpLiquidates(t *testing.T) {
+	e, err := NewPerpEnv(PerpConfig{Prices: flatThenFalling(200, 0.02), Leverage: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Reset()
+	e.Step(agent.ActionBuyLarge)
+	e.Step(agent.ActionBuyLarge)
+	if e.Leverage() < 9.9 {
+		t.Fatalf("leverage %.2f, want 10", e.Leverage())
+	}
+	liq := e.LiquidationPrice()
+	if liq <= 90 || liq >= 100 {
+		t.Fatalf("liquidation price %.2f of a 10x long at 100", liq)
+	}
+	done := false
+	for steps := 0; !done; steps++ {
+		if steps > 20 {
+			t.Fatal("not liquidated")
+		}
+		_, _, done = e.Step(agent.ActionNothing)
+	}
+	if !e.Liquidated() || e.Contracts() != 0 {
+		t.Errorf("liquidated %v with %.4f contracts", e.Liquidated(), e.Contracts())
+	}
+	if e.Equity() < 0 || e.Equity() > 0.1*e.InitialValue() {
+		t.Errorf("equity %.2f after liquidation", e.Equity())
+	}
+}
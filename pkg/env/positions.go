@@ -0,0 +1,54 @@
+package env
+
+import "sync"
+
+// Positions caps the number of simultaneous positions of a portfolio traded by
+// several MarketEnvs, one per asset, that share it (see MarketConfig.Positions). An
+// asset counts as held while any of its shares remain. It is safe for concurrent
+// use by the environments.
+type Positions struct {
+	Max int // Most assets held at once; 0 means no limit
+
+	mu   sync.Mutex
+	open map[*MarketEnv]bool
+}
+
+// NewPositions creates a limit of max simultaneous positions.
+func NewPositions(max int) *Positions {
+	return &Positions{Max: max, open: make(map[*MarketEnv]bool)}
+}
+
+// Open returns the number of assets held.
+func (p *Positions) Open() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.open)
+}
+
+// full reports whether e may not open a position: it holds none, and Max assets
+// are held.
+func (p *Positions) full(e *MarketEnv) bool {
+	if p == nil || p.Max <= 0 {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.open[e] && len(p.open) >= p.Max
+}
+
+// update records whether e holds the asset.
+func (p *Positions) update(e *MarketEnv) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.open == nil {
+		p.open = make(map[*MarketEnv]bool)
+	}
+	if e.shares > 0 {
+		p.open[e] = true
+	} else {
+		delete(p.open, e)
+	}
+}
@@ -0,0 +1,186 @@
+package env
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// StepRecord is one step of a recorded trajectory, a line of a trajectory file.
+type StepRecord struct {
+	Episode   int          `json:"episode"` // 1-based episode number within the file
+	Step      int          `json:"step"`    // 0-based step within the episode
+	State     state.State  `json:"state"`
+	Action    agent.Action `json:"action"`
+	Reward    float64      `json:"reward"`
+	NextState state.State  `json:"next_state"`
+	Done      bool         `json:"done"`
+	Info      *StepInfo    `json:"info,omitempty"`
+}
+
+// StepInfo is the market context of a recorded step, present when a MarketEnv is
+// at the bottom of the recorded environment.
+type StepInfo struct {
+	PriceIdx int     `json:"price_idx"`
+	Price    float64 `json:"price"`
+	Cash     float64 `json:"cash"`   // Before the action
+	Shares   float64 `json:"shares"` // Before the action
+	Value    float64 `json:"value"`  // Portfolio value after the step
+}
+
+// Transition returns the step as a learning transition.
+func (r StepRecord) Transition() agent.Transition {
+	return agent.Transition{State: r.State, Action: r.Action, Reward: r.Reward, NextState: r.NextState, Done: r.Done}
+}
+
+// Recorder writes every step of the wrapped environment to a trajectory file in
+// JSON Lines, for offline learning and exact replays. Write errors cannot be
+// returned from Step: the first one stops the recording and is returned by Err and
+// Close.
+type Recorder struct {
+	Wrapper
+
+	f       *os.File
+	w       *bufio.Writer
+	enc     *json.Encoder
+	err     error
+	market  *MarketEnv
+	current state.State
+	episode int
+	step    int
+}
+
+// NewRecorder wraps e with a recorder writing to path, creating its directory if
+// needed. An existing file is replaced.
+func NewRecorder(e Environment, path string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create trajectory directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trajectory file: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	market, _ := Market(e)
+	return &Recorder{Wrapper: Wrapper{Env: e}, f: f, w: w, enc: json.NewEncoder(w), market: market}, nil
+}
+
+// Reset resets the inner environment and starts a new episode.
+func (r *Recorder) Reset() state.State {
+	r.current = r.Env.Reset()
+	r.episode++
+	r.step = 0
+	return r.current
+}
+
+// Step steps the inner environment and records the step.
+func (r *Recorder) Step(action agent.Action) (next state.State, reward float64, done bool) {
+	var info *StepInfo
+	if r.market != nil {
+		info = &StepInfo{
+			PriceIdx: r.market.CurrentIdx(),
+			Price:    r.market.CurrentPrice(),
+			Cash:     r.market.Cash(),
+			Shares:   r.market.Shares(),
+		}
+	}
+	next, reward, done = r.Env.Step(action)
+	if info != nil {
+		info.Value = r.market.PortfolioValue()
+	}
+
+	if r.err == nil {
+		if err := r.enc.Encode(StepRecord{Episode: r.episode, Step: r.step, State: r.current, Action: action,
+			Reward: reward, NextState: next, Done: done, Info: info}); err != nil {
+			r.err = fmt.Errorf("failed to write trajectory: %w", err)
+		}
+	}
+	r.current = next
+	r.step++
+	return next, reward, done
+}
+
+// Err returns the first write error, if any.
+func (r *Recorder) Err() error {
+	return r.err
+}
+
+// Close flushes and closes the trajectory file.
+func (r *Recorder) Close() error {
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to write trajectory: %w", err)
+	}
+	if err := r.f.Close(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to close trajectory file: %w", err)
+	}
+	return r.err
+}
+
+// ReadTrajectory reads the steps of a trajectory file.
+func ReadTrajectory(path string) ([]StepRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trajectory file: %w", err)
+	}
+	defer f.Close()
+
+	var records []StepRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r StepRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("failed to parse trajectory line %d: %w", line, err)
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trajectory file: %w", err)
+	}
+	return records, nil
+}
+
+// Episodes splits trajectory steps into their episodes, in order.
+func Episodes(records []StepRecord) [][]StepRecord {
+	var episodes [][]StepRecord
+	for i, r := range records {
+		if i == 0 || r.Episode != records[i-1].Episode {
+			episodes = append(episodes, nil)
+		}
+		episodes[len(episodes)-1] = append(episodes[len(episodes)-1], r)
+	}
+	return episodes
+}
+
+// Replay resets e and plays the actions of one recorded episode, returning an error
+// at the first step whose state, reward, or end differs from the recording, e.g.
+// to check that a change to the environment keeps past runs reproducible.
+func Replay(e Environment, episode []StepRecord) error {
+	if len(episode) == 0 {
+		return nil
+	}
+	s := e.Reset()
+	if s != episode[0].State {
+		return fmt.Errorf("initial state %d, recorded %d", s.Index, episode[0].State.Index)
+	}
+	for i, r := range episode {
+		next, reward, done := e.Step(r.Action)
+		switch {
+		case next != r.NextState:
+			return fmt.Errorf("step %d: state %d, recorded %d", i, next.Index, r.NextState.Index)
+		case reward != r.Reward:
+			return fmt.Errorf("step %d: reward %v, recorded %v", i, reward, r.Reward)
+		case done != r.Done:
+			return fmt.Errorf("step %d: done %v, recorded %v", i, done, r.Done)
+		}
+	}
+	return nil
+}
@@ -2,6 +2,10 @@ package env
 
 import "math"
 
+// RewardFunc computes the reward of a step from the portfolio value before the action
+// and after the price move.
+type RewardFunc func(portfolioValueBefore, portfolioValueAfter float64) float64
+
 // CalculateReward calculates the reward as log return of portfolio value.
 func CalculateReward(portfolioValueBefore, portfolioValueAfter float64) float64 {
 	if portfolioValueBefore > 0 {
@@ -9,3 +13,11 @@ func CalculateReward(portfolioValueBefore, portfolioValueAfter float64) float64
 	}
 	return 0
 }
+
+// SimpleReturnReward calculates the reward as the simple return of portfolio value.
+func SimpleReturnReward(portfolioValueBefore, portfolioValueAfter float64) float64 {
+	if portfolioValueBefore > 0 {
+		return portfolioValueAfter/portfolioValueBefore - 1
+	}
+	return 0
+}
@@ -0,0 +1,128 @@
+package env
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+)
+
+// Sizing selects how buy actions are sized.
+type Sizing int
+
+const (
+	// SizingFixed buys the action's fixed fraction of cash (agent.BuySmall or
+	// agent.BuyLarge).
+	SizingFixed Sizing = iota
+	// SizingKelly buys up to a fraction of the Kelly-optimal weight of the asset,
+	// KellySmall or KellyLarge, so the actions are aggressiveness tiers.
+	SizingKelly
+)
+
+// Fractions of the Kelly weight targeted by buy-small and buy-large.
+const (
+	KellySmall = 0.5 // Half Kelly
+	KellyLarge = 1.0 // Full Kelly
+)
+
+// DefaultKellyWindow is the number of returns from which the Kelly weight is
+// estimated.
+const DefaultKellyWindow = 60
+
+// String returns the name of the sizing mode.
+func (s Sizing) String() string {
+	switch s {
+	case SizingFixed:
+		return "fixed"
+	case SizingKelly:
+		return "kelly"
+	default:
+		return fmt.Sprintf("Sizing(%d)", int(s))
+	}
+}
+
+// ParseSizing parses fixed or kelly.
+func ParseSizing(s string) (Sizing, error) {
+	switch s {
+	case "", "fixed":
+		return SizingFixed, nil
+	case "kelly":
+		return SizingKelly, nil
+	default:
+		return 0, fmt.Errorf("unknown sizing %q (want fixed or kelly)", s)
+	}
+}
+
+// DefaultVolWindow is the number of returns over which the volatility target
+// measures the asset's volatility.
+const DefaultVolWindow = 20
+
+// MaxVolScale caps the volatility target's scaling, so calm markets at most double
+// the buy sizes.
+const MaxVolScale = 2.0
+
+// volScale returns the factor the volatility target applies to buy sizes at the
+// current index, from the returns of the last volWindow prices up to it.
+func (e *MarketEnv) volScale() float64 {
+	from := max(e.currentIdx-e.volWindow, 0)
+	_, std := metrics.MeanStd(metrics.StepReturns(e.values[from : e.currentIdx+1]))
+	vol := std * math.Sqrt(e.periods)
+	if vol <= 0 {
+		return MaxVolScale
+	}
+	return min(e.volTarget/vol, MaxVolScale)
+}
+
+// SizeScale returns the factor applied to buy sizes at the last step: 1 without a
+// volatility target.
+func (e *MarketEnv) SizeScale() float64 {
+	return e.sizeScale
+}
+
+// VolTarget returns the annualized volatility buy sizes are scaled to, or 0.
+func (e *MarketEnv) VolTarget() float64 {
+	return e.volTarget
+}
+
+// buyCost returns the cash a buy action spends at price, commission included, after
+// the sizing mode, volatility scale, and position limit.
+func (e *MarketEnv) buyCost(action agent.Action, price float64) float64 {
+	var cost float64
+	switch e.sizing {
+	case SizingKelly:
+		tier := KellySmall
+		if action == agent.ActionBuyLarge {
+			tier = KellyLarge
+		}
+		// Buy up to the target weight; above it the buy does nothing
+		sharesValue := e.shares * price
+		cost = tier*e.kellyWeight()*(e.cash+sharesValue) - sharesValue
+	default:
+		fraction := agent.BuySmall
+		if action == agent.ActionBuyLarge {
+			fraction = agent.BuyLarge
+		}
+		cost = e.cash * fraction
+	}
+	cost = min(max(cost, 0)*e.sizeScale, e.cash)
+	if e.limited() {
+		cost = min(cost, e.buyLimit(price))
+	}
+	return cost
+}
+
+// kellyWeight returns the Kelly-optimal weight of the asset, mean over variance of
+// the returns of the last kellyWindow prices up to the current index, without
+// leverage or shorting.
+func (e *MarketEnv) kellyWeight() float64 {
+	from := max(e.currentIdx-e.kellyWindow, 0)
+	mean, std := metrics.MeanStd(metrics.StepReturns(e.values[from : e.currentIdx+1]))
+	if std == 0 {
+		if mean > 0 {
+			return 1
+		}
+		return 0
+	}
+	return min(max(mean/(std*std), 0), 1)
+}
@@ -0,0 +1,178 @@
+package env
+
+import "fmt"
+
+// LotMethod selects which tax lots a sell closes first.
+type LotMethod int
+
+const (
+	// LotNone disables tax-lot accounting
+	LotNone LotMethod = iota
+	// LotFIFO sells the oldest shares first
+	LotFIFO
+	// LotLIFO sells the newest shares first
+	LotLIFO
+)
+
+// LongTermSteps is the holding period, in steps, from which a gain is long-term
+// without a clock: a year of daily bars.
+const LongTermSteps = 252
+
+// String returns the name of the lot method.
+func (m LotMethod) String() string {
+	switch m {
+	case LotNone:
+		return "none"
+	case LotFIFO:
+		return "fifo"
+	case LotLIFO:
+		return "lifo"
+	default:
+		return fmt.Sprintf("LotMethod(%d)", int(m))
+	}
+}
+
+// ParseLotMethod parses none, fifo, or lifo.
+func ParseLotMethod(s string) (LotMethod, error) {
+	switch s {
+	case "", "none":
+		return LotNone, nil
+	case "fifo":
+		return LotFIFO, nil
+	case "lifo":
+		return LotLIFO, nil
+	default:
+		return 0, fmt.Errorf("unknown lot method %q (want none, fifo, or lifo)", s)
+	}
+}
+
+// Lot is a tax lot: shares bought in one trade.
+type Lot struct {
+	Opened int     // Price index of the buy
+	Shares float64 // Shares still held
+	Cost   float64 // Cost basis of the shares held, buy commission and slippage included
+}
+
+// Realization is the gain or loss realized by selling (part of) a lot.
+type Realization struct {
+	Opened   int // Price index of the buy
+	Closed   int // Price index of the sell
+	Shares   float64
+	Proceeds float64 // Sale proceeds after commission and slippage
+	Cost     float64 // Cost basis of the shares sold
+	Gain     float64 // Proceeds minus cost; negative for a loss
+	LongTerm bool    // Held at least a year
+}
+
+// ledger tracks the tax lots of the position and the gains realized by sells.
+type ledger struct {
+	method   LotMethod
+	lots     []Lot
+	realized []Realization
+	longTerm func(opened, closed int) bool
+}
+
+// reset empties the ledger, keeping its buffers.
+func (l *ledger) reset() {
+	l.lots = l.lots[:0]
+	l.realized = l.realized[:0]
+}
+
+// record books a trade at price index idx that changed the shares by sharesDelta and
+// the cash by cashDelta, and returns the gain it realized (0 for buys).
+func (l *ledger) record(idx int, sharesDelta, cashDelta float64) float64 {
+	switch {
+	case sharesDelta > 0:
+		l.lots = append(l.lots, Lot{Opened: idx, Shares: sharesDelta, Cost: -cashDelta})
+		return 0
+	case sharesDelta < 0:
+		return l.sell(idx, -sharesDelta, cashDelta)
+	}
+	return 0
+}
+
+// sell closes shares from the lots in the ledger's order, splitting the proceeds
+// in proportion to the shares taken from each lot.
+func (l *ledger) sell(idx int, shares, proceeds float64) float64 {
+	total := 0.0
+	remaining := shares
+	for remaining > 0 && len(l.lots) > 0 {
+		i := 0
+		if l.method == LotLIFO {
+			i = len(l.lots) - 1
+		}
+		lot := &l.lots[i]
+		taken := min(remaining, lot.Shares)
+		cost := lot.Cost * taken / lot.Shares
+		r := Realization{
+			Opened:   lot.Opened,
+			Closed:   idx,
+			Shares:   taken,
+			Proceeds: proceeds * taken / shares,
+			Cost:     cost,
+		}
+		r.Gain = r.Proceeds - r.Cost
+		r.LongTerm = l.longTerm(r.Opened, r.Closed)
+		l.realized = append(l.realized, r)
+		total += r.Gain
+
+		lot.Shares -= taken
+		lot.Cost -= cost
+		remaining -= taken
+		// Float residue of a fully sold lot closes it too
+		if lot.Shares <= 1e-12*taken {
+			if l.method == LotLIFO {
+				l.lots = l.lots[:i]
+			} else {
+				l.lots = l.lots[1:]
+			}
+		}
+	}
+	return total
+}
+
+// ledgerState is a saved ledger, restored after a counterfactual step.
+type ledgerState struct {
+	lots     []Lot
+	realized int
+}
+
+func (l *ledger) save() ledgerState {
+	return ledgerState{lots: append([]Lot(nil), l.lots...), realized: len(l.realized)}
+}
+
+func (l *ledger) restore(s ledgerState) {
+	l.lots = append(l.lots[:0], s.lots...)
+	l.realized = l.realized[:s.realized]
+}
+
+// Lots returns the open tax lots, oldest first, or nil without tax-lot accounting.
+func (e *MarketEnv) Lots() []Lot {
+	if e.ledger == nil {
+		return nil
+	}
+	return e.ledger.lots
+}
+
+// Realized returns the gains realized since the last Reset, in the order of the
+// sells, or nil without tax-lot accounting.
+func (e *MarketEnv) Realized() []Realization {
+	if e.ledger == nil {
+		return nil
+	}
+	return e.ledger.realized
+}
+
+// TaxRate returns the rate of the tax drag on the reward, or 0.
+func (e *MarketEnv) TaxRate() float64 {
+	return e.taxRate
+}
+
+// longTerm reports whether shares bought at price index opened and sold at closed
+// were held at least a year: by the clock's timestamps, or LongTermSteps without one.
+func (e *MarketEnv) longTerm(opened, closed int) bool {
+	if e.clock != nil {
+		return !e.clock.Time(closed).Before(e.clock.Time(opened).AddDate(1, 0, 0))
+	}
+	return closed-opened >= LongTermSteps
+}
@@ -0,0 +1,296 @@
+package env
+
+import (
+	"math"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// Wrapper is an Environment built around another one, to change its rewards,
+// actions or states without modifying it. It forwards everything to the inner
+// environment; wrappers embed it and override what they change, so they compose
+// in any order, e.g.
+//
+//	e := NewRewardNormalizer(NewDrawdownOverlay(marketEnv, 0.2, 20), 5)
+type Wrapper struct {
+	Env Environment
+}
+
+// Reset resets the inner environment.
+func (w *Wrapper) Reset() state.State {
+	return w.Env.Reset()
+}
+
+// Step steps the inner environment.
+func (w *Wrapper) Step(action agent.Action) (next state.State, reward float64, done bool) {
+	return w.Env.Step(action)
+}
+
+// ActionMask returns the inner environment's mask, or nil if it does not restrict
+// the actions.
+func (w *Wrapper) ActionMask() []bool {
+	if masker, ok := w.Env.(Masker); ok {
+		return masker.ActionMask()
+	}
+	return nil
+}
+
+// Unwrap returns the inner environment.
+func (w *Wrapper) Unwrap() Environment {
+	return w.Env
+}
+
+// Market returns the MarketEnv at the bottom of a stack of wrappers, or false if
+// there is none.
+func Market(e Environment) (*MarketEnv, bool) {
+	for {
+		switch inner := e.(type) {
+		case *MarketEnv:
+			return inner, true
+		case interface{ Unwrap() Environment }:
+			e = inner.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
+
+// andMask returns the actions allowed by both masks, nil meaning all are allowed.
+func andMask(a, b []bool) []bool {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	allowed := make([]bool, agent.NumActions)
+	for i := range allowed {
+		allowed[i] = i < len(a) && a[i] && i < len(b) && b[i]
+	}
+	return allowed
+}
+
+// RewardNormalizer scales rewards by the running standard deviation of the rewards
+// seen so far, so stocks of different volatility train with rewards of similar
+// size. The statistics persist across episodes.
+type RewardNormalizer struct {
+	Wrapper
+	// Clip, if positive, bounds the scaled rewards to [-Clip, Clip].
+	Clip float64
+
+	n    int
+	mean float64
+	m2   float64
+}
+
+// NewRewardNormalizer wraps e with reward normalization clipped to clip (0 disables
+// clipping).
+func NewRewardNormalizer(e Environment, clip float64) *RewardNormalizer {
+	return &RewardNormalizer{Wrapper: Wrapper{Env: e}, Clip: clip}
+}
+
+// Step steps the inner environment and returns the normalized reward.
+func (r *RewardNormalizer) Step(action agent.Action) (next state.State, reward float64, done bool) {
+	next, reward, done = r.Env.Step(action)
+
+	// Welford's online variance
+	r.n++
+	delta := reward - r.mean
+	r.mean += delta / float64(r.n)
+	r.m2 += delta * (reward - r.mean)
+
+	if std := r.Std(); std > 0 {
+		reward /= std
+	}
+	if r.Clip > 0 {
+		reward = math.Max(-r.Clip, math.Min(r.Clip, reward))
+	}
+	return next, reward, done
+}
+
+// Std returns the standard deviation of the rewards seen so far, or 0 before two.
+func (r *RewardNormalizer) Std() float64 {
+	if r.n < 2 {
+		return 0
+	}
+	return math.Sqrt(r.m2 / float64(r.n-1))
+}
+
+// RewardScaler multiplies the rewards of the inner environment by a fixed Scale,
+// e.g. the inverse of a stock's volatility so stocks of different volatility weigh
+// alike in the updates of a shared Q-table.
+type RewardScaler struct {
+	Wrapper
+	Scale float64
+}
+
+// NewRewardScaler wraps e with its rewards multiplied by scale.
+func NewRewardScaler(e Environment, scale float64) *RewardScaler {
+	return &RewardScaler{Wrapper: Wrapper{Env: e}, Scale: scale}
+}
+
+// Step steps the inner environment and returns the scaled reward.
+func (r *RewardScaler) Step(action agent.Action) (next state.State, reward float64, done bool) {
+	next, reward, done = r.Env.Step(action)
+	return next, reward * r.Scale, done
+}
+
+// ActionFilter restricts the actions allowed in a state on top of the inner
+// environment's mask, e.g. to forbid selling in some regimes. Disallowed actions
+// passed to Step are replaced by agent.ActionNothing.
+type ActionFilter struct {
+	Wrapper
+	// Allow returns the actions allowed in s, indexed by action, or nil if all are.
+	Allow func(s state.State) []bool
+
+	current state.State
+}
+
+// NewActionFilter wraps e with the actions restricted by allow.
+func NewActionFilter(e Environment, allow func(s state.State) []bool) *ActionFilter {
+	return &ActionFilter{Wrapper: Wrapper{Env: e}, Allow: allow}
+}
+
+// Reset resets the inner environment.
+func (f *ActionFilter) Reset() state.State {
+	f.current = f.Env.Reset()
+	return f.current
+}
+
+// Step steps the inner environment, holding instead of a disallowed action.
+func (f *ActionFilter) Step(action agent.Action) (next state.State, reward float64, done bool) {
+	if allowed := f.ActionMask(); allowed != nil && !allowed[action] {
+		action = agent.ActionNothing
+	}
+	next, reward, done = f.Env.Step(action)
+	f.current = next
+	return next, reward, done
+}
+
+// ActionMask returns the actions allowed by both the filter and the inner environment.
+func (f *ActionFilter) ActionMask() []bool {
+	return andMask(f.Wrapper.ActionMask(), f.Allow(f.current))
+}
+
+// Augmenter extends the states of the inner environment with an extra feature of
+// Levels values: the state index becomes Index*Levels + feature, so the Q-table
+// needs NumStates times the encoder's states.
+type Augmenter struct {
+	Wrapper
+	Levels int
+	// Feature returns the extra feature of s, in [0, Levels).
+	Feature func(s state.State) int
+}
+
+// NewAugmenter wraps e with the states extended by feature.
+func NewAugmenter(e Environment, levels int, feature func(s state.State) int) *Augmenter {
+	return &Augmenter{Wrapper: Wrapper{Env: e}, Levels: levels, Feature: feature}
+}
+
+// NumStates returns the size of the augmented state space over an encoder with
+// baseStates states.
+func (a *Augmenter) NumStates(baseStates int) int {
+	return baseStates * a.Levels
+}
+
+// Reset resets the inner environment and returns the augmented initial state.
+func (a *Augmenter) Reset() state.State {
+	return a.augment(a.Env.Reset())
+}
+
+// Step steps the inner environment and returns the augmented next state.
+func (a *Augmenter) Step(action agent.Action) (next state.State, reward float64, done bool) {
+	next, reward, done = a.Env.Step(action)
+	return a.augment(next), reward, done
+}
+
+func (a *Augmenter) augment(s state.State) state.State {
+	f := min(max(a.Feature(s), 0), a.Levels-1)
+	s.Index = s.Index*a.Levels + f
+	return s
+}
+
+// PositionFeature returns an Augmenter feature bucketing the share of m's
+// portfolio held in the asset into levels equal ranges.
+func PositionFeature(m *MarketEnv, levels int) func(s state.State) int {
+	return func(state.State) int {
+		value := m.PortfolioValue()
+		if value <= 0 {
+			return 0
+		}
+		weight := m.Shares() * m.CurrentPrice() / value
+		return min(int(weight*float64(levels)), levels-1)
+	}
+}
+
+// DrawdownOverlay is a risk overlay cutting the position when the portfolio falls
+// MaxDrawdown below its peak: the step's action is replaced by
+// agent.ActionSellLarge, and buys are masked for Cooldown steps, after which the
+// peak restarts from the current value. It needs a MarketEnv at the bottom of the
+// wrappers.
+type DrawdownOverlay struct {
+	Wrapper
+	MaxDrawdown float64
+	Cooldown    int
+
+	market  *MarketEnv
+	peak    float64
+	blocked int // Steps left with buys masked
+}
+
+// NewDrawdownOverlay wraps e with a drawdown limit of maxDrawdown (e.g. 0.2 for 20%).
+// It panics if e has no MarketEnv at the bottom.
+func NewDrawdownOverlay(e Environment, maxDrawdown float64, cooldown int) *DrawdownOverlay {
+	market, ok := Market(e)
+	if !ok {
+		panic("env: DrawdownOverlay needs a MarketEnv")
+	}
+	return &DrawdownOverlay{Wrapper: Wrapper{Env: e}, MaxDrawdown: maxDrawdown, Cooldown: cooldown, market: market}
+}
+
+// Reset resets the inner environment and the peak.
+func (d *DrawdownOverlay) Reset() state.State {
+	s := d.Env.Reset()
+	d.peak = d.market.PortfolioValue()
+	d.blocked = 0
+	return s
+}
+
+// Step steps the inner environment, cutting the position on a breach of the limit.
+func (d *DrawdownOverlay) Step(action agent.Action) (next state.State, reward float64, done bool) {
+	value := d.market.PortfolioValue()
+	d.peak = math.Max(d.peak, value)
+	if d.blocked > 0 {
+		d.blocked--
+		if d.blocked == 0 {
+			d.peak = value
+		}
+	}
+	if d.blocked > 0 && action.IsBuy() {
+		action = agent.ActionNothing
+	}
+	if d.blocked == 0 && d.peak > 0 && value < d.peak*(1-d.MaxDrawdown) {
+		action = agent.ActionSellLarge
+		d.blocked = max(d.Cooldown, 1)
+	}
+	return d.Env.Step(action)
+}
+
+// Breached reports whether the overlay is blocking buys after a breach.
+func (d *DrawdownOverlay) Breached() bool {
+	return d.blocked > 0
+}
+
+// ActionMask returns the inner environment's mask, with buys masked after a breach.
+func (d *DrawdownOverlay) ActionMask() []bool {
+	allowed := d.Wrapper.ActionMask()
+	if d.blocked == 0 {
+		return allowed
+	}
+	blocked := make([]bool, agent.NumActions)
+	for a := range blocked {
+		blocked[a] = !agent.Action(a).IsBuy()
+	}
+	return andMask(allowed, blocked)
+}
@@ -0,0 +1,209 @@
+package env
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+func newWrapperEnv(prices []float64) *MarketEnv {
+	return NewMarketEnv(MarketConfig{
+		Prices:      prices,
+		InitialCash: 10000,
+		MinStartIdx: 120,
+		Commission:  0.002,
+	})
+}
+
+func TestWrapperForwards(t *testing.T) {
+	prices := randomWalk(400)
+	plain, inner := newWrapperEnv(prices), newWrapperEnv(prices)
+	var wrapped Environment = &Wrapper{Env: &Wrapper{Env: inner}}
+
+	if got, ok := Market(wrapped); !ok || got != inner {
+		t.Fatalf("Market() = %p, %v, want the inner environment", got, ok)
+	}
+	s1, s2 := plain.Reset(), wrapped.Reset()
+	for i := 0; ; i++ {
+		if s1 != s2 {
+			t.Fatalf("step %d: state %+v, want %+v", i, s2, s1)
+		}
+		a := agent.Action(i % agent.NumActions)
+		var r1, r2 float64
+		var d1, d2 bool
+		s1, r1, d1 = plain.Step(a)
+		s2, r2, d2 = wrapped.Step(a)
+		if r1 != r2 || d1 != d2 {
+			t.Fatalf("step %d: reward %v, done %v, want %v, %v", i, r2, d2, r1, d1)
+		}
+		if d1 {
+			break
+		}
+	}
+}
+
+func TestRewardNormalizerClips(t *testing.T) {
+	n := NewRewardNormalizer(newWrapperEnv(randomWalk(400)), 1.5)
+	n.Reset()
+	for i := 0; ; i++ {
+		_, r, done := n.Step(agent.ActionBuyLarge)
+		if math.Abs(r) > 1.5 {
+			t.Fatalf("step %d: reward %v exceeds the clip", i, r)
+		}
+		if done {
+			break
+		}
+	}
+	if n.Std() <= 0 {
+		t.Errorf("Std() = %v, want positive", n.Std())
+	}
+}
+
+func TestActionFilterHoldsDisallowedActions(t *testing.T) {
+	noBuys := func(state.State) []bool { return []bool{true, false, false, true, true} }
+	inner := newWrapperEnv(randomWalk(400))
+	f := NewActionFilter(inner, noBuys)
+	f.Reset()
+	f.Step(agent.ActionBuyLarge)
+	if inner.Shares() != 0 {
+		t.Errorf("shares = %v after a filtered buy, want 0", inner.Shares())
+	}
+}
+
+func TestAugmenterExtendsStates(t *testing.T) {
+	inner := newWrapperEnv(randomWalk(400))
+	a := NewAugmenter(inner, 3, PositionFeature(inner, 3))
+	numStates := a.NumStates(state.NewMAEncoder().NumStates())
+	s := a.Reset()
+	for i := 0; ; i++ {
+		if s.Index < 0 || s.Index >= numStates {
+			t.Fatalf("step %d: index %d outside [0, %d)", i, s.Index, numStates)
+		}
+		var done bool
+		if s, _, done = a.Step(agent.ActionBuyLarge); done {
+			break
+		}
+	}
+	if s.Index%3 != 2 {
+		t.Errorf("feature = %d fully invested, want 2", s.Index%3)
+	}
+}
+
+func TestDrawdownOverlayCutsPosition(t *testing.T) {
+	// A steady fall after the warm-up
+	prices := make([]float64, 200)
+	for i := range prices {
+		prices[i] = 100
+		if i > 120 {
+			prices[i] = prices[i-1] * 0.98
+		}
+	}
+	plain, inner := newWrapperEnv(prices), newWrapperEnv(prices)
+	d := NewDrawdownOverlay(inner, 0.1, 5)
+	plain.Reset()
+	d.Reset()
+	breached := false
+	for {
+		plain.Step(agent.ActionBuyLarge)
+		_, _, done := d.Step(agent.ActionBuyLarge)
+		breached = breached || d.Breached()
+		if done {
+			break
+		}
+	}
+	if !breached {
+		t.Fatal("the overlay never cut the position")
+	}
+	if inner.PortfolioValue() <= plain.PortfolioValue() {
+		t.Errorf("final value %.2f with the overlay, want above %.2f without", inner.PortfolioValue(), plain.PortfolioValue())
+	}
+}
+
+func TestRecorderReplays(t *testing.T) {
+	prices := randomWalk(300)
+	path := t.TempDir() + "/run.jsonl"
+	r, err := NewRecorder(newWrapperEnv(prices), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(2))
+	for ep := 0; ep < 2; ep++ {
+		r.Reset()
+		for done := false; !done; {
+			_, _, done = r.Step(agent.Action(rng.Intn(agent.NumActions)))
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadTrajectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	episodes := Episodes(records)
+	if len(episodes) != 2 {
+		t.Fatalf("%d episodes, want 2", len(episodes))
+	}
+	for i, episode := range episodes {
+		if err := Replay(newWrapperEnv(prices), episode); err != nil {
+			t.Errorf("episode %d: %v", i+1, err)
+		}
+	}
+	changed := newWrapperEnv(append(append([]float64(nil), prices[:200]...), randomWalk(100)...))
+	if err := Replay(changed, episodes[0]); err == nil {
+		t.Error("replay on other prices succeeded, want a mismatch")
+	}
+}
+
+func TestDiscretizerPicksClosestAction(t *testing.T) {
+	d := NewDiscretizer(newWrapperEnv(randomWalk(300)))
+	d.Reset()
+	for _, c := range []struct {
+		target agent.ContinuousAction
+		want   agent.Action
+	}{
+		{0, agent.ActionNothing},
+		{0.12, agent.ActionBuySmall},
+		{0.7, agent.ActionBuyLarge},
+	} {
+		if got := d.Discretize(c.target); got != c.want {
+			t.Errorf("target %.2f from cash: %v, want %v", c.target, got, c.want)
+		}
+	}
+	d.StepContinuous(0.7)
+	if d.market.Weight() < 0.4 {
+		t.Errorf("weight %.4f after buying towards 0.7", d.market.Weight())
+	}
+}
+
+func TestEnforcerCutsDownViolations(t *testing.T) {
+	for _, c := range []struct {
+		constraints Constraints
+		rule        string
+		want        agent.Action
+	}{
+		{Constraints{MaxEquityWeight: 0.3}, RuleMaxEquityWeight, agent.ActionBuySmall},
+		{Constraints{MinCashBuffer: 0.95}, RuleMinCashBuffer, agent.ActionNothing},
+		{Constraints{MaxDailyTurnover: 0.15}, RuleMaxDailyTurnover, agent.ActionBuySmall},
+	} {
+		var logged []Violation
+		f := NewEnforcer(newWrapperEnv(randomWalk(300)), c.constraints, func(v Violation) { logged = append(logged, v) })
+		f.Reset()
+		f.Step(agent.ActionBuyLarge)
+		if len(logged) != 1 || logged[0].Rule != c.rule || logged[0].Executed != c.want {
+			t.Errorf("%s: logged %v, want a violation executed as %v", c.rule, logged, c.want)
+			continue
+		}
+		if f.Violations() != 1 {
+			t.Errorf("%s: %d violations counted", c.rule, f.Violations())
+		}
+		// Selling reduces the position and always complies with the first two rules
+		if f.Step(agent.ActionSellSmall); c.rule != RuleMaxDailyTurnover && f.Violations() != 1 {
+			t.Errorf("%s: sell cut down", c.rule)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package eval
+
+import (
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// CrossPolicy is the golden/death cross baseline: it buys while MA50 is above MA200
+// and sells while it is below, trading large until fully invested or in cash. It
+// acts on states of state.RegimeEncoder.
+type CrossPolicy struct{}
+
+// Act returns the baseline action for a regime state.
+func (CrossPolicy) Act(s state.State) agent.Action {
+	regime := ma.Regime(s.MAState)
+	switch {
+	case regime.IsBullish() && s.CashCat != state.PosNone:
+		return agent.ActionBuyLarge
+	case regime.IsBearish() && s.SharesCat != state.PosNone:
+		return agent.ActionSellLarge
+	default:
+		return agent.ActionNothing
+	}
+}
+
+// EvaluateCrossBaseline runs CrossPolicy over prices, for comparison with learned policies.
+func EvaluateCrossBaseline(prices []float64, config Config) (*Result, error) {
+	marketEnv, err := newMarketEnv(state.NewRegimeEncoder(), prices, config)
+	if err != nil {
+		return nil, err
+	}
+	return Run(CrossPolicy{}, marketEnv), nil
+}
@@ -0,0 +1,174 @@
+package eval
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// Job is one greedy policy evaluated on one price series by an Engine.
+type Job struct {
+	Q       [][]float64
+	Prices  []float64
+	FX      []float64     // Overrides Config.FX for the series; jobs sharing a series share its FX
+	Encoder state.Encoder // Encoder of Q; defaults to Engine.Encoder
+	// Bid and Ask override Config.Bid and Config.Ask for the series, like FX.
+	Bid []float64
+	Ask []float64
+}
+
+// Engine evaluates many (policy, series) pairs concurrently, e.g. for grid searches
+// and Monte Carlo stress tests. Environments are pooled per series and encoder, so
+// the per-series encoder precomputation is done once however many policies share it.
+type Engine struct {
+	Config  Config
+	Encoder state.Encoder // Encoder of jobs without one; defaults to state.MAEncoder
+	Workers int           // Defaults to GOMAXPROCS
+}
+
+// NewEngine creates an engine with the given market settings.
+func NewEngine(config Config) *Engine {
+	return &Engine{Config: config}
+}
+
+// poolKey identifies a price series by its backing array, and the encoder bound to it.
+type poolKey struct {
+	first   *float64
+	n       int
+	encoder state.Encoder
+}
+
+// envPool holds reusable environments for one series.
+type envPool struct {
+	once sync.Once
+	pool sync.Pool
+	err  error
+}
+
+// Batch evaluates every job and returns the results in job order. The runs are
+// deterministic, so the results equal calling Evaluate for each job.
+func (e *Engine) Batch(jobs []Job) ([]*Result, error) {
+	defaultEncoder := e.Encoder
+	if defaultEncoder == nil {
+		defaultEncoder = state.NewMAEncoder()
+	}
+	encoders := make([]state.Encoder, len(jobs))
+	for i, job := range jobs {
+		encoder := job.Encoder
+		if encoder == nil {
+			encoder = defaultEncoder
+		}
+		encoders[i] = encoder
+		if len(job.Q) != encoder.NumStates() {
+			return nil, fmt.Errorf("job %d: Q-matrix has %d states, encoder expects %d", i, len(job.Q), encoder.NumStates())
+		}
+		if len(job.Prices) == 0 {
+			return nil, fmt.Errorf("job %d: no prices", i)
+		}
+	}
+
+	var mu sync.Mutex
+	pools := make(map[poolKey]*envPool)
+	poolFor := func(prices []float64, encoder state.Encoder) *envPool {
+		key := poolKey{first: &prices[0], n: len(prices), encoder: encoder}
+		mu.Lock()
+		defer mu.Unlock()
+		p, ok := pools[key]
+		if !ok {
+			p = &envPool{}
+			pools[key] = p
+		}
+		return p
+	}
+
+	workers := e.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	results := make([]*Result, len(jobs))
+	errs := make([]error, len(jobs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i], errs[i] = e.run(poolFor(jobs[i].Prices, encoders[i]), encoders[i], jobs[i])
+			}
+		}()
+	}
+	for i := range jobs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("job %d: %w", i, err)
+		}
+	}
+	return results, nil
+}
+
+// Grid evaluates every policy on every series; results[i][j] is policy i on series j.
+func (e *Engine) Grid(Qs [][][]float64, series [][]float64) ([][]*Result, error) {
+	jobs := make([]Job, 0, len(Qs)*len(series))
+	for _, Q := range Qs {
+		for _, prices := range series {
+			jobs = append(jobs, Job{Q: Q, Prices: prices})
+		}
+	}
+	flat, err := e.Batch(jobs)
+	if err != nil {
+		return nil, err
+	}
+	results := make([][]*Result, len(Qs))
+	for i := range results {
+		results[i] = flat[i*len(series) : (i+1)*len(series)]
+	}
+	return results, nil
+}
+
+// run evaluates one job on an environment taken from the pool of its series and encoder.
+func (e *Engine) run(p *envPool, encoder state.Encoder, job Job) (*Result, error) {
+	config := e.Config
+	if job.FX != nil {
+		config.FX = job.FX
+	}
+	if job.Bid != nil {
+		config.Bid, config.Ask = job.Bid, job.Ask
+	}
+	p.once.Do(func() {
+		// Bind the encoder to the series once; every pooled environment shares it
+		bound := encoder
+		if series, ok := encoder.(state.SeriesEncoder); ok {
+			bound = series.ForSeries(job.Prices)
+		}
+		marketEnv, err := newMarketEnv(bound, job.Prices, config)
+		if err != nil {
+			p.err = err
+			return
+		}
+		p.pool.New = func() any {
+			marketEnv, _ := newMarketEnv(bound, job.Prices, config)
+			return marketEnv
+		}
+		p.pool.Put(marketEnv)
+	})
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	marketEnv := p.pool.Get().(*env.MarketEnv)
+	defer p.pool.Put(marketEnv)
+	return Run(greedyActor(job.Q, encoder), marketEnv), nil
+}
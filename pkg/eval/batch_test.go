@@ -0,0 +1,92 @@
+package eval
+
+import (
+	"math/rand"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// randomWalk returns n deterministic prices.
+func randomWalk(n int, seed int64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	prices := make([]float64, n)
+	p := 100.0
+	for i := range prices {
+		p *= 1 + rng.NormFloat64()*0.01
+		prices[i] = p
+	}
+	return prices
+}
+
+// randomQ returns a deterministic Q-matrix of the encoder's size.
+func randomQ(encoder state.Encoder, seed int64) [][]float64 {
+	rng := rand.New(rand.NewSource(seed))
+	Q := make([][]float64, encoder.NumStates())
+	for s := range Q {
+		Q[s] = make([]float64, agent.NumActions)
+		for a := range Q[s] {
+			Q[s][a] = rng.NormFloat64()
+		}
+	}
+	return Q
+}
+
+// countingEncoder counts the series it is bound to.
+type countingEncoder struct {
+	*state.MAEncoder
+	bound atomic.Int32
+}
+
+func (e *countingEncoder) ForSeries(prices []float64) state.Encoder {
+	e.bound.Add(1)
+	return e.MAEncoder.ForSeries(prices)
+}
+
+func TestBatchMatchesEvaluate(t *testing.T) {
+	ma := &countingEncoder{MAEncoder: state.NewMAEncoder()}
+	slope := state.NewSlopeEncoder(0)
+	series := [][]float64{randomWalk(400, 1), randomWalk(300, 2)}
+	var jobs []Job
+	for i := 0; i < 4; i++ {
+		for _, prices := range series {
+			jobs = append(jobs,
+				Job{Q: randomQ(ma, int64(i)), Prices: prices, Encoder: ma},
+				Job{Q: randomQ(slope, int64(i)), Prices: prices, Encoder: slope})
+		}
+	}
+
+	engine := NewEngine(DefaultConfig())
+	engine.Workers = 4
+	results, err := engine.Batch(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(jobs) {
+		t.Fatalf("%d results for %d jobs", len(results), len(jobs))
+	}
+	// One environment pool per series shares the bound encoder across its jobs
+	if n := ma.bound.Load(); n != int32(len(series)) {
+		t.Errorf("MA encoder bound %d times, want once per series (%d)", n, len(series))
+	}
+	for i, job := range jobs {
+		want, err := Evaluate(job.Q, job.Encoder, job.Prices, DefaultConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(results[i].Actions, want.Actions) || !reflect.DeepEqual(results[i].Equity, want.Equity) {
+			t.Errorf("job %d: batch result differs from Evaluate", i)
+		}
+	}
+}
+
+func TestBatchChecksJobEncoder(t *testing.T) {
+	slope := state.NewSlopeEncoder(0)
+	_, err := NewEngine(DefaultConfig()).Batch([]Job{{Q: randomQ(slope, 1), Prices: randomWalk(300, 1)}})
+	if err == nil {
+		t.Error("slope Q-matrix evaluated with the default MA encoder")
+	}
+}
@@ -0,0 +1,82 @@
+package eval
+
+import (
+	"fmt"
+
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// DefaultCommissions and DefaultSlippages are the trading cost levels swept by
+// default: from a discount broker to an expensive one, and from a liquid large cap
+// to an illiquid small cap.
+var (
+	DefaultCommissions = []float64{0.0005, 0.001, 0.002, 0.005, 0.01}
+	DefaultSlippages   = []float64{0, 0.001, 0.0025, 0.005}
+)
+
+// CostPoint is the outcome of the policy at one level of trading costs.
+type CostPoint struct {
+	Commission float64         `json:"commission"`
+	Slippage   float64         `json:"slippage"`
+	Metrics    metrics.Metrics `json:"metrics"`
+	Costs      float64         `json:"costs_paid"` // Commission and slippage paid over the run
+	// BuyAndHold is the total return of investing all cash at the first decision at
+	// the same costs.
+	BuyAndHold float64 `json:"buy_and_hold_return"`
+}
+
+// CostSweep re-runs the greedy policy of Q on the same prices at every combination
+// of the commission and slippage levels, showing how much of its return survives
+// trading frictions. Points are ordered by slippage, then commission. Commission
+// levels must be positive: a zero commission selects the environment default.
+func CostSweep(Q [][]float64, encoder state.Encoder, prices []float64, config Config, commissions, slippages []float64) ([]CostPoint, error) {
+	if encoder == nil {
+		encoder = state.NewMAEncoder()
+	}
+	if len(Q) != encoder.NumStates() {
+		return nil, fmt.Errorf("Q-matrix has %d states, encoder expects %d", len(Q), encoder.NumStates())
+	}
+	for _, c := range commissions {
+		if c <= 0 {
+			return nil, fmt.Errorf("commission levels must be positive, got %g", c)
+		}
+	}
+	for _, s := range slippages {
+		if s < 0 {
+			return nil, fmt.Errorf("slippage levels must not be negative, got %g", s)
+		}
+	}
+
+	actor := greedyActor(Q, encoder)
+	values := env.ToBase(prices, config.FX)
+	points := make([]CostPoint, 0, len(commissions)*len(slippages))
+	for _, slippage := range slippages {
+		for _, commission := range commissions {
+			config.Commission, config.Slippage = commission, slippage
+			marketEnv, err := newMarketEnv(encoder, prices, config)
+			if err != nil {
+				return nil, err
+			}
+			result := Run(actor, marketEnv)
+			point := CostPoint{
+				Commission: commission,
+				Slippage:   slippage,
+				Metrics:    result.Metrics,
+				BuyAndHold: buyAndHoldReturn(values[result.StartIdx:], commission, slippage),
+			}
+			for _, t := range result.Trades {
+				point.Costs += t.Commission
+			}
+			points = append(points, point)
+		}
+	}
+	return points, nil
+}
+
+// buyAndHoldReturn returns the total return of buying at the first price, after
+// commission and slippage, and holding to the last.
+func buyAndHoldReturn(prices []float64, commission, slippage float64) float64 {
+	return (1-commission)/(1+slippage)*prices[len(prices)-1]/prices[0] - 1
+}
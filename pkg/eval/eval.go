@@ -0,0 +1,300 @@
+package eval
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// Config holds the market settings used for an evaluation run.
+type Config struct {
+	InitialCash float64
+	MinStartIdx int // 0 starts at the encoder's warm-up
+	Commission  float64
+	Slippage    float64 // Fraction of the price lost on every trade, see env.MarketConfig
+	MaxWeight   float64 // Maximum share of the portfolio in the asset; 0 means no limit
+	VolTarget   float64 // Annualized volatility buy sizes are scaled to; 0 disables
+	VolWindow   int     // Returns over which VolTarget measures volatility; 0 means env.DefaultVolWindow
+	Sizing      env.Sizing
+	KellyWindow int // Returns the Kelly sizing estimates from; 0 means env.DefaultKellyWindow
+	// Clock, if set, holds the timestamp of every price: metrics are then annualized
+	// by its PeriodsPerYear instead of assuming daily steps, and trades are dated.
+	Clock *data.Clock
+	// FX, if set, converts the prices into the base currency the portfolio is
+	// valued in (see env.MarketConfig).
+	FX []float64
+	// Lots selects the tax-lot accounting of Result.Realized, and TaxRate the tax
+	// drag on the reward (see env.MarketConfig).
+	Lots    env.LotMethod
+	TaxRate float64
+	// InitialShares is a position held from the start besides InitialCash (see
+	// env.MarketConfig).
+	InitialShares float64
+	// MinTradeWeight and MinTradeValue hold instead of trades below a minimum size
+	// (see env.MarketConfig).
+	MinTradeWeight float64
+	MinTradeValue  float64
+	// CommissionMin and CommissionMax floor and cap the commission of a trade, in
+	// the fee currency converted by FeeFX, or the base currency without it (see
+	// env.MarketConfig).
+	CommissionMin float64
+	CommissionMax float64
+	FeeFX         []float64
+	// Bid and Ask, if set, hold the quotes of every price: buys fill at the ask and
+	// sells at the bid (see env.MarketConfig). Synthetic price paths keep the
+	// quotes' relative spread at every index (see pathQuotes).
+	Bid []float64
+	Ask []float64
+	// Halted, if set, flags the prices at which no trade executes (see
+	// env.MarketConfig); synthetic price paths keep the flags of every index.
+	Halted []bool
+	// Regret records, at every step, the reward of every alternative action in
+	// Result.Regret (see RunRegret).
+	Regret bool
+}
+
+// DefaultConfig returns the settings used by the command-line tools.
+func DefaultConfig() Config {
+	return Config{
+		InitialCash: 10000.0,
+		Commission:  0.002,
+	}
+}
+
+// Trade describes a single executed buy or sell.
+type Trade struct {
+	Step        int       // Step number within the episode
+	PriceIdx    int       // Index into the price series
+	Time        time.Time // Timestamp of the price with Config.Clock, else zero
+	Action      agent.Action
+	Price       float64
+	Shares      float64 // Shares bought (positive) or sold (negative)
+	Notional    float64 // Cash value of the traded shares before commission
+	Commission  float64 // Trading costs: commission, and slippage with Config.Slippage
+	CashAfter   float64
+	SharesAfter float64
+	State       state.State
+}
+
+// Result holds the outcome of evaluating a policy on a price series.
+type Result struct {
+	StartIdx int            // Price index of the first decision
+	Equity   []float64      // Portfolio value before the first step and after every step
+	Actions  []agent.Action // Action chosen at every step
+	States   []state.State  // State observed at every step
+	Trades   []Trade        // Executed trades (actions that changed the position)
+	// SizeScales holds the factor applied to buy sizes at every step with a
+	// volatility target, and is nil without one.
+	SizeScales []float64
+	// Regret holds the counterfactual rewards of every step of a RunRegret, and is
+	// nil otherwise.
+	Regret []RegretStep
+	// Realized holds the gains realized by the sells and Lots the tax lots still
+	// open at the end, with Config.Lots; both are nil otherwise.
+	Realized []env.Realization
+	Lots     []env.Lot
+	Metrics  metrics.Metrics
+}
+
+// Evaluate runs the greedy policy defined by Q over prices and returns the full result.
+// The run is deterministic: the same inputs always produce the same result.
+func Evaluate(Q [][]float64, encoder state.Encoder, prices []float64, config Config) (*Result, error) {
+	if encoder == nil {
+		encoder = state.NewMAEncoder()
+	}
+	if len(Q) != encoder.NumStates() {
+		return nil, fmt.Errorf("Q-matrix has %d states, encoder expects %d", len(Q), encoder.NumStates())
+	}
+
+	marketEnv, err := newMarketEnv(encoder, prices, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Regret {
+		return RunRegret(greedyActor(Q, encoder), marketEnv), nil
+	}
+	return Run(greedyActor(Q, encoder), marketEnv), nil
+}
+
+// greedyActor returns the greedy policy of Q, routed to the table of every regime
+// for a state.GatedEncoder.
+func greedyActor(Q [][]float64, encoder state.Encoder) agent.Actor {
+	gated, ok := encoder.(*state.GatedEncoder)
+	if !ok {
+		return agent.NewGreedyPolicy(Q)
+	}
+	n := gated.TableStates()
+	policies := make([]agent.Actor, gated.Detector.NumRegimes())
+	for r := range policies {
+		policies[r] = agent.NewGreedyPolicy(Q[r*n : (r+1)*n])
+	}
+	return agent.NewMetaPolicy(policies, n)
+}
+
+// newMarketEnv creates the evaluation environment and checks that prices cover at least one step.
+func newMarketEnv(encoder state.Encoder, prices []float64, config Config) (*env.MarketEnv, error) {
+	if config.Clock != nil && config.Clock.Len() != len(prices) {
+		return nil, fmt.Errorf("clock has %d timestamps for %d prices", config.Clock.Len(), len(prices))
+	}
+	if config.FX != nil && len(config.FX) != len(prices) {
+		return nil, fmt.Errorf("%d FX rates for %d prices", len(config.FX), len(prices))
+	}
+	if config.FeeFX != nil && len(config.FeeFX) != len(prices) {
+		return nil, fmt.Errorf("%d fee FX rates for %d prices", len(config.FeeFX), len(prices))
+	}
+	if (config.Bid == nil) != (config.Ask == nil) {
+		return nil, fmt.Errorf("quotes need both bids and asks")
+	}
+	if config.Bid != nil && (len(config.Bid) != len(prices) || len(config.Ask) != len(prices)) {
+		return nil, fmt.Errorf("%d bids and %d asks for %d prices", len(config.Bid), len(config.Ask), len(prices))
+	}
+	if config.Halted != nil && len(config.Halted) != len(prices) {
+		return nil, fmt.Errorf("%d halt flags for %d prices", len(config.Halted), len(prices))
+	}
+	marketEnv := env.NewMarketEnv(env.MarketConfig{
+		Prices:         prices,
+		InitialCash:    config.InitialCash,
+		MinStartIdx:    config.MinStartIdx,
+		Commission:     config.Commission,
+		Slippage:       config.Slippage,
+		Encoder:        encoder,
+		MaxWeight:      config.MaxWeight,
+		VolTarget:      config.VolTarget,
+		VolWindow:      config.VolWindow,
+		Sizing:         config.Sizing,
+		KellyWindow:    config.KellyWindow,
+		Clock:          config.Clock,
+		FX:             config.FX,
+		Lots:           config.Lots,
+		TaxRate:        config.TaxRate,
+		InitialShares:  config.InitialShares,
+		MinTradeWeight: config.MinTradeWeight,
+		MinTradeValue:  config.MinTradeValue,
+		CommissionMin:  config.CommissionMin,
+		CommissionMax:  config.CommissionMax,
+		FeeFX:          config.FeeFX,
+		Bid:            config.Bid,
+		Ask:            config.Ask,
+		Halted:         config.Halted,
+	})
+	if len(prices) < marketEnv.StartIdx()+2 {
+		return nil, fmt.Errorf("need at least %d prices, got %d", marketEnv.StartIdx()+2, len(prices))
+	}
+	return marketEnv, nil
+}
+
+// pathQuotes returns the quotes of a synthetic path derived from prices, with the
+// relative spread of the quotes of prices at every index, and the last one past
+// their end. Without quotes it returns nil.
+func pathQuotes(path, prices, bid, ask []float64) (pathBid, pathAsk []float64) {
+	if bid == nil || ask == nil || len(prices) == 0 {
+		return nil, nil
+	}
+	pathBid, pathAsk = make([]float64, len(path)), make([]float64, len(path))
+	for i, p := range path {
+		j := min(i, len(prices)-1)
+		pathBid[i], pathAsk[i] = p*bid[j]/prices[j], p*ask[j]/prices[j]
+	}
+	return pathBid, pathAsk
+}
+
+// Run plays one episode of the actor on the environment and records the result.
+func Run(actor agent.Actor, marketEnv *env.MarketEnv) *Result {
+	return run(actor, marketEnv, false)
+}
+
+// RunRegret is Run that also records, before every step, the reward each allowed
+// action would have earned, in Result.Regret.
+func RunRegret(actor agent.Actor, marketEnv *env.MarketEnv) *Result {
+	return run(actor, marketEnv, true)
+}
+
+func run(actor agent.Actor, marketEnv *env.MarketEnv, regret bool) *Result {
+	s := marketEnv.Reset()
+	result := &Result{
+		StartIdx: marketEnv.StartIdx(),
+		Equity:   []float64{marketEnv.PortfolioValue()},
+	}
+
+	done := false
+	for step := 0; !done; step++ {
+		mask := marketEnv.ActionMask()
+		action := agent.ActWith(actor, s, mask)
+		if regret {
+			result.Regret = append(result.Regret, whatIf(marketEnv, action, mask))
+		}
+		priceIdx := marketEnv.CurrentIdx()
+		priceTime := marketEnv.Time()
+		price := marketEnv.CurrentPrice()
+		cashBefore := marketEnv.Cash()
+		sharesBefore := marketEnv.Shares()
+
+		next, _, d := marketEnv.Step(action)
+
+		if trade, ok := tradeFromDelta(price, cashBefore, sharesBefore, marketEnv.Cash(), marketEnv.Shares()); ok {
+			trade.Step = step
+			trade.PriceIdx = priceIdx
+			trade.Time = priceTime
+			trade.Action = action
+			trade.State = s
+			result.Trades = append(result.Trades, trade)
+		}
+
+		result.Equity = append(result.Equity, marketEnv.PortfolioValue())
+		result.Actions = append(result.Actions, action)
+		result.States = append(result.States, s)
+		if marketEnv.VolTarget() > 0 {
+			result.SizeScales = append(result.SizeScales, marketEnv.SizeScale())
+		}
+		s = next
+		done = d
+	}
+
+	if realized := marketEnv.Realized(); len(realized) > 0 {
+		result.Realized = append([]env.Realization(nil), realized...)
+	}
+	if lots := marketEnv.Lots(); len(lots) > 0 {
+		result.Lots = append([]env.Lot(nil), lots...)
+	}
+
+	actions := make([]int, len(result.Actions))
+	for i, a := range result.Actions {
+		actions[i] = int(a)
+	}
+	result.Metrics = metrics.ComputeAnnualized(result.Equity, actions, marketEnv.PeriodsPerYear())
+	// Count executed trades rather than requested ones (e.g. sells with no shares)
+	result.Metrics.NumTrades = len(result.Trades)
+	result.Metrics.Commissions = marketEnv.CommissionPaid()
+	return result
+}
+
+// tradeFromDelta derives the executed trade from the change in cash and shares.
+func tradeFromDelta(price, cashBefore, sharesBefore, cashAfter, sharesAfter float64) (Trade, bool) {
+	sharesDelta := sharesAfter - sharesBefore
+	if sharesDelta == 0 {
+		return Trade{}, false
+	}
+
+	trade := Trade{
+		Price:       price,
+		Shares:      sharesDelta,
+		CashAfter:   cashAfter,
+		SharesAfter: sharesAfter,
+	}
+	if sharesDelta > 0 {
+		// Buy: cash spent covers the shares and the commission
+		trade.Notional = sharesDelta * price
+		trade.Commission = (cashBefore - cashAfter) - trade.Notional
+	} else {
+		// Sell: proceeds minus commission are added to cash
+		trade.Notional = -sharesDelta * price
+		trade.Commission = trade.Notional - (cashAfter - cashBefore)
+	}
+	return trade, true
+}
@@ -0,0 +1,151 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// Path generation methods for Monte Carlo evaluation.
+const (
+	MethodBootstrap = "bootstrap" // Block bootstrap of historical log returns
+	MethodGBM       = "gbm"       // Geometric Brownian motion with historical drift and volatility
+)
+
+// MonteCarloConfig holds the settings for Monte Carlo stress testing.
+type MonteCarloConfig struct {
+	Paths     int    // Number of simulated price paths
+	Method    string // MethodBootstrap or MethodGBM
+	BlockSize int    // Block length for the bootstrap (keeps short-term autocorrelation)
+	Seed      int64
+}
+
+// MonteCarloResult holds the distribution of outcomes over all simulated paths.
+type MonteCarloResult struct {
+	Returns   []float64 // Total return of every path
+	Drawdowns []float64 // Maximum drawdown of every path
+}
+
+// Quantiles holds the P5/P50/P95 summary of a distribution.
+type Quantiles struct {
+	P5  float64
+	P50 float64
+	P95 float64
+}
+
+// ReturnQuantiles returns the P5/P50/P95 of the final returns.
+func (r *MonteCarloResult) ReturnQuantiles() Quantiles {
+	return quantiles(r.Returns)
+}
+
+// DrawdownQuantiles returns the P5/P50/P95 of the maximum drawdowns.
+func (r *MonteCarloResult) DrawdownQuantiles() Quantiles {
+	return quantiles(r.Drawdowns)
+}
+
+func quantiles(values []float64) Quantiles {
+	return Quantiles{
+		P5:  metrics.Percentile(values, 5),
+		P50: metrics.Percentile(values, 50),
+		P95: metrics.Percentile(values, 95),
+	}
+}
+
+// MonteCarlo evaluates the greedy policy over synthetic price paths derived from prices.
+func MonteCarlo(Q [][]float64, encoder state.Encoder, prices []float64, config Config, mc MonteCarloConfig) (*MonteCarloResult, error) {
+	if mc.Paths <= 0 {
+		mc.Paths = 500
+	}
+	if mc.BlockSize <= 0 {
+		mc.BlockSize = 20
+	}
+	if mc.Method == "" {
+		mc.Method = MethodBootstrap
+	}
+	if len(prices) < 2 {
+		return nil, fmt.Errorf("need at least 2 prices, got %d", len(prices))
+	}
+
+	logReturns := LogReturns(prices)
+	rng := rand.New(rand.NewSource(mc.Seed))
+	result := &MonteCarloResult{
+		Returns:   make([]float64, 0, mc.Paths),
+		Drawdowns: make([]float64, 0, mc.Paths),
+	}
+
+	// Generate the paths in order so they only depend on the seed, then evaluate them concurrently
+	jobs := make([]Job, mc.Paths)
+	for p := range jobs {
+		var path []float64
+		switch mc.Method {
+		case MethodBootstrap:
+			path = BootstrapPath(prices[0], logReturns, mc.BlockSize, rng)
+		case MethodGBM:
+			path = GBMPath(prices[0], logReturns, rng)
+		default:
+			return nil, fmt.Errorf("unknown Monte Carlo method %q", mc.Method)
+		}
+		jobs[p] = Job{Q: Q, Prices: path}
+		jobs[p].Bid, jobs[p].Ask = pathQuotes(path, prices, config.Bid, config.Ask)
+	}
+
+	engine := NewEngine(config)
+	engine.Encoder = encoder
+	results, err := engine.Batch(jobs)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range results {
+		result.Returns = append(result.Returns, res.Metrics.TotalReturn)
+		result.Drawdowns = append(result.Drawdowns, res.Metrics.MaxDrawdown)
+	}
+
+	return result, nil
+}
+
+// LogReturns calculates log returns from a price series.
+func LogReturns(prices []float64) []float64 {
+	if len(prices) < 2 {
+		return nil
+	}
+	r := make([]float64, len(prices)-1)
+	for i := 1; i < len(prices); i++ {
+		r[i-1] = math.Log(prices[i] / prices[i-1])
+	}
+	return r
+}
+
+// BootstrapPath builds a price path starting at start by resampling blocks of log returns.
+// The path has len(logReturns)+1 prices.
+func BootstrapPath(start float64, logReturns []float64, blockSize int, rng *rand.Rand) []float64 {
+	if blockSize > len(logReturns) {
+		blockSize = len(logReturns)
+	}
+	path := make([]float64, 1, len(logReturns)+1)
+	path[0] = start
+	price := start
+	for len(path) <= len(logReturns) {
+		blockStart := rng.Intn(len(logReturns) - blockSize + 1)
+		for j := blockStart; j < blockStart+blockSize && len(path) <= len(logReturns); j++ {
+			price *= math.Exp(logReturns[j])
+			path = append(path, price)
+		}
+	}
+	return path
+}
+
+// GBMPath builds a geometric Brownian motion price path starting at start,
+// using the mean and standard deviation of the given log returns.
+// The path has len(logReturns)+1 prices.
+func GBMPath(start float64, logReturns []float64, rng *rand.Rand) []float64 {
+	mu, sigma := metrics.MeanStd(logReturns)
+	path := make([]float64, len(logReturns)+1)
+	path[0] = start
+	for i := 1; i < len(path); i++ {
+		path[i] = path[i-1] * math.Exp(mu+sigma*rng.NormFloat64())
+	}
+	return path
+}
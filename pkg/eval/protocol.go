@@ -0,0 +1,243 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// Protocol is a fixed set of evaluation episodes, drawn once and stored with a run
+// (see model.TrainingConfig) so every model is compared on exactly the same
+// segments of the same series, from the same first decisions, with the same random
+// draws.
+type Protocol struct {
+	Data    data.Split `json:"data"`              // Prices the episodes index into
+	Missing string     `json:"missing,omitempty"` // Missing-price policy the series were loaded with
+	// FX and FXMap, if set, are the FX file and the SYMBOL=SERIES assignments (see
+	// data.ParseFXMap) converting the prices into the base currency.
+	FX          string  `json:"fx,omitempty"`
+	FXMap       string  `json:"fx_map,omitempty"`
+	InitialCash float64 `json:"initial_cash,omitempty"`
+	Commission  float64 `json:"commission,omitempty"`
+	Seed        int64   `json:"seed"`   // Seed the episodes were drawn with
+	Length      int     `json:"length"` // Steps of every episode
+	// Epsilon, if positive, is the exploration rate of the evaluated policies, drawn
+	// from every episode's seed.
+	Epsilon  float64   `json:"epsilon,omitempty"`
+	Episodes []Episode `json:"episodes"`
+}
+
+// Episode is one evaluation episode of a Protocol: the prices [From, To) of the
+// symbol's series, with the first decision Start prices into them.
+type Episode struct {
+	Symbol string `json:"symbol"`
+	From   int    `json:"from"`
+	To     int    `json:"to"`
+	Start  int    `json:"start"`
+	Seed   int64  `json:"seed"`
+}
+
+// NewProtocol draws n episodes of length steps from series, each on a random symbol
+// and from a random first decision with the warmUp prices of the encoder before it.
+// Decisions start at or after earliest[symbol], e.g. where the held-out prices
+// begin. The same inputs always draw the same episodes.
+func NewProtocol(series map[string][]float64, earliest map[string]int, n, length, warmUp int, seed int64) (*Protocol, error) {
+	if n < 1 || length < 1 {
+		return nil, fmt.Errorf("need a positive number of episodes and length, got %d and %d", n, length)
+	}
+	warmUp = max(warmUp, 1) // A first decision of 0 would be the environment's default
+	// First decisions of every symbol with room for an episode, in symbol order
+	var symbols []string
+	for symbol, prices := range series {
+		if max(earliest[symbol], warmUp) <= len(prices)-length-1 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("no series has %d prices after the warm-up for an episode", length+1)
+	}
+	sort.Strings(symbols)
+
+	rng := rand.New(rand.NewSource(seed))
+	p := &Protocol{Seed: seed, Length: length, Episodes: make([]Episode, n)}
+	for i := range p.Episodes {
+		symbol := symbols[rng.Intn(len(symbols))]
+		first := max(earliest[symbol], warmUp)
+		decision := first + rng.Intn(len(series[symbol])-length-first)
+		p.Episodes[i] = Episode{Symbol: symbol, From: decision - warmUp, To: decision + length + 1, Start: warmUp, Seed: rng.Int63()}
+	}
+	return p, nil
+}
+
+// LoadProtocol reads a protocol from a JSON file, e.g. the eval_protocol block of
+// a model manifest.
+func LoadProtocol(path string) (*Protocol, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read protocol: %w", err)
+	}
+	var p Protocol
+	if err := json.Unmarshal(content, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse protocol %s: %w", path, err)
+	}
+	if len(p.Episodes) == 0 {
+		return nil, fmt.Errorf("protocol %s has no episodes", path)
+	}
+	return &p, nil
+}
+
+// Load loads the series of the protocol's data, and the FX rates converting them,
+// by symbol.
+func (p *Protocol) Load() (series, fx map[string][]float64, err error) {
+	policy, err := data.ParseMissingPolicy(p.Missing)
+	if err != nil {
+		return nil, nil, err
+	}
+	loaded, _, err := p.Data.Load(data.Options{Missing: policy})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load the protocol's prices: %w", err)
+	}
+	series = make(map[string][]float64, len(loaded))
+	for _, s := range loaded {
+		series[s.Symbol] = s.Closes()
+	}
+	if p.FX == "" {
+		return series, nil, nil
+	}
+	pairs, err := data.ParseFXMap(p.FXMap)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fx, err = data.LoadFXRates(p.FX, pairs, loaded, data.Options{Missing: policy}); err != nil {
+		return nil, nil, err
+	}
+	return series, fx, nil
+}
+
+// Config returns the evaluation config of the protocol's market: DefaultConfig with
+// its initial cash and commission, where recorded.
+func (p *Protocol) Config() Config {
+	config := DefaultConfig()
+	if p.InitialCash > 0 {
+		config.InitialCash = p.InitialCash
+	}
+	if p.Commission > 0 {
+		config.Commission = p.Commission
+	}
+	return config
+}
+
+// Evaluate runs the greedy policy of Q, exploring at the protocol's Epsilon, on
+// every episode over series, the prices of every symbol as the protocol was drawn
+// from, and returns the results in episode order. fx optionally converts the
+// prices of a symbol into the base currency; config.MinStartIdx, Clock, FX, the
+// quotes, and the halts are set per episode.
+func (p *Protocol) Evaluate(Q [][]float64, encoder state.Encoder, series, fx map[string][]float64, config Config) ([]*Result, error) {
+	if encoder == nil {
+		encoder = state.NewMAEncoder()
+	}
+	if len(Q) != encoder.NumStates() {
+		return nil, fmt.Errorf("Q-matrix has %d states, encoder expects %d", len(Q), encoder.NumStates())
+	}
+	results := make([]*Result, len(p.Episodes))
+	for i, ep := range p.Episodes {
+		prices, ok := series[ep.Symbol]
+		if !ok {
+			return nil, fmt.Errorf("episode %d: no series %s", i, ep.Symbol)
+		}
+		if ep.From < 0 || ep.To > len(prices) || ep.From >= ep.To {
+			return nil, fmt.Errorf("episode %d: segment [%d, %d) out of the %d prices of %s", i, ep.From, ep.To, len(prices), ep.Symbol)
+		}
+		episodeConfig := config
+		episodeConfig.MinStartIdx, episodeConfig.Clock, episodeConfig.FX = ep.Start, nil, nil
+		episodeConfig.Bid, episodeConfig.Ask, episodeConfig.Halted = nil, nil, nil
+		if rates := fx[ep.Symbol]; rates != nil {
+			episodeConfig.FX = rates[ep.From:ep.To]
+		}
+		marketEnv, err := newMarketEnv(encoder, prices[ep.From:ep.To], episodeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("episode %d: %w", i, err)
+		}
+		if marketEnv.StartIdx() != ep.Start {
+			return nil, fmt.Errorf("episode %d: starts at %d, but the encoder needs %d prices of warm-up", i, ep.Start, marketEnv.StartIdx())
+		}
+		actor := greedyActor(Q, encoder)
+		if p.Epsilon > 0 {
+			actor = &exploring{actor: actor, epsilon: p.Epsilon, rng: rand.New(rand.NewSource(ep.Seed))}
+		}
+		results[i] = Run(actor, marketEnv)
+	}
+	return results, nil
+}
+
+// ProtocolSummary is the outcome of a policy over the episodes of a Protocol.
+type ProtocolSummary struct {
+	Episodes        int
+	MeanReturn      float64
+	MedianReturn    float64
+	WorstReturn     float64
+	MeanSharpe      float64
+	MeanMaxDrawdown float64
+	MeanTrades      float64
+}
+
+// Summarize summarizes the results of Protocol.Evaluate.
+func Summarize(results []*Result) ProtocolSummary {
+	s := ProtocolSummary{Episodes: len(results)}
+	if len(results) == 0 {
+		return s
+	}
+	returns := make([]float64, len(results))
+	n := float64(len(results))
+	for i, r := range results {
+		m := r.Metrics
+		returns[i] = m.TotalReturn
+		s.MeanReturn += m.TotalReturn / n
+		s.MeanSharpe += m.Sharpe / n
+		s.MeanMaxDrawdown += m.MaxDrawdown / n
+		s.MeanTrades += float64(m.NumTrades) / n
+	}
+	sort.Float64s(returns)
+	s.WorstReturn = returns[0]
+	s.MedianReturn = returns[len(returns)/2]
+	if len(returns)%2 == 0 {
+		s.MedianReturn = (returns[len(returns)/2-1] + returns[len(returns)/2]) / 2
+	}
+	return s
+}
+
+// exploring takes a random allowed action with probability epsilon, and the
+// action of actor otherwise.
+type exploring struct {
+	actor   agent.Actor
+	epsilon float64
+	rng     *rand.Rand
+}
+
+// Act selects an action among all.
+func (e *exploring) Act(s state.State) agent.Action {
+	return e.ActMasked(s, nil)
+}
+
+// ActMasked selects an allowed action.
+func (e *exploring) ActMasked(s state.State, allowed []bool) agent.Action {
+	if e.rng.Float64() >= e.epsilon {
+		return agent.ActWith(e.actor, s, allowed)
+	}
+	var actions []agent.Action
+	for a := agent.Action(0); a < agent.NumActions; a++ {
+		if allowed == nil || int(a) < len(allowed) && allowed[a] {
+			actions = append(actions, a)
+		}
+	}
+	if len(actions) == 0 {
+		return agent.Action(e.rng.Intn(agent.NumActions))
+	}
+	return actions[e.rng.Intn(len(actions))]
+}
@@ -0,0 +1,86 @@
+package eval
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// RandomTestConfig holds the settings for the paired sign-flip test against a random policy.
+type RandomTestConfig struct {
+	Runs         int // Number of evaluation runs (bootstrapped paths) per policy
+	Permutations int // Number of random sign flips used to estimate the p-value
+	BlockSize    int // Block length for the bootstrapped paths
+	Seed         int64
+}
+
+// RandomTestResult holds the outcome of the paired sign-flip test.
+type RandomTestResult struct {
+	PolicyReturns []float64 // Total return of the greedy policy on every run
+	RandomReturns []float64 // Total return of the random policy on every run
+	MeanDiff      float64   // Mean policy return minus mean random return
+	PValue        float64   // One-sided p-value that the policy beats the random policy
+}
+
+// CompareToRandom evaluates the greedy policy and a random-action policy on the same
+// bootstrapped price paths and tests whether the policy's mean return is higher,
+// pairing the two returns of every path.
+func CompareToRandom(Q [][]float64, encoder state.Encoder, prices []float64, config Config, rt RandomTestConfig) (*RandomTestResult, error) {
+	if rt.Runs <= 0 {
+		rt.Runs = 200
+	}
+	if rt.Permutations <= 0 {
+		rt.Permutations = 10000
+	}
+	if rt.BlockSize <= 0 {
+		rt.BlockSize = 20
+	}
+	if encoder == nil {
+		encoder = state.NewMAEncoder()
+	}
+	if len(Q) != encoder.NumStates() {
+		return nil, fmt.Errorf("Q-matrix has %d states, encoder expects %d", len(Q), encoder.NumStates())
+	}
+	if len(prices) < 2 {
+		return nil, fmt.Errorf("need at least 2 prices, got %d", len(prices))
+	}
+
+	rng := rand.New(rand.NewSource(rt.Seed))
+	greedyPolicy := agent.NewGreedyPolicy(Q)
+	randomPolicy := agent.NewRandomPolicy(rng)
+	logReturns := LogReturns(prices)
+
+	result := &RandomTestResult{
+		PolicyReturns: make([]float64, 0, rt.Runs),
+		RandomReturns: make([]float64, 0, rt.Runs),
+	}
+	for run := 0; run < rt.Runs; run++ {
+		path := BootstrapPath(prices[0], logReturns, rt.BlockSize, rng)
+		pathConfig := config
+		pathConfig.Bid, pathConfig.Ask = pathQuotes(path, prices, config.Bid, config.Ask)
+
+		policyEnv, err := newMarketEnv(encoder, path, pathConfig)
+		if err != nil {
+			return nil, fmt.Errorf("run %d: %w", run, err)
+		}
+		result.PolicyReturns = append(result.PolicyReturns, Run(greedyPolicy, policyEnv).Metrics.TotalReturn)
+
+		randomEnv, err := newMarketEnv(encoder, path, pathConfig)
+		if err != nil {
+			return nil, fmt.Errorf("run %d: %w", run, err)
+		}
+		result.RandomReturns = append(result.RandomReturns, Run(randomPolicy, randomEnv).Metrics.TotalReturn)
+	}
+
+	diffs := make([]float64, rt.Runs)
+	for i := range diffs {
+		diffs[i] = result.PolicyReturns[i] - result.RandomReturns[i]
+	}
+	result.MeanDiff, _ = metrics.MeanStd(diffs)
+	result.PValue = metrics.SignFlipTest(diffs, rt.Permutations, rng)
+
+	return result, nil
+}
@@ -0,0 +1,121 @@
+package eval
+
+import (
+	"math"
+	"sort"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+)
+
+// RegretStep compares the action taken at a step with the alternatives in
+// hindsight: the reward each would have earned over the step from the same
+// position. Later steps are not replayed, so the comparison is one step deep.
+type RegretStep struct {
+	Action     agent.Action
+	Rewards    []float64 // Reward of every action, NaN for actions the environment did not allow
+	Best       agent.Action
+	BestReward float64
+	Regret     float64 // BestReward minus the reward of Action, never negative
+}
+
+// Reward returns the reward the taken action earned.
+func (r RegretStep) Reward() float64 {
+	return r.Rewards[r.Action]
+}
+
+// whatIf evaluates every allowed action at the environment's current step.
+func whatIf(marketEnv *env.MarketEnv, action agent.Action, allowed []bool) RegretStep {
+	step := RegretStep{Action: action, Rewards: make([]float64, agent.NumActions)}
+	for a := range step.Rewards {
+		step.Rewards[a] = math.NaN()
+		if allowed == nil || allowed[a] || agent.Action(a) == action {
+			step.Rewards[a] = marketEnv.WhatIf(agent.Action(a))
+		}
+	}
+	step.Best = action
+	for a, r := range step.Rewards {
+		if r > step.Rewards[step.Best] {
+			step.Best = agent.Action(a)
+		}
+	}
+	step.BestReward = step.Rewards[step.Best]
+	step.Regret = step.BestReward - step.Reward()
+	return step
+}
+
+// StateRegret summarizes the regret of the steps taken in one state.
+type StateRegret struct {
+	State    int          `json:"state"` // State index
+	Visits   int          `json:"visits"`
+	Total    float64      `json:"total"`
+	Mean     float64      `json:"mean"`
+	Mistakes int          `json:"mistakes"` // Steps where another action would have earned more
+	Best     agent.Action `json:"best"`     // Action most often best in hindsight
+}
+
+// RegretSummary summarizes the regret of a run.
+type RegretSummary struct {
+	Total      float64       `json:"total"`
+	Mean       float64       `json:"mean"`        // Per step
+	Optimal    float64       `json:"optimal"`     // Share of steps without regret
+	Cumulative []float64     `json:"-"`           // Total regret after every step
+	ByState    []StateRegret `json:"by_state"`    // Ordered by total regret, largest first
+	States     int           `json:"states"`      // States visited
+	WorstState int           `json:"worst_state"` // State with the largest total regret
+}
+
+// SummarizeRegret summarizes the regret of the steps of a run; states holds the
+// state index of every step, e.g. from Result.States.
+func SummarizeRegret(steps []RegretStep, states []int) RegretSummary {
+	summary := RegretSummary{Cumulative: make([]float64, len(steps))}
+	byState := make(map[int]*StateRegret)
+	bestCounts := make(map[int][]int)
+	optimal := 0
+	for i, step := range steps {
+		summary.Total += step.Regret
+		summary.Cumulative[i] = summary.Total
+
+		sr, ok := byState[states[i]]
+		if !ok {
+			sr = &StateRegret{State: states[i]}
+			byState[states[i]] = sr
+			bestCounts[states[i]] = make([]int, agent.NumActions)
+		}
+		sr.Visits++
+		sr.Total += step.Regret
+		bestCounts[states[i]][step.Best]++
+		if step.Regret > 0 {
+			sr.Mistakes++
+		} else {
+			optimal++
+		}
+	}
+	if len(steps) > 0 {
+		summary.Mean = summary.Total / float64(len(steps))
+		summary.Optimal = float64(optimal) / float64(len(steps))
+	}
+
+	for index, sr := range byState {
+		sr.Mean = sr.Total / float64(sr.Visits)
+		counts := bestCounts[index]
+		for a := range counts {
+			if counts[a] > counts[sr.Best] {
+				sr.Best = agent.Action(a)
+			}
+		}
+		summary.ByState = append(summary.ByState, *sr)
+	}
+	sort.Slice(summary.ByState, func(i, j int) bool {
+		a, b := summary.ByState[i], summary.ByState[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.State < b.State
+	})
+	summary.States = len(summary.ByState)
+	if summary.States > 0 {
+		summary.WorstState = summary.ByState[0].State
+	}
+	return summary
+}
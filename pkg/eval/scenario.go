@@ -0,0 +1,182 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// DefaultScenarioBars is the number of synthetic bars a scenario appends.
+const DefaultScenarioBars = 60
+
+// Scenario is a canned adverse market move appended to a real series, to see how a
+// policy copes with conditions its data may never have shown it.
+type Scenario struct {
+	Name        string
+	Description string
+	// Path returns bars prices following a last real price of start.
+	Path func(start float64, bars int) []float64
+}
+
+// Scenarios are the canned worst-case scenarios.
+var Scenarios = []Scenario{
+	{Name: "crash", Description: "35% fall over 5 bars, then flat", Path: crashPath},
+	{Name: "gap-down", Description: "15% gap down, then a slow recovery", Path: gapDownPath},
+	{Name: "chop", Description: "sideways swings of ±5% every 10 bars", Path: chopPath},
+	{Name: "v-recovery", Description: "steady 30% fall over half the bars, then back to the start", Path: vRecoveryPath},
+}
+
+// ParseScenarios returns the canned scenarios named in a comma-separated list, or
+// all of them for "all".
+func ParseScenarios(list string) ([]Scenario, error) {
+	if list == "all" {
+		return Scenarios, nil
+	}
+	var selected []Scenario
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, s := range Scenarios {
+			if s.Name == name {
+				selected = append(selected, s)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown scenario %q (want crash, gap-down, chop, v-recovery, or all)", name)
+		}
+	}
+	return selected, nil
+}
+
+func crashPath(start float64, bars int) []float64 {
+	path := make([]float64, bars)
+	price := start
+	for i := range path {
+		if i < 5 {
+			price *= math.Pow(0.65, 0.2)
+		}
+		path[i] = price
+	}
+	return path
+}
+
+func gapDownPath(start float64, bars int) []float64 {
+	path := make([]float64, bars)
+	price := start * 0.85
+	for i := range path {
+		if i > 0 {
+			price *= 1.001
+		}
+		path[i] = price
+	}
+	return path
+}
+
+func chopPath(start float64, bars int) []float64 {
+	path := make([]float64, bars)
+	for i := range path {
+		path[i] = start * (1 + 0.05*math.Sin(2*math.Pi*float64(i+1)/10))
+	}
+	return path
+}
+
+func vRecoveryPath(start float64, bars int) []float64 {
+	path := make([]float64, bars)
+	half := max(bars/2, 1)
+	bottom := math.Log(0.7)
+	for i := range path {
+		// Linear in log price down to the bottom at half, then back up
+		var x float64
+		if i < half {
+			x = bottom * float64(i+1) / float64(half)
+		} else {
+			x = bottom * float64(bars-1-i) / float64(max(bars-1-half, 1))
+		}
+		path[i] = start * math.Exp(x)
+	}
+	return path
+}
+
+// ScenarioResult is how the policy fared over the synthetic bars of a scenario.
+type ScenarioResult struct {
+	Scenario    string
+	Return      float64 // Portfolio return over the scenario
+	BuyAndHold  float64 // Price return over the scenario
+	MaxDrawdown float64 // Within the scenario
+	Trades      int     // Executed in the scenario
+	// ExposureStart and ExposureEnd are the share of the portfolio held in the
+	// asset entering and leaving the scenario.
+	ExposureStart float64
+	ExposureEnd   float64
+}
+
+// RunScenarios evaluates the greedy policy over prices with every scenario appended
+// in turn, bars synthetic prices each. The policy trades through the real prices
+// first, so it meets the scenario with the position it would actually hold. The
+// synthetic bars are undated and in the prices' currency: config.Clock, config.FX,
+// and config.FeeFX are ignored, and the quotes keep their last relative spread.
+func RunScenarios(Q [][]float64, encoder state.Encoder, prices []float64, config Config, scenarios []Scenario, bars int) ([]ScenarioResult, error) {
+	if bars <= 0 {
+		bars = DefaultScenarioBars
+	}
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("no prices to append the scenarios to")
+	}
+	config.Clock, config.FX, config.FeeFX = nil, nil, nil
+	bid, ask, halted := config.Bid, config.Ask, config.Halted
+
+	var results []ScenarioResult
+	for _, s := range scenarios {
+		series := append(append([]float64(nil), prices...), s.Path(prices[len(prices)-1], bars)...)
+		config.Bid, config.Ask = pathQuotes(series, prices, bid, ask)
+		if halted != nil {
+			// The synthetic bars trade
+			config.Halted = append(append([]bool(nil), halted...), make([]bool, bars)...)
+		}
+		result, err := Evaluate(Q, encoder, series, config)
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", s.Name, err)
+		}
+		from := len(prices) - 1 // Last real price
+		if from < result.StartIdx {
+			return nil, fmt.Errorf("need at least %d prices before the scenarios, got %d", result.StartIdx+1, len(prices))
+		}
+		equity := result.Equity[from-result.StartIdx:]
+		r := ScenarioResult{
+			Scenario:      s.Name,
+			Return:        equity[len(equity)-1]/equity[0] - 1,
+			BuyAndHold:    series[len(series)-1]/series[from] - 1,
+			MaxDrawdown:   metrics.MaxDrawdown(equity),
+			ExposureStart: exposure(result, series, config, from),
+			ExposureEnd:   exposure(result, series, config, len(series)-1),
+		}
+		for _, t := range result.Trades {
+			if t.PriceIdx >= from {
+				r.Trades++
+			}
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// exposure returns the share of the portfolio held in the asset at price index idx,
+// before the trade of that step.
+func exposure(result *Result, prices []float64, config Config, idx int) float64 {
+	shares := config.InitialShares
+	for _, t := range result.Trades {
+		if t.PriceIdx >= idx {
+			break
+		}
+		shares = t.SharesAfter
+	}
+	value := result.Equity[idx-result.StartIdx]
+	if value <= 0 {
+		return 0
+	}
+	return shares * prices[idx] / value
+}
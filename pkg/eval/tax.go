@@ -0,0 +1,39 @@
+package eval
+
+import "github.com/kasaderos/rLportfolio/pkg/env"
+
+// GainsReport summarizes the capital gains of a run with tax-lot accounting.
+type GainsReport struct {
+	Method    string  `json:"method"` // Lot method, fifo or lifo
+	Sales     int     `json:"sales"`  // Lots (or parts of lots) sold
+	Proceeds  float64 `json:"proceeds"`
+	Cost      float64 `json:"cost_basis"`
+	ShortTerm float64 `json:"short_term_gain"` // Net gain of lots held less than a year
+	LongTerm  float64 `json:"long_term_gain"`
+	Net       float64 `json:"net_realized_gain"`
+	// Tax is TaxRate times the net realized gain, if positive
+	Tax        float64 `json:"tax"`
+	OpenLots   int     `json:"open_lots"`
+	Unrealized float64 `json:"unrealized_gain"` // Gain of the open lots at the final price
+}
+
+// SummarizeGains totals the realized gains of a run and values its open lots at
+// the final price, both in the base currency.
+func SummarizeGains(method env.LotMethod, realized []env.Realization, lots []env.Lot, finalPrice, taxRate float64) GainsReport {
+	r := GainsReport{Method: method.String(), Sales: len(realized), OpenLots: len(lots)}
+	for _, s := range realized {
+		r.Proceeds += s.Proceeds
+		r.Cost += s.Cost
+		if s.LongTerm {
+			r.LongTerm += s.Gain
+		} else {
+			r.ShortTerm += s.Gain
+		}
+	}
+	r.Net = r.ShortTerm + r.LongTerm
+	r.Tax = taxRate * max(r.Net, 0)
+	for _, lot := range lots {
+		r.Unrealized += lot.Shares*finalPrice - lot.Cost
+	}
+	return r
+}
@@ -0,0 +1,197 @@
+// Package forecast combines simple forecasts of the next return into an ensemble:
+// the local approximation of the returns, the extrapolated slope of a moving
+// average, and the naive last return.
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	lam "github.com/kasaderos/rLportfolio/pkg/local-approximation"
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+)
+
+// Default ensemble parameters.
+const (
+	DefaultM           = 10 // Local approximation window, in returns
+	DefaultN           = 20 // Local approximation neighbors
+	DefaultTrendPeriod = 20 // Moving average whose slope is extrapolated
+	DefaultTrendLag    = 5  // Prices over which the slope is measured
+)
+
+// Weights are the weights of the ensemble members. Members without a forecast yet
+// are left out and the others reweighted.
+type Weights struct {
+	LAM   float64
+	Trend float64
+	Naive float64
+}
+
+// DefaultWeights favours the local approximation.
+func DefaultWeights() Weights {
+	return Weights{LAM: 0.5, Trend: 0.3, Naive: 0.2}
+}
+
+// ParseWeights parses weights written like lam=0.5,trend=0.3,naive=0.2; members
+// left out get no weight.
+func ParseWeights(s string) (Weights, error) {
+	var w Weights
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return Weights{}, fmt.Errorf("invalid weight %q (want name=value)", part)
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 {
+			return Weights{}, fmt.Errorf("invalid weight %q for %s", value, name)
+		}
+		switch name {
+		case "lam":
+			w.LAM = f
+		case "trend":
+			w.Trend = f
+		case "naive":
+			w.Naive = f
+		default:
+			return Weights{}, fmt.Errorf("unknown ensemble member %q (want lam, trend, or naive)", name)
+		}
+	}
+	if w.LAM+w.Trend+w.Naive == 0 {
+		return Weights{}, fmt.Errorf("ensemble weights must not all be zero")
+	}
+	return w, nil
+}
+
+// String formats the weights like ParseWeights expects them.
+func (w Weights) String() string {
+	format := func(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+	return "lam=" + format(w.LAM) + ",trend=" + format(w.Trend) + ",naive=" + format(w.Naive)
+}
+
+// Ensemble forecasts the return from one price to the next.
+type Ensemble struct {
+	Weights     Weights
+	M, N        int // Local approximation window and neighbors; 0 means DefaultM and DefaultN
+	TrendPeriod int // 0 means DefaultTrendPeriod
+	TrendLag    int // 0 means DefaultTrendLag
+}
+
+// Forecast is the ensemble's forecast of the return after a price index. Members
+// without enough history are NaN.
+type Forecast struct {
+	LAM      float64
+	MinDist  float64 // Distance of the local approximation's nearest neighbor
+	Trend    float64 // Per-step growth of the moving average over the lag
+	Naive    float64 // The last return
+	Ensemble float64 // Weighted mean of the available members
+}
+
+func (e Ensemble) params() (m, n, period, lag int) {
+	m, n, period, lag = e.M, e.N, e.TrendPeriod, e.TrendLag
+	if m < 1 {
+		m = DefaultM
+	}
+	if n < 1 {
+		n = DefaultN
+	}
+	if period < 1 {
+		period = DefaultTrendPeriod
+	}
+	if lag < 1 {
+		lag = DefaultTrendLag
+	}
+	return m, n, period, lag
+}
+
+// At forecasts the return after prices[idx] from prices[:idx+1] only.
+func (e Ensemble) At(prices []float64, idx int) Forecast {
+	m, n, period, lag := e.params()
+	f := Forecast{LAM: math.NaN(), MinDist: math.NaN(), Trend: math.NaN(), Naive: math.NaN()}
+	if idx < 1 || idx >= len(prices) {
+		f.Ensemble = math.NaN()
+		return f
+	}
+	returns := make([]float64, idx)
+	for i := range returns {
+		returns[i] = prices[i+1]/prices[i] - 1
+	}
+	if result, err := lam.LocalApproximation(returns, m, n); err == nil {
+		f.LAM, f.MinDist = result.Prediction, result.MinDist
+	}
+	if idx-lag >= period-1 {
+		f.Trend = trend(maAt(prices, idx, period), maAt(prices, idx-lag, period), lag)
+	}
+	f.Naive = returns[idx-1]
+	f.Ensemble = e.combine(f)
+	return f
+}
+
+// Series forecasts the return after every index of prices, each from the prices up
+// to it, like At but updating the local approximation incrementally.
+func (e Ensemble) Series(prices []float64) []Forecast {
+	m, n, period, lag := e.params()
+	forecasts := make([]Forecast, len(prices))
+	predictor, _ := lam.NewPredictor(m, n, lam.Options{}) // Parameters are positive after defaults
+	mas := ma.CalculateMAAligned(prices, period)
+	for idx := range prices {
+		f := Forecast{LAM: math.NaN(), MinDist: math.NaN(), Trend: math.NaN(), Naive: math.NaN()}
+		if idx < 1 {
+			f.Ensemble = math.NaN()
+			forecasts[idx] = f
+			continue
+		}
+		predictor.Append(prices[idx]/prices[idx-1] - 1)
+		if predictor.Ready() {
+			if result, err := predictor.Predict(); err == nil {
+				f.LAM, f.MinDist = result.Prediction, result.MinDist
+			}
+		}
+		if idx-lag >= period-1 {
+			f.Trend = trend(mas[idx], mas[idx-lag], lag)
+		}
+		history := predictor.History()
+		f.Naive = history[len(history)-1]
+		f.Ensemble = e.combine(f)
+		forecasts[idx] = f
+	}
+	return forecasts
+}
+
+// combine returns the weighted mean of the available members, NaN if none is.
+func (e Ensemble) combine(f Forecast) float64 {
+	var sum, weights float64
+	for _, member := range []struct{ value, weight float64 }{
+		{f.LAM, e.Weights.LAM},
+		{f.Trend, e.Weights.Trend},
+		{f.Naive, e.Weights.Naive},
+	} {
+		if member.weight > 0 && !math.IsNaN(member.value) {
+			sum += member.weight * member.value
+			weights += member.weight
+		}
+	}
+	if weights == 0 {
+		return math.NaN()
+	}
+	return sum / weights
+}
+
+// trend returns the per-step growth rate from a moving average lag prices ago to now.
+func trend(now, before float64, lag int) float64 {
+	if before <= 0 || now <= 0 {
+		return math.NaN()
+	}
+	return math.Pow(now/before, 1/float64(lag)) - 1
+}
+
+// maAt returns the simple moving average of the period prices ending at idx, summed
+// like ma.CalculateMA.
+func maAt(prices []float64, idx, period int) float64 {
+	sum := 0.0
+	for j := idx - period + 1; j <= idx; j++ {
+		sum += prices[j]
+	}
+	return sum / float64(period)
+}
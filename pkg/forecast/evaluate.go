@@ -0,0 +1,82 @@
+package forecast
+
+import "math"
+
+// Accuracy scores forecasts of returns against the actual returns.
+type Accuracy struct {
+	Name        string  `json:"name"`
+	Directional float64 `json:"directional_accuracy"` // Share of forecasts with the sign of the actual return
+	MAE         float64 `json:"mae"`
+	RMSE        float64 `json:"rmse"`
+}
+
+// Evaluation scores every ensemble member over the same steps.
+type Evaluation struct {
+	Steps   int        `json:"steps"` // Forecasts with every member and the actual return available
+	Members []Accuracy `json:"members"`
+	// Baselines a forecast with signal beats: always predicting a rise, and
+	// predicting no change.
+	UpShare float64 `json:"up_share"`
+	ZeroMAE float64 `json:"zero_mae"`
+}
+
+// Evaluate scores forecasts[i], made from prices[:i+1], against the return from
+// prices[i] to prices[i+1]. Only steps where every member has a forecast are
+// scored, so the members are compared on equal terms; the forecasts are out of
+// sample by construction.
+func Evaluate(forecasts []Forecast, prices []float64) Evaluation {
+	names := []string{"lam", "trend", "naive", "ensemble"}
+	members := func(f Forecast) []float64 { return []float64{f.LAM, f.Trend, f.Naive, f.Ensemble} }
+
+	var eval Evaluation
+	var up, zeroAbs float64
+	hits := make([]float64, len(names))
+	abs := make([]float64, len(names))
+	sq := make([]float64, len(names))
+	for i, f := range forecasts {
+		if i+1 >= len(prices) {
+			break
+		}
+		values := members(f)
+		if anyNaN(values) {
+			continue
+		}
+		actual := prices[i+1]/prices[i] - 1
+		eval.Steps++
+		if actual > 0 {
+			up++
+		}
+		zeroAbs += math.Abs(actual)
+		for j, v := range values {
+			if (v > 0) == (actual > 0) {
+				hits[j]++
+			}
+			abs[j] += math.Abs(v - actual)
+			sq[j] += (v - actual) * (v - actual)
+		}
+	}
+	if eval.Steps == 0 {
+		return eval
+	}
+	steps := float64(eval.Steps)
+	for j, name := range names {
+		eval.Members = append(eval.Members, Accuracy{
+			Name:        name,
+			Directional: hits[j] / steps,
+			MAE:         abs[j] / steps,
+			RMSE:        math.Sqrt(sq[j] / steps),
+		})
+	}
+	eval.UpShare = up / steps
+	eval.ZeroMAE = zeroAbs / steps
+	return eval
+}
+
+func anyNaN(values []float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/data"
+)
+
+// Feed delivers closed bars in time order.
+type Feed interface {
+	// History returns up to n of the latest closed bars, oldest first. Next continues
+	// after the last of them.
+	History(ctx context.Context, n int) ([]data.Bar, error)
+	// Next blocks until the next bar closes and returns it.
+	Next(ctx context.Context) (data.Bar, error)
+}
+
+// BinanceFeed polls the Binance klines endpoint for newly closed bars.
+type BinanceFeed struct {
+	Client    *data.BinanceClient
+	Symbol    string
+	Interval  string
+	PollEvery time.Duration
+
+	step    time.Duration
+	last    time.Time // Open time of the last delivered bar
+	pending []data.Bar
+}
+
+// NewBinanceFeed creates a feed for symbol at a Binance kline interval.
+func NewBinanceFeed(client *data.BinanceClient, symbol, interval string, pollEvery time.Duration) (*BinanceFeed, error) {
+	step, ok := data.BinanceIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unsupported interval %q", interval)
+	}
+	if pollEvery <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive")
+	}
+	return &BinanceFeed{Client: client, Symbol: symbol, Interval: interval, PollEvery: pollEvery, step: step}, nil
+}
+
+// History implements Feed.
+func (f *BinanceFeed) History(ctx context.Context, n int) ([]data.Bar, error) {
+	now := time.Now().UTC()
+	bars, err := f.closed(ctx, now.Add(-time.Duration(n+1)*f.step), now)
+	if err != nil {
+		return nil, err
+	}
+	if len(bars) > n {
+		bars = bars[len(bars)-n:]
+	}
+	if len(bars) > 0 {
+		f.last = bars[len(bars)-1].Time
+	}
+	return bars, nil
+}
+
+// Next implements Feed. Without a prior History call it starts at the bar that
+// closes next.
+func (f *BinanceFeed) Next(ctx context.Context) (data.Bar, error) {
+	if f.last.IsZero() {
+		if _, err := f.History(ctx, 1); err != nil {
+			return data.Bar{}, err
+		}
+	}
+	for len(f.pending) == 0 {
+		now := time.Now().UTC()
+		bars, err := f.closed(ctx, f.last.Add(f.step), now)
+		if err != nil {
+			return data.Bar{}, err
+		}
+		f.pending = bars
+		if len(bars) > 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return data.Bar{}, ctx.Err()
+		case <-time.After(f.PollEvery):
+		}
+	}
+	bar := f.pending[0]
+	f.pending = f.pending[1:]
+	f.last = bar.Time
+	return bar, nil
+}
+
+// closed fetches the bars opened in [start, now) that have closed by now.
+func (f *BinanceFeed) closed(ctx context.Context, start, now time.Time) ([]data.Bar, error) {
+	if !start.Before(now) {
+		return nil, nil
+	}
+	s, err := f.Client.FetchKlines(ctx, f.Symbol, f.Interval, start, now)
+	if err != nil {
+		return nil, err
+	}
+	bars := s.Bars
+	for len(bars) > 0 && bars[len(bars)-1].Time.Add(f.step).After(now) {
+		bars = bars[:len(bars)-1]
+	}
+	return bars, nil
+}
+
+// ReplayFeed replays a recorded series bar by bar, for dry runs of the live loop.
+type ReplayFeed struct {
+	Bars  []data.Bar
+	Delay time.Duration // Pause before each bar
+	next  int
+}
+
+// NewReplayFeed creates a feed over recorded bars.
+func NewReplayFeed(bars []data.Bar, delay time.Duration) *ReplayFeed {
+	return &ReplayFeed{Bars: bars, Delay: delay}
+}
+
+// History implements Feed.
+func (f *ReplayFeed) History(ctx context.Context, n int) ([]data.Bar, error) {
+	if n > len(f.Bars) {
+		n = len(f.Bars)
+	}
+	f.next = n
+	return f.Bars[:n], nil
+}
+
+// Next implements Feed. It returns io.EOF after the last bar.
+func (f *ReplayFeed) Next(ctx context.Context) (data.Bar, error) {
+	if f.next >= len(f.Bars) {
+		return data.Bar{}, io.EOF
+	}
+	if f.Delay > 0 {
+		select {
+		case <-ctx.Done():
+			return data.Bar{}, ctx.Err()
+		case <-time.After(f.Delay):
+		}
+	}
+	bar := f.Bars[f.next]
+	f.next++
+	return bar, nil
+}
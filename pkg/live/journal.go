@@ -0,0 +1,75 @@
+package live
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Journal appends fills to a JSON Lines file.
+type Journal struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+// OpenJournal opens a journal for appending, creating it and its directory if needed.
+func OpenJournal(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	return &Journal{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Record appends a fill.
+func (j *Journal) Record(fill Fill) error {
+	if err := j.enc.Encode(fill); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
+
+// LastFill returns the last fill of a journal, to resume its portfolio. It reports
+// false when the journal does not exist or is empty.
+func LastFill(path string) (Fill, bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Fill{}, false, nil
+	}
+	if err != nil {
+		return Fill{}, false, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	var last []byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Fill{}, false, fmt.Errorf("failed to read journal: %w", err)
+	}
+	if last == nil {
+		return Fill{}, false, nil
+	}
+	var fill Fill
+	if err := json.Unmarshal(last, &fill); err != nil {
+		return Fill{}, false, fmt.Errorf("failed to parse last journal entry: %w", err)
+	}
+	return fill, true, nil
+}
@@ -0,0 +1,162 @@
+package live
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/broker"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+)
+
+// WindowBars is the number of prices a trader needs before trading: the MA encoder
+// looks back 120 bars and compares the MA spread with the one 10 bars earlier.
+const WindowBars = model.MinPrices + 10
+
+// Fill is the simulated outcome of one decision, one per bar.
+type Fill struct {
+	Time       time.Time `json:"time"` // Open time of the bar
+	Symbol     string    `json:"symbol"`
+	Price      float64   `json:"price"` // Close of the bar; fills are simulated at this price
+	Action     string    `json:"action"`
+	State      int       `json:"state"`
+	Trained    bool      `json:"trained"`
+	Quantity   float64   `json:"quantity"` // Shares bought (positive) or sold (negative)
+	Notional   float64   `json:"notional"` // Cash spent (buys) or received before commission (sells)
+	Commission float64   `json:"commission"`
+	Cash       float64   `json:"cash"`
+	Shares     float64   `json:"shares"`
+	Value      float64   `json:"value"`
+	OrderID    string    `json:"order_id,omitempty"` // Broker order ID when the fill was routed
+}
+
+// Trader keeps the moving averages of the price stream and a simulated portfolio,
+// and trades it with a model's greedy policy as bars arrive.
+type Trader struct {
+	Symbol     string
+	Commission float64
+
+	bundle *model.Bundle
+	stream *ma.Stream // Updated in O(1) per bar
+	last   time.Time
+	cash   float64
+	shares float64
+}
+
+// NewTrader creates a trader holding cash and shares.
+func NewTrader(bundle *model.Bundle, symbol string, cash, shares, commission float64) *Trader {
+	return &Trader{
+		Symbol:     symbol,
+		Commission: commission,
+		bundle:     bundle,
+		stream:     ma.NewStream(),
+		cash:       cash,
+		shares:     shares,
+	}
+}
+
+// WarmUp appends history without trading.
+func (t *Trader) WarmUp(bars []data.Bar) {
+	for _, bar := range bars {
+		t.push(bar)
+	}
+}
+
+// Ready reports whether enough prices are held to decide as in training.
+func (t *Trader) Ready() bool {
+	return t.stream.Ready()
+}
+
+// OnBar appends a closed bar and trades on it. Bars at or before the last one
+// seen are ignored and reported as false.
+func (t *Trader) OnBar(bar data.Bar) (Fill, bool, error) {
+	if !t.last.IsZero() && !bar.Time.After(t.last) {
+		return Fill{}, false, nil
+	}
+	if bar.Close <= 0 {
+		return Fill{}, false, fmt.Errorf("bar at %s has non-positive close %g", bar.Time.Format(time.DateTime), bar.Close)
+	}
+	t.push(bar)
+	if !t.Ready() {
+		return Fill{}, false, nil
+	}
+
+	decision, err := t.bundle.DecideStream(t.stream, t.cash, t.shares)
+	if err != nil {
+		return Fill{}, false, err
+	}
+	cashBefore, sharesBefore := t.cash, t.shares
+	var fee float64
+	t.cash, t.shares, fee = env.ApplyAction(decision.Action, t.cash, t.shares, bar.Close, t.Commission)
+
+	return Fill{
+		Time:       bar.Time,
+		Symbol:     t.Symbol,
+		Price:      bar.Close,
+		Action:     decision.Action.String(),
+		State:      decision.State.Index,
+		Trained:    decision.Trained,
+		Quantity:   t.shares - sharesBefore,
+		Notional:   notional(cashBefore, t.cash, fee),
+		Commission: fee,
+		Cash:       t.cash,
+		Shares:     t.shares,
+		Value:      t.Value(),
+	}, true, nil
+}
+
+// Cash returns the simulated cash.
+func (t *Trader) Cash() float64 {
+	return t.cash
+}
+
+// Shares returns the simulated shares.
+func (t *Trader) Shares() float64 {
+	return t.shares
+}
+
+// Value returns the portfolio value at the latest price.
+func (t *Trader) Value() float64 {
+	if t.stream.Len() == 0 {
+		return t.cash
+	}
+	return t.cash + t.shares*t.stream.Price()
+}
+
+// SetHoldings replaces the simulated holdings, e.g. with a broker's positions.
+func (t *Trader) SetHoldings(cash, shares float64) {
+	t.cash, t.shares = cash, shares
+}
+
+// push adds a bar's close to the stream.
+func (t *Trader) push(bar data.Bar) {
+	t.stream.Push(bar.Close)
+	t.last = bar.Time
+}
+
+// notional returns the traded cash amount before commission from the cash change.
+func notional(cashBefore, cashAfter, fee float64) float64 {
+	if cashAfter < cashBefore {
+		return cashBefore - cashAfter
+	}
+	if cashAfter > cashBefore {
+		return cashAfter - cashBefore + fee
+	}
+	return 0
+}
+
+// Traded reports whether a fill changed the position.
+func (f Fill) Traded() bool {
+	return f.Quantity != 0
+}
+
+// Order returns the broker order that reproduces a traded fill: buys spend the same
+// cash and sells sell the same shares.
+func (f Fill) Order(symbol string) broker.Order {
+	if f.Quantity > 0 {
+		return broker.Order{Symbol: symbol, Side: broker.Buy, Notional: f.Notional}
+	}
+	return broker.Order{Symbol: symbol, Side: broker.Sell, Quantity: -f.Quantity}
+}
@@ -0,0 +1,196 @@
+package localapproximation
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+)
+
+// Index is a KD-tree over every window of length m of a series, built once so that
+// repeated forecasts take sublinear time instead of scanning all windows.
+type Index struct {
+	x       []float64
+	m       int
+	norm    Normalization
+	metric  Metric
+	rank    ranker
+	windows []float64 // Normalized windows, m values per window end from m-1; nil without normalization
+	nodes   []node
+	root    int
+}
+
+// node is a window in the tree. Children are node indices or -1.
+type node struct {
+	end         int // Window end index
+	axis        int
+	left, right int
+	minEnd      int // Smallest window end in the subtree, to skip windows after a cutoff
+}
+
+// NewIndex indexes the windows of length m of x that have a next value.
+func NewIndex(x []float64, m int) *Index {
+	return NewIndexWith(x, m, Options{})
+}
+
+// NewIndexWith is NewIndex with options; the windows are indexed with
+// opts.Normalize and searched with opts.Metric, which forecasts must then use.
+// Metrics without a per-coordinate lower bound (correlation, DTW) visit every
+// window, like a scan.
+func NewIndexWith(x []float64, m int, opts Options) *Index {
+	idx := &Index{x: x, m: m, norm: opts.Normalize, metric: opts.Metric, rank: newRanker(opts.Metric), root: -1}
+	if m < 1 || len(x) < m+1 {
+		return idx
+	}
+	ends := make([]int, 0, len(x)-m)
+	for end := m - 1; end <= len(x)-2; end++ {
+		ends = append(ends, end)
+	}
+	if idx.norm != NormalizeNone {
+		idx.windows = make([]float64, len(ends)*m)
+		for i, end := range ends {
+			idx.norm.apply(x[end-m+1:end+1], idx.windows[i*m:(i+1)*m])
+		}
+	}
+	idx.nodes = make([]node, 0, len(ends))
+	idx.root = idx.build(ends, 0)
+	return idx
+}
+
+// build creates the subtree of ends, splitting at the median on the axis for depth.
+func (idx *Index) build(ends []int, depth int) int {
+	if len(ends) == 0 {
+		return -1
+	}
+	axis := depth % idx.m
+	sort.Slice(ends, func(i, j int) bool {
+		vi, vj := idx.coord(ends[i], axis), idx.coord(ends[j], axis)
+		if vi != vj {
+			return vi < vj
+		}
+		return ends[i] < ends[j]
+	})
+	mid := len(ends) / 2
+	i := len(idx.nodes)
+	idx.nodes = append(idx.nodes, node{end: ends[mid], axis: axis})
+	left := idx.build(ends[:mid], depth+1)
+	right := idx.build(ends[mid+1:], depth+1)
+
+	n := &idx.nodes[i]
+	n.left, n.right = left, right
+	n.minEnd = n.end
+	for _, c := range []int{left, right} {
+		if c >= 0 && idx.nodes[c].minEnd < n.minEnd {
+			n.minEnd = idx.nodes[c].minEnd
+		}
+	}
+	return i
+}
+
+// coord returns coordinate j of the window ending at end.
+func (idx *Index) coord(end, j int) float64 {
+	return idx.window(end)[j]
+}
+
+// window returns the window ending at end as it is compared.
+func (idx *Index) window(end int) []float64 {
+	start := end - idx.m + 1
+	if idx.windows == nil {
+		return idx.x[start : end+1]
+	}
+	return idx.windows[start*idx.m : (start+1)*idx.m]
+}
+
+// Len returns the number of indexed windows.
+func (idx *Index) Len() int {
+	return len(idx.nodes)
+}
+
+// Predict forecasts x[t+1] from x[:t+1] only, like LocalApproximation(x[:t+1], m, n),
+// so it can be used at every step of a series without looking ahead.
+func (idx *Index) Predict(t, n int) (Result, error) {
+	return idx.PredictWith(t, n, Options{})
+}
+
+// PredictWith is Predict with options, like LocalApproximationWith(x[:t+1], m, n, opts):
+// with a horizon p it forecasts x[t+p] from the windows whose p following values are
+// known at t. Concurrency is ignored, and Normalize and Metric must be the index's.
+func (idx *Index) PredictWith(t, n int, opts Options) (Result, error) {
+	p := opts.horizon()
+	if err := checkParams(t+1, idx.m, n, p); err != nil {
+		return Result{}, err
+	}
+	if opts.Normalize != idx.norm {
+		return Result{}, fmt.Errorf("index normalizes windows with %s, forecast asks for %s", idx.norm, opts.Normalize)
+	}
+	if !sameMetric(opts.Metric, idx.metric) {
+		return Result{}, fmt.Errorf("index uses metric %T, forecast asks for %T", orEuclidean(idx.metric), orEuclidean(opts.Metric))
+	}
+	query := idx.x[t-idx.m+1 : t+1]
+	return newResult(idx.x, idx.Nearest(query, n, t-p+1), opts), nil
+}
+
+// Nearest returns the n windows nearest to query among those ending before cutoff,
+// nearest first, ranked by the index's metric in Dist (squared for the Euclidean
+// one). The query is normalized like the indexed windows.
+func (idx *Index) Nearest(query []float64, n, cutoff int) []Neighbor {
+	query = idx.norm.apply(query, make([]float64, len(query)))
+	h := &neighborHeap{}
+	idx.search(idx.root, query, n, cutoff, h)
+	return h.sorted()
+}
+
+// search visits the subtree at i, keeping the n best candidates in h.
+func (idx *Index) search(i int, query []float64, n, cutoff int, h *neighborHeap) {
+	if i < 0 || idx.nodes[i].minEnd >= cutoff {
+		return
+	}
+	nd := idx.nodes[i]
+	if nd.end < cutoff {
+		h.offer(Neighbor{End: nd.end, Dist: idx.rank.dist(idx.window(nd.end), query)}, n)
+	}
+
+	diff := query[nd.axis] - idx.coord(nd.end, nd.axis)
+	near, far := nd.left, nd.right
+	if diff >= 0 {
+		near, far = nd.right, nd.left
+	}
+	idx.search(near, query, n, cutoff, h)
+	// The far side can only hold windows at least |diff| away
+	if h.Len() < n || idx.rank.bound == nil || idx.rank.bound(diff) <= (*h)[0].Dist {
+		idx.search(far, query, n, cutoff, h)
+	}
+}
+
+// neighborHeap is a max-heap of neighbors, farthest on top.
+type neighborHeap []Neighbor
+
+func (h neighborHeap) Len() int           { return len(h) }
+func (h neighborHeap) Less(i, j int) bool { return closer(h[j], h[i]) }
+func (h neighborHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *neighborHeap) Push(v any)        { *h = append(*h, v.(Neighbor)) }
+func (h *neighborHeap) Pop() any {
+	old := *h
+	v := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return v
+}
+
+// offer adds cand if fewer than n neighbors are kept or it is closer than the
+// farthest, which it then replaces.
+func (h *neighborHeap) offer(cand Neighbor, n int) {
+	if h.Len() < n {
+		heap.Push(h, cand)
+	} else if closer(cand, (*h)[0]) {
+		(*h)[0] = cand
+		heap.Fix(h, 0)
+	}
+}
+
+// sorted empties the heap and returns its neighbors nearest first.
+func (h *neighborHeap) sorted() []Neighbor {
+	out := make([]Neighbor, h.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(h).(Neighbor)
+	}
+	return out
+}
@@ -0,0 +1,204 @@
+// Package localapproximation forecasts a series by the local approximation method
+// (method of analogues): the latest window of m values is compared with every
+// earlier window, and the values that followed the n most similar windows are
+// averaged into the prediction. The horizon p selects how far ahead the prediction
+// looks: the values p steps after the neighbors are averaged.
+package localapproximation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Neighbor is a historical window similar to the query.
+type Neighbor struct {
+	End  int     // Index of the window's last value; the value it predicts is End+p
+	Dist float64 // Distance to the query window under the metric
+}
+
+// Result is a local approximation forecast.
+type Result struct {
+	Prediction float64    // Mean of the values p steps after the neighbors
+	Forecasts  []float64  // Forecasts[h-1] is the h-step-ahead mean for h = 1..p; set with Options.Path
+	Std        float64    // Standard deviation of the values averaged into Prediction
+	IQR        float64    // Interquartile range of those values
+	MinDist    float64    // Distance to the nearest neighbor
+	Neighbors  []Neighbor // Nearest first
+}
+
+// Options tune LocalApproximationWith.
+type Options struct {
+	// Concurrency is the number of goroutines computing window distances; 0 or 1
+	// scans sequentially. Results do not depend on it.
+	Concurrency int
+	// Horizon is the number of steps p ahead to predict; 0 means 1. Only windows
+	// followed by p known values are candidates.
+	Horizon int
+	// Path also returns the forecasts for every horizon up to p, averaged over the
+	// same neighbors, so short and medium horizons can be read from one search.
+	Path bool
+	// Normalize is applied to every window before distances are computed. The
+	// prediction still averages raw values.
+	Normalize Normalization
+	// Metric measures window distances; nil is Euclidean.
+	Metric Metric
+}
+
+// horizon returns the prediction horizon p.
+func (o Options) horizon() int {
+	if o.Horizon < 1 {
+		return 1
+	}
+	return o.Horizon
+}
+
+// minWindowsPerWorker keeps small scans sequential, where goroutines cost more than
+// they save.
+const minWindowsPerWorker = 4096
+
+// LocalApproximation predicts the value after x from the n windows of length m most
+// similar to the last m values of x. It scans every window: O(len(x)·m). Use an
+// Index to predict repeatedly over one series.
+func LocalApproximation(x []float64, m, n int) (Result, error) {
+	return LocalApproximationWith(x, m, n, Options{})
+}
+
+// LocalApproximationWith is LocalApproximation with options; with a horizon p it
+// predicts the value p steps after x.
+func LocalApproximationWith(x []float64, m, n int, opts Options) (Result, error) {
+	p := opts.horizon()
+	if err := checkParams(len(x), m, n, p); err != nil {
+		return Result{}, err
+	}
+	query := opts.Normalize.apply(x[len(x)-m:], make([]float64, m))
+	first, last := m-1, len(x)-1-p // Window ends with a known value p steps later
+
+	workers := opts.Concurrency
+	if max := (last - first + 1) / minWindowsPerWorker; workers > max {
+		workers = max
+	}
+	if workers <= 1 {
+		return newResult(x, scan(x, query, first, last+1, n, opts), opts), nil
+	}
+
+	// Each worker keeps the n nearest windows of its chunk; merging the chunks'
+	// candidates in (distance, position) order gives the sequential result.
+	parts := make([][]Neighbor, workers)
+	chunk := (last - first + workers) / workers
+	var wg sync.WaitGroup
+	for w := range parts {
+		from := first + w*chunk
+		to := min(from+chunk, last+1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			parts[w] = scan(x, query, from, to, n, opts)
+		}()
+	}
+	wg.Wait()
+
+	var merged []Neighbor
+	for _, part := range parts {
+		merged = append(merged, part...)
+	}
+	sort.Slice(merged, func(i, j int) bool { return closer(merged[i], merged[j]) })
+	if len(merged) > n {
+		merged = merged[:n]
+	}
+	return newResult(x, merged, opts), nil
+}
+
+// scan returns the n windows ending in [from, to) nearest to query, nearest first,
+// ranked by opts.Metric in Dist. Windows are normalized like query.
+func scan(x, query []float64, from, to, n int, opts Options) []Neighbor {
+	h := make(neighborHeap, 0, n)
+	m := len(query)
+	buf := make([]float64, m)
+	rank := newRanker(opts.Metric)
+	for end := from; end < to; end++ {
+		window := opts.Normalize.apply(x[end-m+1:end+1], buf)
+		h.offer(Neighbor{End: end, Dist: rank.dist(window, query)}, n)
+	}
+	return h.sorted()
+}
+
+// checkParams validates the window length, neighbor count, and horizon for a series
+// of size values.
+func checkParams(size, m, n, p int) error {
+	if m < 1 || n < 1 {
+		return fmt.Errorf("window length and neighbor count must be positive, got m=%d n=%d", m, n)
+	}
+	if size < m+p {
+		return fmt.Errorf("need at least %d values for windows of %d and horizon %d, got %d", m+p, m, p, size)
+	}
+	return nil
+}
+
+// sqDist returns the squared distance between a window and query.
+func sqDist(window, query []float64) float64 {
+	d := 0.0
+	for j, q := range query {
+		diff := window[j] - q
+		d += diff * diff
+	}
+	return d
+}
+
+// closer orders neighbors by distance, then by position for determinism.
+func closer(a, b Neighbor) bool {
+	if a.Dist != b.Dist {
+		return a.Dist < b.Dist
+	}
+	return a.End < b.End
+}
+
+// newResult averages the values p steps after the neighbors, and every step up to p
+// with opts.Path, and converts the neighbors' ranks to distances.
+func newResult(x []float64, neighbors []Neighbor, opts Options) Result {
+	p := opts.horizon()
+	squared := newRanker(opts.Metric).squared
+	r := Result{Neighbors: neighbors}
+	values := make([]float64, len(neighbors))
+	for i := range neighbors {
+		if squared {
+			neighbors[i].Dist = math.Sqrt(neighbors[i].Dist)
+		}
+		values[i] = x[neighbors[i].End+p]
+		r.Prediction += values[i]
+	}
+	r.Prediction /= float64(len(neighbors))
+	r.MinDist = neighbors[0].Dist
+	r.Std, r.IQR = dispersion(values, r.Prediction)
+
+	if opts.Path {
+		r.Forecasts = make([]float64, p)
+		for h := 1; h <= p; h++ {
+			sum := 0.0
+			for _, nb := range neighbors {
+				sum += x[nb.End+h]
+			}
+			r.Forecasts[h-1] = sum / float64(len(neighbors))
+		}
+	}
+	return r
+}
+
+// dispersion returns the standard deviation of values around mean and their
+// interquartile range, interpolating between the closest ranks. It sorts values.
+func dispersion(values []float64, mean float64) (std, iqr float64) {
+	for _, v := range values {
+		std += (v - mean) * (v - mean)
+	}
+	std = math.Sqrt(std / float64(len(values)))
+
+	sort.Float64s(values)
+	quantile := func(q float64) float64 {
+		pos := q * float64(len(values)-1)
+		lo := int(math.Floor(pos))
+		hi := int(math.Ceil(pos))
+		return values[lo] + (values[hi]-values[lo])*(pos-float64(lo))
+	}
+	return std, quantile(0.75) - quantile(0.25)
+}
@@ -0,0 +1,160 @@
+package localapproximation
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Metric measures how far a window is from the query; both have the same length.
+type Metric interface {
+	Distance(window, query []float64) float64
+}
+
+// Euclidean is the default metric, the L2 distance.
+type Euclidean struct{}
+
+// Manhattan is the L1 distance.
+type Manhattan struct{}
+
+// Correlation is one minus the Pearson correlation of the windows, from 0 for
+// windows moving together to 2 for opposite ones. It ignores level and scale like
+// z-scoring; windows without variation count as uncorrelated.
+type Correlation struct{}
+
+// DTW is the dynamic-time-warping distance with absolute differences as costs,
+// which matches patterns that are shifted or stretched in time. Band limits how far
+// the alignment may stray from the diagonal; 0 leaves it unconstrained.
+type DTW struct {
+	Band int
+}
+
+// Distance returns the Euclidean distance.
+func (Euclidean) Distance(window, query []float64) float64 {
+	return math.Sqrt(sqDist(window, query))
+}
+
+// Distance returns the sum of absolute differences.
+func (Manhattan) Distance(window, query []float64) float64 {
+	d := 0.0
+	for j, q := range query {
+		d += math.Abs(window[j] - q)
+	}
+	return d
+}
+
+// Distance returns one minus the correlation.
+func (Correlation) Distance(window, query []float64) float64 {
+	n := float64(len(query))
+	var meanW, meanQ float64
+	for j, q := range query {
+		meanW += window[j]
+		meanQ += q
+	}
+	meanW /= n
+	meanQ /= n
+
+	var cov, varW, varQ float64
+	for j, q := range query {
+		dw, dq := window[j]-meanW, q-meanQ
+		cov += dw * dq
+		varW += dw * dw
+		varQ += dq * dq
+	}
+	if varW == 0 || varQ == 0 {
+		return 1
+	}
+	return 1 - cov/math.Sqrt(varW*varQ)
+}
+
+// Distance returns the cost of the cheapest alignment of the windows.
+func (d DTW) Distance(window, query []float64) float64 {
+	m := len(query)
+	band := d.Band
+	if band <= 0 || band > m {
+		band = m
+	}
+	inf := math.Inf(1)
+	prev := make([]float64, m+1)
+	cur := make([]float64, m+1)
+	for j := range prev {
+		prev[j] = inf
+	}
+	prev[0] = 0
+	for i := 1; i <= m; i++ {
+		for j := range cur {
+			cur[j] = inf
+		}
+		for j := max(1, i-band); j <= min(m, i+band); j++ {
+			cost := math.Abs(window[i-1] - query[j-1])
+			cur[j] = cost + min(prev[j], cur[j-1], prev[j-1])
+		}
+		prev, cur = cur, prev
+	}
+	return prev[m]
+}
+
+// ParseMetric parses euclidean, manhattan, correlation, dtw, or dtw:<band>.
+func ParseMetric(s string) (Metric, error) {
+	switch s {
+	case "", "euclidean":
+		return Euclidean{}, nil
+	case "manhattan":
+		return Manhattan{}, nil
+	case "correlation":
+		return Correlation{}, nil
+	case "dtw":
+		return DTW{}, nil
+	}
+	if band, ok := strings.CutPrefix(s, "dtw:"); ok {
+		n, err := strconv.Atoi(band)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid DTW band %q", band)
+		}
+		return DTW{Band: n}, nil
+	}
+	return nil, fmt.Errorf("unknown metric %q (want euclidean, manhattan, correlation, or dtw[:band])", s)
+}
+
+// ranker computes a value ordered like the metric's distances. The Euclidean
+// metric ranks by squared distance, which newResult converts back.
+type ranker struct {
+	dist    func(window, query []float64) float64
+	squared bool
+	// bound is a lower bound on the rank of any window whose coordinate differs
+	// from the query's by diff, for pruning the KD-tree; nil when there is none.
+	bound func(diff float64) float64
+}
+
+// newRanker returns the ranker of metric; nil selects the Euclidean metric.
+func newRanker(metric Metric) ranker {
+	switch metric.(type) {
+	case nil, Euclidean:
+		return ranker{dist: sqDist, squared: true, bound: func(diff float64) float64 { return diff * diff }}
+	case Manhattan:
+		return ranker{dist: metric.Distance, bound: math.Abs}
+	default:
+		return ranker{dist: metric.Distance}
+	}
+}
+
+// sameMetric reports whether a and b are the same metric; nil is the Euclidean one.
+// Metrics of incomparable types are the same when their types are.
+func sameMetric(a, b Metric) bool {
+	a, b = orEuclidean(a), orEuclidean(b)
+	ta := reflect.TypeOf(a)
+	if ta != reflect.TypeOf(b) {
+		return false
+	}
+	return !ta.Comparable() || a == b
+}
+
+// orEuclidean returns metric, or the Euclidean metric for nil.
+func orEuclidean(metric Metric) Metric {
+	if metric == nil {
+		return Euclidean{}
+	}
+	return metric
+}
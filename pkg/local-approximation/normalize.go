@@ -0,0 +1,76 @@
+package localapproximation
+
+import (
+	"fmt"
+	"math"
+)
+
+// Normalization is applied to every window, and the query, before distances are
+// computed.
+type Normalization int
+
+const (
+	// NormalizeNone compares raw values.
+	NormalizeNone Normalization = iota
+	// NormalizeCenter subtracts the window mean, so matching ignores the level.
+	NormalizeCenter
+	// NormalizeZScore also divides by the window's standard deviation, so matching
+	// ignores level and volatility. Constant windows are only centered.
+	NormalizeZScore
+)
+
+// String returns the name of the normalization.
+func (n Normalization) String() string {
+	switch n {
+	case NormalizeNone:
+		return "none"
+	case NormalizeCenter:
+		return "center"
+	case NormalizeZScore:
+		return "zscore"
+	default:
+		return fmt.Sprintf("Normalization(%d)", int(n))
+	}
+}
+
+// ParseNormalization parses none, center, or zscore.
+func ParseNormalization(s string) (Normalization, error) {
+	switch s {
+	case "", "none":
+		return NormalizeNone, nil
+	case "center":
+		return NormalizeCenter, nil
+	case "zscore":
+		return NormalizeZScore, nil
+	default:
+		return 0, fmt.Errorf("unknown normalization %q (want none, center, or zscore)", s)
+	}
+}
+
+// apply returns window normalized into buf, or window itself without normalization.
+func (n Normalization) apply(window, buf []float64) []float64 {
+	if n == NormalizeNone {
+		return window
+	}
+	mean := 0.0
+	for _, v := range window {
+		mean += v
+	}
+	mean /= float64(len(window))
+
+	scale := 1.0
+	if n == NormalizeZScore {
+		variance := 0.0
+		for _, v := range window {
+			variance += (v - mean) * (v - mean)
+		}
+		if std := math.Sqrt(variance / float64(len(window))); std > 0 {
+			scale = 1 / std
+		}
+	}
+	buf = buf[:len(window)]
+	for i, v := range window {
+		buf[i] = (v - mean) * scale
+	}
+	return buf
+}
@@ -0,0 +1,76 @@
+package localapproximation
+
+import "fmt"
+
+// minPredictorTail is the number of appended values a Predictor scans linearly
+// before it rebuilds its index; larger indexes tolerate an eighth of their size.
+const minPredictorTail = 256
+
+// Predictor forecasts a growing series, such as the returns of a live stream: values
+// are appended one at a time and each forecast reuses a KD-tree over the earlier
+// history. Windows appended since the tree was built are scanned, and the tree is
+// rebuilt once they grow too many, so forecasts stay sublinear on average and equal
+// LocalApproximationWith over the whole history.
+type Predictor struct {
+	m, n    int
+	opts    Options
+	x       []float64
+	idx     *Index
+	indexed int // Values covered by idx
+	query   []float64
+}
+
+// NewPredictor creates a predictor of the value opts.Horizon steps after the
+// history, from the n nearest windows of length m.
+func NewPredictor(m, n int, opts Options) (*Predictor, error) {
+	if m < 1 || n < 1 {
+		return nil, fmt.Errorf("window length and neighbor count must be positive, got m=%d n=%d", m, n)
+	}
+	return &Predictor{m: m, n: n, opts: opts, query: make([]float64, m)}, nil
+}
+
+// Append adds values to the history.
+func (p *Predictor) Append(values ...float64) {
+	p.x = append(p.x, values...)
+}
+
+// Len returns the number of values in the history.
+func (p *Predictor) Len() int {
+	return len(p.x)
+}
+
+// History returns the values appended so far; it must not be modified.
+func (p *Predictor) History() []float64 {
+	return p.x
+}
+
+// Ready reports whether the history is long enough to forecast.
+func (p *Predictor) Ready() bool {
+	return len(p.x) >= p.m+p.opts.horizon()
+}
+
+// Predict forecasts from the whole history, like LocalApproximationWith(history, m,
+// n, opts).
+func (p *Predictor) Predict() (Result, error) {
+	horizon := p.opts.horizon()
+	if err := checkParams(len(p.x), p.m, p.n, horizon); err != nil {
+		return Result{}, err
+	}
+	if tail := len(p.x) - p.indexed; p.idx == nil || tail > max(minPredictorTail, p.indexed/8) {
+		p.idx = NewIndexWith(p.x, p.m, p.opts)
+		p.indexed = len(p.x)
+	}
+
+	query := p.opts.Normalize.apply(p.x[len(p.x)-p.m:], p.query)
+	cutoff := len(p.x) - horizon // Window ends with a known value horizon steps later
+	h := make(neighborHeap, 0, p.n)
+	p.idx.search(p.idx.root, query, p.n, cutoff, &h)
+
+	// Windows ending at indexed-1 or later are not in the tree
+	buf := make([]float64, p.m)
+	for end := max(p.indexed-1, p.m-1); end < cutoff; end++ {
+		window := p.opts.Normalize.apply(p.x[end-p.m+1:end+1], buf)
+		h.offer(Neighbor{End: end, Dist: p.idx.rank.dist(window, query)}, p.n)
+	}
+	return newResult(p.x, h.sorted(), p.opts), nil
+}
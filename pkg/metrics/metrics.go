@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+)
+
+// TradingDaysPerYear is used to annualize per-step statistics of daily series.
+const TradingDaysPerYear = 252
+
+// Metrics summarizes the performance of a portfolio value series.
+type Metrics struct {
+	InitialValue float64 `json:"initial_value"`
+	FinalValue   float64 `json:"final_value"`
+	TotalReturn  float64 `json:"total_return"` // Fractional return over the whole series
+	MaxDrawdown  float64 `json:"max_drawdown"` // Largest peak-to-trough decline as a fraction
+	Volatility   float64 `json:"volatility"`   // Annualized standard deviation of per-step returns
+	Sharpe       float64 `json:"sharpe"`       // Annualized Sharpe ratio (zero risk-free rate)
+	NumTrades    int     `json:"num_trades"`   // Number of buy/sell actions
+	// Commissions is the commission paid over the series, when known, e.g. set by
+	// eval from the environment; Compute leaves it 0.
+	Commissions float64 `json:"commissions_paid,omitempty"`
+}
+
+// Compute calculates performance metrics for a portfolio value series of daily steps
+// and the actions taken.
+func Compute(values []float64, actions []int) Metrics {
+	return ComputeAnnualized(values, actions, TradingDaysPerYear)
+}
+
+// ComputeAnnualized is Compute for a series of periodsPerYear steps per year, e.g.
+// from a data.Clock of intraday or irregular bars.
+func ComputeAnnualized(values []float64, actions []int, periodsPerYear float64) Metrics {
+	m := Metrics{NumTrades: CountTrades(actions)}
+	if len(values) == 0 {
+		return m
+	}
+
+	m.InitialValue = values[0]
+	m.FinalValue = values[len(values)-1]
+	if m.InitialValue > 0 {
+		m.TotalReturn = m.FinalValue/m.InitialValue - 1.0
+	}
+	m.MaxDrawdown = MaxDrawdown(values)
+
+	returns := StepReturns(values)
+	mean, std := MeanStd(returns)
+	m.Volatility = std * math.Sqrt(periodsPerYear)
+	if std > 0 {
+		m.Sharpe = mean / std * math.Sqrt(periodsPerYear)
+	}
+
+	return m
+}
+
+// CountTrades returns the number of buy and sell actions in the slice.
+// Negative entries (no action recorded) are ignored.
+func CountTrades(actions []int) int {
+	count := 0
+	for _, a := range actions {
+		if a >= 0 && agent.Action(a).IsTrade() {
+			count++
+		}
+	}
+	return count
+}
+
+// StepReturns calculates simple returns between consecutive values.
+func StepReturns(values []float64) []float64 {
+	if len(values) < 2 {
+		return nil
+	}
+	r := make([]float64, 0, len(values)-1)
+	for i := 1; i < len(values); i++ {
+		if values[i-1] <= 0 {
+			r = append(r, 0)
+			continue
+		}
+		r = append(r, values[i]/values[i-1]-1.0)
+	}
+	return r
+}
+
+// MaxDrawdown returns the largest peak-to-trough decline of the series as a fraction.
+func MaxDrawdown(values []float64) float64 {
+	peak := 0.0
+	maxDD := 0.0
+	for _, v := range values {
+		if v > peak {
+			peak = v
+		}
+		if peak > 0 {
+			if dd := (peak - v) / peak; dd > maxDD {
+				maxDD = dd
+			}
+		}
+	}
+	return maxDD
+}
+
+// MeanStd returns the mean and sample standard deviation of the slice.
+func MeanStd(arr []float64) (mean, std float64) {
+	if len(arr) == 0 {
+		return 0, 0
+	}
+	for _, v := range arr {
+		mean += v
+	}
+	mean /= float64(len(arr))
+	if len(arr) < 2 {
+		return mean, 0
+	}
+	variance := 0.0
+	for _, v := range arr {
+		d := v - mean
+		variance += d * d
+	}
+	variance /= float64(len(arr) - 1)
+	return mean, math.Sqrt(variance)
+}
+
+// Percentile returns the p-th percentile (0-100) of the values using linear interpolation.
+// The input slice is not modified.
+func Percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 100 {
+		return sorted[len(sorted)-1]
+	}
+	pos := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	frac := pos - float64(lo)
+	return sorted[lo]*(1-frac) + sorted[hi]*frac
+}
+
+// SignFlipTest returns the one-sided p-value for the hypothesis that the mean of
+// paired differences (e.g. a[i]-b[i] of two policies on the same path) is greater than
+// zero, estimated by randomly flipping the signs of the differences. The p-value
+// includes the observed signs, so it is never zero.
+func SignFlipTest(diffs []float64, iterations int, rng *rand.Rand) float64 {
+	if len(diffs) == 0 || iterations <= 0 {
+		return 1
+	}
+
+	observed, _ := MeanStd(diffs)
+
+	extreme := 0
+	for i := 0; i < iterations; i++ {
+		sum := 0.0
+		for _, d := range diffs {
+			if rng.Intn(2) == 0 {
+				d = -d
+			}
+			sum += d
+		}
+		if sum/float64(len(diffs)) >= observed {
+			extreme++
+		}
+	}
+
+	return float64(extreme+1) / float64(iterations+1)
+}
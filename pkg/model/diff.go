@@ -0,0 +1,119 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+)
+
+// StateChange is how training changed the Q-values of one state between two models.
+type StateChange struct {
+	Index     int
+	OldAction agent.Action // Greedy action of the old model
+	NewAction agent.Action // Greedy action of the new model
+	Action    agent.Action // Action whose Q-value changed most
+	OldValue  float64      // Q-value of Action in the old model
+	NewValue  float64      // Q-value of Action in the new model
+}
+
+// Delta returns the change of the Q-value of Action.
+func (c StateChange) Delta() float64 {
+	return c.NewValue - c.OldValue
+}
+
+// RegimeChange summarizes the changes over the states of one market regime, as
+// Rulebook groups them.
+type RegimeChange struct {
+	Regime   string // Conditions of the regime, e.g. "market ..., divergence neutral"
+	States   int    // States trained in either model
+	Changed  int    // States whose greedy action changed
+	MaxDelta float64
+}
+
+// PolicyDiff is what changed between the Q-tables of two models of the same
+// encoder, e.g. two checkpoints of a training run.
+type PolicyDiff struct {
+	States       int // States trained in either model
+	NewlyTrained int // States trained only in the new model
+	Changed      int // States whose greedy action changed
+	// Changes holds every state trained in either model, the largest Q-value
+	// change first.
+	Changes []StateChange
+	// Regimes holds every regime with trained states, the most changed states
+	// first (then the most states); empty for encoders Rulebook cannot decode.
+	Regimes []RegimeChange
+}
+
+// Diff compares the Q-table of the old model with the new one, trained with the
+// same encoder.
+func Diff(old, updated *Bundle) (*PolicyDiff, error) {
+	if old.Encoder.Name != updated.Encoder.Name || old.NumStates() != updated.NumStates() {
+		return nil, fmt.Errorf("models have different encoders: %q (%d states) and %q (%d states)",
+			old.Encoder.Name, old.NumStates(), updated.Encoder.Name, updated.NumStates())
+	}
+	decode, _ := updated.stateDecoder() // nil if it cannot decode the states
+
+	diff := &PolicyDiff{}
+	regimes := make(map[Rule]*RegimeChange)
+	var order []Rule
+	for i := 0; i < updated.NumStates(); i++ {
+		oldQ, newQ := old.QValues(i), updated.QValues(i)
+		if len(oldQ) != len(newQ) {
+			return nil, fmt.Errorf("state %d has %d actions in the old model and %d in the new", i, len(oldQ), len(newQ))
+		}
+		oldUntrained, newUntrained := isUntrained(oldQ), isUntrained(newQ)
+		if oldUntrained && newUntrained {
+			continue
+		}
+		c := StateChange{Index: i, OldAction: agent.Action(agent.ArgMax(oldQ)), NewAction: agent.Action(agent.ArgMax(newQ))}
+		if len(newQ) > 0 {
+			c.OldValue, c.NewValue = oldQ[0], newQ[0]
+		}
+		for a := range newQ {
+			if math.Abs(newQ[a]-oldQ[a]) > math.Abs(c.Delta()) {
+				c.Action, c.OldValue, c.NewValue = agent.Action(a), oldQ[a], newQ[a]
+			}
+		}
+		diff.States++
+		changed := c.OldAction != c.NewAction
+		if changed {
+			diff.Changed++
+		}
+		if oldUntrained {
+			diff.NewlyTrained++
+		}
+		diff.Changes = append(diff.Changes, c)
+
+		if decode == nil {
+			continue
+		}
+		key := decode(i)
+		key.Cash, key.Shares = "", ""
+		r, ok := regimes[key]
+		if !ok {
+			r = &RegimeChange{Regime: strings.Join(key.Conditions(), ", ")}
+			regimes[key] = r
+			order = append(order, key)
+		}
+		r.States++
+		if changed {
+			r.Changed++
+		}
+		r.MaxDelta = max(r.MaxDelta, math.Abs(c.Delta()))
+	}
+
+	sort.SliceStable(diff.Changes, func(i, j int) bool {
+		return math.Abs(diff.Changes[i].Delta()) > math.Abs(diff.Changes[j].Delta())
+	})
+	for _, key := range order {
+		diff.Regimes = append(diff.Regimes, *regimes[key])
+	}
+	sort.SliceStable(diff.Regimes, func(i, j int) bool {
+		a, b := diff.Regimes[i], diff.Regimes[j]
+		return a.Changed > b.Changed || a.Changed == b.Changed && a.States > b.States
+	})
+	return diff, nil
+}
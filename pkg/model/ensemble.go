@@ -0,0 +1,116 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+)
+
+// Vote is how an Ensemble combines the decisions of its models.
+type Vote int
+
+const (
+	// VoteMajority takes the greedy action most models choose, ties going to the
+	// tied action with the highest mean Q-value. Models that never visited the
+	// state abstain unless none did.
+	VoteMajority Vote = iota
+	// VoteMean takes the greedy action of the Q-values averaged over the models
+	VoteMean
+)
+
+// String returns the vote as ParseVote accepts it.
+func (v Vote) String() string {
+	if v == VoteMean {
+		return "mean"
+	}
+	return "majority"
+}
+
+// ParseVote parses "majority" or "mean".
+func ParseVote(s string) (Vote, error) {
+	switch s {
+	case "majority":
+		return VoteMajority, nil
+	case "mean":
+		return VoteMean, nil
+	default:
+		return VoteMajority, fmt.Errorf("unknown vote %q (use majority or mean)", s)
+	}
+}
+
+// Ensemble combines the greedy decisions of several models, e.g. of training runs
+// with different seeds, which is often more robust out of sample than any one of
+// them. The models may use different state encoders but share the action space.
+type Ensemble struct {
+	Bundles []*Bundle
+	Vote    Vote
+}
+
+// EnsembleDecision is the combined decision of an Ensemble for the latest price.
+type EnsembleDecision struct {
+	Action  agent.Action
+	Votes   []int      // Voting models choosing every action, indexed by action
+	QValues []float64  // Q-values averaged over the models, indexed by action
+	Members []Decision // Decision of every model, in Bundles order
+}
+
+// NewEnsemble creates an ensemble of at least one model, checking that every model
+// can be used with the current action space.
+func NewEnsemble(bundles []*Bundle, vote Vote) (*Ensemble, error) {
+	if len(bundles) == 0 {
+		return nil, fmt.Errorf("ensemble needs at least one model")
+	}
+	for i, b := range bundles {
+		if _, err := b.StateEncoder(); err != nil {
+			return nil, fmt.Errorf("model %d: %w", i+1, err)
+		}
+	}
+	return &Ensemble{Bundles: bundles, Vote: vote}, nil
+}
+
+// Decide asks every model for its decision at the last price for the given holdings
+// and combines them.
+func (e *Ensemble) Decide(prices []float64, cash, shares float64) (EnsembleDecision, error) {
+	d := EnsembleDecision{
+		Votes:   make([]int, agent.NumActions),
+		QValues: make([]float64, agent.NumActions),
+		Members: make([]Decision, len(e.Bundles)),
+	}
+	for i, b := range e.Bundles {
+		member, err := b.Decide(prices, cash, shares)
+		if err != nil {
+			return EnsembleDecision{}, fmt.Errorf("model %d: %w", i+1, err)
+		}
+		d.Members[i] = member
+		for a, v := range member.QValues {
+			d.QValues[a] += v / float64(len(e.Bundles))
+		}
+	}
+
+	trained := 0
+	for _, m := range d.Members {
+		if m.Trained {
+			trained++
+		}
+	}
+	for _, m := range d.Members {
+		if m.Trained || trained == 0 {
+			d.Votes[m.Action]++
+		}
+	}
+
+	if e.Vote == VoteMean {
+		d.Action = agent.Action(agent.ArgMax(d.QValues))
+		return d, nil
+	}
+	most := 0
+	for _, n := range d.Votes {
+		most = max(most, n)
+	}
+	tied := make([]bool, agent.NumActions)
+	for a, n := range d.Votes {
+		tied[a] = n == most
+	}
+	d.Action = agent.Action(agent.ArgMaxAllowed(d.QValues, tied))
+	return d, nil
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// MinPrices is the number of prices Decide needs with the default MA encoder: its
+// warm-up bars before the decision bar. Other encoders need their WarmUp()+1.
+const MinPrices = state.MAWarmUp + 1
+
+// Decision is the greedy action of a model for the latest price.
+type Decision struct {
+	State   state.State
+	Action  agent.Action
+	QValues []float64 // Q-values of State, indexed by action
+	Trained bool      // False when every Q-value of State is still zero
+}
+
+// Decide encodes the state at the last price for the given holdings and returns
+// the greedy action.
+func (b *Bundle) Decide(prices []float64, cash, shares float64) (Decision, error) {
+	encoder, err := b.StateEncoder()
+	if err != nil {
+		return Decision{}, err
+	}
+	if len(prices) < encoder.WarmUp()+1 {
+		return Decision{}, fmt.Errorf("need at least %d prices, got %d", encoder.WarmUp()+1, len(prices))
+	}
+	if err := checkHoldings(cash, shares); err != nil {
+		return Decision{}, err
+	}
+	return b.decide(encoder.Encode(prices, len(prices)-1, cash, shares)), nil
+}
+
+// DecideStream is Decide for a price stream, e.g. of a live trader: the state at the
+// stream's latest price is composed from its online MA ordering in O(1).
+func (b *Bundle) DecideStream(stream *ma.Stream, cash, shares float64) (Decision, error) {
+	if !stream.Ready() {
+		return Decision{}, fmt.Errorf("need at least %d prices, got %d", stream.WarmUp(), stream.Len())
+	}
+	if err := checkHoldings(cash, shares); err != nil {
+		return Decision{}, err
+	}
+	if err := b.CheckCompatible(&state.MAEncoder{Lines: stream.Lines()}); err != nil {
+		return Decision{}, err
+	}
+	return b.decide(state.Compose(stream.State(), stream.Divergence(), stream.Price(), cash, shares)), nil
+}
+
+// decide returns the greedy decision in state s.
+func (b *Bundle) decide(s state.State) Decision {
+	qValues := b.QValues(s.Index)
+	trained := !isUntrained(qValues)
+	return Decision{
+		State:   s,
+		Action:  agent.Action(agent.ArgMax(qValues)),
+		QValues: qValues,
+		Trained: trained,
+	}
+}
+
+// checkHoldings checks that the portfolio to decide for is valid.
+func checkHoldings(cash, shares float64) error {
+	if cash < 0 || shares < 0 {
+		return fmt.Errorf("cash and shares must not be negative")
+	}
+	if cash == 0 && shares == 0 {
+		return fmt.Errorf("portfolio is empty: set cash or shares")
+	}
+	return nil
+}
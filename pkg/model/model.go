@@ -0,0 +1,559 @@
+package model
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/eval"
+	"github.com/kasaderos/rLportfolio/pkg/forecast"
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+	"github.com/kasaderos/rLportfolio/pkg/plot"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// SchemaVersion is the bundle format written by this version. Bundles with a newer
+// version are rejected; bare Q-matrix files load as version 0.
+const SchemaVersion = 1
+
+const (
+	// ManifestFile is the manifest of a directory bundle.
+	ManifestFile = "model.json"
+	// QTableFile is the Q-table of a directory bundle, stored in the exact binary format.
+	QTableFile = "q_table.bin"
+)
+
+// EncoderSpec identifies the state encoder a Q-table was trained with.
+type EncoderSpec struct {
+	Name      string            `json:"name"`
+	NumStates int               `json:"num_states"`
+	Params    map[string]string `json:"params,omitempty"`
+}
+
+// ActionSpec describes one action of the action space.
+type ActionSpec struct {
+	Index    int     `json:"index"`
+	Name     string  `json:"name"`
+	Fraction float64 `json:"fraction"` // Fraction of cash (buys) or shares (sells) traded
+}
+
+// TrainingConfig records how the model was trained.
+type TrainingConfig struct {
+	Alpha        float64  `json:"alpha"`
+	Gamma        float64  `json:"gamma"`
+	GammaDays    float64  `json:"gamma_days,omitempty"` // Calendar days Gamma discounts over; 0 per step
+	Epsilon      float64  `json:"epsilon"`
+	Episodes     int      `json:"episodes"`
+	SeriesLength int      `json:"series_length,omitempty"`
+	Seed         int64    `json:"seed"`
+	InitialCash  float64  `json:"initial_cash"`
+	Commission   float64  `json:"commission"`
+	Data         string   `json:"data,omitempty"`
+	Dataset      string   `json:"dataset,omitempty"` // Catalog dataset the data came from
+	Symbols      []string `json:"symbols,omitempty"`
+	Env          string   `json:"env,omitempty"` // Registered component names (see pkg/registry)
+	Agent        string   `json:"agent,omitempty"`
+	Policy       string   `json:"policy,omitempty"`
+	Reward       string   `json:"reward,omitempty"`
+	// RewardScales holds the factor every stock's rewards were scaled by to
+	// normalize their volatility, if they were
+	RewardScales map[string]float64 `json:"reward_scales,omitempty"`
+	// Coverage records the training data of every stock, for the leakage guard of
+	// the testing tools
+	Coverage []data.Coverage `json:"coverage,omitempty"`
+	// EvalProtocol holds the fixed evaluation episodes of the run, so other models
+	// can be compared with it on the same episodes
+	EvalProtocol *eval.Protocol `json:"eval_protocol,omitempty"`
+}
+
+// Manifest is the metadata of a model bundle.
+type Manifest struct {
+	SchemaVersion int            `json:"schema_version"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Encoder       EncoderSpec    `json:"encoder"`
+	Actions       []ActionSpec   `json:"actions"`
+	Training      TrainingConfig `json:"training"`
+	QTable        string         `json:"q_table,omitempty"` // Q-table file of a directory bundle
+}
+
+// Bundle is a trained model: the Q-table plus everything needed to use it safely.
+type Bundle struct {
+	Manifest
+	Q [][]float64 `json:"q,omitempty"` // Q[state][action]; embedded only in single-file bundles; nil when mapped
+
+	mapped *agent.MappedQTable // Q-table of a bundle opened with LoadMapped
+}
+
+// New creates a bundle for a Q-table trained with encoder.
+func New(Q [][]float64, encoder state.Encoder, training TrainingConfig) *Bundle {
+	return &Bundle{
+		Manifest: Manifest{
+			SchemaVersion: SchemaVersion,
+			CreatedAt:     time.Now().UTC(),
+			Encoder:       DescribeEncoder(encoder),
+			Actions:       DefaultActions(),
+			Training:      training,
+		},
+		Q: Q,
+	}
+}
+
+// DescribeEncoder returns the identity and parameters of a state encoder.
+func DescribeEncoder(encoder state.Encoder) EncoderSpec {
+	switch e := encoder.(type) {
+	case *state.MAEncoder:
+		periods := make([]string, len(ma.MAPeriods))
+		for i, p := range ma.MAPeriods {
+			periods[i] = strconv.Itoa(p)
+		}
+		spec := EncoderSpec{
+			Name:      "ma",
+			NumStates: encoder.NumStates(),
+			Params: map[string]string{
+				"ma_periods": strings.Join(periods, ","),
+			},
+		}
+		if e.Lines != nil && !ma.IsDefault(e.Lines) {
+			// Mixed types or other periods; the default spec is kept for older bundles
+			for i, l := range e.Lines {
+				periods[i] = strconv.Itoa(l.Period)
+			}
+			spec.Params["ma_periods"] = strings.Join(periods, ",")
+			spec.Params["ma_lines"] = ma.FormatLines(e.Lines)
+		}
+		if e.Divergence != nil {
+			spec.Params["divergence_thresholds"] = e.Divergence.String()
+		}
+		return spec
+	case *state.RegimeEncoder:
+		return EncoderSpec{Name: "regime", NumStates: encoder.NumStates()}
+	case *state.SlopeEncoder:
+		lag := e.Lag
+		if lag < 1 {
+			lag = state.DefaultSlopeLag
+		}
+		return EncoderSpec{
+			Name:      "slope",
+			NumStates: encoder.NumStates(),
+			Params:    map[string]string{"lag": strconv.Itoa(lag)},
+		}
+	case *state.LAMEncoder:
+		// The base encoder's spec with the forecast parameters added
+		spec := DescribeEncoder(e.Base)
+		spec.NumStates = encoder.NumStates()
+		if spec.Params == nil {
+			spec.Params = make(map[string]string)
+		}
+		m, n := e.Params()
+		spec.Params["approx_m"] = strconv.Itoa(m)
+		spec.Params["approx_n"] = strconv.Itoa(n)
+		if e.Weights != nil {
+			spec.Params["forecast_weights"] = e.Weights.String()
+		}
+		return spec
+	case *state.GatedEncoder:
+		// The base encoder's spec with the regime detector added; the Q-table holds
+		// the regimes' tables stacked
+		spec := DescribeEncoder(e.Base)
+		spec.NumStates = encoder.NumStates()
+		if spec.Params == nil {
+			spec.Params = make(map[string]string)
+		}
+		spec.Params["regime_detector"] = e.Detector.String()
+		return spec
+	default:
+		return EncoderSpec{
+			Name:      fmt.Sprintf("%T", encoder),
+			NumStates: encoder.NumStates(),
+		}
+	}
+}
+
+// DefaultActions returns the action space of pkg/agent.
+func DefaultActions() []ActionSpec {
+	fractions := map[agent.Action]float64{
+		agent.ActionBuySmall:  agent.BuySmall,
+		agent.ActionBuyLarge:  agent.BuyLarge,
+		agent.ActionSellSmall: agent.SellSmall,
+		agent.ActionSellLarge: agent.SellLarge,
+	}
+	actions := make([]ActionSpec, agent.NumActions)
+	for i := range actions {
+		a := agent.Action(i)
+		actions[i] = ActionSpec{Index: i, Name: a.String(), Fraction: fractions[a]}
+	}
+	return actions
+}
+
+// Validate checks that the bundle is internally consistent and not from a newer version.
+func (b *Bundle) Validate() error {
+	if b.SchemaVersion > SchemaVersion {
+		return fmt.Errorf("model schema version %d is newer than the supported version %d", b.SchemaVersion, SchemaVersion)
+	}
+	if b.NumStates() == 0 {
+		return fmt.Errorf("model has an empty Q-table")
+	}
+	if b.NumStates() != b.Encoder.NumStates {
+		return fmt.Errorf("Q-table has %d states, encoder %q has %d", b.NumStates(), b.Encoder.Name, b.Encoder.NumStates)
+	}
+	if b.mapped != nil {
+		if b.mapped.NumActions() != len(b.Actions) {
+			return fmt.Errorf("Q-table has %d actions, model defines %d", b.mapped.NumActions(), len(b.Actions))
+		}
+		return nil
+	}
+	for s, row := range b.Q {
+		if len(row) != len(b.Actions) {
+			return fmt.Errorf("Q-table row %d has %d actions, model defines %d", s, len(row), len(b.Actions))
+		}
+	}
+	return nil
+}
+
+// NumStates returns the number of states of the Q-table.
+func (b *Bundle) NumStates() int {
+	if b.mapped != nil {
+		return b.mapped.NumStates()
+	}
+	return len(b.Q)
+}
+
+// QValues returns a copy of the Q-values of state index s.
+func (b *Bundle) QValues(s int) []float64 {
+	if b.mapped != nil {
+		return b.mapped.Row(s)
+	}
+	return append([]float64(nil), b.Q[s]...)
+}
+
+// Close releases the mapped Q-table of a bundle opened with LoadMapped; it is a
+// no-op for other bundles.
+func (b *Bundle) Close() error {
+	if b.mapped == nil {
+		return nil
+	}
+	return b.mapped.Close()
+}
+
+// StateEncoder returns the state encoder the model was trained with, rebuilt from
+// its encoder spec, or an error when it is not a known encoder or does not match the
+// current action space.
+func (b *Bundle) StateEncoder() (state.Encoder, error) {
+	var encoder state.Encoder
+	switch b.Encoder.Name {
+	case "ma":
+		e := state.NewMAEncoder()
+		if s, ok := b.Encoder.Params["ma_lines"]; ok {
+			lines, err := ma.ParseLines(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid model encoder lines: %w", err)
+			}
+			e.Lines = lines
+		}
+		if s, ok := b.Encoder.Params["divergence_thresholds"]; ok {
+			buckets, err := ma.ParseBuckets(s)
+			if err != nil || len(buckets.Thresholds) != 2 {
+				return nil, fmt.Errorf("invalid model divergence thresholds %q", s)
+			}
+			e.Divergence = &buckets
+		}
+		encoder = e
+	case "regime":
+		encoder = state.NewRegimeEncoder()
+	case "slope":
+		lag, err := strconv.Atoi(b.Encoder.Params["lag"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid model slope lag %q", b.Encoder.Params["lag"])
+		}
+		encoder = state.NewSlopeEncoder(lag)
+	default:
+		return nil, fmt.Errorf("model was trained with unknown encoder %q", b.Encoder.Name)
+	}
+	if s, ok := b.Encoder.Params["approx_m"]; ok {
+		m, err := strconv.Atoi(s)
+		if err != nil || m < 1 {
+			return nil, fmt.Errorf("invalid model approximation window %q", s)
+		}
+		n, err := strconv.Atoi(b.Encoder.Params["approx_n"])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid model approximation neighbor count %q", b.Encoder.Params["approx_n"])
+		}
+		lamEncoder := state.NewLAMEncoder(encoder, m, n)
+		if s, ok := b.Encoder.Params["forecast_weights"]; ok {
+			weights, err := forecast.ParseWeights(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid model forecast weights: %w", err)
+			}
+			lamEncoder.Weights = &weights
+		}
+		encoder = lamEncoder
+	}
+	if s, ok := b.Encoder.Params["regime_detector"]; ok {
+		detector, err := state.ParseDetector(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid model regime detector: %w", err)
+		}
+		encoder = state.NewGatedEncoder(encoder, detector)
+	}
+	if err := b.CheckCompatible(encoder); err != nil {
+		return nil, err
+	}
+	return encoder, nil
+}
+
+// CheckCompatible reports whether the model can be used with encoder and the current action space.
+func (b *Bundle) CheckCompatible(encoder state.Encoder) error {
+	want := DescribeEncoder(encoder)
+	if b.Encoder.Name != want.Name || b.Encoder.NumStates != want.NumStates {
+		return fmt.Errorf("model was trained with encoder %q (%d states), not %q (%d states)",
+			b.Encoder.Name, b.Encoder.NumStates, want.Name, want.NumStates)
+	}
+	if len(b.Encoder.Params) > 0 && !reflect.DeepEqual(b.Encoder.Params, want.Params) {
+		return fmt.Errorf("model encoder parameters %v differ from %v", b.Encoder.Params, want.Params)
+	}
+	actions := DefaultActions()
+	if len(b.Actions) != len(actions) {
+		return fmt.Errorf("model has %d actions, expected %d", len(b.Actions), len(actions))
+	}
+	for i, a := range b.Actions {
+		if a.Name != actions[i].Name || a.Fraction != actions[i].Fraction {
+			return fmt.Errorf("model action %d is %s (%.2f), expected %s (%.2f)",
+				i, a.Name, a.Fraction, actions[i].Name, actions[i].Fraction)
+		}
+	}
+	return nil
+}
+
+// isSingleFile reports whether path names a single-file bundle (.json or .json.gz).
+func isSingleFile(path string) bool {
+	return strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".json.gz")
+}
+
+// Save writes the bundle. A path ending in .json or .json.gz is written as a single file
+// with the Q-table embedded; any other path is written as a directory holding the
+// manifest and the Q-table.
+func (b *Bundle) Save(path string) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	if isSingleFile(path) {
+		return b.saveFile(path)
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", path, err)
+	}
+	manifest := b.Manifest
+	if manifest.QTable == "" {
+		manifest.QTable = QTableFile
+	}
+	if err := plot.SaveQMatrixDataToFile(b.Q, filepath.Join(path, manifest.QTable)); err != nil {
+		return fmt.Errorf("failed to save Q-table: %w", err)
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(path, ManifestFile), append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+func (b *Bundle) saveFile(path string) error {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	var w io.Writer = file
+	var gz *gzip.Writer
+	if data.IsGzip(path) {
+		gz = gzip.NewWriter(file)
+		w = gz
+	}
+	bundle := *b
+	bundle.QTable = ""
+	if err := json.NewEncoder(w).Encode(&bundle); err != nil {
+		return fmt.Errorf("failed to encode model: %w", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to finish gzip stream: %w", err)
+		}
+	}
+	return file.Close()
+}
+
+// Load reads a model bundle (directory or single file) and validates it. A bare
+// Q-matrix file (CSV, JSON, Parquet, or binary) is accepted as a version 0 bundle assumed to
+// use the MA encoder and the default action space.
+func Load(path string) (*Bundle, error) {
+	var b *Bundle
+	var err error
+	info, statErr := os.Stat(path)
+	switch {
+	case statErr != nil:
+		return nil, fmt.Errorf("failed to open model: %w", statErr)
+	case info.IsDir():
+		b, err = loadDir(path)
+	case isSingleFile(path):
+		b, err = loadFile(path)
+	default:
+		b, err = loadLegacy(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid model %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// LoadMapped is Load for the inference and serving path: the Q-table of a directory
+// bundle stored in the uncompressed binary format is memory-mapped read-only instead
+// of being read, so startup does not depend on its size and its memory is shared
+// between processes. The bundle's Q field is then nil; use QValues and NumStates, and
+// Close when done. Any other model is read with Load.
+func LoadMapped(path string) (*Bundle, error) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return Load(path)
+	}
+	b, err := readManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	qFile := b.QTable
+	if qFile == "" {
+		qFile = QTableFile
+	}
+	if !plot.IsBinaryFile(qFile) || data.IsGzip(qFile) {
+		return Load(path)
+	}
+	b.mapped, err = agent.OpenMappedQTable(filepath.Join(path, qFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Q-table: %w", err)
+	}
+	if err := b.Validate(); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("invalid model %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// LoadProtocol returns the evaluation protocol stored with the model at path,
+// reading only the manifest of a directory bundle, or reads path as a protocol file
+// (see eval.LoadProtocol) when it is not a model.
+func LoadProtocol(path string) (*eval.Protocol, error) {
+	var b *Bundle
+	var err error
+	if info, statErr := os.Stat(path); statErr == nil && info.IsDir() {
+		b, err = readManifest(path)
+	} else if b, err = Load(path); err != nil {
+		return eval.LoadProtocol(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b.Training.EvalProtocol == nil {
+		return nil, fmt.Errorf("model %s has no evaluation protocol (train it with -eval-episodes)", path)
+	}
+	return b.Training.EvalProtocol, nil
+}
+
+// readManifest reads the manifest of a directory bundle.
+func readManifest(dir string) (*Bundle, error) {
+	content, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	b := &Bundle{}
+	if err := json.Unmarshal(content, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return b, nil
+}
+
+func loadDir(dir string) (*Bundle, error) {
+	b, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	qFile := b.QTable
+	if qFile == "" {
+		qFile = QTableFile
+	}
+	b.Q, err = plot.LoadQMatrixDataFromFile(filepath.Join(dir, qFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Q-table: %w", err)
+	}
+	return b, nil
+}
+
+func loadFile(path string) (*Bundle, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model: %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if data.IsGzip(path) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model: %w", err)
+	}
+
+	// A JSON array is a Q-matrix written with orient="records", not a bundle
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		return loadLegacy(path)
+	}
+	b := &Bundle{}
+	if err := json.Unmarshal(content, b); err != nil {
+		return nil, fmt.Errorf("failed to decode model: %w", err)
+	}
+	if b.SchemaVersion == 0 {
+		return loadLegacy(path)
+	}
+	return b, nil
+}
+
+// loadLegacy wraps a bare Q-matrix file in a version 0 bundle.
+func loadLegacy(path string) (*Bundle, error) {
+	Q, err := plot.LoadQMatrixDataFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Bundle{
+		Manifest: Manifest{
+			Encoder: DescribeEncoder(state.NewMAEncoder()),
+			Actions: DefaultActions(),
+		},
+		Q: Q,
+	}, nil
+}
@@ -0,0 +1,224 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// Agreement thresholds of the rule confidence levels.
+const (
+	HighAgreement   = 0.8
+	MediumAgreement = 0.5
+)
+
+// Rule is the distilled recommendation of a model for one market regime: the
+// action its trained states agree on most, and how strongly they agree.
+type Rule struct {
+	Regime     string // Regime of a model with a table per regime, else empty
+	Market     string // Market component of the encoder, e.g. the MA ordering
+	Divergence string
+	Forecast   string // Forecast categories of a model with forecasts, else empty
+	Cash       string // Position categories, only in rules split by position
+	Shares     string
+	Action     agent.Action
+	States     int     // Trained states the rule summarizes
+	Agreement  float64 // Share of those states whose greedy action is Action
+	Margin     float64 // Mean Q-value of Action minus the best other mean Q-value
+	Confidence string  // high, medium, or low by Agreement
+}
+
+// Conditions returns the non-empty conditions of the rule, e.g. for a heading.
+func (r Rule) Conditions() []string {
+	var conditions []string
+	for _, c := range []struct{ name, value string }{
+		{"regime", r.Regime}, {"market", r.Market}, {"divergence", r.Divergence},
+		{"forecast", r.Forecast}, {"cash", r.Cash}, {"shares", r.Shares},
+	} {
+		if c.value != "" {
+			conditions = append(conditions, c.name+" "+c.value)
+		}
+	}
+	return conditions
+}
+
+// Rulebook distills the Q-table into one rule per market regime, every combination
+// of the state components but the cash and shares positions, or per full state
+// with byPosition. Regimes without trained states have no rule. Rules are ordered
+// by the number of states they summarize, most first.
+func (b *Bundle) Rulebook(byPosition bool) ([]Rule, error) {
+	decode, err := b.stateDecoder()
+	if err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		rule   Rule
+		sums   []float64
+		greedy []int
+	}
+	groups := make(map[Rule]*group)
+	var order []Rule
+	for i := 0; i < b.NumStates(); i++ {
+		q := b.QValues(i)
+		if isUntrained(q) {
+			continue
+		}
+		key := decode(i)
+		if !byPosition {
+			key.Cash, key.Shares = "", ""
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{rule: key, sums: make([]float64, len(q)), greedy: make([]int, len(q))}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.rule.States++
+		g.greedy[agent.ArgMax(q)]++
+		for a, v := range q {
+			g.sums[a] += v
+		}
+	}
+
+	rules := make([]Rule, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		r := g.rule
+		best := agent.ArgMax(g.sums)
+		r.Action = agent.Action(best)
+		r.Agreement = float64(g.greedy[best]) / float64(r.States)
+		if len(g.sums) > 1 {
+			other := make([]bool, len(g.sums))
+			for a := range other {
+				other[a] = a != best
+			}
+			second := agent.ArgMaxAllowed(g.sums, other)
+			r.Margin = (g.sums[best] - g.sums[second]) / float64(r.States)
+		}
+		switch {
+		case r.Agreement >= HighAgreement:
+			r.Confidence = "high"
+		case r.Agreement >= MediumAgreement:
+			r.Confidence = "medium"
+		default:
+			r.Confidence = "low"
+		}
+		rules = append(rules, r)
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].States > rules[j].States })
+	return rules, nil
+}
+
+// stateDecoder returns a function describing the components of a state index of
+// the model's encoder: the MA, regime, or slope base state, extended by forecast
+// categories and split into regime tables as the encoder spec says.
+func (b *Bundle) stateDecoder() (func(int) Rule, error) {
+	switch b.Encoder.Name {
+	case "ma", "regime", "slope":
+	default:
+		return nil, fmt.Errorf("cannot decode the states of encoder %q", b.Encoder.Name)
+	}
+	var detector state.Detector
+	if s, ok := b.Encoder.Params["regime_detector"]; ok {
+		d, err := state.ParseDetector(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid model regime detector: %w", err)
+		}
+		detector = d
+	}
+	_, forecasts := b.Encoder.Params["approx_m"]
+	tableStates := b.Encoder.NumStates
+	if detector != nil {
+		tableStates /= detector.NumRegimes()
+	}
+
+	var lines []ma.Line
+	if s, ok := b.Encoder.Params["ma_lines"]; ok {
+		parsed, err := ma.ParseLines(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid model encoder lines: %w", err)
+		}
+		lines = parsed
+	}
+
+	return func(index int) Rule {
+		var r Rule
+		if detector != nil {
+			r.Regime = state.RegimeName(detector, index/tableStates)
+			index %= tableStates
+		}
+		if forecasts {
+			minDist := index % state.NumMinDistCategories
+			index /= state.NumMinDistCategories
+			expRet := index % state.NumExpRetCategories
+			index /= state.NumExpRetCategories
+			r.Forecast = state.ExpRetName(expRet) + ", analogue " + state.MinDistName(minDist)
+		}
+		s := state.FromIndex(index)
+		r.Market = DescribeMarket(b.Encoder.Name, s.MAState, lines)
+		r.Divergence = state.DivergenceName(s.MADivergence)
+		r.Cash = state.PositionName(s.CashCat)
+		r.Shares = state.PositionName(s.SharesCat)
+		return r
+	}, nil
+}
+
+// DescribeState returns the components of a state index as the rulebook names
+// them, e.g. "market MA5 > Price > ..., divergence neutral, cash high, shares none",
+// or the bare index for encoders it cannot decode.
+func (b *Bundle) DescribeState(index int) string {
+	decode, err := b.stateDecoder()
+	if err != nil {
+		return strconv.Itoa(index)
+	}
+	return strings.Join(decode(index).Conditions(), ", ")
+}
+
+// DescribeMarket returns a readable market component of a state of the ma, regime,
+// or slope encoder. lines name the MAs of an ma encoder with other lines than the
+// default SMAs, and may be nil.
+func DescribeMarket(encoder string, marketState int, lines []ma.Line) string {
+	switch encoder {
+	case "ma":
+		names := ma.OrderingNames(ma.DecodeMAState(marketState))
+		if lines != nil {
+			// OrderingNames names the default periods; the positions are the lines
+			for i, idx := range ma.DecodeMAState(marketState) {
+				if idx != ma.Price && idx-1 < len(lines) {
+					names[i] = lines[idx-1].String()
+				}
+			}
+		}
+		return strings.Join(names, " > ")
+	case "regime":
+		return ma.Regime(marketState).String()
+	case "slope":
+		parts := make([]string, len(ma.MAPeriods))
+		for i, period := range ma.MAPeriods {
+			direction := "down"
+			if marketState&(1<<i) != 0 {
+				direction = "up"
+			}
+			parts[i] = fmt.Sprintf("MA%d %s", period, direction)
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return strconv.Itoa(marketState)
+	}
+}
+
+// isUntrained reports whether all Q-values of a state are still zero.
+func isUntrained(q []float64) bool {
+	for _, v := range q {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -2,7 +2,7 @@ package movingaverage
 
 import (
 	"math"
-	"sort"
+	"strconv"
 )
 
 // MAPeriods defines the moving average periods to use.
@@ -29,6 +29,24 @@ var periodToIndex = map[int]int{
 	120: MA120,
 }
 
+// OrderingNames returns the names of an ordering's elements, e.g. [MA5 Price MA10 ...].
+func OrderingNames(ordering []int) []string {
+	names := make([]string, len(ordering))
+	for i, idx := range ordering {
+		if idx == Price {
+			names[i] = "Price"
+			continue
+		}
+		names[i] = "MA?"
+		for period, p := range periodToIndex {
+			if p == idx {
+				names[i] = "MA" + strconv.Itoa(period)
+			}
+		}
+	}
+	return names
+}
+
 // CalculateMA calculates a simple moving average for the given period.
 func CalculateMA(prices []float64, period int) []float64 {
 	if len(prices) < period {
@@ -58,6 +76,29 @@ func CalculateAllMAs(prices []float64) map[int][]float64 {
 	return mas
 }
 
+// CalculateMAAligned calculates a simple moving average aligned to the price index:
+// element i is the MA of the period prices ending at i, NaN during the warm-up (i < period-1).
+func CalculateMAAligned(prices []float64, period int) []float64 {
+	aligned := make([]float64, len(prices))
+	values := CalculateMA(prices, period)
+	warmUp := len(prices) - len(values)
+	for i := 0; i < warmUp; i++ {
+		aligned[i] = math.NaN()
+	}
+	copy(aligned[warmUp:], values)
+	return aligned
+}
+
+// CalculateAllMAsAligned calculates all moving averages like CalculateAllMAs, but every
+// array has len(prices) elements aligned to the price index, NaN during the warm-up.
+func CalculateAllMAsAligned(prices []float64) map[int][]float64 {
+	mas := make(map[int][]float64)
+	for _, period := range MAPeriods {
+		mas[period] = CalculateMAAligned(prices, period)
+	}
+	return mas
+}
+
 // ValueWithIndex represents a value with its identifier (MA period or Price).
 type ValueWithIndex struct {
 	Value float64
@@ -73,25 +114,40 @@ func GetMAOrdering(prices []float64, idx int) []int {
 		return nil
 	}
 
-	currentPrice := prices[idx]
+	ordering := orderingOf(maValuesAt(prices, idx), prices[idx])
+	return ordering[:]
+}
 
-	// Pre-allocate with exact size
-	values := make([]ValueWithIndex, 7)
+// numPeriods is len(MAPeriods); the orderings hold the MAs plus the price.
+const numPeriods = 6
 
-	// Calculate only the last MA value for each period (more efficient than calculating all MAs)
-	// Assumes idx >= 120, so all periods have enough data
+// maValuesAt returns the MA of every period in MAPeriods at idx.
+// Assumes idx >= 120, so all periods have enough data.
+func maValuesAt(prices []float64, idx int) [numPeriods]float64 {
+	var values [numPeriods]float64
 	for i, period := range MAPeriods {
 		// Calculate MA value directly: sum of last 'period' prices
 		sum := 0.0
 		start := idx - period + 1
+		if start < 0 {
+			start = 0
+		}
 		for j := start; j <= idx; j++ {
 			sum += prices[j]
 		}
-		maValue := sum / float64(period)
+		values[i] = sum / float64(period)
+	}
+	return values
+}
 
+// orderingOf orders the MA values (in MAPeriods order) and the price from highest
+// to lowest, without allocating.
+func orderingOf(maValues [numPeriods]float64, currentPrice float64) [7]int {
+	var values [7]ValueWithIndex
+	for i, period := range MAPeriods {
 		// Map period to index using the mapping
 		values[i] = ValueWithIndex{
-			Value: maValue,
+			Value: maValues[i],
 			Index: periodToIndex[period],
 		}
 	}
@@ -102,19 +158,17 @@ func GetMAOrdering(prices []float64, idx int) []int {
 		Index: Price,
 	}
 
-	// Sort by value (descending - highest first)
-	// For 7 elements, this is very fast
-	sort.Slice(values, func(i, j int) bool {
-		diff := values[i].Value - values[j].Value
-		if math.Abs(diff) < 1e-10 {
-			// If values are equal, maintain original order (by index)
-			return values[i].Index < values[j].Index
+	// Sort by value (descending - highest first). Insertion sort is what sort.Slice
+	// uses for 7 elements; keeping it matters because the tolerance below makes the
+	// order depend on the algorithm when values are nearly equal.
+	for i := 1; i < len(values); i++ {
+		for j := i; j > 0 && higher(values[j], values[j-1]); j-- {
+			values[j], values[j-1] = values[j-1], values[j]
 		}
-		return diff > 0
-	})
+	}
 
 	// Extract ordering
-	ordering := make([]int, 7)
+	var ordering [7]int
 	for i := range values {
 		ordering[i] = values[i].Index
 	}
@@ -122,6 +176,17 @@ func GetMAOrdering(prices []float64, idx int) []int {
 	return ordering
 }
 
+// higher reports whether a sorts before b: a larger value, or the lower index when
+// the values are equal.
+func higher(a, b ValueWithIndex) bool {
+	diff := a.Value - b.Value
+	if math.Abs(diff) < 1e-10 {
+		// If values are equal, maintain original order (by index)
+		return a.Index < b.Index
+	}
+	return diff > 0
+}
+
 // EncodeMAState encodes the MA ordering into a state index.
 // The ordering is a permutation of [1,2,3,4,5,6,7] representing MA5, MA10, MA20, MA40, MA80, MA120, Price.
 // Returns a unique integer state index (0 to 5039, since 7! = 5040).
@@ -129,12 +194,16 @@ func EncodeMAState(ordering []int) int {
 	if len(ordering) != 7 {
 		return 0
 	}
+	return encodeOrdering([7]int(ordering))
+}
 
+// encodeOrdering is EncodeMAState without allocating.
+func encodeOrdering(ordering [7]int) int {
 	// Use factorial number system (Lehmer code) to encode permutation
 	// This gives us a unique index for each permutation
 	state := 0
-	factorials := []int{720, 120, 24, 6, 2, 1, 1} // 6!, 5!, 4!, 3!, 2!, 1!, 0!
-	used := make([]bool, 8)                       // 1-indexed, so we need 8 elements (indices 0-7, use 1-7)
+	factorials := [7]int{720, 120, 24, 6, 2, 1, 1} // 6!, 5!, 4!, 3!, 2!, 1!, 0!
+	var used [8]bool                               // 1-indexed, so we need 8 elements (indices 0-7, use 1-7)
 
 	for i := 0; i < 7; i++ {
 		// Count how many unused numbers are smaller than ordering[i]
@@ -180,11 +249,10 @@ func DecodeMAState(stateIndex int) []int {
 
 // GetMAStateForIndex calculates the MA ordering state for a given price index.
 func GetMAStateForIndex(prices []float64, idx int) int {
-	ordering := GetMAOrdering(prices, idx)
-	if ordering == nil || len(ordering) != 7 {
+	if idx < 0 || idx >= len(prices) {
 		return 0
 	}
-	return EncodeMAState(ordering)
+	return encodeOrdering(orderingOf(maValuesAt(prices, idx), prices[idx]))
 }
 
 // NumMAStates returns the total number of possible MA ordering states.
@@ -202,16 +270,27 @@ func GetMADivergenceState(prices []float64, idx int) int {
 	}
 
 	// Calculate current MA values
-	currentMAs := make([]float64, len(MAPeriods))
-	for i, period := range MAPeriods {
-		sum := 0.0
-		start := idx - period + 1
-		for j := start; j <= idx; j++ {
-			sum += prices[j]
+	currentMAs := maValuesAt(prices, idx)
+
+	// Calculate previous MA values (10 periods ago, but ensure we have enough data)
+	prevIdx := idx - 10
+	if prevIdx < 120 {
+		// If we can't go back 10 periods, use the earliest valid point (120)
+		// In this case, we'll return neutral since we can't make a comparison
+		if idx == 120 {
+			return 1 // Neutral - can't compare yet
 		}
-		currentMAs[i] = sum / float64(period)
+		prevIdx = 120
 	}
 
+	prevMAs := maValuesAt(prices, prevIdx)
+
+	return divergenceOf(currentMAs, prevMAs)
+}
+
+// divergenceOf compares the spread (max - min) of the current MA values with the
+// previous ones: 0 = converging, 1 = neutral, 2 = diverging.
+func divergenceOf(currentMAs, prevMAs [numPeriods]float64) int {
 	// Calculate current spread (range: max - min)
 	currentMax := currentMAs[0]
 	currentMin := currentMAs[0]
@@ -225,30 +304,6 @@ func GetMADivergenceState(prices []float64, idx int) int {
 	}
 	currentSpread := currentMax - currentMin
 
-	// Calculate previous spread (10 periods ago, but ensure we have enough data)
-	prevIdx := idx - 10
-	if prevIdx < 120 {
-		// If we can't go back 10 periods, use the earliest valid point (120)
-		// In this case, we'll return neutral since we can't make a comparison
-		if idx == 120 {
-			return 1 // Neutral - can't compare yet
-		}
-		prevIdx = 120
-	}
-
-	prevMAs := make([]float64, len(MAPeriods))
-	for i, period := range MAPeriods {
-		sum := 0.0
-		start := prevIdx - period + 1
-		if start < 0 {
-			start = 0
-		}
-		for j := start; j <= prevIdx; j++ {
-			sum += prices[j]
-		}
-		prevMAs[i] = sum / float64(period)
-	}
-
 	prevMax := prevMAs[0]
 	prevMin := prevMAs[0]
 	for _, ma := range prevMAs {
@@ -0,0 +1,50 @@
+package movingaverage
+
+import (
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+	"testing/quick"
+)
+
+// permutation is a random ordering of the seven lines, 1 to 7, for quick.Check.
+type permutation []int
+
+// Generate implements quick.Generator.
+func (permutation) Generate(rng *rand.Rand, _ int) reflect.Value {
+	p := make(permutation, 7)
+	for i, v := range rng.Perm(7) {
+		p[i] = v + 1
+	}
+	return reflect.ValueOf(p)
+}
+
+func TestMAStateIsBijection(t *testing.T) {
+	seen := make(map[int]bool, NumMAStates())
+	for index := 0; index < NumMAStates(); index++ {
+		ordering := DecodeMAState(index)
+		sorted := slices.Sorted(slices.Values(ordering))
+		if !slices.Equal(sorted, []int{1, 2, 3, 4, 5, 6, 7}) {
+			t.Fatalf("DecodeMAState(%d) = %v, not a permutation of 1..7", index, ordering)
+		}
+		key := EncodeMAState(ordering)
+		if key != index {
+			t.Fatalf("EncodeMAState(DecodeMAState(%d)) = %d", index, key)
+		}
+		if seen[key] {
+			t.Fatalf("state %d decoded twice", key)
+		}
+		seen[key] = true
+	}
+}
+
+func TestEncodeDecodeMAStateRoundTrip(t *testing.T) {
+	roundTrip := func(p permutation) bool {
+		index := EncodeMAState(p)
+		return index >= 0 && index < NumMAStates() && slices.Equal(DecodeMAState(index), p)
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
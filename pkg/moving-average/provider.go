@@ -0,0 +1,83 @@
+package movingaverage
+
+import "math"
+
+// Provider answers moving average queries for one price series in O(1) per query
+// using prefix sums. Build it once per series and reuse it for every step.
+type Provider struct {
+	prices []float64
+	// sums[i] + errs[i] is the sum of prices[:i]. The compensation keeps window
+	// sums exact enough that flat stretches still compare equal to the price.
+	sums []float64
+	errs []float64
+}
+
+// NewProvider precomputes the prefix sums of prices.
+func NewProvider(prices []float64) *Provider {
+	sums := make([]float64, len(prices)+1)
+	errs := make([]float64, len(prices)+1)
+	for i, p := range prices {
+		// Neumaier summation
+		t := sums[i] + p
+		if math.Abs(sums[i]) >= math.Abs(p) {
+			errs[i+1] = errs[i] + (sums[i] - t) + p
+		} else {
+			errs[i+1] = errs[i] + (p - t) + sums[i]
+		}
+		sums[i+1] = t
+	}
+	return &Provider{prices: prices, sums: sums, errs: errs}
+}
+
+// Prices returns the series the provider was built for.
+func (p *Provider) Prices() []float64 {
+	return p.prices
+}
+
+// MA returns the simple moving average of the period prices ending at idx.
+// It assumes idx >= period-1.
+func (p *Provider) MA(idx, period int) float64 {
+	start := idx + 1 - period
+	return ((p.sums[idx+1] - p.sums[start]) + (p.errs[idx+1] - p.errs[start])) / float64(period)
+}
+
+// Ordering is GetMAOrdering for the provider's series.
+func (p *Provider) Ordering(idx int) []int {
+	if idx < 0 || idx >= len(p.prices) {
+		return nil
+	}
+	ordering := orderingOf(p.mas(idx), p.prices[idx])
+	return ordering[:]
+}
+
+// State is GetMAStateForIndex for the provider's series.
+func (p *Provider) State(idx int) int {
+	if idx < 0 || idx >= len(p.prices) {
+		return 0
+	}
+	return encodeOrdering(orderingOf(p.mas(idx), p.prices[idx]))
+}
+
+// Divergence is GetMADivergenceState for the provider's series.
+func (p *Provider) Divergence(idx int) int {
+	if idx < 120 || idx >= len(p.prices) {
+		return 1 // Neutral if not enough data
+	}
+	prevIdx := idx - 10
+	if prevIdx < 120 {
+		if idx == 120 {
+			return 1 // Neutral - can't compare yet
+		}
+		prevIdx = 120
+	}
+	return divergenceOf(p.mas(idx), p.mas(prevIdx))
+}
+
+// mas returns the MA of every period in MAPeriods at idx.
+func (p *Provider) mas(idx int) [numPeriods]float64 {
+	var values [numPeriods]float64
+	for i, period := range MAPeriods {
+		values[i] = p.MA(idx, period)
+	}
+	return values
+}
@@ -0,0 +1,88 @@
+package movingaverage
+
+// Regime is the trend regime given by the relationship of the 50- and 200-period
+// moving averages.
+type Regime int
+
+const (
+	RegimeUnknown Regime = iota // Fewer than 200 prices
+	Bullish                     // MA50 above MA200
+	Bearish                     // MA50 at or below MA200
+	GoldenCross                 // MA50 crossed above MA200 at this price
+	DeathCross                  // MA50 crossed below MA200 at this price
+	NumRegimes    = 5
+)
+
+// Periods of the regime moving averages.
+const (
+	RegimeFast = 50
+	RegimeSlow = 200
+)
+
+// String returns a readable name for the regime.
+func (r Regime) String() string {
+	switch r {
+	case Bullish:
+		return "bullish"
+	case Bearish:
+		return "bearish"
+	case GoldenCross:
+		return "golden-cross"
+	case DeathCross:
+		return "death-cross"
+	default:
+		return "unknown"
+	}
+}
+
+// IsBullish reports whether MA50 is above MA200 (a bullish regime or a golden cross).
+func (r Regime) IsBullish() bool {
+	return r == Bullish || r == GoldenCross
+}
+
+// IsBearish reports whether MA50 is at or below MA200 (a bearish regime or a death cross).
+func (r Regime) IsBearish() bool {
+	return r == Bearish || r == DeathCross
+}
+
+// GetRegime classifies the regime at idx. A cross needs the previous price too, so the
+// first classified index (199) is never a cross.
+func GetRegime(prices []float64, idx int) Regime {
+	if idx < RegimeSlow-1 || idx >= len(prices) {
+		return RegimeUnknown
+	}
+	p := NewProvider(prices[:idx+1])
+	return regimeAt(p, idx)
+}
+
+// Regimes classifies every index of prices in O(len(prices)).
+func Regimes(prices []float64) []Regime {
+	p := NewProvider(prices)
+	regimes := make([]Regime, len(prices))
+	for idx := RegimeSlow - 1; idx < len(prices); idx++ {
+		regimes[idx] = regimeAt(p, idx)
+	}
+	return regimes
+}
+
+// regimeAt classifies idx >= RegimeSlow-1 from precomputed prefix sums.
+func regimeAt(p *Provider, idx int) Regime {
+	above := p.MA(idx, RegimeFast) > p.MA(idx, RegimeSlow)
+	if idx == RegimeSlow-1 {
+		if above {
+			return Bullish
+		}
+		return Bearish
+	}
+	wasAbove := p.MA(idx-1, RegimeFast) > p.MA(idx-1, RegimeSlow)
+	switch {
+	case above && !wasAbove:
+		return GoldenCross
+	case !above && wasAbove:
+		return DeathCross
+	case above:
+		return Bullish
+	default:
+		return Bearish
+	}
+}
@@ -0,0 +1,196 @@
+package movingaverage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RibbonWidth returns the normalized spread of the moving averages at idx,
+// (max − min) / price. Assumes idx >= 120 (all MAs available).
+func RibbonWidth(prices []float64, idx int) float64 {
+	if idx < 0 || idx >= len(prices) {
+		return math.NaN()
+	}
+	return ribbonWidth(maValuesAt(prices, idx), prices[idx])
+}
+
+// RibbonWidths returns the ribbon width at every index of prices (NaN before 120).
+func RibbonWidths(prices []float64) []float64 {
+	p := NewProvider(prices)
+	widths := make([]float64, len(prices))
+	for idx := range widths {
+		widths[idx] = p.RibbonWidth(idx)
+	}
+	return widths
+}
+
+// RibbonChanges returns the change of the ribbon width at every index of prices
+// relative to 10 prices earlier, with the look-back of GetMADivergenceState (NaN
+// where it returns neutral for lack of data).
+func RibbonChanges(prices []float64) []float64 {
+	p := NewProvider(prices)
+	changes := make([]float64, len(prices))
+	for idx := range changes {
+		changes[idx] = p.RibbonChange(idx)
+	}
+	return changes
+}
+
+// RibbonWidth returns the ribbon width at idx, or NaN before 120.
+func (p *Provider) RibbonWidth(idx int) float64 {
+	if idx < 120 || idx >= len(p.prices) {
+		return math.NaN()
+	}
+	return ribbonWidth(p.mas(idx), p.prices[idx])
+}
+
+// RibbonChange returns the ribbon width change at idx (see RibbonChanges).
+func (p *Provider) RibbonChange(idx int) float64 {
+	prevIdx, ok := divergenceLookBack(idx, 120, len(p.prices))
+	if !ok {
+		return math.NaN()
+	}
+	return p.RibbonWidth(idx) - p.RibbonWidth(prevIdx)
+}
+
+// RibbonWidth returns the ribbon width of the provider's lines at idx, or NaN
+// before the warm-up.
+func (p *LinesProvider) RibbonWidth(idx int) float64 {
+	if idx < p.warmUp || idx >= len(p.prices) {
+		return math.NaN()
+	}
+	return ribbonWidth(p.at(idx), p.prices[idx])
+}
+
+// RibbonChange returns the ribbon width change of the provider's lines at idx.
+func (p *LinesProvider) RibbonChange(idx int) float64 {
+	prevIdx, ok := divergenceLookBack(idx, p.warmUp, len(p.prices))
+	if !ok {
+		return math.NaN()
+	}
+	return p.RibbonWidth(idx) - p.RibbonWidth(prevIdx)
+}
+
+// divergenceLookBack returns the index the MA spread at idx is compared with: 10
+// earlier, but not before warmUp. ok is false where the divergence is neutral for
+// lack of data.
+func divergenceLookBack(idx, warmUp, n int) (prevIdx int, ok bool) {
+	if idx <= warmUp || idx >= n {
+		return 0, false
+	}
+	return max(idx-10, warmUp), true
+}
+
+func ribbonWidth(mas [numPeriods]float64, price float64) float64 {
+	lo, hi := mas[0], mas[0]
+	for _, v := range mas[1:] {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	return (hi - lo) / price
+}
+
+// Buckets splits a continuous feature at quantile thresholds fitted on data.
+type Buckets struct {
+	Thresholds []float64 // Ascending; a value v falls in the bucket of the thresholds <= v
+}
+
+// FitBuckets returns the thresholds at the given quantiles (in (0, 1), ascending)
+// of values, ignoring NaNs.
+func FitBuckets(values []float64, quantiles []float64) (Buckets, error) {
+	sorted := make([]float64, 0, len(values))
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sorted = append(sorted, v)
+		}
+	}
+	if len(sorted) == 0 {
+		return Buckets{}, fmt.Errorf("no values to fit buckets on")
+	}
+	sort.Float64s(sorted)
+
+	thresholds := make([]float64, len(quantiles))
+	for i, q := range quantiles {
+		if q <= 0 || q >= 1 || (i > 0 && q <= quantiles[i-1]) {
+			return Buckets{}, fmt.Errorf("quantiles must be ascending and in (0, 1), got %v", quantiles)
+		}
+		// Linear interpolation between the closest ranks
+		pos := q * float64(len(sorted)-1)
+		lo := int(math.Floor(pos))
+		hi := int(math.Ceil(pos))
+		thresholds[i] = sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+	}
+	return Buckets{Thresholds: thresholds}, nil
+}
+
+// Len returns the number of buckets.
+func (b Buckets) Len() int {
+	return len(b.Thresholds) + 1
+}
+
+// Bucket returns the bucket of v, from 0 (below the first threshold) to Len()-1.
+func (b Buckets) Bucket(v float64) int {
+	return sort.Search(len(b.Thresholds), func(i int) bool { return b.Thresholds[i] > v })
+}
+
+// String formats the thresholds as ParseBuckets accepts them.
+func (b Buckets) String() string {
+	parts := make([]string, len(b.Thresholds))
+	for i, t := range b.Thresholds {
+		parts[i] = strconv.FormatFloat(t, 'g', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseBuckets parses comma-separated ascending thresholds.
+func ParseBuckets(s string) (Buckets, error) {
+	values, err := parseFloats(s)
+	if err != nil {
+		return Buckets{}, err
+	}
+	if !sort.Float64sAreSorted(values) {
+		return Buckets{}, fmt.Errorf("bucket thresholds must be ascending, got %v", values)
+	}
+	return Buckets{Thresholds: values}, nil
+}
+
+// ParseQuantiles parses comma-separated quantiles, e.g. "0.33,0.67".
+func ParseQuantiles(s string) ([]float64, error) {
+	return parseFloats(s)
+}
+
+func parseFloats(s string) ([]float64, error) {
+	var values []float64
+	for _, part := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", part)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// FitDivergence fits data-driven divergence thresholds: the quantiles low and high of
+// the ribbon width changes of every series. With them, QuantileDivergence replaces
+// the fixed 1% threshold of GetMADivergenceState.
+func FitDivergence(series [][]float64, low, high float64) (Buckets, error) {
+	var changes []float64
+	for _, prices := range series {
+		changes = append(changes, RibbonChanges(prices)...)
+	}
+	return FitBuckets(changes, []float64{low, high})
+}
+
+// QuantileDivergence maps a ribbon width change to a divergence state with fitted
+// thresholds: 0 = converging (below the first), 1 = neutral, 2 = diverging (at or
+// above the second). NaN changes (not enough data) are neutral.
+func QuantileDivergence(b Buckets, change float64) int {
+	if math.IsNaN(change) || len(b.Thresholds) != 2 {
+		return 1
+	}
+	return b.Bucket(change)
+}
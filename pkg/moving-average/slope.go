@@ -0,0 +1,32 @@
+package movingaverage
+
+// NumSlopeStates is the number of slope-sign vectors: one bit per MA period.
+const NumSlopeStates = 1 << numPeriods
+
+// GetSlopeState returns the signs of the slopes of the MAs at idx as a bit vector:
+// bit i is set when the MA of MAPeriods[i] rose over the last lag prices. Assumes
+// idx-lag >= 120, so all MAs are available at both ends.
+func GetSlopeState(prices []float64, idx, lag int) int {
+	if idx < 0 || idx >= len(prices) || lag < 1 || idx-lag < 0 {
+		return 0
+	}
+	return slopeBits(maValuesAt(prices, idx), maValuesAt(prices, idx-lag))
+}
+
+// SlopeState is GetSlopeState for the provider's series.
+func (p *Provider) SlopeState(idx, lag int) int {
+	if idx < 0 || idx >= len(p.prices) || lag < 1 || idx-lag < 119 {
+		return 0
+	}
+	return slopeBits(p.mas(idx), p.mas(idx-lag))
+}
+
+func slopeBits(current, prev [numPeriods]float64) int {
+	bits := 0
+	for i := range current {
+		if current[i] > prev[i] {
+			bits |= 1 << i
+		}
+	}
+	return bits
+}
@@ -0,0 +1,207 @@
+package movingaverage
+
+import "math"
+
+// MovingAverage is an online moving average: Push adds a price in O(1) and Value
+// returns the average of the latest period prices (for EMAs, of all prices pushed).
+type MovingAverage struct {
+	typ    Type
+	period int
+	window []float64 // Ring buffer of the latest period prices
+	pos    int       // Next slot of window
+	n      int       // Prices pushed
+	sum    float64   // Sum of window, compensated by comp
+	comp   float64
+	wsum   float64 // Weighted sum of window for WMAs
+	ema    float64
+}
+
+// NewMovingAverage creates an empty moving average of the given type and period.
+func NewMovingAverage(t Type, period int) *MovingAverage {
+	if period < 1 {
+		period = 1
+	}
+	return &MovingAverage{typ: t, period: period, window: make([]float64, period)}
+}
+
+// Type returns the type of the moving average.
+func (m *MovingAverage) Type() Type {
+	return m.typ
+}
+
+// Period returns the period of the moving average.
+func (m *MovingAverage) Period() int {
+	return m.period
+}
+
+// Ready reports whether period prices were pushed, so Value is defined.
+func (m *MovingAverage) Ready() bool {
+	return m.n >= m.period
+}
+
+// Push adds the next price.
+func (m *MovingAverage) Push(price float64) {
+	var oldest float64
+	full := m.n >= m.period
+	if full {
+		oldest = m.window[m.pos]
+	}
+	m.window[m.pos] = price
+	m.pos = (m.pos + 1) % m.period
+	m.n++
+
+	if m.typ == Weighted {
+		if full {
+			// Every weight drops by one, the oldest price to zero
+			m.wsum += float64(m.period)*price - m.windowSum()
+		} else {
+			m.wsum += float64(m.n) * price
+		}
+	}
+	m.add(price)
+	if full {
+		m.add(-oldest)
+	}
+
+	if m.typ == Exponential {
+		switch {
+		case m.n == m.period:
+			m.ema = m.windowSum() / float64(m.period)
+		case m.n > m.period:
+			alpha := 2.0 / float64(m.period+1)
+			m.ema += alpha * (price - m.ema)
+		}
+	}
+}
+
+// add adds x to the window sum with Neumaier compensation, so a long stream does not
+// drift from the exact window sum.
+func (m *MovingAverage) add(x float64) {
+	t := m.sum + x
+	if math.Abs(m.sum) >= math.Abs(x) {
+		m.comp += (m.sum - t) + x
+	} else {
+		m.comp += (x - t) + m.sum
+	}
+	m.sum = t
+}
+
+func (m *MovingAverage) windowSum() float64 {
+	return m.sum + m.comp
+}
+
+// Value returns the current average, or NaN until Ready.
+func (m *MovingAverage) Value() float64 {
+	if !m.Ready() {
+		return math.NaN()
+	}
+	switch m.typ {
+	case Exponential:
+		return m.ema
+	case Weighted:
+		return m.wsum / float64(m.period*(m.period+1)/2)
+	default:
+		return m.windowSum() / float64(m.period)
+	}
+}
+
+// Reset empties the moving average.
+func (m *MovingAverage) Reset() {
+	*m = MovingAverage{typ: m.typ, period: m.period, window: m.window}
+	clear(m.window)
+}
+
+// divergenceLag is how many prices back GetMADivergenceState compares the MA spread.
+const divergenceLag = 10
+
+// Stream tracks the MA ordering of a price stream online. Each Push updates the six
+// moving averages in O(1); once Ready, State and Divergence equal
+// GetMAStateForIndex and GetMADivergenceState at the latest price.
+type Stream struct {
+	lines   []Line
+	mas     [numPeriods]*MovingAverage
+	history [divergenceLag + 1][numPeriods]float64 // MA values of the latest prices
+	n       int
+	price   float64
+}
+
+// NewStream creates a stream over the default lines (the SMAs of MAPeriods).
+func NewStream() *Stream {
+	s, _ := NewLinesStream(DefaultLines())
+	return s
+}
+
+// NewLinesStream creates a stream over six lines, which may mix MA types.
+func NewLinesStream(lines []Line) (*Stream, error) {
+	if err := CheckLines(lines); err != nil {
+		return nil, err
+	}
+	s := &Stream{lines: lines}
+	for i, l := range lines {
+		s.mas[i] = NewMovingAverage(l.Type, l.Period)
+	}
+	return s, nil
+}
+
+// Lines returns the stream's lines.
+func (s *Stream) Lines() []Line {
+	return s.lines
+}
+
+// Push adds the next price.
+func (s *Stream) Push(price float64) {
+	var values [numPeriods]float64
+	for i, m := range s.mas {
+		m.Push(price)
+		values[i] = m.Value()
+	}
+	s.history[s.n%len(s.history)] = values
+	s.n++
+	s.price = price
+}
+
+// Len returns the number of prices pushed.
+func (s *Stream) Len() int {
+	return s.n
+}
+
+// Price returns the latest price.
+func (s *Stream) Price() float64 {
+	return s.price
+}
+
+// WarmUp returns the number of prices needed before Ready: the longest period, plus
+// the divergence look-back.
+func (s *Stream) WarmUp() int {
+	longest := 0
+	for _, l := range s.lines {
+		longest = max(longest, l.Period)
+	}
+	return longest + divergenceLag + 1
+}
+
+// Ready reports whether enough prices were pushed for State and Divergence.
+func (s *Stream) Ready() bool {
+	return s.n >= s.WarmUp()
+}
+
+// State returns the MA ordering state at the latest price.
+func (s *Stream) State() int {
+	if s.n == 0 {
+		return 0
+	}
+	return encodeOrdering(orderingOf(s.current(), s.price))
+}
+
+// Divergence returns the MA divergence state at the latest price, or neutral until Ready.
+func (s *Stream) Divergence() int {
+	if !s.Ready() {
+		return 1 // Neutral if not enough data
+	}
+	prev := s.history[(s.n-1-divergenceLag)%len(s.history)]
+	return divergenceOf(s.current(), prev)
+}
+
+func (s *Stream) current() [numPeriods]float64 {
+	return s.history[(s.n-1)%len(s.history)]
+}
@@ -0,0 +1,312 @@
+package movingaverage
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Type is how a moving average weights the prices of its window.
+type Type int
+
+const (
+	Simple      Type = iota // SMA: equal weights
+	Exponential             // EMA: weights decay by 2/(period+1); seeded with the SMA of the first period
+	Weighted                // WMA: linear weights, the newest price highest
+)
+
+// String returns the short name of the type (sma, ema, or wma).
+func (t Type) String() string {
+	switch t {
+	case Simple:
+		return "sma"
+	case Exponential:
+		return "ema"
+	case Weighted:
+		return "wma"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseType parses sma, ema, or wma (case-insensitive).
+func ParseType(s string) (Type, error) {
+	switch strings.ToLower(s) {
+	case "sma":
+		return Simple, nil
+	case "ema":
+		return Exponential, nil
+	case "wma":
+		return Weighted, nil
+	default:
+		return 0, fmt.Errorf("unknown moving average type %q (use sma, ema, or wma)", s)
+	}
+}
+
+// CalculateEMA calculates an exponential moving average for the given period.
+// Like CalculateMA, the result starts at index period-1 of prices, where the EMA is
+// seeded with the simple average of the first period prices.
+func CalculateEMA(prices []float64, period int) []float64 {
+	if period < 1 || len(prices) < period {
+		return nil
+	}
+	return emaSeries(prices, period)[period-1:]
+}
+
+// CalculateWMA calculates a linearly weighted moving average for the given period,
+// aligned like CalculateMA.
+func CalculateWMA(prices []float64, period int) []float64 {
+	if period < 1 || len(prices) < period {
+		return nil
+	}
+	return wmaSeries(prices, period)[period-1:]
+}
+
+// Calculate calculates a moving average of the given type, aligned like CalculateMA.
+func Calculate(t Type, prices []float64, period int) []float64 {
+	switch t {
+	case Exponential:
+		return CalculateEMA(prices, period)
+	case Weighted:
+		return CalculateWMA(prices, period)
+	default:
+		return CalculateMA(prices, period)
+	}
+}
+
+// emaSeries returns the EMA at every index of prices (NaN before period-1).
+func emaSeries(prices []float64, period int) []float64 {
+	values := make([]float64, len(prices))
+	alpha := 2.0 / float64(period+1)
+	sum := 0.0
+	for i, p := range prices {
+		switch {
+		case i < period-1:
+			sum += p
+			values[i] = math.NaN()
+		case i == period-1:
+			values[i] = (sum + p) / float64(period)
+		default:
+			values[i] = values[i-1] + alpha*(p-values[i-1])
+		}
+	}
+	return values
+}
+
+// wmaSeries returns the WMA at every index of prices (NaN before period-1).
+func wmaSeries(prices []float64, period int) []float64 {
+	values := make([]float64, len(prices))
+	weights := float64(period*(period+1)) / 2
+	for i := range prices {
+		if i < period-1 {
+			values[i] = math.NaN()
+			continue
+		}
+		sum := 0.0
+		for k := 0; k < period; k++ {
+			sum += float64(period-k) * prices[i-k]
+		}
+		values[i] = sum / weights
+	}
+	return values
+}
+
+// Line is one moving average of an ordering: its type and period.
+type Line struct {
+	Type   Type
+	Period int
+}
+
+// String returns the line in the form ParseLines accepts, e.g. ema20.
+func (l Line) String() string {
+	return l.Type.String() + strconv.Itoa(l.Period)
+}
+
+// DefaultLines returns the SMAs of MAPeriods, the lines of the default ordering.
+func DefaultLines() []Line {
+	lines := make([]Line, len(MAPeriods))
+	for i, period := range MAPeriods {
+		lines[i] = Line{Type: Simple, Period: period}
+	}
+	return lines
+}
+
+// IsDefault reports whether lines are DefaultLines.
+func IsDefault(lines []Line) bool {
+	if len(lines) != len(MAPeriods) {
+		return false
+	}
+	for i, l := range lines {
+		if l != (Line{Type: Simple, Period: MAPeriods[i]}) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLines parses a comma-separated list of lines such as
+// "sma5,ema10,ema20,sma40,wma80,sma120". Orderings need exactly six lines.
+func ParseLines(s string) ([]Line, error) {
+	parts := strings.Split(s, ",")
+	lines := make([]Line, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if len(part) < 4 {
+			return nil, fmt.Errorf("invalid moving average %q (want e.g. ema20)", part)
+		}
+		t, err := ParseType(part[:3])
+		if err != nil {
+			return nil, err
+		}
+		period, err := strconv.Atoi(part[3:])
+		if err != nil || period < 1 {
+			return nil, fmt.Errorf("invalid moving average period in %q", part)
+		}
+		lines = append(lines, Line{Type: t, Period: period})
+	}
+	if err := CheckLines(lines); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// FormatLines formats lines as ParseLines accepts them.
+func FormatLines(lines []Line) string {
+	names := make([]string, len(lines))
+	for i, l := range lines {
+		names[i] = l.String()
+	}
+	return strings.Join(names, ",")
+}
+
+// CheckLines reports whether lines can be used for orderings: six lines with
+// positive periods.
+func CheckLines(lines []Line) error {
+	if len(lines) != numPeriods {
+		return fmt.Errorf("orderings need %d moving averages, got %d", numPeriods, len(lines))
+	}
+	for _, l := range lines {
+		if l.Period < 1 {
+			return fmt.Errorf("moving average %s has no valid period", l)
+		}
+		if l.Type < Simple || l.Type > Weighted {
+			return fmt.Errorf("moving average %d has an unknown type", l.Period)
+		}
+	}
+	return nil
+}
+
+// LineNames returns the names of an ordering's elements for the given lines, like
+// OrderingNames does for the default lines.
+func LineNames(lines []Line, ordering []int) []string {
+	names := make([]string, len(ordering))
+	for i, idx := range ordering {
+		switch {
+		case idx == Price:
+			names[i] = "Price"
+		case idx >= 1 && idx <= len(lines):
+			names[i] = strings.ToUpper(lines[idx-1].String())
+		default:
+			names[i] = "MA?"
+		}
+	}
+	return names
+}
+
+// LinesProvider is Provider for any six lines, which may mix MA types. Element i+1
+// of an ordering is lines[i], so the default lines give the same orderings, states,
+// and divergence as Provider.
+type LinesProvider struct {
+	prices []float64
+	lines  []Line
+	values [numPeriods][]float64 // values[i][idx] is lines[i] at idx
+	warmUp int
+}
+
+// NewLinesProvider precomputes every line over prices.
+func NewLinesProvider(prices []float64, lines []Line) (*LinesProvider, error) {
+	if err := CheckLines(lines); err != nil {
+		return nil, err
+	}
+	p := &LinesProvider{prices: prices, lines: lines}
+	var sma *Provider
+	for i, l := range lines {
+		if l.Period > p.warmUp {
+			p.warmUp = l.Period
+		}
+		switch l.Type {
+		case Exponential:
+			p.values[i] = emaSeries(prices, l.Period)
+		case Weighted:
+			p.values[i] = wmaSeries(prices, l.Period)
+		default:
+			if sma == nil {
+				sma = NewProvider(prices)
+			}
+			values := make([]float64, len(prices))
+			for idx := range values {
+				if idx < l.Period-1 {
+					values[idx] = math.NaN()
+					continue
+				}
+				values[idx] = sma.MA(idx, l.Period)
+			}
+			p.values[i] = values
+		}
+	}
+	return p, nil
+}
+
+// Lines returns the provider's lines.
+func (p *LinesProvider) Lines() []Line {
+	return p.lines
+}
+
+// WarmUp returns the first index with enough data for states: the longest period
+// (120 for the default lines, as the default encoder assumes).
+func (p *LinesProvider) WarmUp() int {
+	return p.warmUp
+}
+
+// Ordering is GetMAOrdering for the provider's lines.
+func (p *LinesProvider) Ordering(idx int) []int {
+	if idx < 0 || idx >= len(p.prices) {
+		return nil
+	}
+	ordering := orderingOf(p.at(idx), p.prices[idx])
+	return ordering[:]
+}
+
+// State is GetMAStateForIndex for the provider's lines.
+func (p *LinesProvider) State(idx int) int {
+	if idx < 0 || idx >= len(p.prices) {
+		return 0
+	}
+	return encodeOrdering(orderingOf(p.at(idx), p.prices[idx]))
+}
+
+// Divergence is GetMADivergenceState for the provider's lines, with the warm-up
+// index in place of 120.
+func (p *LinesProvider) Divergence(idx int) int {
+	if idx < p.warmUp || idx >= len(p.prices) {
+		return 1 // Neutral if not enough data
+	}
+	prevIdx := idx - 10
+	if prevIdx < p.warmUp {
+		if idx == p.warmUp {
+			return 1 // Neutral - can't compare yet
+		}
+		prevIdx = p.warmUp
+	}
+	return divergenceOf(p.at(idx), p.at(prevIdx))
+}
+
+// at returns the value of every line at idx.
+func (p *LinesProvider) at(idx int) [numPeriods]float64 {
+	var values [numPeriods]float64
+	for i := range values {
+		values[i] = p.values[i][idx]
+	}
+	return values
+}
@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kind is the type of a notification event.
+type Kind string
+
+const (
+	RunCompleted   Kind = "run_completed"
+	EarlyStopped   Kind = "early_stopped"
+	BestValidation Kind = "best_validation"
+	TDAlarm        Kind = "td_alarm"
+)
+
+// Event is something worth telling the user about during a run.
+type Event struct {
+	Kind    Kind               `json:"kind"`
+	Run     string             `json:"run,omitempty"`
+	Episode int                `json:"episode"`
+	Message string             `json:"message"`
+	Values  map[string]float64 `json:"values,omitempty"`
+	Time    time.Time          `json:"time"`
+}
+
+// Text formats an event as one line.
+func (e Event) Text() string {
+	var b strings.Builder
+	if e.Run != "" {
+		fmt.Fprintf(&b, "[%s] ", e.Run)
+	}
+	b.WriteString(e.Message)
+	keys := make([]string, 0, len(e.Values))
+	for k := range e.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%.4g", k, e.Values[k])
+	}
+	return b.String()
+}
+
+// Notifier delivers events.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Webhook posts events to a URL, either as the Event JSON or, in Slack format, as
+// {"text": ...} for Slack incoming webhooks.
+type Webhook struct {
+	URL        string
+	Slack      bool
+	HTTPClient *http.Client
+}
+
+// NewWebhook creates a webhook notifier. format is "json" or "slack".
+func NewWebhook(url, format string) (*Webhook, error) {
+	if url == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+	w := &Webhook{URL: url, HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+	switch format {
+	case "", "json":
+	case "slack":
+		w.Slack = true
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q (want json or slack)", format)
+	}
+	return w, nil
+}
+
+// Notify implements Notifier.
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	var payload any = e
+	if w.Slack {
+		payload = map[string]string{"text": e.Text()}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package plot
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+)
+
+// IsBinaryFile reports whether a file name selects the binary Q-table or transitions
+// format (.bin, optionally followed by .gz).
+func IsBinaryFile(filename string) bool {
+	return strings.EqualFold(filepath.Ext(strings.TrimSuffix(filename, ".gz")), ".bin")
+}
+
+// SaveTransitionsToFile saves transitions (e.g. a replay buffer) in the binary format.
+// A file ending in .gz is compressed.
+func SaveTransitionsToFile(transitions []agent.Transition, filename string) error {
+	return writeBinaryFile(filename, func(w io.Writer) error {
+		return agent.WriteTransitions(w, transitions)
+	})
+}
+
+// LoadTransitionsFromFile loads transitions saved by SaveTransitionsToFile.
+func LoadTransitionsFromFile(filename string) ([]agent.Transition, error) {
+	var transitions []agent.Transition
+	err := readBinaryFile(filename, func(r io.Reader) error {
+		var err error
+		transitions, err = agent.ReadTransitions(r)
+		return err
+	})
+	return transitions, err
+}
+
+// writeBinaryFile creates filename (and its directory) and passes a writer to write,
+// compressing the output when the name ends in .gz.
+func writeBinaryFile(filename string, write func(io.Writer) error) error {
+	if dir := filepath.Dir(filename); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	var w io.Writer = file
+	var gz *gzip.Writer
+	if data.IsGzip(filename) {
+		gz = gzip.NewWriter(file)
+		w = gz
+	}
+	if err := write(w); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to finish gzip stream: %w", err)
+		}
+	}
+	return file.Close()
+}
+
+// readBinaryFile opens filename and passes a reader to read, decompressing .gz files.
+func readBinaryFile(filename string, read func(io.Reader) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if data.IsGzip(filename) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return read(r)
+}
@@ -0,0 +1,261 @@
+package plot
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// ChartData holds the series shown in the interactive report, for static export.
+type ChartData struct {
+	Prices     []float64
+	MAs        map[int][]float64 // MA values by period, aligned to Prices (NaN during the warm-up)
+	Actions    []int
+	RunNames   []string
+	RunValues  [][]float64 // Portfolio value series of every run
+	BuyHold    []float64
+	Cash       []float64
+	Equity     []float64 // Shares value (shares * price)
+	Drawdown   []float64 // Drawdown from the running peak (zero or negative)
+	ChartWidth vg.Length
+}
+
+// maColors matches the MA colors used in the interactive report.
+var maColors = []color.RGBA{
+	{R: 255, G: 127, B: 14, A: 255},
+	{R: 148, G: 103, B: 189, A: 255},
+	{R: 140, G: 86, B: 75, A: 255},
+	{R: 227, G: 119, B: 194, A: 255},
+	{R: 127, G: 127, B: 127, A: 255},
+	{R: 188, G: 189, B: 34, A: 255},
+}
+
+// ExportCharts writes every report chart to dir in the given format ("png" or "svg").
+// It returns the paths of the written files.
+func ExportCharts(data ChartData, dir string, format string) ([]string, error) {
+	if format != "png" && format != "svg" {
+		return nil, fmt.Errorf("unsupported export format %q (use png or svg)", format)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	if data.ChartWidth == 0 {
+		data.ChartWidth = 12 * vg.Inch
+	}
+
+	var written []string
+	save := func(name string, fn func(filename string) error) error {
+		filename := filepath.Join(dir, name+"."+format)
+		if err := fn(filename); err != nil {
+			return fmt.Errorf("failed to export %s: %w", name, err)
+		}
+		written = append(written, filename)
+		return nil
+	}
+
+	if err := save("price", func(filename string) error {
+		return savePriceChart(data, filename)
+	}); err != nil {
+		return written, err
+	}
+
+	if len(data.RunValues) > 0 {
+		names := append([]string{}, data.RunNames...)
+		curves := append([][]float64{}, data.RunValues...)
+		if len(data.BuyHold) > 0 {
+			names = append(names, "Buy & Hold")
+			curves = append(curves, data.BuyHold)
+		}
+		if err := save("equity", func(filename string) error {
+			return SaveEquityCurves(names, curves, filename)
+		}); err != nil {
+			return written, err
+		}
+	}
+
+	if len(data.Cash) > 0 {
+		if err := save("allocation", func(filename string) error {
+			return saveAllocationChart(data.Cash, data.Equity, data.ChartWidth, filename)
+		}); err != nil {
+			return written, err
+		}
+	}
+
+	if len(data.Drawdown) > 0 {
+		if err := save("drawdown", func(filename string) error {
+			return saveDrawdownChart(data.Drawdown, data.ChartWidth, filename)
+		}); err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// savePriceChart draws prices, moving averages, and buy/sell markers.
+func savePriceChart(data ChartData, filename string) error {
+	p := plot.New()
+	p.Title.Text = "Price and actions"
+	p.X.Label.Text = "t"
+	p.Y.Label.Text = "price"
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	priceLine, err := plotter.NewLine(seriesXYs(data.Prices, 0))
+	if err != nil {
+		return err
+	}
+	priceLine.Color = color.RGBA{R: 31, G: 119, B: 180, A: 255}
+	priceLine.Width = vg.Points(1.5)
+	p.Add(priceLine)
+	p.Legend.Add("Price", priceLine)
+
+	periods := make([]int, 0, len(data.MAs))
+	for period := range data.MAs {
+		periods = append(periods, period)
+	}
+	sort.Ints(periods)
+	for i, period := range periods {
+		values := data.MAs[period]
+		if len(values) == 0 {
+			continue
+		}
+		maLine, err := plotter.NewLine(definedXYs(values))
+		if err != nil {
+			return err
+		}
+		maLine.Color = maColors[i%len(maColors)]
+		maLine.Dashes = []vg.Length{vg.Points(4), vg.Points(2)}
+		p.Add(maLine)
+		p.Legend.Add("MA"+strconv.Itoa(period), maLine)
+	}
+
+	for _, action := range []agent.Action{
+		agent.ActionBuySmall,
+		agent.ActionBuyLarge,
+		agent.ActionSellSmall,
+		agent.ActionSellLarge,
+	} {
+		points := make(plotter.XYs, 0)
+		for i, a := range data.Actions {
+			if a != int(action) || i >= len(data.Prices) {
+				continue
+			}
+			points = append(points, plotter.XY{X: float64(i), Y: data.Prices[i]})
+		}
+		if len(points) == 0 {
+			continue
+		}
+		scatter, err := plotter.NewScatter(points)
+		if err != nil {
+			return err
+		}
+		scatter.GlyphStyle.Radius = vg.Points(2.5)
+		scatter.GlyphStyle.Color = actionColorRGBA(int(action))
+		p.Add(scatter)
+		p.Legend.Add(action.String(), scatter)
+	}
+
+	return p.Save(data.ChartWidth, 5*vg.Inch, filename)
+}
+
+// saveAllocationChart draws cash and equity value as stacked areas.
+func saveAllocationChart(cash, equity []float64, width vg.Length, filename string) error {
+	p := plot.New()
+	p.Title.Text = "Allocation"
+	p.X.Label.Text = "t"
+	p.Y.Label.Text = "value"
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	zero := make([]float64, len(cash))
+	total := make([]float64, len(cash))
+	for i := range cash {
+		total[i] = cash[i]
+		if i < len(equity) {
+			total[i] += equity[i]
+		}
+	}
+
+	cashArea, err := newArea(zero, cash, color.NRGBA{R: 188, G: 189, B: 34, A: 160})
+	if err != nil {
+		return err
+	}
+	equityArea, err := newArea(cash, total, color.NRGBA{R: 31, G: 119, B: 180, A: 160})
+	if err != nil {
+		return err
+	}
+	p.Add(cashArea, equityArea)
+	p.Legend.Add("Cash", cashArea)
+	p.Legend.Add("Equity", equityArea)
+
+	return p.Save(width, 3*vg.Inch, filename)
+}
+
+// saveDrawdownChart draws the drawdown series as a filled area below zero.
+func saveDrawdownChart(drawdown []float64, width vg.Length, filename string) error {
+	p := plot.New()
+	p.Title.Text = "Drawdown"
+	p.X.Label.Text = "t"
+	p.Y.Label.Text = "drawdown"
+
+	area, err := newArea(make([]float64, len(drawdown)), drawdown, color.NRGBA{R: 214, G: 39, B: 40, A: 110})
+	if err != nil {
+		return err
+	}
+	p.Add(area)
+
+	return p.Save(width, 3*vg.Inch, filename)
+}
+
+// newArea returns a filled polygon between the lower and upper series.
+func newArea(lower, upper []float64, fill color.Color) (*plotter.Polygon, error) {
+	n := len(upper)
+	if len(lower) < n {
+		n = len(lower)
+	}
+	xys := make(plotter.XYs, 0, 2*n)
+	for i := 0; i < n; i++ {
+		xys = append(xys, plotter.XY{X: float64(i), Y: upper[i]})
+	}
+	for i := n - 1; i >= 0; i-- {
+		xys = append(xys, plotter.XY{X: float64(i), Y: lower[i]})
+	}
+	poly, err := plotter.NewPolygon(xys)
+	if err != nil {
+		return nil, err
+	}
+	poly.Color = fill
+	poly.LineStyle.Width = 0
+	return poly, nil
+}
+
+// definedXYs returns the points of values that are not NaN, at their index.
+func definedXYs(values []float64) plotter.XYs {
+	xys := make(plotter.XYs, 0, len(values))
+	for i, v := range values {
+		if !math.IsNaN(v) {
+			xys = append(xys, plotter.XY{X: float64(i), Y: v})
+		}
+	}
+	return xys
+}
+
+// seriesXYs converts a series to points, starting the x axis at offset.
+func seriesXYs(values []float64, offset int) plotter.XYs {
+	xys := make(plotter.XYs, len(values))
+	for i, v := range values {
+		xys[i].X = float64(offset + i)
+		xys[i].Y = v
+	}
+	return xys
+}
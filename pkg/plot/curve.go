@@ -0,0 +1,75 @@
+package plot
+
+import (
+	"fmt"
+	"image/color"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// SaveLearningCurve writes the reward of every training episode, in order, into a
+// chart of its components stacked around zero: the return of the positions above
+// or below, the trade penalty (zero or negative) below, and the total reward as a
+// line. Episodes are smoothed by a trailing mean of window episodes (1 plots them
+// raw), so whether the penalty dominates learning shows through the noise.
+func SaveLearningCurve(rewards, penalties []float64, window int, filename string) error {
+	if len(rewards) == 0 || len(penalties) != len(rewards) {
+		return fmt.Errorf("invalid input sizes for plot")
+	}
+	rewards, penalties = trailingMean(rewards, window), trailingMean(penalties, window)
+	zero := make([]float64, len(rewards))
+	returns := make([]float64, len(rewards))
+	for i := range rewards {
+		returns[i] = rewards[i] - penalties[i]
+	}
+
+	p := plot.New()
+	p.Title.Text = "Learning curve"
+	p.X.Label.Text = "episode"
+	p.Y.Label.Text = "reward"
+	if window > 1 {
+		p.Y.Label.Text = fmt.Sprintf("reward (mean of %d episodes)", window)
+	}
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	returnArea, err := newArea(zero, returns, color.NRGBA{R: 31, G: 119, B: 180, A: 140})
+	if err != nil {
+		return err
+	}
+	penaltyArea, err := newArea(zero, penalties, color.NRGBA{R: 214, G: 39, B: 40, A: 140})
+	if err != nil {
+		return err
+	}
+	total, err := plotter.NewLine(seriesXYs(rewards, 0))
+	if err != nil {
+		return err
+	}
+	total.Color = color.RGBA{A: 255}
+	p.Add(plotter.NewGrid(), returnArea, penaltyArea, total)
+	p.Legend.Add("Return", returnArea)
+	p.Legend.Add("Trade penalty", penaltyArea)
+	p.Legend.Add("Reward", total)
+
+	return p.Save(12*vg.Inch, 5*vg.Inch, filename)
+}
+
+// trailingMean returns the mean of every value with the window-1 values before it,
+// or of all values before it early on.
+func trailingMean(values []float64, window int) []float64 {
+	if window <= 1 {
+		return values
+	}
+	means := make([]float64, len(values))
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= window {
+			sum -= values[i-window]
+		}
+		means[i] = sum / float64(min(i+1, window))
+	}
+	return means
+}
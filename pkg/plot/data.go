@@ -1,11 +1,12 @@
 package plot
 
 import (
-	"encoding/csv"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 	"strconv"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
 )
 
 // ActionData represents action information for saving to CSV.
@@ -24,33 +25,11 @@ func SaveSeriesData(prices []float64, portfolioSeries []float64, actions []int,
 	return SaveSeriesDataToFile(prices, portfolioSeries, actions, actionData, "data/series.csv")
 }
 
-// SaveSeriesDataToFile saves all series data to a specified CSV file.
+// SaveSeriesDataToFile saves all series data to a specified file.
+// The format (CSV, JSON, or Parquet) is selected by the file extension.
 // The portfolioSeries should contain portfolio values (cash + price * shares) for each time step.
 func SaveSeriesDataToFile(prices []float64, portfolioSeries []float64, actions []int, actionData []ActionData, filename string) error {
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(filename)
-	if dir != "" && dir != "." {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", dir, err)
-		}
-	}
-
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// Write header
-	header := []string{"time", "price", "portfolio_value", "action", "action_name", "amount_bought", "amount_sold", "cash", "shares", "commission"}
-	if err := writer.Write(header); err != nil {
-		return fmt.Errorf("failed to write header: %w", err)
-	}
-
-	// Write data - ensure all arrays have the same length
+	// Ensure all arrays have the same length
 	maxLen := len(prices)
 	if len(portfolioSeries) > maxLen {
 		maxLen = len(portfolioSeries)
@@ -62,6 +41,9 @@ func SaveSeriesDataToFile(prices []float64, portfolioSeries []float64, actions [
 		maxLen = len(actionData)
 	}
 
+	records := make([][]string, 0, maxLen+1)
+	records = append(records, []string{"time", "price", "portfolio_value", "action", "action_name", "amount_bought", "amount_sold", "cash", "shares", "commission"})
+
 	for i := 0; i < maxLen; i++ {
 		price := 0.0
 		if i < len(prices) {
@@ -91,7 +73,7 @@ func SaveSeriesDataToFile(prices []float64, portfolioSeries []float64, actions [
 			commission = actionData[i].Commission
 		}
 
-		record := []string{
+		records = append(records, []string{
 			strconv.Itoa(i),
 			strconv.FormatFloat(price, 'f', 6, 64),
 			strconv.FormatFloat(portfolioValue, 'f', 6, 64),
@@ -102,71 +84,70 @@ func SaveSeriesDataToFile(prices []float64, portfolioSeries []float64, actions [
 			strconv.FormatFloat(cash, 'f', 6, 64),
 			strconv.FormatFloat(shares, 'f', 6, 64),
 			strconv.FormatFloat(commission, 'f', 6, 64),
-		}
-		if err := writer.Write(record); err != nil {
-			return fmt.Errorf("failed to write record: %w", err)
-		}
+		})
 	}
 
-	return writer.Error()
+	return data.WriteTable(filename, records)
 }
 
 // SaveQMatrixData saves the Q-matrix to CSV in data directory.
 func SaveQMatrixData(Q [][]float64) error {
-	// Create data directory if it doesn't exist
-	dir := "data"
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", dir, err)
-	}
+	return SaveQMatrixDataToFile(Q, "data/q_matrix.csv")
+}
 
-	filepath := filepath.Join(dir, "q_matrix.csv")
-	file, err := os.Create(filepath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+// SaveQMatrixDataToFile saves the Q-matrix to a specified file.
+// The format (CSV, JSON, Parquet, or binary for .bin) is selected by the file extension.
+// Text formats round values to 6 decimals; the binary format is exact and much faster.
+func SaveQMatrixDataToFile(Q [][]float64, filename string) error {
+	if IsBinaryFile(filename) {
+		return writeBinaryFile(filename, func(w io.Writer) error {
+			return agent.WriteQTableBinary(w, Q)
+		})
 	}
-	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	records := make([][]string, 0, len(Q)+1)
 
-	// Write header: action indices
+	// Header: action indices
 	header := make([]string, len(Q[0])+1)
 	header[0] = "state"
 	for i := 0; i < len(Q[0]); i++ {
 		header[i+1] = "action_" + strconv.Itoa(i)
 	}
-	if err := writer.Write(header); err != nil {
-		return fmt.Errorf("failed to write header: %w", err)
-	}
+	records = append(records, header)
 
-	// Write each state row
+	// One row per state
 	for state, row := range Q {
 		record := make([]string, len(row)+1)
 		record[0] = strconv.Itoa(state)
 		for i, v := range row {
 			record[i+1] = strconv.FormatFloat(v, 'f', 6, 64)
 		}
-		if err := writer.Write(record); err != nil {
-			return fmt.Errorf("failed to write row for state %d: %w", state, err)
-		}
+		records = append(records, record)
 	}
 
-	return writer.Error()
+	return data.WriteTable(filename, records)
 }
 
 // LoadQMatrixData loads the Q-matrix from data/q_matrix.csv.
 func LoadQMatrixData() ([][]float64, error) {
-	filepath := "data/q_matrix.csv"
-	file, err := os.Open(filepath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+	return LoadQMatrixDataFromFile("data/q_matrix.csv")
+}
+
+// LoadQMatrixDataFromFile loads the Q-matrix from a specified CSV, JSON, Parquet, or binary (.bin) file.
+func LoadQMatrixDataFromFile(filename string) ([][]float64, error) {
+	if IsBinaryFile(filename) {
+		var Q [][]float64
+		err := readBinaryFile(filename, func(r io.Reader) error {
+			var err error
+			Q, err = agent.ReadQTableBinary(r)
+			return err
+		})
+		return Q, err
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	records, err := data.ReadTable(filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV: %w", err)
+		return nil, err
 	}
 
 	if len(records) < 2 {
@@ -200,56 +181,73 @@ func LoadQMatrixData() ([][]float64, error) {
 	return Q, nil
 }
 
+// Series holds the columns of a saved series file.
+type Series struct {
+	Prices          []float64
+	PortfolioValues []float64 // cash + price * shares
+	Actions         []int
+	ActionData      []ActionData
+}
+
 // LoadSeriesData loads series data from data/series.csv.
 // Returns prices, portfolio values (cash + price * shares), and actions.
-// The new columns (action_name, amount_bought, amount_sold) are ignored for backward compatibility.
 func LoadSeriesData() ([]float64, []float64, []int, error) {
-	filepath := "data/series.csv"
-	file, err := os.Open(filepath)
+	series, err := LoadSeriesDataFromFile("data/series.csv")
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, nil, nil, err
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	reader.Comment = '#'
+	return series.Prices, series.PortfolioValues, series.Actions, nil
+}
 
-	records, err := reader.ReadAll()
+// LoadSeriesDataFromFile loads all series columns from a specified CSV, JSON, or Parquet file.
+// Files written before the action detail columns existed are accepted; the
+// missing details are left as zero values.
+func LoadSeriesDataFromFile(filename string) (*Series, error) {
+	records, err := data.ReadTable(filename)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to read CSV: %w", err)
+		return nil, err
 	}
 
 	if len(records) < 2 {
-		return nil, nil, nil, fmt.Errorf("insufficient data in file")
+		return nil, fmt.Errorf("insufficient data in file")
 	}
 
 	// Skip header
-	var prices []float64
-	var portfolioSeries []float64
-	var actions []int
-
+	series := &Series{}
 	for i := 1; i < len(records); i++ {
-		if len(records[i]) < 4 {
+		record := records[i]
+		if len(record) < 4 {
 			continue
 		}
 
-		price, err := strconv.ParseFloat(records[i][1], 64)
+		price, err := strconv.ParseFloat(record[1], 64)
 		if err != nil {
 			continue
 		}
-		portfolioValue, err := strconv.ParseFloat(records[i][2], 64)
+		portfolioValue, err := strconv.ParseFloat(record[2], 64)
 		if err != nil {
 			continue
 		}
-		action, err := strconv.Atoi(records[i][3])
+		action, err := strconv.Atoi(record[3])
 		if err != nil {
 			continue
 		}
 
-		prices = append(prices, price)
-		portfolioSeries = append(portfolioSeries, portfolioValue)
-		actions = append(actions, action)
+		var details ActionData
+		if len(record) >= 10 {
+			details.ActionName = record[4]
+			details.AmountBought, _ = strconv.ParseFloat(record[5], 64)
+			details.AmountSold, _ = strconv.ParseFloat(record[6], 64)
+			details.Cash, _ = strconv.ParseFloat(record[7], 64)
+			details.Shares, _ = strconv.ParseFloat(record[8], 64)
+			details.Commission, _ = strconv.ParseFloat(record[9], 64)
+		}
+
+		series.Prices = append(series.Prices, price)
+		series.PortfolioValues = append(series.PortfolioValues, portfolioValue)
+		series.Actions = append(series.Actions, action)
+		series.ActionData = append(series.ActionData, details)
 	}
 
-	return prices, portfolioSeries, actions, nil
+	return series, nil
 }
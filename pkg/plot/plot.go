@@ -98,3 +98,81 @@ func actionColorRGBA(action int) color.RGBA {
 		return color.RGBA{R: 170, G: 170, B: 170, A: 255}
 	}
 }
+
+// equityColors is the palette used for overlaid equity curves.
+var equityColors = []color.RGBA{
+	{R: 31, G: 119, B: 180, A: 255},
+	{R: 255, G: 127, B: 14, A: 255},
+	{R: 44, G: 160, B: 44, A: 255},
+	{R: 214, G: 39, B: 40, A: 255},
+	{R: 148, G: 103, B: 189, A: 255},
+	{R: 140, G: 86, B: 75, A: 255},
+	{R: 227, G: 119, B: 194, A: 255},
+	{R: 127, G: 127, B: 127, A: 255},
+}
+
+// SaveEquityCurves writes several portfolio value series into one chart with a legend.
+func SaveEquityCurves(names []string, curves [][]float64, filename string) error {
+	if len(names) == 0 || len(names) != len(curves) {
+		return fmt.Errorf("invalid input sizes for plot")
+	}
+
+	p := plot.New()
+	p.Title.Text = "Equity curves"
+	p.X.Label.Text = "t"
+	p.Y.Label.Text = "portfolio value"
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	for i, curve := range curves {
+		xys := make(plotter.XYs, len(curve))
+		for j, v := range curve {
+			xys[j].X = float64(j)
+			xys[j].Y = v
+		}
+		line, err := plotter.NewLine(xys)
+		if err != nil {
+			return err
+		}
+		line.Color = equityColors[i%len(equityColors)]
+		p.Add(line)
+		p.Legend.Add(names[i], line)
+	}
+
+	return p.Save(12*vg.Inch, 5*vg.Inch, filename)
+}
+
+// SaveCostSensitivity writes the total return against the commission rate into one
+// chart, with a line for every slippage level. returns are indexed by slippage, then
+// commission.
+func SaveCostSensitivity(commissions, slippages []float64, returns [][]float64, filename string) error {
+	if len(commissions) == 0 || len(slippages) == 0 || len(returns) != len(slippages) {
+		return fmt.Errorf("invalid input sizes for plot")
+	}
+
+	p := plot.New()
+	p.Title.Text = "Return vs trading costs"
+	p.X.Label.Text = "commission %"
+	p.Y.Label.Text = "total return %"
+	p.Legend.Top = true
+
+	for i, slippage := range slippages {
+		if len(returns[i]) != len(commissions) {
+			return fmt.Errorf("invalid input sizes for plot")
+		}
+		xys := make(plotter.XYs, len(commissions))
+		for j, c := range commissions {
+			xys[j] = plotter.XY{X: c * 100, Y: returns[i][j] * 100}
+		}
+		line, points, err := plotter.NewLinePoints(xys)
+		if err != nil {
+			return err
+		}
+		line.Color = equityColors[i%len(equityColors)]
+		points.Color = line.Color
+		p.Add(line, points)
+		p.Legend.Add(fmt.Sprintf("slippage %g%%", slippage*100), line, points)
+	}
+
+	return p.Save(9*vg.Inch, 5*vg.Inch, filename)
+}
@@ -0,0 +1,168 @@
+package registry
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+)
+
+// Names of the built-in components, used when a config leaves a name empty.
+const (
+	DefaultEnv    = "market"
+	DefaultAgent  = "q-learning"
+	DefaultPolicy = "epsilon-greedy"
+	DefaultReward = "log-return"
+)
+
+func init() {
+	Envs.Register(DefaultEnv, newMarketEnv)
+	Agents.Register(DefaultAgent, newQLearningAgent)
+	Policies.Register(DefaultPolicy, newEpsilonGreedyPolicy)
+	Rewards.Register(DefaultReward, func(Params) (env.RewardFunc, error) { return env.CalculateReward, nil })
+	Rewards.Register("simple-return", func(Params) (env.RewardFunc, error) { return env.SimpleReturnReward, nil })
+}
+
+// newMarketEnv builds env.MarketEnv; params: min_start_idx (default 0: the encoder's
+// warm-up), max_weight
+// (maximum share of the portfolio in the asset, default 0: no limit), vol_target
+// (annualized volatility buy sizes are scaled to, default 0: off), vol_window
+// (default env.DefaultVolWindow), sizing (fixed or kelly, default fixed),
+// kelly_window (default env.DefaultKellyWindow), lots (tax-lot accounting: none,
+// fifo, or lifo, default none), and tax_rate (tax drag on the reward of realized
+// gains with lots, default 0), min_trade_weight and min_trade_value (minimum trade
+// size as a fraction of the portfolio value and in cash, default 0: off), and
+// commission_min and commission_max (floor and cap on a trade's commission, default
+// 0: none).
+func newMarketEnv(config EnvConfig) (env.Environment, error) {
+	minStartIdx, err := config.Params.Int("min_start_idx", 0)
+	if err != nil {
+		return nil, err
+	}
+	maxWeight, err := config.Params.Float("max_weight", 0)
+	if err != nil {
+		return nil, err
+	}
+	if maxWeight < 0 || maxWeight > 1 {
+		return nil, fmt.Errorf("parameter max_weight must be in [0, 1], got %g", maxWeight)
+	}
+	volTarget, err := config.Params.Float("vol_target", 0)
+	if err != nil {
+		return nil, err
+	}
+	volWindow, err := config.Params.Int("vol_window", env.DefaultVolWindow)
+	if err != nil {
+		return nil, err
+	}
+	sizing, err := env.ParseSizing(config.Params["sizing"])
+	if err != nil {
+		return nil, fmt.Errorf("parameter sizing: %w", err)
+	}
+	kellyWindow, err := config.Params.Int("kelly_window", env.DefaultKellyWindow)
+	if err != nil {
+		return nil, err
+	}
+	lots, err := env.ParseLotMethod(config.Params["lots"])
+	if err != nil {
+		return nil, fmt.Errorf("parameter lots: %w", err)
+	}
+	taxRate, err := config.Params.Float("tax_rate", 0)
+	if err != nil {
+		return nil, err
+	}
+	if taxRate < 0 || taxRate > 1 {
+		return nil, fmt.Errorf("parameter tax_rate must be in [0, 1], got %g", taxRate)
+	}
+	if taxRate > 0 && lots == env.LotNone {
+		return nil, fmt.Errorf("parameter tax_rate needs lots fifo or lifo")
+	}
+	minTradeWeight, err := config.Params.Float("min_trade_weight", 0)
+	if err != nil {
+		return nil, err
+	}
+	minTradeValue, err := config.Params.Float("min_trade_value", 0)
+	if err != nil {
+		return nil, err
+	}
+	if minTradeWeight < 0 || minTradeValue < 0 {
+		return nil, fmt.Errorf("parameters min_trade_weight and min_trade_value must not be negative")
+	}
+	commissionMin, err := config.Params.Float("commission_min", 0)
+	if err != nil {
+		return nil, err
+	}
+	commissionMax, err := config.Params.Float("commission_max", 0)
+	if err != nil {
+		return nil, err
+	}
+	if commissionMin < 0 || commissionMax < 0 || commissionMax > 0 && commissionMax < commissionMin {
+		return nil, fmt.Errorf("parameters commission_min and commission_max must not be negative, with commission_max at least commission_min")
+	}
+	return env.NewMarketEnv(env.MarketConfig{
+		Prices:         config.Prices,
+		InitialCash:    config.InitialCash,
+		MinStartIdx:    minStartIdx,
+		Commission:     config.Commission,
+		Reward:         config.Reward,
+		Encoder:        config.Encoder,
+		MaxWeight:      maxWeight,
+		VolTarget:      volTarget,
+		VolWindow:      volWindow,
+		Sizing:         sizing,
+		KellyWindow:    kellyWindow,
+		FX:             config.FX,
+		Lots:           lots,
+		TaxRate:        taxRate,
+		MinTradeWeight: minTradeWeight,
+		MinTradeValue:  minTradeValue,
+		CommissionMin:  commissionMin,
+		CommissionMax:  commissionMax,
+		Halted:         config.Halted,
+		Clock:          config.Clock,
+	}), nil
+}
+
+// newQLearningAgent builds agent.QLearningAgent; params: alpha (0.1), gamma (0.95),
+// gamma_days (0: gamma discounts per step, else per that many calendar days of the
+// prices' clock).
+func newQLearningAgent(config AgentConfig) (agent.Agent, error) {
+	alpha, err := config.Params.Float("alpha", 0.1)
+	if err != nil {
+		return nil, err
+	}
+	gamma, err := config.Params.Float("gamma", 0.95)
+	if err != nil {
+		return nil, err
+	}
+	if alpha <= 0 || alpha > 1 || gamma < 0 || gamma > 1 {
+		return nil, fmt.Errorf("alpha must be in (0, 1] and gamma in [0, 1], got %g and %g", alpha, gamma)
+	}
+	gammaDays, err := config.Params.Float("gamma_days", 0)
+	if err != nil {
+		return nil, err
+	}
+	if gammaDays < 0 {
+		return nil, fmt.Errorf("parameter gamma_days must not be negative, got %g", gammaDays)
+	}
+	learner := agent.NewQLearningAgent(config.Q, config.Policy, alpha, gamma)
+	learner.Period = time.Duration(gammaDays * float64(24*time.Hour))
+	return learner, nil
+}
+
+// newEpsilonGreedyPolicy builds agent.EpsilonGreedyPolicy, or EpsilonGreedyValuePolicy
+// for tables other than agent.QTable; params: epsilon (0.1).
+func newEpsilonGreedyPolicy(Q agent.ValueFunction, rng *rand.Rand, params Params) (agent.Policy, error) {
+	epsilon, err := params.Float("epsilon", 0.1)
+	if err != nil {
+		return nil, err
+	}
+	if epsilon < 0 || epsilon > 1 {
+		return nil, fmt.Errorf("epsilon must be in [0, 1], got %g", epsilon)
+	}
+	if dense, ok := Q.(*agent.QTable); ok {
+		return agent.NewEpsilonGreedyPolicy(dense.Q, epsilon, rng), nil
+	}
+	return agent.NewEpsilonGreedyValuePolicy(Q, epsilon, rng), nil
+}
@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"gopkg.in/yaml.v3"
+)
+
+// Component selects a registered component and its parameters.
+type Component struct {
+	Name   string `yaml:"name"`
+	Params Params `yaml:"params"`
+}
+
+// Config selects the components of a training run, e.g.:
+//
+//	plugins: [./boltzmann.so]
+//	agent:   {name: q-learning, params: {alpha: 0.05, gamma: 0.99}}
+//	policy:  {name: epsilon-greedy, params: {epsilon: 0.2}}
+//	reward:  {name: simple-return}
+//	constraints: {max_equity_weight: 0.8, min_cash_buffer: 0.05}
+//
+// Omitted components use the built-in defaults. The constraints, if any, are
+// enforced on the trades of market environments (see env.Constraints).
+type Config struct {
+	Plugins     []string        `yaml:"plugins"`
+	Env         Component       `yaml:"env"`
+	Agent       Component       `yaml:"agent"`
+	Policy      Component       `yaml:"policy"`
+	Reward      Component       `yaml:"reward"`
+	Constraints env.Constraints `yaml:"constraints"`
+}
+
+// DefaultConfig returns the built-in components with default parameters.
+func DefaultConfig() *Config {
+	c := &Config{}
+	c.setDefaults()
+	return c
+}
+
+// LoadConfig reads a YAML config file and loads its plugins.
+func LoadConfig(filename string) (*Config, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	var c Config
+	if err := yaml.Unmarshal(content, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", filename, err)
+	}
+	if err := c.Constraints.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid constraints in config %s: %w", filename, err)
+	}
+	for _, path := range c.Plugins {
+		if err := LoadPlugin(path); err != nil {
+			return nil, err
+		}
+	}
+	c.setDefaults()
+	return &c, nil
+}
+
+func (c *Config) setDefaults() {
+	for _, d := range []struct {
+		component *Component
+		name      string
+	}{
+		{&c.Env, DefaultEnv},
+		{&c.Agent, DefaultAgent},
+		{&c.Policy, DefaultPolicy},
+		{&c.Reward, DefaultReward},
+	} {
+		if d.component.Name == "" {
+			d.component.Name = d.name
+		}
+		if d.component.Params == nil {
+			d.component.Params = Params{}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Package registry lets packages add environments, agents, policies, and reward
+// functions that cmd/train can instantiate by name from a config file.
+//
+// A plugin registers its factories in an init function:
+//
+//	func init() {
+//		registry.Policies.Register("boltzmann", newBoltzmannPolicy)
+//	}
+//
+// and is linked in with a blank import in a custom main, or built with
+// go build -buildmode=plugin and loaded by cmd/train -plugin.
+package registry
+
+import (
+	"fmt"
+	"math/rand"
+	"plugin"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// Params are the free-form settings of a component in the config file.
+type Params map[string]string
+
+// Float returns a float parameter, or def when it is not set.
+func (p Params) Float(key string, def float64) (float64, error) {
+	v, ok := p[key]
+	if !ok || v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parameter %s: invalid number %q", key, v)
+	}
+	return f, nil
+}
+
+// Int returns an integer parameter, or def when it is not set.
+func (p Params) Int(key string, def int) (int, error) {
+	v, ok := p[key]
+	if !ok || v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("parameter %s: invalid integer %q", key, v)
+	}
+	return n, nil
+}
+
+// EnvConfig is passed to environment factories.
+type EnvConfig struct {
+	Prices      []float64
+	InitialCash float64
+	Commission  float64
+	Reward      env.RewardFunc
+	Encoder     state.Encoder // Nil selects the environment's default encoder
+	FX          []float64     // Rates converting the prices into the base currency; nil if quoted in it
+	Halted      []bool        // Prices at which trading is halted; nil without halts
+	Clock       *data.Clock   // Timestamps of the prices; nil if undated
+	Params      Params
+}
+
+// AgentConfig is passed to agent factories.
+type AgentConfig struct {
+	Q      agent.ValueFunction
+	Policy agent.Policy
+	Params Params
+}
+
+// Factory signatures for each kind of component.
+type (
+	EnvFactory    func(config EnvConfig) (env.Environment, error)
+	AgentFactory  func(config AgentConfig) (agent.Agent, error)
+	PolicyFactory func(Q agent.ValueFunction, rng *rand.Rand, params Params) (agent.Policy, error)
+	RewardFactory func(params Params) (env.RewardFunc, error)
+)
+
+// Registry maps names to factories of one kind. It is safe for concurrent use.
+type Registry[F any] struct {
+	kind      string
+	mu        sync.RWMutex
+	factories map[string]F
+}
+
+// The registries of each kind of component.
+var (
+	Envs     = newRegistry[EnvFactory]("environment")
+	Agents   = newRegistry[AgentFactory]("agent")
+	Policies = newRegistry[PolicyFactory]("policy")
+	Rewards  = newRegistry[RewardFactory]("reward")
+)
+
+func newRegistry[F any](kind string) *Registry[F] {
+	return &Registry[F]{kind: kind, factories: make(map[string]F)}
+}
+
+// Register adds a factory under name. It panics if the name is empty or taken, as
+// registration happens in init functions.
+func (r *Registry[F]) Register(name string, factory F) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == "" {
+		panic(fmt.Sprintf("registry: empty %s name", r.kind))
+	}
+	if _, ok := r.factories[name]; ok {
+		panic(fmt.Sprintf("registry: %s %q registered twice", r.kind, name))
+	}
+	r.factories[name] = factory
+}
+
+// Lookup returns the factory registered under name.
+func (r *Registry[F]) Lookup(name string) (F, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	factory, ok := r.factories[name]
+	if !ok {
+		var zero F
+		return zero, fmt.Errorf("unknown %s %q (registered: %v)", r.kind, name, r.namesLocked())
+	}
+	return factory, nil
+}
+
+// Names returns the registered names in sorted order.
+func (r *Registry[F]) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.namesLocked()
+}
+
+func (r *Registry[F]) namesLocked() []string {
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadPlugin opens a Go plugin built with -buildmode=plugin; its init functions
+// register its components.
+func LoadPlugin(path string) error {
+	if _, err := plugin.Open(path); err != nil {
+		return fmt.Errorf("failed to load plugin %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,1117 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: rlportfolio/v1/rlportfolio.proto
+
+// Services mirroring the Go env.Environment and agent.Agent interfaces, so external
+// components (e.g. a Python notebook) can drive the Go market environment or query
+// and train the Go Q-learning agent. Regenerate the Go code with `make proto`.
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// State is the encoded market state (see pkg/state).
+type State struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`                                   // Encoded state index (0 to num_states-1)
+	MaState       int32                  `protobuf:"varint,2,opt,name=ma_state,json=maState,proto3" json:"ma_state,omitempty"`                // Moving average ordering state (0-5039)
+	MaDivergence  int32                  `protobuf:"varint,3,opt,name=ma_divergence,json=maDivergence,proto3" json:"ma_divergence,omitempty"` // 0=converging, 1=neutral, 2=diverging
+	CashCat       int32                  `protobuf:"varint,4,opt,name=cash_cat,json=cashCat,proto3" json:"cash_cat,omitempty"`                // 0=none, 1=medium, 2=high
+	SharesCat     int32                  `protobuf:"varint,5,opt,name=shares_cat,json=sharesCat,proto3" json:"shares_cat,omitempty"`          // 0=none, 1=medium, 2=high
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *State) Reset() {
+	*x = State{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *State) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*State) ProtoMessage() {}
+
+func (x *State) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use State.ProtoReflect.Descriptor instead.
+func (*State) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *State) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *State) GetMaState() int32 {
+	if x != nil {
+		return x.MaState
+	}
+	return 0
+}
+
+func (x *State) GetMaDivergence() int32 {
+	if x != nil {
+		return x.MaDivergence
+	}
+	return 0
+}
+
+func (x *State) GetCashCat() int32 {
+	if x != nil {
+		return x.CashCat
+	}
+	return 0
+}
+
+func (x *State) GetSharesCat() int32 {
+	if x != nil {
+		return x.SharesCat
+	}
+	return 0
+}
+
+type Transition struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         *State                 `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	Action        int32                  `protobuf:"varint,2,opt,name=action,proto3" json:"action,omitempty"`
+	Reward        float64                `protobuf:"fixed64,3,opt,name=reward,proto3" json:"reward,omitempty"`
+	NextState     *State                 `protobuf:"bytes,4,opt,name=next_state,json=nextState,proto3" json:"next_state,omitempty"`
+	Done          bool                   `protobuf:"varint,5,opt,name=done,proto3" json:"done,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transition) Reset() {
+	*x = Transition{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transition) ProtoMessage() {}
+
+func (x *Transition) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transition.ProtoReflect.Descriptor instead.
+func (*Transition) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Transition) GetState() *State {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+func (x *Transition) GetAction() int32 {
+	if x != nil {
+		return x.Action
+	}
+	return 0
+}
+
+func (x *Transition) GetReward() float64 {
+	if x != nil {
+		return x.Reward
+	}
+	return 0
+}
+
+func (x *Transition) GetNextState() *State {
+	if x != nil {
+		return x.NextState
+	}
+	return nil
+}
+
+func (x *Transition) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+type CreateEnvRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Prices        []float64              `protobuf:"fixed64,1,rep,packed,name=prices,proto3" json:"prices,omitempty"`                        // Oldest first
+	InitialCash   float64                `protobuf:"fixed64,2,opt,name=initial_cash,json=initialCash,proto3" json:"initial_cash,omitempty"`  // Default 10000
+	Commission    float64                `protobuf:"fixed64,3,opt,name=commission,proto3" json:"commission,omitempty"`                       // Default 0.002
+	MinStartIdx   int32                  `protobuf:"varint,4,opt,name=min_start_idx,json=minStartIdx,proto3" json:"min_start_idx,omitempty"` // Default 0: the encoder's warm-up
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateEnvRequest) Reset() {
+	*x = CreateEnvRequest{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateEnvRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateEnvRequest) ProtoMessage() {}
+
+func (x *CreateEnvRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateEnvRequest.ProtoReflect.Descriptor instead.
+func (*CreateEnvRequest) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateEnvRequest) GetPrices() []float64 {
+	if x != nil {
+		return x.Prices
+	}
+	return nil
+}
+
+func (x *CreateEnvRequest) GetInitialCash() float64 {
+	if x != nil {
+		return x.InitialCash
+	}
+	return 0
+}
+
+func (x *CreateEnvRequest) GetCommission() float64 {
+	if x != nil {
+		return x.Commission
+	}
+	return 0
+}
+
+func (x *CreateEnvRequest) GetMinStartIdx() int32 {
+	if x != nil {
+		return x.MinStartIdx
+	}
+	return 0
+}
+
+type CreateEnvResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EnvId         string                 `protobuf:"bytes,1,opt,name=env_id,json=envId,proto3" json:"env_id,omitempty"`
+	NumStates     int32                  `protobuf:"varint,2,opt,name=num_states,json=numStates,proto3" json:"num_states,omitempty"`
+	NumActions    int32                  `protobuf:"varint,3,opt,name=num_actions,json=numActions,proto3" json:"num_actions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateEnvResponse) Reset() {
+	*x = CreateEnvResponse{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateEnvResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateEnvResponse) ProtoMessage() {}
+
+func (x *CreateEnvResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateEnvResponse.ProtoReflect.Descriptor instead.
+func (*CreateEnvResponse) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CreateEnvResponse) GetEnvId() string {
+	if x != nil {
+		return x.EnvId
+	}
+	return ""
+}
+
+func (x *CreateEnvResponse) GetNumStates() int32 {
+	if x != nil {
+		return x.NumStates
+	}
+	return 0
+}
+
+func (x *CreateEnvResponse) GetNumActions() int32 {
+	if x != nil {
+		return x.NumActions
+	}
+	return 0
+}
+
+type ResetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EnvId         string                 `protobuf:"bytes,1,opt,name=env_id,json=envId,proto3" json:"env_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResetRequest) Reset() {
+	*x = ResetRequest{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetRequest) ProtoMessage() {}
+
+func (x *ResetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetRequest.ProtoReflect.Descriptor instead.
+func (*ResetRequest) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ResetRequest) GetEnvId() string {
+	if x != nil {
+		return x.EnvId
+	}
+	return ""
+}
+
+type ResetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         *State                 `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	Portfolio     *Portfolio             `protobuf:"bytes,2,opt,name=portfolio,proto3" json:"portfolio,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResetResponse) Reset() {
+	*x = ResetResponse{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetResponse) ProtoMessage() {}
+
+func (x *ResetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetResponse.ProtoReflect.Descriptor instead.
+func (*ResetResponse) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ResetResponse) GetState() *State {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+func (x *ResetResponse) GetPortfolio() *Portfolio {
+	if x != nil {
+		return x.Portfolio
+	}
+	return nil
+}
+
+type StepRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EnvId         string                 `protobuf:"bytes,1,opt,name=env_id,json=envId,proto3" json:"env_id,omitempty"`
+	Action        int32                  `protobuf:"varint,2,opt,name=action,proto3" json:"action,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StepRequest) Reset() {
+	*x = StepRequest{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StepRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StepRequest) ProtoMessage() {}
+
+func (x *StepRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StepRequest.ProtoReflect.Descriptor instead.
+func (*StepRequest) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StepRequest) GetEnvId() string {
+	if x != nil {
+		return x.EnvId
+	}
+	return ""
+}
+
+func (x *StepRequest) GetAction() int32 {
+	if x != nil {
+		return x.Action
+	}
+	return 0
+}
+
+type StepResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	NextState     *State                 `protobuf:"bytes,1,opt,name=next_state,json=nextState,proto3" json:"next_state,omitempty"`
+	Reward        float64                `protobuf:"fixed64,2,opt,name=reward,proto3" json:"reward,omitempty"`
+	Done          bool                   `protobuf:"varint,3,opt,name=done,proto3" json:"done,omitempty"`
+	Portfolio     *Portfolio             `protobuf:"bytes,4,opt,name=portfolio,proto3" json:"portfolio,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StepResponse) Reset() {
+	*x = StepResponse{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StepResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StepResponse) ProtoMessage() {}
+
+func (x *StepResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StepResponse.ProtoReflect.Descriptor instead.
+func (*StepResponse) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *StepResponse) GetNextState() *State {
+	if x != nil {
+		return x.NextState
+	}
+	return nil
+}
+
+func (x *StepResponse) GetReward() float64 {
+	if x != nil {
+		return x.Reward
+	}
+	return 0
+}
+
+func (x *StepResponse) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+func (x *StepResponse) GetPortfolio() *Portfolio {
+	if x != nil {
+		return x.Portfolio
+	}
+	return nil
+}
+
+type Portfolio struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cash          float64                `protobuf:"fixed64,1,opt,name=cash,proto3" json:"cash,omitempty"`
+	Shares        float64                `protobuf:"fixed64,2,opt,name=shares,proto3" json:"shares,omitempty"`
+	Price         float64                `protobuf:"fixed64,3,opt,name=price,proto3" json:"price,omitempty"`
+	Value         float64                `protobuf:"fixed64,4,opt,name=value,proto3" json:"value,omitempty"`
+	PriceIdx      int32                  `protobuf:"varint,5,opt,name=price_idx,json=priceIdx,proto3" json:"price_idx,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Portfolio) Reset() {
+	*x = Portfolio{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Portfolio) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Portfolio) ProtoMessage() {}
+
+func (x *Portfolio) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Portfolio.ProtoReflect.Descriptor instead.
+func (*Portfolio) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Portfolio) GetCash() float64 {
+	if x != nil {
+		return x.Cash
+	}
+	return 0
+}
+
+func (x *Portfolio) GetShares() float64 {
+	if x != nil {
+		return x.Shares
+	}
+	return 0
+}
+
+func (x *Portfolio) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Portfolio) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *Portfolio) GetPriceIdx() int32 {
+	if x != nil {
+		return x.PriceIdx
+	}
+	return 0
+}
+
+type CloseEnvRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EnvId         string                 `protobuf:"bytes,1,opt,name=env_id,json=envId,proto3" json:"env_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CloseEnvRequest) Reset() {
+	*x = CloseEnvRequest{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CloseEnvRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CloseEnvRequest) ProtoMessage() {}
+
+func (x *CloseEnvRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CloseEnvRequest.ProtoReflect.Descriptor instead.
+func (*CloseEnvRequest) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CloseEnvRequest) GetEnvId() string {
+	if x != nil {
+		return x.EnvId
+	}
+	return ""
+}
+
+type CloseEnvResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CloseEnvResponse) Reset() {
+	*x = CloseEnvResponse{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CloseEnvResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CloseEnvResponse) ProtoMessage() {}
+
+func (x *CloseEnvResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CloseEnvResponse.ProtoReflect.Descriptor instead.
+func (*CloseEnvResponse) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{10}
+}
+
+type ActRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         *State                 `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	Explore       bool                   `protobuf:"varint,2,opt,name=explore,proto3" json:"explore,omitempty"` // Use the epsilon-greedy policy instead of the greedy one
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ActRequest) Reset() {
+	*x = ActRequest{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActRequest) ProtoMessage() {}
+
+func (x *ActRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActRequest.ProtoReflect.Descriptor instead.
+func (*ActRequest) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ActRequest) GetState() *State {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+func (x *ActRequest) GetExplore() bool {
+	if x != nil {
+		return x.Explore
+	}
+	return false
+}
+
+type ActResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Action        int32                  `protobuf:"varint,1,opt,name=action,proto3" json:"action,omitempty"`
+	QValues       []float64              `protobuf:"fixed64,2,rep,packed,name=q_values,json=qValues,proto3" json:"q_values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ActResponse) Reset() {
+	*x = ActResponse{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActResponse) ProtoMessage() {}
+
+func (x *ActResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActResponse.ProtoReflect.Descriptor instead.
+func (*ActResponse) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ActResponse) GetAction() int32 {
+	if x != nil {
+		return x.Action
+	}
+	return 0
+}
+
+func (x *ActResponse) GetQValues() []float64 {
+	if x != nil {
+		return x.QValues
+	}
+	return nil
+}
+
+type LearnRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transitions   []*Transition          `protobuf:"bytes,1,rep,name=transitions,proto3" json:"transitions,omitempty"` // Applied in order
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LearnRequest) Reset() {
+	*x = LearnRequest{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LearnRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LearnRequest) ProtoMessage() {}
+
+func (x *LearnRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LearnRequest.ProtoReflect.Descriptor instead.
+func (*LearnRequest) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *LearnRequest) GetTransitions() []*Transition {
+	if x != nil {
+		return x.Transitions
+	}
+	return nil
+}
+
+type LearnResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Learned       int32                  `protobuf:"varint,1,opt,name=learned,proto3" json:"learned,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LearnResponse) Reset() {
+	*x = LearnResponse{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LearnResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LearnResponse) ProtoMessage() {}
+
+func (x *LearnResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LearnResponse.ProtoReflect.Descriptor instead.
+func (*LearnResponse) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *LearnResponse) GetLearned() int32 {
+	if x != nil {
+		return x.Learned
+	}
+	return 0
+}
+
+type SaveModelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"` // Model bundle path on the server
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SaveModelRequest) Reset() {
+	*x = SaveModelRequest{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SaveModelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SaveModelRequest) ProtoMessage() {}
+
+func (x *SaveModelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SaveModelRequest.ProtoReflect.Descriptor instead.
+func (*SaveModelRequest) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *SaveModelRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type SaveModelResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SaveModelResponse) Reset() {
+	*x = SaveModelResponse{}
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SaveModelResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SaveModelResponse) ProtoMessage() {}
+
+func (x *SaveModelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rlportfolio_v1_rlportfolio_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SaveModelResponse.ProtoReflect.Descriptor instead.
+func (*SaveModelResponse) Descriptor() ([]byte, []int) {
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP(), []int{16}
+}
+
+var File_rlportfolio_v1_rlportfolio_proto protoreflect.FileDescriptor
+
+const file_rlportfolio_v1_rlportfolio_proto_rawDesc = "" +
+	"\n" +
+	" rlportfolio/v1/rlportfolio.proto\x12\x0erlportfolio.v1\"\x97\x01\n" +
+	"\x05State\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x19\n" +
+	"\bma_state\x18\x02 \x01(\x05R\amaState\x12#\n" +
+	"\rma_divergence\x18\x03 \x01(\x05R\fmaDivergence\x12\x19\n" +
+	"\bcash_cat\x18\x04 \x01(\x05R\acashCat\x12\x1d\n" +
+	"\n" +
+	"shares_cat\x18\x05 \x01(\x05R\tsharesCat\"\xb3\x01\n" +
+	"\n" +
+	"Transition\x12+\n" +
+	"\x05state\x18\x01 \x01(\v2\x15.rlportfolio.v1.StateR\x05state\x12\x16\n" +
+	"\x06action\x18\x02 \x01(\x05R\x06action\x12\x16\n" +
+	"\x06reward\x18\x03 \x01(\x01R\x06reward\x124\n" +
+	"\n" +
+	"next_state\x18\x04 \x01(\v2\x15.rlportfolio.v1.StateR\tnextState\x12\x12\n" +
+	"\x04done\x18\x05 \x01(\bR\x04done\"\x91\x01\n" +
+	"\x10CreateEnvRequest\x12\x16\n" +
+	"\x06prices\x18\x01 \x03(\x01R\x06prices\x12!\n" +
+	"\finitial_cash\x18\x02 \x01(\x01R\vinitialCash\x12\x1e\n" +
+	"\n" +
+	"commission\x18\x03 \x01(\x01R\n" +
+	"commission\x12\"\n" +
+	"\rmin_start_idx\x18\x04 \x01(\x05R\vminStartIdx\"j\n" +
+	"\x11CreateEnvResponse\x12\x15\n" +
+	"\x06env_id\x18\x01 \x01(\tR\x05envId\x12\x1d\n" +
+	"\n" +
+	"num_states\x18\x02 \x01(\x05R\tnumStates\x12\x1f\n" +
+	"\vnum_actions\x18\x03 \x01(\x05R\n" +
+	"numActions\"%\n" +
+	"\fResetRequest\x12\x15\n" +
+	"\x06env_id\x18\x01 \x01(\tR\x05envId\"u\n" +
+	"\rResetResponse\x12+\n" +
+	"\x05state\x18\x01 \x01(\v2\x15.rlportfolio.v1.StateR\x05state\x127\n" +
+	"\tportfolio\x18\x02 \x01(\v2\x19.rlportfolio.v1.PortfolioR\tportfolio\"<\n" +
+	"\vStepRequest\x12\x15\n" +
+	"\x06env_id\x18\x01 \x01(\tR\x05envId\x12\x16\n" +
+	"\x06action\x18\x02 \x01(\x05R\x06action\"\xa9\x01\n" +
+	"\fStepResponse\x124\n" +
+	"\n" +
+	"next_state\x18\x01 \x01(\v2\x15.rlportfolio.v1.StateR\tnextState\x12\x16\n" +
+	"\x06reward\x18\x02 \x01(\x01R\x06reward\x12\x12\n" +
+	"\x04done\x18\x03 \x01(\bR\x04done\x127\n" +
+	"\tportfolio\x18\x04 \x01(\v2\x19.rlportfolio.v1.PortfolioR\tportfolio\"\x80\x01\n" +
+	"\tPortfolio\x12\x12\n" +
+	"\x04cash\x18\x01 \x01(\x01R\x04cash\x12\x16\n" +
+	"\x06shares\x18\x02 \x01(\x01R\x06shares\x12\x14\n" +
+	"\x05price\x18\x03 \x01(\x01R\x05price\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\x01R\x05value\x12\x1b\n" +
+	"\tprice_idx\x18\x05 \x01(\x05R\bpriceIdx\"(\n" +
+	"\x0fCloseEnvRequest\x12\x15\n" +
+	"\x06env_id\x18\x01 \x01(\tR\x05envId\"\x12\n" +
+	"\x10CloseEnvResponse\"S\n" +
+	"\n" +
+	"ActRequest\x12+\n" +
+	"\x05state\x18\x01 \x01(\v2\x15.rlportfolio.v1.StateR\x05state\x12\x18\n" +
+	"\aexplore\x18\x02 \x01(\bR\aexplore\"@\n" +
+	"\vActResponse\x12\x16\n" +
+	"\x06action\x18\x01 \x01(\x05R\x06action\x12\x19\n" +
+	"\bq_values\x18\x02 \x03(\x01R\aqValues\"L\n" +
+	"\fLearnRequest\x12<\n" +
+	"\vtransitions\x18\x01 \x03(\v2\x1a.rlportfolio.v1.TransitionR\vtransitions\")\n" +
+	"\rLearnResponse\x12\x18\n" +
+	"\alearned\x18\x01 \x01(\x05R\alearned\"&\n" +
+	"\x10SaveModelRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"\x13\n" +
+	"\x11SaveModelResponse2\xb7\x02\n" +
+	"\vEnvironment\x12P\n" +
+	"\tCreateEnv\x12 .rlportfolio.v1.CreateEnvRequest\x1a!.rlportfolio.v1.CreateEnvResponse\x12D\n" +
+	"\x05Reset\x12\x1c.rlportfolio.v1.ResetRequest\x1a\x1d.rlportfolio.v1.ResetResponse\x12A\n" +
+	"\x04Step\x12\x1b.rlportfolio.v1.StepRequest\x1a\x1c.rlportfolio.v1.StepResponse\x12M\n" +
+	"\bCloseEnv\x12\x1f.rlportfolio.v1.CloseEnvRequest\x1a .rlportfolio.v1.CloseEnvResponse2\xdf\x01\n" +
+	"\x05Agent\x12>\n" +
+	"\x03Act\x12\x1a.rlportfolio.v1.ActRequest\x1a\x1b.rlportfolio.v1.ActResponse\x12D\n" +
+	"\x05Learn\x12\x1c.rlportfolio.v1.LearnRequest\x1a\x1d.rlportfolio.v1.LearnResponse\x12P\n" +
+	"\tSaveModel\x12 .rlportfolio.v1.SaveModelRequest\x1a!.rlportfolio.v1.SaveModelResponseB0Z.github.com/kasaderos/rLportfolio/pkg/rpc/pb;pbb\x06proto3"
+
+var (
+	file_rlportfolio_v1_rlportfolio_proto_rawDescOnce sync.Once
+	file_rlportfolio_v1_rlportfolio_proto_rawDescData []byte
+)
+
+func file_rlportfolio_v1_rlportfolio_proto_rawDescGZIP() []byte {
+	file_rlportfolio_v1_rlportfolio_proto_rawDescOnce.Do(func() {
+		file_rlportfolio_v1_rlportfolio_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_rlportfolio_v1_rlportfolio_proto_rawDesc), len(file_rlportfolio_v1_rlportfolio_proto_rawDesc)))
+	})
+	return file_rlportfolio_v1_rlportfolio_proto_rawDescData
+}
+
+var file_rlportfolio_v1_rlportfolio_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_rlportfolio_v1_rlportfolio_proto_goTypes = []any{
+	(*State)(nil),             // 0: rlportfolio.v1.State
+	(*Transition)(nil),        // 1: rlportfolio.v1.Transition
+	(*CreateEnvRequest)(nil),  // 2: rlportfolio.v1.CreateEnvRequest
+	(*CreateEnvResponse)(nil), // 3: rlportfolio.v1.CreateEnvResponse
+	(*ResetRequest)(nil),      // 4: rlportfolio.v1.ResetRequest
+	(*ResetResponse)(nil),     // 5: rlportfolio.v1.ResetResponse
+	(*StepRequest)(nil),       // 6: rlportfolio.v1.StepRequest
+	(*StepResponse)(nil),      // 7: rlportfolio.v1.StepResponse
+	(*Portfolio)(nil),         // 8: rlportfolio.v1.Portfolio
+	(*CloseEnvRequest)(nil),   // 9: rlportfolio.v1.CloseEnvRequest
+	(*CloseEnvResponse)(nil),  // 10: rlportfolio.v1.CloseEnvResponse
+	(*ActRequest)(nil),        // 11: rlportfolio.v1.ActRequest
+	(*ActResponse)(nil),       // 12: rlportfolio.v1.ActResponse
+	(*LearnRequest)(nil),      // 13: rlportfolio.v1.LearnRequest
+	(*LearnResponse)(nil),     // 14: rlportfolio.v1.LearnResponse
+	(*SaveModelRequest)(nil),  // 15: rlportfolio.v1.SaveModelRequest
+	(*SaveModelResponse)(nil), // 16: rlportfolio.v1.SaveModelResponse
+}
+var file_rlportfolio_v1_rlportfolio_proto_depIdxs = []int32{
+	0,  // 0: rlportfolio.v1.Transition.state:type_name -> rlportfolio.v1.State
+	0,  // 1: rlportfolio.v1.Transition.next_state:type_name -> rlportfolio.v1.State
+	0,  // 2: rlportfolio.v1.ResetResponse.state:type_name -> rlportfolio.v1.State
+	8,  // 3: rlportfolio.v1.ResetResponse.portfolio:type_name -> rlportfolio.v1.Portfolio
+	0,  // 4: rlportfolio.v1.StepResponse.next_state:type_name -> rlportfolio.v1.State
+	8,  // 5: rlportfolio.v1.StepResponse.portfolio:type_name -> rlportfolio.v1.Portfolio
+	0,  // 6: rlportfolio.v1.ActRequest.state:type_name -> rlportfolio.v1.State
+	1,  // 7: rlportfolio.v1.LearnRequest.transitions:type_name -> rlportfolio.v1.Transition
+	2,  // 8: rlportfolio.v1.Environment.CreateEnv:input_type -> rlportfolio.v1.CreateEnvRequest
+	4,  // 9: rlportfolio.v1.Environment.Reset:input_type -> rlportfolio.v1.ResetRequest
+	6,  // 10: rlportfolio.v1.Environment.Step:input_type -> rlportfolio.v1.StepRequest
+	9,  // 11: rlportfolio.v1.Environment.CloseEnv:input_type -> rlportfolio.v1.CloseEnvRequest
+	11, // 12: rlportfolio.v1.Agent.Act:input_type -> rlportfolio.v1.ActRequest
+	13, // 13: rlportfolio.v1.Agent.Learn:input_type -> rlportfolio.v1.LearnRequest
+	15, // 14: rlportfolio.v1.Agent.SaveModel:input_type -> rlportfolio.v1.SaveModelRequest
+	3,  // 15: rlportfolio.v1.Environment.CreateEnv:output_type -> rlportfolio.v1.CreateEnvResponse
+	5,  // 16: rlportfolio.v1.Environment.Reset:output_type -> rlportfolio.v1.ResetResponse
+	7,  // 17: rlportfolio.v1.Environment.Step:output_type -> rlportfolio.v1.StepResponse
+	10, // 18: rlportfolio.v1.Environment.CloseEnv:output_type -> rlportfolio.v1.CloseEnvResponse
+	12, // 19: rlportfolio.v1.Agent.Act:output_type -> rlportfolio.v1.ActResponse
+	14, // 20: rlportfolio.v1.Agent.Learn:output_type -> rlportfolio.v1.LearnResponse
+	16, // 21: rlportfolio.v1.Agent.SaveModel:output_type -> rlportfolio.v1.SaveModelResponse
+	15, // [15:22] is the sub-list for method output_type
+	8,  // [8:15] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_rlportfolio_v1_rlportfolio_proto_init() }
+func file_rlportfolio_v1_rlportfolio_proto_init() {
+	if File_rlportfolio_v1_rlportfolio_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_rlportfolio_v1_rlportfolio_proto_rawDesc), len(file_rlportfolio_v1_rlportfolio_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_rlportfolio_v1_rlportfolio_proto_goTypes,
+		DependencyIndexes: file_rlportfolio_v1_rlportfolio_proto_depIdxs,
+		MessageInfos:      file_rlportfolio_v1_rlportfolio_proto_msgTypes,
+	}.Build()
+	File_rlportfolio_v1_rlportfolio_proto = out.File
+	file_rlportfolio_v1_rlportfolio_proto_goTypes = nil
+	file_rlportfolio_v1_rlportfolio_proto_depIdxs = nil
+}
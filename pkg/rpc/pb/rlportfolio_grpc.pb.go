@@ -0,0 +1,425 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: rlportfolio/v1/rlportfolio.proto
+
+// Services mirroring the Go env.Environment and agent.Agent interfaces, so external
+// components (e.g. a Python notebook) can drive the Go market environment or query
+// and train the Go Q-learning agent. Regenerate the Go code with `make proto`.
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Environment_CreateEnv_FullMethodName = "/rlportfolio.v1.Environment/CreateEnv"
+	Environment_Reset_FullMethodName     = "/rlportfolio.v1.Environment/Reset"
+	Environment_Step_FullMethodName      = "/rlportfolio.v1.Environment/Step"
+	Environment_CloseEnv_FullMethodName  = "/rlportfolio.v1.Environment/CloseEnv"
+)
+
+// EnvironmentClient is the client API for Environment service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Environment hosts market environments; every environment has its own ID.
+type EnvironmentClient interface {
+	CreateEnv(ctx context.Context, in *CreateEnvRequest, opts ...grpc.CallOption) (*CreateEnvResponse, error)
+	Reset(ctx context.Context, in *ResetRequest, opts ...grpc.CallOption) (*ResetResponse, error)
+	Step(ctx context.Context, in *StepRequest, opts ...grpc.CallOption) (*StepResponse, error)
+	CloseEnv(ctx context.Context, in *CloseEnvRequest, opts ...grpc.CallOption) (*CloseEnvResponse, error)
+}
+
+type environmentClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEnvironmentClient(cc grpc.ClientConnInterface) EnvironmentClient {
+	return &environmentClient{cc}
+}
+
+func (c *environmentClient) CreateEnv(ctx context.Context, in *CreateEnvRequest, opts ...grpc.CallOption) (*CreateEnvResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateEnvResponse)
+	err := c.cc.Invoke(ctx, Environment_CreateEnv_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *environmentClient) Reset(ctx context.Context, in *ResetRequest, opts ...grpc.CallOption) (*ResetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResetResponse)
+	err := c.cc.Invoke(ctx, Environment_Reset_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *environmentClient) Step(ctx context.Context, in *StepRequest, opts ...grpc.CallOption) (*StepResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StepResponse)
+	err := c.cc.Invoke(ctx, Environment_Step_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *environmentClient) CloseEnv(ctx context.Context, in *CloseEnvRequest, opts ...grpc.CallOption) (*CloseEnvResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CloseEnvResponse)
+	err := c.cc.Invoke(ctx, Environment_CloseEnv_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EnvironmentServer is the server API for Environment service.
+// All implementations must embed UnimplementedEnvironmentServer
+// for forward compatibility.
+//
+// Environment hosts market environments; every environment has its own ID.
+type EnvironmentServer interface {
+	CreateEnv(context.Context, *CreateEnvRequest) (*CreateEnvResponse, error)
+	Reset(context.Context, *ResetRequest) (*ResetResponse, error)
+	Step(context.Context, *StepRequest) (*StepResponse, error)
+	CloseEnv(context.Context, *CloseEnvRequest) (*CloseEnvResponse, error)
+	mustEmbedUnimplementedEnvironmentServer()
+}
+
+// UnimplementedEnvironmentServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEnvironmentServer struct{}
+
+func (UnimplementedEnvironmentServer) CreateEnv(context.Context, *CreateEnvRequest) (*CreateEnvResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateEnv not implemented")
+}
+func (UnimplementedEnvironmentServer) Reset(context.Context, *ResetRequest) (*ResetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reset not implemented")
+}
+func (UnimplementedEnvironmentServer) Step(context.Context, *StepRequest) (*StepResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Step not implemented")
+}
+func (UnimplementedEnvironmentServer) CloseEnv(context.Context, *CloseEnvRequest) (*CloseEnvResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CloseEnv not implemented")
+}
+func (UnimplementedEnvironmentServer) mustEmbedUnimplementedEnvironmentServer() {}
+func (UnimplementedEnvironmentServer) testEmbeddedByValue()                     {}
+
+// UnsafeEnvironmentServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EnvironmentServer will
+// result in compilation errors.
+type UnsafeEnvironmentServer interface {
+	mustEmbedUnimplementedEnvironmentServer()
+}
+
+func RegisterEnvironmentServer(s grpc.ServiceRegistrar, srv EnvironmentServer) {
+	// If the following call pancis, it indicates UnimplementedEnvironmentServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Environment_ServiceDesc, srv)
+}
+
+func _Environment_CreateEnv_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateEnvRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvironmentServer).CreateEnv(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Environment_CreateEnv_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvironmentServer).CreateEnv(ctx, req.(*CreateEnvRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Environment_Reset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvironmentServer).Reset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Environment_Reset_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvironmentServer).Reset(ctx, req.(*ResetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Environment_Step_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StepRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvironmentServer).Step(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Environment_Step_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvironmentServer).Step(ctx, req.(*StepRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Environment_CloseEnv_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CloseEnvRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnvironmentServer).CloseEnv(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Environment_CloseEnv_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnvironmentServer).CloseEnv(ctx, req.(*CloseEnvRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Environment_ServiceDesc is the grpc.ServiceDesc for Environment service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Environment_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rlportfolio.v1.Environment",
+	HandlerType: (*EnvironmentServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateEnv",
+			Handler:    _Environment_CreateEnv_Handler,
+		},
+		{
+			MethodName: "Reset",
+			Handler:    _Environment_Reset_Handler,
+		},
+		{
+			MethodName: "Step",
+			Handler:    _Environment_Step_Handler,
+		},
+		{
+			MethodName: "CloseEnv",
+			Handler:    _Environment_CloseEnv_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "rlportfolio/v1/rlportfolio.proto",
+}
+
+const (
+	Agent_Act_FullMethodName       = "/rlportfolio.v1.Agent/Act"
+	Agent_Learn_FullMethodName     = "/rlportfolio.v1.Agent/Learn"
+	Agent_SaveModel_FullMethodName = "/rlportfolio.v1.Agent/SaveModel"
+)
+
+// AgentClient is the client API for Agent service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Agent serves one Q-learning agent.
+type AgentClient interface {
+	Act(ctx context.Context, in *ActRequest, opts ...grpc.CallOption) (*ActResponse, error)
+	Learn(ctx context.Context, in *LearnRequest, opts ...grpc.CallOption) (*LearnResponse, error)
+	SaveModel(ctx context.Context, in *SaveModelRequest, opts ...grpc.CallOption) (*SaveModelResponse, error)
+}
+
+type agentClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAgentClient(cc grpc.ClientConnInterface) AgentClient {
+	return &agentClient{cc}
+}
+
+func (c *agentClient) Act(ctx context.Context, in *ActRequest, opts ...grpc.CallOption) (*ActResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ActResponse)
+	err := c.cc.Invoke(ctx, Agent_Act_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentClient) Learn(ctx context.Context, in *LearnRequest, opts ...grpc.CallOption) (*LearnResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LearnResponse)
+	err := c.cc.Invoke(ctx, Agent_Learn_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentClient) SaveModel(ctx context.Context, in *SaveModelRequest, opts ...grpc.CallOption) (*SaveModelResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SaveModelResponse)
+	err := c.cc.Invoke(ctx, Agent_SaveModel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AgentServer is the server API for Agent service.
+// All implementations must embed UnimplementedAgentServer
+// for forward compatibility.
+//
+// Agent serves one Q-learning agent.
+type AgentServer interface {
+	Act(context.Context, *ActRequest) (*ActResponse, error)
+	Learn(context.Context, *LearnRequest) (*LearnResponse, error)
+	SaveModel(context.Context, *SaveModelRequest) (*SaveModelResponse, error)
+	mustEmbedUnimplementedAgentServer()
+}
+
+// UnimplementedAgentServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAgentServer struct{}
+
+func (UnimplementedAgentServer) Act(context.Context, *ActRequest) (*ActResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Act not implemented")
+}
+func (UnimplementedAgentServer) Learn(context.Context, *LearnRequest) (*LearnResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Learn not implemented")
+}
+func (UnimplementedAgentServer) SaveModel(context.Context, *SaveModelRequest) (*SaveModelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveModel not implemented")
+}
+func (UnimplementedAgentServer) mustEmbedUnimplementedAgentServer() {}
+func (UnimplementedAgentServer) testEmbeddedByValue()               {}
+
+// UnsafeAgentServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AgentServer will
+// result in compilation errors.
+type UnsafeAgentServer interface {
+	mustEmbedUnimplementedAgentServer()
+}
+
+func RegisterAgentServer(s grpc.ServiceRegistrar, srv AgentServer) {
+	// If the following call pancis, it indicates UnimplementedAgentServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Agent_ServiceDesc, srv)
+}
+
+func _Agent_Act_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ActRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServer).Act(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Agent_Act_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServer).Act(ctx, req.(*ActRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Agent_Learn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LearnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServer).Learn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Agent_Learn_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServer).Learn(ctx, req.(*LearnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Agent_SaveModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SaveModelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServer).SaveModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Agent_SaveModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServer).SaveModel(ctx, req.(*SaveModelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Agent_ServiceDesc is the grpc.ServiceDesc for Agent service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Agent_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rlportfolio.v1.Agent",
+	HandlerType: (*AgentServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Act",
+			Handler:    _Agent_Act_Handler,
+		},
+		{
+			MethodName: "Learn",
+			Handler:    _Agent_Learn_Handler,
+		},
+		{
+			MethodName: "SaveModel",
+			Handler:    _Agent_SaveModel_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "rlportfolio/v1/rlportfolio.proto",
+}
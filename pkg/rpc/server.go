@@ -0,0 +1,254 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+	mathrand "math/rand"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+	"github.com/kasaderos/rLportfolio/pkg/rpc/pb"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// Register registers both services on a gRPC server.
+func Register(s *grpc.Server, envs *EnvironmentService, agents *AgentService) {
+	pb.RegisterEnvironmentServer(s, envs)
+	pb.RegisterAgentServer(s, agents)
+}
+
+// EnvironmentService hosts market environments for remote clients.
+type EnvironmentService struct {
+	pb.UnimplementedEnvironmentServer
+
+	mu   sync.Mutex
+	envs map[string]*env.MarketEnv
+}
+
+// NewEnvironmentService creates an environment service without environments.
+func NewEnvironmentService() *EnvironmentService {
+	return &EnvironmentService{envs: make(map[string]*env.MarketEnv)}
+}
+
+// CreateEnv creates a market environment over the given prices.
+func (s *EnvironmentService) CreateEnv(ctx context.Context, req *pb.CreateEnvRequest) (*pb.CreateEnvResponse, error) {
+	for i, p := range req.Prices {
+		if p <= 0 || math.IsNaN(p) || math.IsInf(p, 0) {
+			return nil, status.Errorf(codes.InvalidArgument, "price %d is not a positive number", i)
+		}
+	}
+	// A zero MinStartIdx starts at the encoder's warm-up
+	marketEnv := env.NewMarketEnv(env.MarketConfig{
+		Prices:      req.Prices,
+		InitialCash: req.InitialCash,
+		MinStartIdx: int(req.MinStartIdx),
+		Commission:  req.Commission,
+	})
+	if len(req.Prices) < marketEnv.StartIdx()+2 {
+		return nil, status.Errorf(codes.InvalidArgument, "need at least %d prices, got %d", marketEnv.StartIdx()+2, len(req.Prices))
+	}
+
+	id := newID()
+	s.mu.Lock()
+	s.envs[id] = marketEnv
+	s.mu.Unlock()
+	return &pb.CreateEnvResponse{EnvId: id, NumStates: state.NumStates, NumActions: agent.NumActions}, nil
+}
+
+// Reset resets an environment and returns the initial state.
+func (s *EnvironmentService) Reset(ctx context.Context, req *pb.ResetRequest) (*pb.ResetResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	marketEnv, err := s.lookup(req.EnvId)
+	if err != nil {
+		return nil, err
+	}
+	st := marketEnv.Reset()
+	return &pb.ResetResponse{State: toProtoState(st), Portfolio: portfolio(marketEnv)}, nil
+}
+
+// Step executes an action in an environment.
+func (s *EnvironmentService) Step(ctx context.Context, req *pb.StepRequest) (*pb.StepResponse, error) {
+	action, err := toAction(req.Action)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	marketEnv, err := s.lookup(req.EnvId)
+	if err != nil {
+		return nil, err
+	}
+	next, reward, done := marketEnv.Step(action)
+	return &pb.StepResponse{
+		NextState: toProtoState(next),
+		Reward:    reward,
+		Done:      done,
+		Portfolio: portfolio(marketEnv),
+	}, nil
+}
+
+// CloseEnv removes an environment.
+func (s *EnvironmentService) CloseEnv(ctx context.Context, req *pb.CloseEnvRequest) (*pb.CloseEnvResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.lookup(req.EnvId); err != nil {
+		return nil, err
+	}
+	delete(s.envs, req.EnvId)
+	return &pb.CloseEnvResponse{}, nil
+}
+
+// lookup returns an environment; the caller holds s.mu.
+func (s *EnvironmentService) lookup(id string) (*env.MarketEnv, error) {
+	marketEnv, ok := s.envs[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown environment %q", id)
+	}
+	return marketEnv, nil
+}
+
+// AgentService serves one Q-learning agent. Learn updates the Q-table in place;
+// SaveModel writes it as a model bundle.
+type AgentService struct {
+	pb.UnimplementedAgentServer
+
+	mu       sync.Mutex
+	q        *agent.QTable
+	learner  *agent.QLearningAgent
+	greedy   *agent.GreedyPolicy
+	explorer *agent.EpsilonGreedyPolicy
+	training model.TrainingConfig
+}
+
+// NewAgentService creates an agent service over Q (e.g. a loaded bundle's Q-table or
+// agent.NewQTable for a fresh agent) with the given learning parameters.
+func NewAgentService(Q [][]float64, training model.TrainingConfig) *AgentService {
+	q := &agent.QTable{Q: Q}
+	explorer := agent.NewEpsilonGreedyPolicy(Q, training.Epsilon, mathrand.New(mathrand.NewSource(training.Seed)))
+	return &AgentService{
+		q:        q,
+		learner:  agent.NewQLearningAgent(q, explorer, training.Alpha, training.Gamma),
+		greedy:   agent.NewGreedyPolicy(Q),
+		explorer: explorer,
+		training: training,
+	}
+}
+
+// Act returns the action for a state.
+func (s *AgentService) Act(ctx context.Context, req *pb.ActRequest) (*pb.ActResponse, error) {
+	st, err := s.fromProtoState(req.State)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var action agent.Action
+	if req.Explore {
+		action = s.explorer.Act(st)
+	} else {
+		action = s.greedy.Act(st)
+	}
+	return &pb.ActResponse{
+		Action:  int32(action),
+		QValues: append([]float64(nil), s.q.Q[st.Index]...),
+	}, nil
+}
+
+// Learn applies Q-learning updates for the transitions in order.
+func (s *AgentService) Learn(ctx context.Context, req *pb.LearnRequest) (*pb.LearnResponse, error) {
+	transitions := make([]agent.Transition, len(req.Transitions))
+	for i, t := range req.Transitions {
+		st, err := s.fromProtoState(t.State)
+		if err != nil {
+			return nil, err
+		}
+		next, err := s.fromProtoState(t.NextState)
+		if err != nil {
+			return nil, err
+		}
+		action, err := toAction(t.Action)
+		if err != nil {
+			return nil, err
+		}
+		transitions[i] = agent.Transition{State: st, Action: action, Reward: t.Reward, NextState: next, Done: t.Done}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range transitions {
+		s.learner.Learn(t)
+	}
+	return &pb.LearnResponse{Learned: int32(len(transitions))}, nil
+}
+
+// SaveModel writes the current Q-table as a model bundle on the server.
+func (s *AgentService) SaveModel(ctx context.Context, req *pb.SaveModelRequest) (*pb.SaveModelResponse, error) {
+	if req.Path == "" {
+		return nil, status.Error(codes.InvalidArgument, "path is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := model.New(s.q.Q, state.NewMAEncoder(), s.training).Save(req.Path); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to save model: %v", err)
+	}
+	return &pb.SaveModelResponse{}, nil
+}
+
+// fromProtoState checks a state's index against the Q-table.
+func (s *AgentService) fromProtoState(p *pb.State) (state.State, error) {
+	if p == nil {
+		return state.State{}, status.Error(codes.InvalidArgument, "state is required")
+	}
+	if p.Index < 0 || int(p.Index) >= len(s.q.Q) {
+		return state.State{}, status.Errorf(codes.InvalidArgument, "state index %d out of range [0, %d)", p.Index, len(s.q.Q))
+	}
+	return state.State{
+		Index:        int(p.Index),
+		MAState:      int(p.MaState),
+		MADivergence: int(p.MaDivergence),
+		CashCat:      int(p.CashCat),
+		SharesCat:    int(p.SharesCat),
+	}, nil
+}
+
+func toProtoState(s state.State) *pb.State {
+	return &pb.State{
+		Index:        int32(s.Index),
+		MaState:      int32(s.MAState),
+		MaDivergence: int32(s.MADivergence),
+		CashCat:      int32(s.CashCat),
+		SharesCat:    int32(s.SharesCat),
+	}
+}
+
+func toAction(a int32) (agent.Action, error) {
+	if a < 0 || a >= agent.NumActions {
+		return 0, status.Errorf(codes.InvalidArgument, "action %d out of range [0, %d)", a, agent.NumActions)
+	}
+	return agent.Action(a), nil
+}
+
+func portfolio(marketEnv *env.MarketEnv) *pb.Portfolio {
+	return &pb.Portfolio{
+		Cash:     marketEnv.Cash(),
+		Shares:   marketEnv.Shares(),
+		Price:    marketEnv.CurrentPrice(),
+		Value:    marketEnv.PortfolioValue(),
+		PriceIdx: int32(marketEnv.CurrentIdx()),
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
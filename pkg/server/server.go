@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/model"
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// maxBodyBytes limits the size of request bodies (about 100k prices).
+const maxBodyBytes = 4 << 20
+
+// ActRequest is the body of POST /act. Prices are ordered oldest first; the decision
+// is made for the last one.
+type ActRequest struct {
+	Prices []float64 `json:"prices"`
+	Cash   float64   `json:"cash"`
+	Shares float64   `json:"shares"`
+}
+
+// ActResponse is the greedy decision for an ActRequest.
+type ActResponse struct {
+	Action     string             `json:"action"`
+	Fraction   float64            `json:"fraction"` // Fraction of cash (buys) or shares (sells) to trade
+	State      int                `json:"state"`
+	MAOrdering []string           `json:"ma_ordering,omitempty"`
+	Market     string             `json:"market,omitempty"` // Market component of encoders other than ma
+	Divergence string             `json:"divergence"`
+	ExpRet     string             `json:"expected_return,omitempty"` // Local approximation forecast, when the model uses one
+	MinDist    string             `json:"nearest_analogue,omitempty"`
+	Regime     string             `json:"regime,omitempty"` // Regime whose Q-table decided, when the model has one per regime
+	Cash       string             `json:"cash_position"`
+	Shares     string             `json:"shares_position"`
+	QValues    map[string]float64 `json:"q_values"`
+	Trained    bool               `json:"trained"` // False when the state was never visited in training
+}
+
+// ModelResponse is the body of GET /model.
+type ModelResponse struct {
+	Path      string `json:"path"`
+	NumStates int    `json:"num_states"`
+	model.Manifest
+}
+
+// Server serves a model bundle over HTTP.
+type Server struct {
+	bundle  *model.Bundle
+	path    string
+	started time.Time
+	mux     *http.ServeMux
+}
+
+// New creates a server for a loaded bundle; path is reported by GET /model.
+func New(bundle *model.Bundle, path string) *Server {
+	s := &Server{bundle: bundle, path: path, started: time.Now(), mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /act", s.handleAct)
+	s.mux.HandleFunc("GET /model", s.handleModel)
+	s.mux.HandleFunc("GET /health", s.handleHealth)
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleAct(w http.ResponseWriter, r *http.Request) {
+	var req ActRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	for i, p := range req.Prices {
+		if p <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("price %d is not positive", i))
+			return
+		}
+	}
+
+	decision, err := s.bundle.Decide(req.Prices, req.Cash, req.Shares)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, NewActResponse(decision, s.bundle))
+}
+
+// NewActResponse describes a decision of the bundle, using its encoder and action space.
+func NewActResponse(d model.Decision, bundle *model.Bundle) ActResponse {
+	resp := ActResponse{
+		Action:     d.Action.String(),
+		State:      d.State.Index,
+		Divergence: state.DivergenceName(d.State.MADivergence),
+		Cash:       state.PositionName(d.State.CashCat),
+		Shares:     state.PositionName(d.State.SharesCat),
+		QValues:    make(map[string]float64, len(d.QValues)),
+		Trained:    d.Trained,
+	}
+	if bundle.Encoder.Name == "ma" {
+		resp.MAOrdering = ma.OrderingNames(ma.DecodeMAState(d.State.MAState))
+	} else {
+		resp.Market = model.DescribeMarket(bundle.Encoder.Name, d.State.MAState, nil)
+	}
+	if _, ok := bundle.Encoder.Params["approx_m"]; ok {
+		resp.ExpRet = state.ExpRetName(d.State.ExpRetCat)
+		resp.MinDist = state.MinDistName(d.State.MinDistCat)
+	}
+	if spec, ok := bundle.Encoder.Params["regime_detector"]; ok {
+		if detector, err := state.ParseDetector(spec); err == nil {
+			resp.Regime = state.RegimeName(detector, d.State.Regime)
+		}
+	}
+	if int(d.Action) < len(bundle.Actions) {
+		resp.Fraction = bundle.Actions[d.Action].Fraction
+	}
+	for a, v := range d.QValues {
+		resp.QValues[agent.Action(a).String()] = v
+	}
+	return resp
+}
+
+func (s *Server) handleModel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, ModelResponse{
+		Path:      s.path,
+		NumStates: s.bundle.NumStates(),
+		Manifest:  s.bundle.Manifest,
+	})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":         "ok",
+		"uptime_seconds": int(time.Since(s.started).Seconds()),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
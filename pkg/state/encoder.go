@@ -0,0 +1,206 @@
+package state
+
+import (
+	"math"
+
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+)
+
+// Encoder computes the agent state from the price history and the portfolio position.
+type Encoder interface {
+	// Encode returns the state at price index idx for the given cash and shares holdings.
+	Encode(prices []float64, idx int, cash, shares float64) State
+	// NumStates returns the size of the state space produced by the encoder.
+	NumStates() int
+	// WarmUp returns the first price index with a full state, i.e. the history
+	// needed before the first decision. Environments start there.
+	WarmUp() int
+}
+
+// MAWarmUp is the first price index at which every moving average of ma.MAPeriods
+// is available.
+const MAWarmUp = 120
+
+// SeriesEncoder is an Encoder that can precompute data for one price series, so
+// that encoding every step of it is cheaper. Environments call ForSeries once.
+type SeriesEncoder interface {
+	Encoder
+	// ForSeries returns an encoder for prices; it encodes other series like the receiver.
+	ForSeries(prices []float64) Encoder
+}
+
+// MAEncoder encodes moving average ordering, MA convergence/divergence, and portfolio position.
+type MAEncoder struct {
+	// Lines are the six moving averages ordered with the price, which may mix types
+	// (see ma.ParseLines). Nil means the SMAs of ma.MAPeriods.
+	Lines []ma.Line
+	// Divergence holds data-driven thresholds on the ribbon width change (see
+	// ma.FitDivergence) replacing the fixed 1% threshold. Nil keeps the fixed one.
+	Divergence *ma.Buckets
+}
+
+// NewMAEncoder creates the default moving average state encoder.
+func NewMAEncoder() *MAEncoder {
+	return &MAEncoder{}
+}
+
+// NewMixedMAEncoder creates an encoder ordering the given moving averages, e.g. EMAs
+// for the short periods, which react faster than SMAs.
+func NewMixedMAEncoder(lines []ma.Line) (*MAEncoder, error) {
+	if err := ma.CheckLines(lines); err != nil {
+		return nil, err
+	}
+	return &MAEncoder{Lines: lines}, nil
+}
+
+// Encode computes the state at price index idx.
+func (e *MAEncoder) Encode(prices []float64, idx int, cash, shares float64) State {
+	if e.Lines != nil {
+		return e.encodeLines(prices, idx, cash, shares)
+	}
+
+	// Need at least 120 prices for all MAs to be available
+	if idx < MAWarmUp || idx >= len(prices) {
+		return NewState(0, MANeutral, 0, 0)
+	}
+
+	// Get moving average ordering state
+	maState := ma.GetMAStateForIndex(prices, idx)
+
+	// Get MA convergence/divergence state
+	maDivergence := ma.GetMADivergenceState(prices, idx)
+	if e.Divergence != nil {
+		maDivergence = ma.QuantileDivergence(*e.Divergence, ribbonChange(prices, idx))
+	}
+
+	// Get portfolio position categories
+	currentPrice := prices[idx]
+	portfolioValue := cash + shares*currentPrice
+	sharesValue := shares * currentPrice
+	cashCat := GetCashCategory(cash, portfolioValue)
+	sharesCat := GetSharesCategory(sharesValue, portfolioValue)
+
+	return NewState(maState, maDivergence, cashCat, sharesCat)
+}
+
+// encodeLines is Encode for custom lines. It computes the lines over prices[:idx+1]
+// on every call; ForSeries precomputes them once instead.
+func (e *MAEncoder) encodeLines(prices []float64, idx int, cash, shares float64) State {
+	if idx < 0 || idx >= len(prices) {
+		return NewState(0, MANeutral, 0, 0)
+	}
+	provider, err := ma.NewLinesProvider(prices[:idx+1], e.Lines)
+	if err != nil || idx < max(MAWarmUp, provider.WarmUp()) {
+		return NewState(0, MANeutral, 0, 0)
+	}
+	maDivergence := e.divergence(provider.Divergence(idx), provider.RibbonChange(idx))
+	return Compose(provider.State(idx), maDivergence, prices[idx], cash, shares)
+}
+
+// ribbonChange is ma.RibbonChanges at idx without precomputing the series.
+func ribbonChange(prices []float64, idx int) float64 {
+	if idx <= MAWarmUp {
+		return math.NaN()
+	}
+	return ma.RibbonWidth(prices, idx) - ma.RibbonWidth(prices, max(idx-10, MAWarmUp))
+}
+
+// Compose builds a state from its market component (MA ordering and divergence
+// states) and the portfolio position at currentPrice.
+func Compose(maState, maDivergence int, currentPrice, cash, shares float64) State {
+	portfolioValue := cash + shares*currentPrice
+	return NewState(
+		maState,
+		maDivergence,
+		GetCashCategory(cash, portfolioValue),
+		GetSharesCategory(shares*currentPrice, portfolioValue),
+	)
+}
+
+// NumStates returns the total number of states.
+func (e *MAEncoder) NumStates() int {
+	return NumStates
+}
+
+// WarmUp returns MAWarmUp, or the longest period of custom lines if longer.
+func (e *MAEncoder) WarmUp() int {
+	warmUp := MAWarmUp
+	for _, l := range e.Lines {
+		warmUp = max(warmUp, l.Period)
+	}
+	return warmUp
+}
+
+// ForSeries returns an encoder with the market component (MA ordering and
+// divergence) of every index of prices precomputed, since it does not depend on the
+// portfolio; only the cash and shares categories are computed per step.
+func (e *MAEncoder) ForSeries(prices []float64) Encoder {
+	enc := &seriesMAEncoder{
+		MAEncoder:    e,
+		prices:       prices,
+		start:        MAWarmUp,
+		maStates:     make([]uint16, len(prices)),
+		maDivergence: make([]uint8, len(prices)),
+	}
+	if e.Lines != nil {
+		provider, err := ma.NewLinesProvider(prices, e.Lines)
+		if err != nil {
+			return e
+		}
+		enc.start = max(enc.start, provider.WarmUp())
+		for idx := enc.start; idx < len(prices); idx++ {
+			enc.maStates[idx] = uint16(provider.State(idx))
+			enc.maDivergence[idx] = uint8(e.divergence(provider.Divergence(idx), provider.RibbonChange(idx)))
+		}
+		return enc
+	}
+
+	provider := ma.NewProvider(prices)
+	for idx := MAWarmUp; idx < len(prices); idx++ {
+		enc.maStates[idx] = uint16(provider.State(idx))
+		enc.maDivergence[idx] = uint8(e.divergence(provider.Divergence(idx), provider.RibbonChange(idx)))
+	}
+	return enc
+}
+
+// divergence returns fixed, the divergence with the fixed threshold, unless the
+// encoder has fitted thresholds for the ribbon width change.
+func (e *MAEncoder) divergence(fixed int, ribbonChange float64) int {
+	if e.Divergence == nil {
+		return fixed
+	}
+	return ma.QuantileDivergence(*e.Divergence, ribbonChange)
+}
+
+// seriesMAEncoder is an MAEncoder bound to one price series.
+type seriesMAEncoder struct {
+	*MAEncoder
+	prices       []float64
+	start        int      // First index with a market component
+	maStates     []uint16 // MA ordering state per index (0 before start)
+	maDivergence []uint8  // MA divergence state per index
+}
+
+// Encode computes the state at price index idx, like MAEncoder.Encode.
+func (e *seriesMAEncoder) Encode(prices []float64, idx int, cash, shares float64) State {
+	if !sameSeries(prices, e.prices) {
+		return e.MAEncoder.Encode(prices, idx, cash, shares)
+	}
+	if idx < e.start || idx >= len(prices) {
+		return NewState(0, MANeutral, 0, 0)
+	}
+	return Compose(int(e.maStates[idx]), int(e.maDivergence[idx]), prices[idx], cash, shares)
+}
+
+// ForSeries returns the receiver for its own series, so binding it again is free.
+func (e *seriesMAEncoder) ForSeries(prices []float64) Encoder {
+	if sameSeries(prices, e.prices) {
+		return e
+	}
+	return e.MAEncoder.ForSeries(prices)
+}
+
+// sameSeries reports whether a and b are the same slice.
+func sameSeries(a, b []float64) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
@@ -0,0 +1,118 @@
+package state
+
+import "math"
+
+const (
+	// Forecast confidence categories
+	ConfidenceLow           = iota // The neighbors disagree more than the forecast moves
+	ConfidenceMedium               // The forecast stands out from the noise
+	ConfidenceHigh                 // The neighbors agree on the forecast
+	NumConfidenceCategories = 3
+)
+
+// GetConfidenceCategory maps a forecast to a confidence category by its size relative
+// to the dispersion (standard deviation or IQR) of the values it averages: below 0.5
+// is low, below 1 medium, and above that, or without any dispersion, high.
+func GetConfidenceCategory(prediction, dispersion float64) int {
+	if dispersion <= 0 {
+		return ConfidenceHigh
+	}
+	ratio := math.Abs(prediction) / dispersion
+	if ratio < 0.5 {
+		return ConfidenceLow
+	} else if ratio < 1 {
+		return ConfidenceMedium
+	}
+	return ConfidenceHigh
+}
+
+// ConfidenceName returns a readable name for a forecast confidence category.
+func ConfidenceName(c int) string {
+	switch c {
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceMedium:
+		return "medium"
+	case ConfidenceHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// Expected return categories of the local approximation forecast
+	ExpRetDown          = iota // The forecast return is below -ExpRetThreshold
+	ExpRetFlat                 // The forecast return is within ±ExpRetThreshold
+	ExpRetUp                   // The forecast return is above ExpRetThreshold
+	NumExpRetCategories = 3
+
+	// ExpRetThreshold is the forecast return, 0.1% per step, beyond which the market
+	// is expected to move.
+	ExpRetThreshold = 0.001
+)
+
+const (
+	// Nearest-neighbor distance categories of the local approximation forecast
+	MinDistNear          = iota // The past holds a close analogue of the latest window
+	MinDistMedium               // The nearest analogue is loose
+	MinDistFar                  // No past window resembles the latest one
+	NumMinDistCategories = 3
+)
+
+// GetExpRetCategory maps a forecast return to an expected return category.
+func GetExpRetCategory(expRet float64) int {
+	if expRet < -ExpRetThreshold {
+		return ExpRetDown
+	} else if expRet <= ExpRetThreshold {
+		return ExpRetFlat
+	}
+	return ExpRetUp
+}
+
+// GetMinDistCategory maps the distance to the nearest neighbor, relative to the norm
+// of the query window so that it does not depend on the scale of the series, to a
+// category: below 0.5 is near, below 1 medium, and above that far.
+func GetMinDistCategory(minDist, queryNorm float64) int {
+	if queryNorm <= 0 {
+		if minDist <= 0 {
+			return MinDistNear
+		}
+		return MinDistFar
+	}
+	ratio := minDist / queryNorm
+	if ratio < 0.5 {
+		return MinDistNear
+	} else if ratio < 1 {
+		return MinDistMedium
+	}
+	return MinDistFar
+}
+
+// ExpRetName returns a readable name for an expected return category.
+func ExpRetName(c int) string {
+	switch c {
+	case ExpRetDown:
+		return "down"
+	case ExpRetFlat:
+		return "flat"
+	case ExpRetUp:
+		return "up"
+	default:
+		return "unknown"
+	}
+}
+
+// MinDistName returns a readable name for a nearest-neighbor distance category.
+func MinDistName(c int) string {
+	switch c {
+	case MinDistNear:
+		return "near"
+	case MinDistMedium:
+		return "medium"
+	case MinDistFar:
+		return "far"
+	default:
+		return "unknown"
+	}
+}
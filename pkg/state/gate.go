@@ -0,0 +1,253 @@
+package state
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+)
+
+// Detector classifies every index of a price series into one of NumRegimes market
+// regimes, using only the prices up to that index.
+type Detector interface {
+	Regimes(prices []float64) []int
+	NumRegimes() int
+	// String returns the detector as ParseDetector accepts it.
+	String() string
+}
+
+// Trend regimes of TrendDetector.
+const (
+	TrendUnknown = iota // Fewer than ma.RegimeSlow prices
+	TrendBullish        // MA50 above MA200, including golden crosses
+	TrendBearish        // MA50 at or below MA200, including death crosses
+	NumTrendRegimes
+)
+
+// TrendDetector detects the MA50/MA200 trend (see ma.Regime). Crosses count as the
+// side they cross to, so that no regime lasts a single price.
+type TrendDetector struct{}
+
+// Regimes returns the trend regime at every index of prices.
+func (TrendDetector) Regimes(prices []float64) []int {
+	regimes := make([]int, len(prices))
+	for idx, r := range ma.Regimes(prices) {
+		switch {
+		case r.IsBullish():
+			regimes[idx] = TrendBullish
+		case r.IsBearish():
+			regimes[idx] = TrendBearish
+		}
+	}
+	return regimes
+}
+
+// NumRegimes returns NumTrendRegimes.
+func (TrendDetector) NumRegimes() int {
+	return NumTrendRegimes
+}
+
+func (TrendDetector) String() string {
+	return "trend"
+}
+
+// DefaultVolWindow is the number of returns VolatilityDetector measures over by default.
+const DefaultVolWindow = 20
+
+// DefaultVolThresholds split annualized volatility into calm, normal, and turbulent.
+var DefaultVolThresholds = []float64{0.15, 0.30}
+
+// VolatilityDetector buckets the annualized volatility of recent returns: regime i
+// holds the volatilities between thresholds i-1 and i. Indices with fewer than two
+// returns are in regime 0.
+type VolatilityDetector struct {
+	Window     int       // Returns measured; 0 means DefaultVolWindow
+	Thresholds []float64 // Ascending; nil means DefaultVolThresholds
+}
+
+// buckets returns the volatility thresholds with the default applied.
+func (d VolatilityDetector) buckets() ma.Buckets {
+	if d.Thresholds == nil {
+		return ma.Buckets{Thresholds: DefaultVolThresholds}
+	}
+	return ma.Buckets{Thresholds: d.Thresholds}
+}
+
+// Regimes returns the volatility regime at every index of prices.
+func (d VolatilityDetector) Regimes(prices []float64) []int {
+	window := d.Window
+	if window < 2 {
+		window = DefaultVolWindow
+	}
+	buckets := d.buckets()
+	regimes := make([]int, len(prices))
+	for idx := 2; idx < len(prices); idx++ {
+		regimes[idx] = buckets.Bucket(annualizedVol(prices[max(idx-window, 0) : idx+1]))
+	}
+	return regimes
+}
+
+// annualizedVol returns the sample standard deviation of the simple returns of
+// prices, annualized like metrics.Compute (252 trading days).
+func annualizedVol(prices []float64) float64 {
+	n := len(prices) - 1
+	mean := 0.0
+	for i := 1; i < len(prices); i++ {
+		mean += prices[i]/prices[i-1] - 1
+	}
+	mean /= float64(n)
+	variance := 0.0
+	for i := 1; i < len(prices); i++ {
+		d := prices[i]/prices[i-1] - 1 - mean
+		variance += d * d
+	}
+	return math.Sqrt(variance / float64(n-1) * 252)
+}
+
+// NumRegimes returns the number of volatility buckets.
+func (d VolatilityDetector) NumRegimes() int {
+	return d.buckets().Len()
+}
+
+func (d VolatilityDetector) String() string {
+	window := d.Window
+	if window < 2 {
+		window = DefaultVolWindow
+	}
+	return fmt.Sprintf("vol:%d:%s", window, d.buckets())
+}
+
+// ParseDetector parses a regime detector: "trend", or "vol" with an optional window
+// and thresholds, e.g. "vol:20:0.15,0.3".
+func ParseDetector(s string) (Detector, error) {
+	name, rest, _ := strings.Cut(strings.TrimSpace(s), ":")
+	switch name {
+	case "trend":
+		if rest != "" {
+			return nil, fmt.Errorf("trend detector takes no parameters, got %q", rest)
+		}
+		return TrendDetector{}, nil
+	case "vol":
+		d := VolatilityDetector{}
+		if rest == "" {
+			return d, nil
+		}
+		window, thresholds, _ := strings.Cut(rest, ":")
+		w, err := strconv.Atoi(window)
+		if err != nil || w < 2 {
+			return nil, fmt.Errorf("invalid volatility window %q (need at least 2 returns)", window)
+		}
+		d.Window = w
+		if thresholds != "" {
+			buckets, err := ma.ParseBuckets(thresholds)
+			if err != nil {
+				return nil, fmt.Errorf("invalid volatility thresholds: %w", err)
+			}
+			d.Thresholds = buckets.Thresholds
+		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("unknown regime detector %q (use trend or vol)", s)
+	}
+}
+
+// GatedEncoder extends a base encoder with the market regime of a detector, so that
+// each regime gets its own Q-table: State.Regime holds the regime, and State.Index
+// indexes the tables stacked in regime order, TableStates rows per regime.
+type GatedEncoder struct {
+	Base     Encoder
+	Detector Detector
+}
+
+// NewGatedEncoder gates base by the regimes of detector.
+func NewGatedEncoder(base Encoder, detector Detector) *GatedEncoder {
+	return &GatedEncoder{Base: base, Detector: detector}
+}
+
+// Encode computes the base state at price index idx and adds the regime.
+func (e *GatedEncoder) Encode(prices []float64, idx int, cash, shares float64) State {
+	s := e.Base.Encode(prices, idx, cash, shares)
+	if idx < 0 || idx >= len(prices) {
+		return e.withRegime(s, 0)
+	}
+	regimes := e.Detector.Regimes(prices[:idx+1])
+	return e.withRegime(s, regimes[idx])
+}
+
+// NumStates returns the total number of states over all regimes.
+func (e *GatedEncoder) NumStates() int {
+	return e.TableStates() * e.Detector.NumRegimes()
+}
+
+// WarmUp returns the base encoder's warm-up; before the detector has enough prices
+// it reports its first regime, which is a state like any other.
+func (e *GatedEncoder) WarmUp() int {
+	return e.Base.WarmUp()
+}
+
+// TableStates returns the number of states of each regime's Q-table.
+func (e *GatedEncoder) TableStates() int {
+	return e.Base.NumStates()
+}
+
+// ForSeries returns an encoder with the regime of every index of prices
+// precomputed, and the base encoder bound to prices if it supports it.
+func (e *GatedEncoder) ForSeries(prices []float64) Encoder {
+	base := e.Base
+	if series, ok := base.(SeriesEncoder); ok {
+		base = series.ForSeries(prices)
+	}
+	return &seriesGatedEncoder{GatedEncoder: e, base: base, prices: prices, regimes: e.Detector.Regimes(prices)}
+}
+
+// withRegime adds the regime to a base state.
+func (e *GatedEncoder) withRegime(s State, regime int) State {
+	s.Index += regime * e.TableStates()
+	s.Regime = regime
+	return s
+}
+
+// seriesGatedEncoder is a GatedEncoder bound to one price series.
+type seriesGatedEncoder struct {
+	*GatedEncoder
+	base    Encoder
+	prices  []float64
+	regimes []int
+}
+
+// Encode computes the state at price index idx, like GatedEncoder.Encode.
+func (e *seriesGatedEncoder) Encode(prices []float64, idx int, cash, shares float64) State {
+	if !sameSeries(prices, e.prices) || idx < 0 || idx >= len(prices) {
+		return e.GatedEncoder.Encode(prices, idx, cash, shares)
+	}
+	return e.withRegime(e.base.Encode(prices, idx, cash, shares), e.regimes[idx])
+}
+
+// ForSeries returns the receiver for its own series.
+func (e *seriesGatedEncoder) ForSeries(prices []float64) Encoder {
+	if sameSeries(prices, e.prices) {
+		return e
+	}
+	return e.GatedEncoder.ForSeries(prices)
+}
+
+// RegimeName returns a readable name for a regime of detector.
+func RegimeName(detector Detector, regime int) string {
+	switch detector.(type) {
+	case TrendDetector:
+		switch regime {
+		case TrendBullish:
+			return "bullish"
+		case TrendBearish:
+			return "bearish"
+		default:
+			return "unknown"
+		}
+	case VolatilityDetector:
+		return fmt.Sprintf("volatility bucket %d", regime)
+	default:
+		return strconv.Itoa(regime)
+	}
+}
@@ -0,0 +1,149 @@
+package state
+
+import (
+	"math"
+
+	"github.com/kasaderos/rLportfolio/pkg/forecast"
+)
+
+// Default local approximation parameters of LAMEncoder.
+const (
+	DefaultApproxM = forecast.DefaultM // Window length, in returns
+	DefaultApproxN = forecast.DefaultN // Neighbor count
+)
+
+// LAMEncoder extends a base encoder with a local approximation forecast of the next
+// return: the expected return category and how close the nearest analogue is. The
+// forecast only uses the returns up to the encoded index.
+type LAMEncoder struct {
+	Base Encoder
+	M    int // Window length; 0 means DefaultApproxM
+	N    int // Neighbor count; 0 means DefaultApproxN
+	// Weights, when set, categorize the expected return of a forecast.Ensemble of the
+	// local approximation, MA trend, and last return instead of the local
+	// approximation alone.
+	Weights *forecast.Weights
+}
+
+// NewLAMEncoder extends base with forecasts from the n nearest windows of m returns.
+func NewLAMEncoder(base Encoder, m, n int) *LAMEncoder {
+	return &LAMEncoder{Base: base, M: m, N: n}
+}
+
+// Params returns the window length and neighbor count with defaults applied.
+func (e *LAMEncoder) Params() (m, n int) {
+	m, n = e.M, e.N
+	if m < 1 {
+		m = DefaultApproxM
+	}
+	if n < 1 {
+		n = DefaultApproxN
+	}
+	return m, n
+}
+
+// ensemble returns the forecaster: the local approximation alone without weights.
+func (e *LAMEncoder) ensemble() forecast.Ensemble {
+	m, n := e.Params()
+	weights := forecast.Weights{LAM: 1}
+	if e.Weights != nil {
+		weights = *e.Weights
+	}
+	return forecast.Ensemble{Weights: weights, M: m, N: n}
+}
+
+// Encode computes the base state at price index idx and adds the forecast categories.
+func (e *LAMEncoder) Encode(prices []float64, idx int, cash, shares float64) State {
+	s := e.Base.Encode(prices, idx, cash, shares)
+	expRet, minDist := forecastCategories(e.ensemble().At(prices, idx), prices, idx, e.M)
+	return withForecast(s, expRet, minDist)
+}
+
+// NumStates returns the total number of states.
+func (e *LAMEncoder) NumStates() int {
+	return e.Base.NumStates() * NumExpRetCategories * NumMinDistCategories
+}
+
+// WarmUp returns the base encoder's warm-up, or the first index with a window of
+// returns to forecast from if later.
+func (e *LAMEncoder) WarmUp() int {
+	m, _ := e.Params()
+	return max(e.Base.WarmUp(), m)
+}
+
+// ForSeries returns an encoder with the forecast of every index of prices
+// precomputed incrementally, and the base encoder bound to prices if it supports it.
+func (e *LAMEncoder) ForSeries(prices []float64) Encoder {
+	base := e.Base
+	if series, ok := base.(SeriesEncoder); ok {
+		base = series.ForSeries(prices)
+	}
+	enc := &seriesLAMEncoder{
+		LAMEncoder: e,
+		base:       base,
+		prices:     prices,
+		expRet:     make([]uint8, len(prices)),
+		minDist:    make([]uint8, len(prices)),
+	}
+	for idx, f := range e.ensemble().Series(prices) {
+		expRet, minDist := forecastCategories(f, prices, idx, e.M)
+		enc.expRet[idx], enc.minDist[idx] = uint8(expRet), uint8(minDist)
+	}
+	return enc
+}
+
+// seriesLAMEncoder is a LAMEncoder bound to one price series.
+type seriesLAMEncoder struct {
+	*LAMEncoder
+	base    Encoder
+	prices  []float64
+	expRet  []uint8
+	minDist []uint8
+}
+
+// Encode computes the state at price index idx, like LAMEncoder.Encode.
+func (e *seriesLAMEncoder) Encode(prices []float64, idx int, cash, shares float64) State {
+	if !sameSeries(prices, e.prices) || idx < 0 || idx >= len(prices) {
+		return e.LAMEncoder.Encode(prices, idx, cash, shares)
+	}
+	s := e.base.Encode(prices, idx, cash, shares)
+	return withForecast(s, int(e.expRet[idx]), int(e.minDist[idx]))
+}
+
+// ForSeries returns the receiver for its own series.
+func (e *seriesLAMEncoder) ForSeries(prices []float64) Encoder {
+	if sameSeries(prices, e.prices) {
+		return e
+	}
+	return e.LAMEncoder.ForSeries(prices)
+}
+
+// withForecast adds the forecast categories to a base state.
+func withForecast(s State, expRet, minDist int) State {
+	s.Index = (s.Index*NumExpRetCategories+expRet)*NumMinDistCategories + minDist
+	s.ExpRetCat = expRet
+	s.MinDistCat = minDist
+	return s
+}
+
+// forecastCategories returns the categories of the forecast made at price index idx
+// with windows of m returns; missing forecasts are flat and far.
+func forecastCategories(f forecast.Forecast, prices []float64, idx, m int) (expRet, minDist int) {
+	expRet, minDist = ExpRetFlat, MinDistFar
+	if !math.IsNaN(f.Ensemble) {
+		expRet = GetExpRetCategory(f.Ensemble)
+	}
+	if !math.IsNaN(f.MinDist) {
+		if m < 1 {
+			m = DefaultApproxM
+		}
+		// The query window is the last m returns up to idx
+		norm := 0.0
+		for i := idx - m + 1; i <= idx; i++ {
+			r := prices[i]/prices[i-1] - 1
+			norm += r * r
+		}
+		minDist = GetMinDistCategory(f.MinDist, math.Sqrt(norm))
+	}
+	return expRet, minDist
+}
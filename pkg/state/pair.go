@@ -0,0 +1,38 @@
+package state
+
+// Pair trading states combine the z-score of the spread between two prices with
+// the position held in the spread.
+const (
+	// NumSpreadCategories buckets the spread z-score at SpreadBounds
+	NumSpreadCategories = 7
+	// NumPairPositions are the spread position levels: short, half short, flat,
+	// half long, and long
+	NumPairPositions = 5
+	// PairFlat is the position level holding no spread
+	PairFlat = 2
+	// NumPairStates is the size of the pair trading state space
+	NumPairStates = NumSpreadCategories * NumPairPositions
+)
+
+// SpreadBounds are the z-scores separating the spread categories.
+var SpreadBounds = [NumSpreadCategories - 1]float64{-2, -1, -0.5, 0.5, 1, 2}
+
+// SpreadCategory maps a spread z-score to its category, 0 for the most negative.
+func SpreadCategory(z float64) int {
+	for i, bound := range SpreadBounds {
+		if z < bound {
+			return i
+		}
+	}
+	return NumSpreadCategories - 1
+}
+
+// NewPairState creates a pair trading State from its spread category and position
+// level.
+func NewPairState(spreadCat, position int) State {
+	return State{
+		Index:        spreadCat*NumPairPositions + position,
+		SpreadCat:    spreadCat,
+		PairPosition: position,
+	}
+}
@@ -0,0 +1,65 @@
+package state
+
+import ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+
+// RegimeEncoder is a cheap alternative to MAEncoder: the market component is the
+// MA50/MA200 regime (see ma.Regime) in place of the MA ordering, with a neutral
+// divergence, so the state space has only NumRegimeStates states.
+type RegimeEncoder struct{}
+
+// NumRegimeStates is the size of the state space of RegimeEncoder.
+const NumRegimeStates = ma.NumRegimes * NumMADivergenceCategories * NumPositionCategories * NumPositionCategories
+
+// NewRegimeEncoder creates a regime state encoder.
+func NewRegimeEncoder() *RegimeEncoder {
+	return &RegimeEncoder{}
+}
+
+// Encode computes the state at price index idx; State.MAState holds the ma.Regime.
+func (e *RegimeEncoder) Encode(prices []float64, idx int, cash, shares float64) State {
+	if idx < 0 || idx >= len(prices) {
+		return NewState(int(ma.RegimeUnknown), MANeutral, 0, 0)
+	}
+	return Compose(int(ma.GetRegime(prices, idx)), MANeutral, prices[idx], cash, shares)
+}
+
+// NumStates returns the total number of states.
+func (e *RegimeEncoder) NumStates() int {
+	return NumRegimeStates
+}
+
+// WarmUp returns ma.RegimeSlow, so the regime is known from the first decision.
+func (e *RegimeEncoder) WarmUp() int {
+	return max(MAWarmUp, ma.RegimeSlow)
+}
+
+// ForSeries returns an encoder with the regime of every index of prices precomputed.
+func (e *RegimeEncoder) ForSeries(prices []float64) Encoder {
+	return &seriesRegimeEncoder{RegimeEncoder: e, prices: prices, regimes: ma.Regimes(prices)}
+}
+
+// seriesRegimeEncoder is a RegimeEncoder bound to one price series.
+type seriesRegimeEncoder struct {
+	*RegimeEncoder
+	prices  []float64
+	regimes []ma.Regime
+}
+
+// Encode computes the state at price index idx, like RegimeEncoder.Encode.
+func (e *seriesRegimeEncoder) Encode(prices []float64, idx int, cash, shares float64) State {
+	if !sameSeries(prices, e.prices) {
+		return e.RegimeEncoder.Encode(prices, idx, cash, shares)
+	}
+	if idx < 0 || idx >= len(prices) {
+		return NewState(int(ma.RegimeUnknown), MANeutral, 0, 0)
+	}
+	return Compose(int(e.regimes[idx]), MANeutral, prices[idx], cash, shares)
+}
+
+// ForSeries returns the receiver for its own series.
+func (e *seriesRegimeEncoder) ForSeries(prices []float64) Encoder {
+	if sameSeries(prices, e.prices) {
+		return e
+	}
+	return e.RegimeEncoder.ForSeries(prices)
+}
@@ -0,0 +1,95 @@
+package state
+
+import ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+
+// DefaultSlopeLag is the number of prices over which SlopeEncoder measures MA slopes.
+const DefaultSlopeLag = 5
+
+// NumSlopeStates is the size of the state space of SlopeEncoder.
+const NumSlopeStates = ma.NumSlopeStates * NumMADivergenceCategories * NumPositionCategories * NumPositionCategories
+
+// SlopeEncoder is a compact alternative to MAEncoder: the market component is the
+// sign of each MA's recent slope (6 bits, 64 states) in place of the 5040 orderings,
+// with the usual divergence and position categories.
+type SlopeEncoder struct {
+	Lag int // Prices over which slopes are measured; 0 means DefaultSlopeLag
+}
+
+// NewSlopeEncoder creates a slope-sign encoder measuring slopes over lag prices.
+func NewSlopeEncoder(lag int) *SlopeEncoder {
+	return &SlopeEncoder{Lag: lag}
+}
+
+func (e *SlopeEncoder) lag() int {
+	if e.Lag < 1 {
+		return DefaultSlopeLag
+	}
+	return e.Lag
+}
+
+// Encode computes the state at price index idx. State.MAState holds the slope bits.
+func (e *SlopeEncoder) Encode(prices []float64, idx int, cash, shares float64) State {
+	if idx < MAWarmUp || idx >= len(prices) {
+		return NewState(0, MANeutral, 0, 0)
+	}
+	slopes := 0
+	if idx-e.lag() >= MAWarmUp {
+		slopes = ma.GetSlopeState(prices, idx, e.lag())
+	}
+	return Compose(slopes, ma.GetMADivergenceState(prices, idx), prices[idx], cash, shares)
+}
+
+// NumStates returns the total number of states.
+func (e *SlopeEncoder) NumStates() int {
+	return NumSlopeStates
+}
+
+// WarmUp returns the first index whose slopes are measured from available MAs.
+func (e *SlopeEncoder) WarmUp() int {
+	return MAWarmUp + e.lag()
+}
+
+// ForSeries returns an encoder with the market component of every index of prices precomputed.
+func (e *SlopeEncoder) ForSeries(prices []float64) Encoder {
+	provider := ma.NewProvider(prices)
+	enc := &seriesSlopeEncoder{
+		SlopeEncoder: e,
+		prices:       prices,
+		slopes:       make([]uint8, len(prices)),
+		divergence:   make([]uint8, len(prices)),
+	}
+	for idx := MAWarmUp; idx < len(prices); idx++ {
+		if idx-e.lag() >= MAWarmUp {
+			enc.slopes[idx] = uint8(provider.SlopeState(idx, e.lag()))
+		}
+		enc.divergence[idx] = uint8(provider.Divergence(idx))
+	}
+	return enc
+}
+
+// seriesSlopeEncoder is a SlopeEncoder bound to one price series.
+type seriesSlopeEncoder struct {
+	*SlopeEncoder
+	prices     []float64
+	slopes     []uint8
+	divergence []uint8
+}
+
+// Encode computes the state at price index idx, like SlopeEncoder.Encode.
+func (e *seriesSlopeEncoder) Encode(prices []float64, idx int, cash, shares float64) State {
+	if !sameSeries(prices, e.prices) {
+		return e.SlopeEncoder.Encode(prices, idx, cash, shares)
+	}
+	if idx < MAWarmUp || idx >= len(prices) {
+		return NewState(0, MANeutral, 0, 0)
+	}
+	return Compose(int(e.slopes[idx]), int(e.divergence[idx]), prices[idx], cash, shares)
+}
+
+// ForSeries returns the receiver for its own series.
+func (e *seriesSlopeEncoder) ForSeries(prices []float64) Encoder {
+	if sameSeries(prices, e.prices) {
+		return e
+	}
+	return e.SlopeEncoder.ForSeries(prices)
+}
@@ -11,6 +11,11 @@ type State struct {
 	MADivergence int // MA convergence/divergence: 0=converging, 1=neutral, 2=diverging
 	CashCat      int // Cash position category
 	SharesCat    int // Shares position category
+	ExpRetCat    int // Forecast return category (LAMEncoder only)
+	MinDistCat   int // Forecast nearest-neighbor distance category (LAMEncoder only)
+	Regime       int // Market regime selecting the Q-table (GatedEncoder only)
+	SpreadCat    int // Spread z-score category (pair trading only)
+	PairPosition int // Spread position level, 0 fully short to NumPairPositions-1 fully long (pair trading only)
 }
 
 const (
@@ -53,6 +58,23 @@ func Encode(maState, maDivergence, cashCat, sharesCat int) int {
 	return maStateWithDivergence*NumPositionCategories*NumPositionCategories + cashCat*NumPositionCategories + sharesCat
 }
 
+// Decode decodes a state index back into (ma_state, ma_divergence, cash_cat, shares_cat).
+func Decode(index int) (maState, maDivergence, cashCat, sharesCat int) {
+	sharesCat = index % NumPositionCategories
+	index /= NumPositionCategories
+	cashCat = index % NumPositionCategories
+	index /= NumPositionCategories
+	maDivergence = index % NumMADivergenceCategories
+	maState = index / NumMADivergenceCategories
+	return maState, maDivergence, cashCat, sharesCat
+}
+
+// FromIndex creates a State with its components decoded from the state index.
+func FromIndex(index int) State {
+	maState, maDivergence, cashCat, sharesCat := Decode(index)
+	return NewState(maState, maDivergence, cashCat, sharesCat)
+}
+
 // GetCashCategory maps cash percentage of portfolio to category.
 // portfolioValue is the total portfolio value (cash + shares * price).
 func GetCashCategory(cash, portfolioValue float64) int {
@@ -82,3 +104,31 @@ func GetSharesCategory(sharesValue, portfolioValue float64) int {
 	}
 	return PosHigh
 }
+
+// DivergenceName returns a readable name for an MA divergence category.
+func DivergenceName(d int) string {
+	switch d {
+	case MAConverging:
+		return "converging"
+	case MANeutral:
+		return "neutral"
+	case MADiverging:
+		return "diverging"
+	default:
+		return "unknown"
+	}
+}
+
+// PositionName returns a readable name for a cash or shares position category.
+func PositionName(c int) string {
+	switch c {
+	case PosNone:
+		return "none"
+	case PosMedium:
+		return "medium"
+	case PosHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
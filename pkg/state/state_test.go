@@ -0,0 +1,116 @@
+package state
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	ma "github.com/kasaderos/rLportfolio/pkg/moving-average"
+)
+
+// components are random state components in range, for quick.Check.
+type components struct {
+	MAState, MADivergence, CashCat, SharesCat int
+}
+
+// Generate implements quick.Generator.
+func (components) Generate(rng *rand.Rand, _ int) reflect.Value {
+	return reflect.ValueOf(components{
+		MAState:      rng.Intn(NumMarketStates),
+		MADivergence: rng.Intn(NumMADivergenceCategories),
+		CashCat:      rng.Intn(NumPositionCategories),
+		SharesCat:    rng.Intn(NumPositionCategories),
+	})
+}
+
+// market is a random price series, decision index, and portfolio, for quick.Check.
+type market struct {
+	Prices       []float64
+	Idx          int
+	Cash, Shares float64
+}
+
+// Generate implements quick.Generator: a random walk whose volatility and drift
+// vary between series, with holdings from all cash to all shares.
+func (market) Generate(rng *rand.Rand, _ int) reflect.Value {
+	n := 130 + rng.Intn(300)
+	vol := 0.001 + rng.Float64()*0.05
+	drift := (rng.Float64() - 0.5) * 0.01
+	prices := make([]float64, n)
+	p := 1 + rng.Float64()*1000
+	for i := range prices {
+		p *= math.Exp(drift + rng.NormFloat64()*vol)
+		prices[i] = p
+	}
+	m := market{Prices: prices, Idx: rng.Intn(n)}
+	switch rng.Intn(3) {
+	case 0:
+		m.Cash = rng.Float64() * 1e5
+	case 1:
+		m.Shares = rng.Float64() * 100
+	default:
+		m.Cash, m.Shares = rng.Float64()*1e5, rng.Float64()*100
+	}
+	return reflect.ValueOf(m)
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	roundTrip := func(c components) bool {
+		index := Encode(c.MAState, c.MADivergence, c.CashCat, c.SharesCat)
+		if index < 0 || index >= NumStates {
+			return false
+		}
+		maState, maDivergence, cashCat, sharesCat := Decode(index)
+		return components{maState, maDivergence, cashCat, sharesCat} == c
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 5000}); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestEncodersStayInRange checks that every encoder, alone and combined, maps any
+// market to a state index below its NumStates, and encodes a series it precomputed
+// like any other.
+func TestEncodersStayInRange(t *testing.T) {
+	lines, err := ma.ParseLines("ema5,ema10,sma20,sma40,wma80,sma120")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixed, err := NewMixedMAEncoder(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoders := map[string]Encoder{
+		"ma":          NewMAEncoder(),
+		"mixed-ma":    mixed,
+		"regime":      NewRegimeEncoder(),
+		"slope":       NewSlopeEncoder(0),
+		"lam":         NewLAMEncoder(NewMAEncoder(), 10, 0),
+		"gated-trend": NewGatedEncoder(NewMAEncoder(), TrendDetector{}),
+		"gated-vol":   NewGatedEncoder(NewSlopeEncoder(0), VolatilityDetector{}),
+		"gated-lam":   NewGatedEncoder(NewLAMEncoder(NewRegimeEncoder(), 10, 0), TrendDetector{}),
+	}
+	for name, encoder := range encoders {
+		t.Run(name, func(t *testing.T) {
+			inRange := func(m market) bool {
+				s := encoder.Encode(m.Prices, m.Idx, m.Cash, m.Shares)
+				if s.Index < 0 || s.Index >= encoder.NumStates() {
+					t.Logf("index %d of %d prices: state %d, want below %d", m.Idx, len(m.Prices), s.Index, encoder.NumStates())
+					return false
+				}
+				if series, ok := encoder.(SeriesEncoder); ok {
+					if p := series.ForSeries(m.Prices).Encode(m.Prices, m.Idx, m.Cash, m.Shares); p != s {
+						t.Logf("index %d of %d prices: precomputed state %+v, want %+v", m.Idx, len(m.Prices), p, s)
+						return false
+					}
+				}
+				return true
+			}
+			if err := quick.Check(inRange, &quick.Config{MaxCount: 300}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
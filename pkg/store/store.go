@@ -0,0 +1,309 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/eval"
+	"github.com/kasaderos/rLportfolio/pkg/metrics"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver, registered as "sqlite"
+)
+
+// Store records experiment runs, per-episode metrics, and trades in a SQLite database.
+type Store struct {
+	db *sql.DB
+}
+
+// Run is one training or test run.
+type Run struct {
+	ID        int64
+	Name      string
+	Kind      string // "train" or "test"
+	CreatedAt time.Time
+	Data      string // Price file the run used
+	Symbol    string
+	Model     string // Model bundle written or read by the run
+	Seed      int64
+	Config    string // Free-form configuration, usually JSON
+	Metrics   metrics.Metrics
+}
+
+// Episode holds the metrics of one training episode.
+type Episode struct {
+	Stock      string
+	Episode    int
+	Reward     float64
+	FinalValue float64
+	ReturnPct  float64
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	name         TEXT NOT NULL DEFAULT '',
+	kind         TEXT NOT NULL DEFAULT '',
+	created_at   TEXT NOT NULL,
+	data         TEXT NOT NULL DEFAULT '',
+	symbol       TEXT NOT NULL DEFAULT '',
+	model        TEXT NOT NULL DEFAULT '',
+	seed         INTEGER NOT NULL DEFAULT 0,
+	config       TEXT NOT NULL DEFAULT '',
+	initial_value REAL NOT NULL DEFAULT 0,
+	final_value  REAL NOT NULL DEFAULT 0,
+	total_return REAL NOT NULL DEFAULT 0,
+	max_drawdown REAL NOT NULL DEFAULT 0,
+	volatility   REAL NOT NULL DEFAULT 0,
+	sharpe       REAL NOT NULL DEFAULT 0,
+	num_trades   INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS episodes (
+	run_id      INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+	stock       TEXT NOT NULL,
+	episode     INTEGER NOT NULL,
+	reward      REAL NOT NULL,
+	final_value REAL NOT NULL,
+	return_pct  REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS episodes_run ON episodes(run_id);
+CREATE TABLE IF NOT EXISTS trades (
+	run_id       INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+	step         INTEGER NOT NULL,
+	price_idx    INTEGER NOT NULL,
+	action       TEXT NOT NULL,
+	price        REAL NOT NULL,
+	shares       REAL NOT NULL,
+	notional     REAL NOT NULL,
+	commission   REAL NOT NULL,
+	cash_after   REAL NOT NULL,
+	shares_after REAL NOT NULL,
+	state        INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS trades_run ON trades(run_id);
+`
+
+// Open opens (or creates) the database at path and makes sure the tables exist.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	// SQLite allows one writer at a time
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to configure store: %w", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// DB returns the underlying database for ad-hoc queries.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// CreateRun inserts a run and returns its ID. A zero CreatedAt is set to now.
+func (s *Store) CreateRun(r Run) (int64, error) {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now().UTC()
+	}
+	res, err := s.db.Exec(`INSERT INTO runs (name, kind, created_at, data, symbol, model, seed, config,
+		initial_value, final_value, total_return, max_drawdown, volatility, sharpe, num_trades)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Name, r.Kind, r.CreatedAt.Format(time.RFC3339Nano), r.Data, r.Symbol, r.Model, r.Seed, r.Config,
+		r.Metrics.InitialValue, r.Metrics.FinalValue, r.Metrics.TotalReturn, r.Metrics.MaxDrawdown,
+		r.Metrics.Volatility, r.Metrics.Sharpe, r.Metrics.NumTrades)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert run: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// SetMetrics stores the final metrics of a run.
+func (s *Store) SetMetrics(runID int64, m metrics.Metrics) error {
+	_, err := s.db.Exec(`UPDATE runs SET initial_value = ?, final_value = ?, total_return = ?,
+		max_drawdown = ?, volatility = ?, sharpe = ?, num_trades = ? WHERE id = ?`,
+		m.InitialValue, m.FinalValue, m.TotalReturn, m.MaxDrawdown, m.Volatility, m.Sharpe, m.NumTrades, runID)
+	if err != nil {
+		return fmt.Errorf("failed to update run %d: %w", runID, err)
+	}
+	return nil
+}
+
+// AddEpisodes inserts episode metrics for a run in one transaction.
+func (s *Store) AddEpisodes(runID int64, episodes []Episode) error {
+	return s.insertAll(`INSERT INTO episodes (run_id, stock, episode, reward, final_value, return_pct)
+		VALUES (?, ?, ?, ?, ?, ?)`, len(episodes), func(stmt *sql.Stmt, i int) error {
+		e := episodes[i]
+		_, err := stmt.Exec(runID, e.Stock, e.Episode, e.Reward, e.FinalValue, e.ReturnPct)
+		return err
+	})
+}
+
+// AddTrades inserts the trades of a run in one transaction.
+func (s *Store) AddTrades(runID int64, trades []eval.Trade) error {
+	return s.insertAll(`INSERT INTO trades (run_id, step, price_idx, action, price, shares, notional,
+		commission, cash_after, shares_after, state) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		len(trades), func(stmt *sql.Stmt, i int) error {
+			t := trades[i]
+			_, err := stmt.Exec(runID, t.Step, t.PriceIdx, t.Action.String(), t.Price, t.Shares, t.Notional,
+				t.Commission, t.CashAfter, t.SharesAfter, t.State.Index)
+			return err
+		})
+}
+
+// insertAll executes a prepared insert n times inside a transaction.
+func (s *Store) insertAll(query string, n int, exec func(stmt *sql.Stmt, i int) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for i := 0; i < n; i++ {
+		if err := exec(stmt, i); err != nil {
+			return fmt.Errorf("failed to insert row %d: %w", i, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RunFilter selects runs for Runs. Zero fields match everything.
+type RunFilter struct {
+	Kind    string
+	Name    string // SQL LIKE pattern, e.g. "sweep-%"
+	OrderBy string // A metric column: total_return, sharpe, max_drawdown, volatility, final_value, num_trades (default: newest first)
+	Limit   int
+}
+
+// orderColumns are the columns RunFilter.OrderBy may name; lower is better only for risk.
+var orderColumns = map[string]string{
+	"total_return": "total_return DESC",
+	"sharpe":       "sharpe DESC",
+	"final_value":  "final_value DESC",
+	"num_trades":   "num_trades DESC",
+	"max_drawdown": "max_drawdown ASC",
+	"volatility":   "volatility ASC",
+}
+
+// Runs returns the runs matching filter.
+func (s *Store) Runs(filter RunFilter) ([]Run, error) {
+	query := `SELECT id, name, kind, created_at, data, symbol, model, seed, config,
+		initial_value, final_value, total_return, max_drawdown, volatility, sharpe, num_trades
+		FROM runs WHERE (? = '' OR kind = ?) AND (? = '' OR name LIKE ?)`
+	order := "id DESC"
+	if filter.OrderBy != "" {
+		var ok bool
+		if order, ok = orderColumns[filter.OrderBy]; !ok {
+			return nil, fmt.Errorf("cannot order runs by %q", filter.OrderBy)
+		}
+	}
+	query += " ORDER BY " + order
+	args := []any{filter.Kind, filter.Kind, filter.Name, filter.Name}
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var r Run
+		var createdAt string
+		m := &r.Metrics
+		if err := rows.Scan(&r.ID, &r.Name, &r.Kind, &createdAt, &r.Data, &r.Symbol, &r.Model, &r.Seed, &r.Config,
+			&m.InitialValue, &m.FinalValue, &m.TotalReturn, &m.MaxDrawdown, &m.Volatility, &m.Sharpe, &m.NumTrades); err != nil {
+			return nil, fmt.Errorf("failed to read run: %w", err)
+		}
+		r.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// Episodes returns the episode metrics of a run in insertion order.
+func (s *Store) Episodes(runID int64) ([]Episode, error) {
+	rows, err := s.db.Query(`SELECT stock, episode, reward, final_value, return_pct
+		FROM episodes WHERE run_id = ? ORDER BY rowid`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query episodes: %w", err)
+	}
+	defer rows.Close()
+
+	var episodes []Episode
+	for rows.Next() {
+		var e Episode
+		if err := rows.Scan(&e.Stock, &e.Episode, &e.Reward, &e.FinalValue, &e.ReturnPct); err != nil {
+			return nil, fmt.Errorf("failed to read episode: %w", err)
+		}
+		episodes = append(episodes, e)
+	}
+	return episodes, rows.Err()
+}
+
+// Trades returns the trades of a run ordered by step. Only the state index is stored,
+// so Trade.State holds just the Index.
+func (s *Store) Trades(runID int64) ([]eval.Trade, error) {
+	rows, err := s.db.Query(`SELECT step, price_idx, action, price, shares, notional, commission,
+		cash_after, shares_after, state FROM trades WHERE run_id = ? ORDER BY step`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trades: %w", err)
+	}
+	defer rows.Close()
+
+	var trades []eval.Trade
+	for rows.Next() {
+		var t eval.Trade
+		var action string
+		if err := rows.Scan(&t.Step, &t.PriceIdx, &action, &t.Price, &t.Shares, &t.Notional, &t.Commission,
+			&t.CashAfter, &t.SharesAfter, &t.State.Index); err != nil {
+			return nil, fmt.Errorf("failed to read trade: %w", err)
+		}
+		if t.Action, err = agent.ParseAction(action); err != nil {
+			return nil, err
+		}
+		trades = append(trades, t)
+	}
+	return trades, rows.Err()
+}
+
+// DeleteRun removes a run with its episodes and trades.
+func (s *Store) DeleteRun(runID int64) error {
+	if _, err := s.db.Exec("DELETE FROM runs WHERE id = ?", runID); err != nil {
+		return fmt.Errorf("failed to delete run %d: %w", runID, err)
+	}
+	return nil
+}
+
+// RecordResult stores the metrics and trades of an evaluation as the outcome of a run.
+func (s *Store) RecordResult(runID int64, result *eval.Result) error {
+	if err := s.SetMetrics(runID, result.Metrics); err != nil {
+		return err
+	}
+	return s.AddTrades(runID, result.Trades)
+}
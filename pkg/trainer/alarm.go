@@ -0,0 +1,62 @@
+package trainer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+)
+
+// TDAlarm watches the TD errors of the training episodes for a blow-up, the sign of
+// hyperparameters such as a learning rate or a discount too high for the rewards,
+// so a long sweep can drop them early. An episode's TD errors are measured by their
+// root mean square; non-finite errors always raise the alarm.
+type TDAlarm struct {
+	// MaxRMS, if positive, is the largest RMS TD error of an episode.
+	MaxRMS float64
+	// Growth, if positive, is the largest ratio of an episode's RMS TD error to the
+	// lowest of the episodes before it, e.g. 100.
+	Growth float64
+	// Abort makes the trainer stop at the first alarm.
+	Abort bool
+	// OnAlarm, if set, is called with every episode that raises the alarm; the
+	// trainer logs only the first of a run of them.
+	OnAlarm func(Alarm)
+
+	lowest float64 // Lowest RMS TD error so far, 0 before the first episode
+	raised bool    // Whether the last episode raised the alarm
+}
+
+// Alarm describes an episode whose TD errors blew up.
+type Alarm struct {
+	Episode  int
+	TDErrors agent.TDStats
+	Reason   string
+}
+
+// String describes the alarm on one line.
+func (a Alarm) String() string {
+	return fmt.Sprintf("episode %d: TD errors %s (mean=%.4g, std=%.4g over %d updates)",
+		a.Episode, a.Reason, a.TDErrors.Mean, a.TDErrors.Std(), a.TDErrors.Count)
+}
+
+// check returns the alarm raised by the TD errors of an episode, if any.
+func (a *TDAlarm) check(episode int, td agent.TDStats) (Alarm, bool) {
+	if td.Count == 0 {
+		return Alarm{}, false
+	}
+	rms := td.RMS()
+	reason := ""
+	switch {
+	case math.IsNaN(rms) || math.IsInf(rms, 0):
+		reason = "are not finite"
+	case a.MaxRMS > 0 && rms > a.MaxRMS:
+		reason = fmt.Sprintf("reached RMS %.4g (limit %.4g)", rms, a.MaxRMS)
+	case a.Growth > 0 && a.lowest > 0 && rms > a.Growth*a.lowest:
+		reason = fmt.Sprintf("grew to RMS %.4g, %.0fx the lowest %.4g (limit %gx)", rms, rms/a.lowest, a.lowest, a.Growth)
+	}
+	if reason == "" && rms > 0 && (a.lowest == 0 || rms < a.lowest) {
+		a.lowest = rms
+	}
+	return Alarm{Episode: episode, TDErrors: td, Reason: reason}, reason != ""
+}
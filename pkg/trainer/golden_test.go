@@ -0,0 +1,115 @@
+package trainer_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/eval"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+	"github.com/kasaderos/rLportfolio/pkg/trainer"
+)
+
+// Golden-run settings: changing any of them changes the golden values.
+const (
+	goldenSeed     = 42
+	goldenPrices   = 1200
+	goldenSplit    = 800 // Prices before the split train, the rest test
+	goldenEpisodes = 25
+)
+
+// syntheticPrices returns a deterministic random walk with drift and slowly
+// alternating trends, so the MA orderings vary.
+func syntheticPrices(n int, seed int64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	prices := make([]float64, n)
+	p := 100.0
+	for i := range prices {
+		trend := 0.002 * math.Sin(float64(i)/60)
+		p *= 1 + trend + rng.NormFloat64()*0.012
+		prices[i] = p
+	}
+	return prices
+}
+
+// hashQ returns the SHA-256 of the bits of every Q-value, in state and action order.
+func hashQ(Q [][]float64) string {
+	h := sha256.New()
+	var buf [8]byte
+	for _, row := range Q {
+		for _, v := range row {
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+			h.Write(buf[:])
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// TestGoldenRun trains a Q-learning agent with a fixed seed on a synthetic series and
+// asserts the final Q-table and the greedy test return, so refactors of env, agent,
+// or state cannot silently change learning behavior. When a change is meant to alter
+// learning, update the golden values from the failure message.
+func TestGoldenRun(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		// Other architectures may fuse multiply-adds and round differently
+		t.Skipf("golden values are recorded on amd64, not %s", runtime.GOARCH)
+	}
+
+	tests := []struct {
+		name       string
+		encoder    func() state.Encoder
+		hash       string
+		testReturn float64
+	}{
+		{
+			name:       "ma",
+			encoder:    func() state.Encoder { return state.NewMAEncoder() },
+			hash:       "7251cf433bec7da0b174e0df8f43c3e50ca9908e93e43d8e1c4724c11dd9bfd3",
+			testReturn: -0.25417536763261672,
+		},
+		{
+			name: "trend-gated",
+			encoder: func() state.Encoder {
+				return state.NewGatedEncoder(state.NewMAEncoder(), state.TrendDetector{})
+			},
+			hash:       "9ac766bba64a07ca6b7554747a326fe15cf6c578c1cb0bbe974fe10f5175e5c5",
+			testReturn: -0.18947613105896288,
+		},
+	}
+
+	prices := syntheticPrices(goldenPrices, goldenSeed)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder := tt.encoder()
+			Q := agent.NewQTable(encoder.NumStates(), agent.NumActions)
+			rng := rand.New(rand.NewSource(goldenSeed))
+			policy := agent.NewEpsilonGreedyValuePolicy(Q, 0.1, rng)
+			learner := agent.NewQLearningAgent(Q, policy, 0.1, 0.95)
+			marketEnv := env.NewMarketEnv(env.MarketConfig{
+				Prices:      prices[:goldenSplit],
+				InitialCash: 10000,
+				MinStartIdx: 120,
+				Commission:  0.002,
+				Encoder:     encoder,
+			})
+			trainer.NewTrainer(marketEnv, learner).Run(goldenEpisodes, goldenEpisodes)
+
+			result, err := eval.Evaluate(Q.Q, encoder, prices[goldenSplit-120:], eval.DefaultConfig())
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if got := hashQ(Q.Q); got != tt.hash {
+				t.Errorf("Q-table hash = %s, want %s", got, tt.hash)
+			}
+			if got := result.Metrics.TotalReturn; math.Abs(got-tt.testReturn) > 1e-12 {
+				t.Errorf("test return = %.17g, want %.17g", got, tt.testReturn)
+			}
+		})
+	}
+}
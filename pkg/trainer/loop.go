@@ -2,15 +2,54 @@ package trainer
 
 import (
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/kasaderos/rLportfolio/pkg/agent"
 	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/state"
 )
 
+// EpisodeStats summarizes one finished training episode.
+type EpisodeStats struct {
+	Episode    int // 1-based episode number of the trainer
+	Reward     float64
+	FinalValue float64 // Portfolio value at the end (market environments only)
+	ReturnPct  float64
+	Steps      int           // Environment steps taken
+	Duration   time.Duration // Wall time of the episode
+	// BlockedOrders counts the trades halts blocked (market environments only).
+	BlockedOrders int
+	// TradePenalty is the part of Reward lost to trading costs (see
+	// env.MarketEnv.TradePenalty), and Reward minus it the part earned by the
+	// position's returns; market environments only, exact when no wrapper rescales
+	// the rewards.
+	TradePenalty float64
+	// TDErrors are the statistics of the agent's TD errors over the episode, for
+	// agents implementing agent.TDTracker.
+	TDErrors agent.TDStats
+	// RegimeSteps and RegimeRewards split Steps and Reward by the regime of the
+	// state acted in, when the trainer tracks regimes.
+	RegimeSteps   []int
+	RegimeRewards []float64
+}
+
 // Trainer runs training episodes for an RL agent.
 type Trainer struct {
 	Env   env.Environment
 	Agent agent.Agent
+	// OnEpisode, if set, is called after every episode (e.g. to record metrics).
+	OnEpisode func(EpisodeStats)
+	// Regimes, if positive, is the number of regimes of the states (see
+	// state.GatedEncoder); episodes then report their steps and reward per regime.
+	Regimes int
+	// TDAlarm, if set, raises an alarm when the TD errors of an episode blow up.
+	TDAlarm *TDAlarm
+	// Visits, if set, records every state acted in.
+	Visits *Visits
+
+	stopped  atomic.Bool
+	episodes int // Episodes run so far
 }
 
 // NewTrainer creates a new trainer.
@@ -21,45 +60,147 @@ func NewTrainer(env env.Environment, agent agent.Agent) *Trainer {
 	}
 }
 
+// Stop makes Run return after the current episode, e.g. from OnEpisode for early
+// stopping. It is safe to call from another goroutine.
+func (t *Trainer) Stop() {
+	t.stopped.Store(true)
+}
+
+// Stopped reports whether Stop was called.
+func (t *Trainer) Stopped() bool {
+	return t.stopped.Load()
+}
+
 // Run executes training episodes.
 func (t *Trainer) Run(episodes int, reportInterval int) {
 	if reportInterval <= 0 {
 		reportInterval = 100
 	}
+	for ep := 0; ep < episodes && !t.stopped.Load(); ep++ {
+		stats := t.RunEpisode()
+		if stats.Episode%reportInterval == 0 {
+			t.report(stats)
+		}
+	}
+}
 
-	for ep := 0; ep < episodes; ep++ {
-		s := t.Env.Reset()
-		done := false
-		episodeReward := 0.0
+// RunEpisode executes the trainer's next training episode, calls OnEpisode, and
+// returns its statistics.
+func (t *Trainer) RunEpisode() EpisodeStats {
+	masker, _ := t.Env.(env.Masker)
+	market, _ := env.Market(t.Env)
+	tracker, _ := t.Agent.(agent.TDTracker)
+	if tracker != nil {
+		tracker.ResetTDErrors()
+	}
+	started := time.Now()
+	s := t.Env.Reset()
+	done := false
+	episodeReward, tradePenalty := 0.0, 0.0
+	steps := 0
+	var regimeSteps []int
+	var regimeRewards []float64
+	if t.Regimes > 0 {
+		regimeSteps = make([]int, t.Regimes)
+		regimeRewards = make([]float64, t.Regimes)
+	}
 
-		for !done {
-			action := t.Agent.Act(s)
-			next, reward, d := t.Env.Step(action)
+	for !done {
+		action := t.act(s, masker)
+		if t.Visits != nil {
+			t.Visits.Add(s.Index)
+		}
+		next, reward, d := t.Env.Step(action)
+		var elapsed time.Duration
+		if market != nil {
+			elapsed = market.Elapsed()
+			tradePenalty += market.TradePenalty()
+		}
 
-			t.Agent.Learn(agent.Transition{
-				State:     s,
-				Action:    action,
-				Reward:    reward,
-				NextState: next,
-				Done:      d,
-			})
+		t.Agent.Learn(agent.Transition{
+			State:     s,
+			Action:    action,
+			Reward:    reward,
+			NextState: next,
+			Done:      d,
+			Elapsed:   elapsed,
+		})
 
-			s = next
-			done = d
-			episodeReward += reward
+		if s.Regime >= 0 && s.Regime < len(regimeSteps) {
+			regimeSteps[s.Regime]++
+			regimeRewards[s.Regime] += reward
 		}
+		s = next
+		done = d
+		episodeReward += reward
+		steps++
+	}
 
-		if (ep+1)%reportInterval == 0 {
-			// Get final portfolio value if environment supports it
-			if marketEnv, ok := t.Env.(*env.MarketEnv); ok {
-				finalValue := marketEnv.PortfolioValue()
-				initialValue := marketEnv.InitialValue()
-				returnPct := (finalValue/initialValue - 1.0) * 100
-				fmt.Printf("Episode %d: Final value=%.2f, Return=%.2f%%, Reward=%.4f\n",
-					ep+1, finalValue, returnPct, episodeReward)
-			} else {
-				fmt.Printf("Episode %d: Reward=%.4f\n", ep+1, episodeReward)
-			}
+	t.episodes++
+	stats := EpisodeStats{Episode: t.episodes, Reward: episodeReward, Steps: steps, Duration: time.Since(started),
+		TradePenalty: tradePenalty, RegimeSteps: regimeSteps, RegimeRewards: regimeRewards}
+	// Get final portfolio value if environment supports it, through any wrappers
+	if marketEnv, isMarket := env.Market(t.Env); isMarket {
+		stats.FinalValue = marketEnv.PortfolioValue()
+		stats.ReturnPct = (stats.FinalValue/marketEnv.InitialValue() - 1.0) * 100
+		stats.BlockedOrders = marketEnv.BlockedOrders()
+	}
+	if tracker != nil {
+		stats.TDErrors = tracker.TDErrors()
+		if t.TDAlarm != nil {
+			t.checkTDErrors(stats)
 		}
 	}
+	if t.OnEpisode != nil {
+		t.OnEpisode(stats)
+	}
+	return stats
+}
+
+// report prints the outcome of an episode.
+func (t *Trainer) report(stats EpisodeStats) {
+	if _, isMarket := env.Market(t.Env); isMarket {
+		fmt.Printf("Episode %d: Final value=%.2f, Return=%.2f%%, Reward=%.4f\n",
+			stats.Episode, stats.FinalValue, stats.ReturnPct, stats.Reward)
+	} else {
+		fmt.Printf("Episode %d: Reward=%.4f\n", stats.Episode, stats.Reward)
+	}
+	if td := stats.TDErrors; td.Count > 0 {
+		fmt.Printf("  TD error: mean=%.4g, std=%.4g\n", td.Mean, td.Std())
+	}
+	if stats.BlockedOrders > 0 {
+		fmt.Printf("  Blocked orders: %d\n", stats.BlockedOrders)
+	}
+	for r, n := range stats.RegimeSteps {
+		fmt.Printf("  Regime %d: %d steps, reward=%.4f\n", r, n, stats.RegimeRewards[r])
+	}
+}
+
+// checkTDErrors raises the TD alarm if the episode's TD errors blew up: it logs
+// the first alarm of a run of them, calls OnAlarm, and stops training on Abort.
+func (t *Trainer) checkTDErrors(stats EpisodeStats) {
+	a := t.TDAlarm
+	alarm, raised := a.check(stats.Episode, stats.TDErrors)
+	if raised && !a.raised {
+		fmt.Printf("TD error alarm: %s\n", alarm)
+	}
+	a.raised = raised
+	if !raised {
+		return
+	}
+	if a.OnAlarm != nil {
+		a.OnAlarm(alarm)
+	}
+	if a.Abort {
+		t.Stop()
+	}
+}
+
+// act asks the agent for an action, among those the environment allows if it
+// restricts them.
+func (t *Trainer) act(s state.State, masker env.Masker) agent.Action {
+	if masker == nil {
+		return t.Agent.Act(s)
+	}
+	return agent.ActWith(t.Agent, s, masker.ActionMask())
 }
@@ -0,0 +1,95 @@
+package trainer
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/data"
+	"github.com/kasaderos/rLportfolio/pkg/env"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+// recordingAgent holds and keeps the transitions it learns from.
+type recordingAgent struct {
+	transitions []agent.Transition
+}
+
+func (a *recordingAgent) Act(s state.State) agent.Action { return agent.ActionNothing }
+
+func (a *recordingAgent) Learn(t agent.Transition) { a.transitions = append(a.transitions, t) }
+
+func TestTransitionsCarryCalendarTime(t *testing.T) {
+	// Weekdays only, so every Monday follows a weekend
+	var times []time.Time
+	prices := make([]float64, 0, 200)
+	for day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); len(times) < 200; day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			times = append(times, day)
+			prices = append(prices, 100+math.Sin(float64(len(prices))/5))
+		}
+	}
+	clock, err := data.NewClock(times)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marketEnv := env.NewMarketEnv(env.MarketConfig{Prices: prices, Clock: clock})
+	recorder := &recordingAgent{}
+	NewTrainer(marketEnv, recorder).RunEpisode()
+
+	for i, tr := range recorder.transitions {
+		idx := marketEnv.StartIdx() + i + 1
+		if want := times[idx].Sub(times[idx-1]); tr.Elapsed != want {
+			t.Fatalf("step %d: elapsed %v, want %v", i, tr.Elapsed, want)
+		}
+	}
+
+	// A weekend step is discounted by three days of a daily gamma
+	Q := agent.NewQTable(1, agent.NumActions)
+	Q.Set(state.State{}, agent.ActionNothing, 1)
+	learner := agent.NewQLearningAgent(Q, nil, 1, 0.9)
+	learner.Period = 24 * time.Hour
+	learner.Learn(agent.Transition{Action: agent.ActionBuySmall, Elapsed: 72 * time.Hour})
+	if got, want := Q.Get(state.State{}, agent.ActionBuySmall), math.Pow(0.9, 3); math.Abs(got-want) > 1e-12 {
+		t.Errorf("value over a weekend %.6f, want %.6f", got, want)
+	}
+}
+
+func TestTDAlarmAbortsDivergingTraining(t *testing.T) {
+	prices := make([]float64, 300)
+	for i := range prices {
+		prices[i] = 100 + 10*math.Sin(float64(i)/7)
+	}
+	marketEnv := env.NewMarketEnv(env.MarketConfig{Prices: prices, InitialCash: 10000, Encoder: state.NewMAEncoder()})
+	Q := agent.NewQTable(state.NumStates, agent.NumActions)
+	rng := rand.New(rand.NewSource(1))
+	// A discount above 1 makes the values grow without bound
+	learner := agent.NewQLearningAgent(Q, agent.NewEpsilonGreedyPolicy(Q.Q, 0.5, rng), 0.5, 1.5)
+
+	var alarms []Alarm
+	tr := NewTrainer(marketEnv, learner)
+	tr.TDAlarm = &TDAlarm{Growth: 10, Abort: true, OnAlarm: func(a Alarm) { alarms = append(alarms, a) }}
+	tr.Run(200, 1000)
+	if len(alarms) != 1 || !tr.Stopped() {
+		t.Fatalf("%d alarms, stopped %v; want 1 and stopped", len(alarms), tr.Stopped())
+	}
+	if a := alarms[0]; a.Episode == 1 || a.TDErrors.Count == 0 {
+		t.Errorf("alarm %s", a)
+	}
+}
+
+func TestGreedyActionsEntropy(t *testing.T) {
+	Q := [][]float64{{1, 0, 0, 0, 0}, {0, 1, 0, 0, 0}, {0, 0, 0, 0, 1}, {0, 0, 0, 1, 0}}
+	visits := NewVisits(len(Q))
+	visits.Add(0)
+	visits.Add(0)
+	visits.Add(1)
+	if counts, entropy := GreedyActions(Q, visits); counts[0] != 1 || counts[1] != 1 || math.Abs(entropy-1) > 1e-12 {
+		t.Errorf("counts %v, entropy %.4f; want one each of the first two actions and 1 bit", counts, entropy)
+	}
+	if visits.Count() != 2 {
+		t.Errorf("%d visited states, want 2", visits.Count())
+	}
+}
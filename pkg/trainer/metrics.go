@@ -0,0 +1,119 @@
+package trainer
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Metrics collects training progress and serves it in the Prometheus text format.
+// It is safe for concurrent use.
+type Metrics struct {
+	mu              sync.Mutex
+	started         time.Time
+	plannedEpisodes int
+	episodes        int
+	steps           int
+	stepsPerSecond  float64
+	episodeReturn   float64
+	epsilon         float64
+	validation      float64 // NaN until the first validation run
+	validations     int
+	greedyEntropy   float64 // NaN until the first evaluation
+}
+
+// NewMetrics creates metrics for a training run of the given number of episodes.
+func NewMetrics(plannedEpisodes int) *Metrics {
+	return &Metrics{started: time.Now(), plannedEpisodes: plannedEpisodes, validation: math.NaN(), greedyEntropy: math.NaN()}
+}
+
+// ObserveEpisode records a finished episode.
+func (m *Metrics) ObserveEpisode(stats EpisodeStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.episodes++
+	m.steps += stats.Steps
+	if stats.Duration > 0 {
+		m.stepsPerSecond = float64(stats.Steps) / stats.Duration.Seconds()
+	}
+	m.episodeReturn = stats.ReturnPct / 100
+}
+
+// SetEpsilon records the current exploration rate.
+func (m *Metrics) SetEpsilon(epsilon float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.epsilon = epsilon
+}
+
+// SetValidationReturn records the fractional return of the latest validation run.
+func (m *Metrics) SetValidationReturn(r float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validation = r
+	m.validations++
+}
+
+// SetGreedyEntropy records the entropy in bits of the greedy actions over the
+// visited states (see GreedyActions).
+func (m *Metrics) SetGreedyEntropy(bits float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.greedyEntropy = bits
+}
+
+// ServeHTTP implements http.Handler for a /metrics endpoint.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	write := func(name, kind, help string, value float64) error {
+		k, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, formatValue(value))
+		n += int64(k)
+		return err
+	}
+	metrics := []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"rlportfolio_train_start_time_seconds", "gauge", "Unix time the training run started.", float64(m.started.UnixNano()) / 1e9},
+		{"rlportfolio_train_episodes_planned", "gauge", "Number of episodes the run will train for.", float64(m.plannedEpisodes)},
+		{"rlportfolio_train_episodes_total", "counter", "Training episodes finished.", float64(m.episodes)},
+		{"rlportfolio_train_steps_total", "counter", "Environment steps taken in training.", float64(m.steps)},
+		{"rlportfolio_train_steps_per_second", "gauge", "Training throughput over the latest episode.", m.stepsPerSecond},
+		{"rlportfolio_train_episode_return", "gauge", "Fractional portfolio return of the latest training episode.", m.episodeReturn},
+		{"rlportfolio_train_epsilon", "gauge", "Current exploration rate.", m.epsilon},
+		{"rlportfolio_train_validations_total", "counter", "Validation runs finished.", float64(m.validations)},
+		{"rlportfolio_train_validation_return", "gauge", "Fractional return of the greedy policy in the latest validation run.", m.validation},
+		{"rlportfolio_train_greedy_entropy_bits", "gauge", "Entropy of the greedy actions over the visited states in the latest evaluation.", m.greedyEntropy},
+	}
+	for _, metric := range metrics {
+		if err := write(metric.name, metric.kind, metric.help, metric.value); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// formatValue formats a sample value as Prometheus expects.
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return fmt.Sprintf("%g", v)
+}
@@ -0,0 +1,99 @@
+package trainer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+)
+
+// OfflineConfig configures learning from logged transitions.
+type OfflineConfig struct {
+	Epochs int     // Passes over the transitions, shuffled each time
+	Alpha  float64 // Learning rate
+	Gamma  float64 // Discount factor
+	// Penalty weights the conservative (CQL-style) term: every update also lowers
+	// the soft maximum of Q(s, ·) and raises the logged action, so actions the
+	// behavior policy never took in s are not overestimated. 0 is plain Q-learning.
+	Penalty float64
+	Seed    int64
+}
+
+// OfflineStats summarizes one epoch of offline learning.
+type OfflineStats struct {
+	Epoch    int     // 1-based
+	TDError  float64 // Mean absolute TD error
+	Coverage float64 // Share of (state, action) pairs present in the data
+}
+
+// TrainOffline learns Q from logged transitions without an environment, e.g. ones
+// read with env.ReadTrajectory. Q is updated in place; onEpoch, if set, is called
+// after every epoch.
+func TrainOffline(Q [][]float64, transitions []agent.Transition, config OfflineConfig, onEpoch func(OfflineStats)) error {
+	if len(transitions) == 0 {
+		return fmt.Errorf("no transitions to learn from")
+	}
+	seen := make(map[[2]int]bool)
+	for i, t := range transitions {
+		for _, s := range []int{t.State.Index, t.NextState.Index} {
+			if s < 0 || s >= len(Q) {
+				return fmt.Errorf("transition %d: state %d outside the Q-table of %d states", i, s, len(Q))
+			}
+		}
+		if t.Action < 0 || int(t.Action) >= len(Q[t.State.Index]) {
+			return fmt.Errorf("transition %d: action %d outside the Q-table", i, t.Action)
+		}
+		seen[[2]int{t.State.Index, int(t.Action)}] = true
+	}
+	coverage := float64(len(seen)) / float64(len(Q)*len(Q[0]))
+
+	rng := rand.New(rand.NewSource(config.Seed))
+	order := make([]int, len(transitions))
+	for i := range order {
+		order[i] = i
+	}
+	probs := make([]float64, len(Q[0]))
+	for epoch := 1; epoch <= config.Epochs; epoch++ {
+		rng.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		tdSum := 0.0
+		for _, i := range order {
+			t := transitions[i]
+			q := Q[t.State.Index]
+			var qNext float64
+			if !t.Done {
+				qNext = agent.MaxValue(Q[t.NextState.Index])
+			}
+			tdError := t.Reward + config.Gamma*qNext - q[t.Action]
+			tdSum += math.Abs(tdError)
+
+			if config.Penalty > 0 {
+				// Gradient of logsumexp(Q(s, ·)) - Q(s, a): the softmax of Q(s, ·) minus
+				// the logged action
+				softmax(q, probs)
+				for a := range q {
+					q[a] -= config.Alpha * config.Penalty * probs[a]
+				}
+				q[t.Action] += config.Alpha * config.Penalty
+			}
+			q[t.Action] += config.Alpha * tdError
+		}
+		if onEpoch != nil {
+			onEpoch(OfflineStats{Epoch: epoch, TDError: tdSum / float64(len(order)), Coverage: coverage})
+		}
+	}
+	return nil
+}
+
+// softmax writes the softmax of values to out.
+func softmax(values, out []float64) {
+	hi := agent.MaxValue(values)
+	sum := 0.0
+	for i, v := range values {
+		out[i] = math.Exp(v - hi)
+		sum += out[i]
+	}
+	for i := range out {
+		out[i] /= sum
+	}
+}
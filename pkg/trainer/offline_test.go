@@ -0,0 +1,36 @@
+package trainer
+
+import (
+	"testing"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+	"github.com/kasaderos/rLportfolio/pkg/state"
+)
+
+func TestTrainOfflinePenalizesUnseenActions(t *testing.T) {
+	// One state whose logged action 1 earns a small reward; the other actions never
+	// appear in the data but start out overestimated
+	transitions := []agent.Transition{{State: state.State{Index: 0}, Action: 1, Reward: 0.01, Done: true}}
+	for _, penalty := range []float64{0, 0.1} {
+		Q := [][]float64{{0.5, 0, 0.5}}
+		config := OfflineConfig{Epochs: 200, Alpha: 0.1, Gamma: 0.95, Penalty: penalty}
+		if err := TrainOffline(Q, transitions, config, nil); err != nil {
+			t.Fatal(err)
+		}
+		greedy := agent.ArgMax(Q[0])
+		if penalty == 0 && greedy == 1 {
+			t.Errorf("without a penalty the unseen actions should stay preferred, got Q=%v", Q[0])
+		}
+		if penalty > 0 && greedy != 1 {
+			t.Errorf("penalty %v: greedy action %d, want the logged action 1 (Q=%v)", penalty, greedy, Q[0])
+		}
+	}
+}
+
+func TestTrainOfflineRejectsForeignStates(t *testing.T) {
+	Q := [][]float64{{0, 0}}
+	transitions := []agent.Transition{{State: state.State{Index: 3}}}
+	if err := TrainOffline(Q, transitions, OfflineConfig{Epochs: 1}, nil); err == nil {
+		t.Error("want an error for a state outside the Q-table")
+	}
+}
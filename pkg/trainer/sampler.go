@@ -0,0 +1,154 @@
+package trainer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Sampler picks the environment of every episode when one agent trains on several,
+// e.g. one per stock.
+type Sampler interface {
+	// Next returns the index of the environment of the next episode, in [0, n).
+	Next(n int) int
+	// Observe reports the outcome of an episode on environment i.
+	Observe(i int, stats EpisodeStats)
+}
+
+// Sampler names accepted by NewSampler.
+const (
+	SamplerRoundRobin  = "round-robin"
+	SamplerRandom      = "random"
+	SamplerPerformance = "performance"
+)
+
+// NewSampler returns the sampler with the given name, drawing from rng.
+func NewSampler(name string, rng *rand.Rand) (Sampler, error) {
+	switch name {
+	case SamplerRoundRobin:
+		return &RoundRobinSampler{}, nil
+	case SamplerRandom:
+		return &RandomSampler{Rng: rng}, nil
+	case SamplerPerformance:
+		return NewPerformanceSampler(rng, DefaultSamplerTemperature), nil
+	default:
+		return nil, fmt.Errorf("unknown sampler %q (use %s, %s, or %s)", name, SamplerRoundRobin, SamplerRandom, SamplerPerformance)
+	}
+}
+
+// RoundRobinSampler cycles through the environments, one episode each.
+type RoundRobinSampler struct {
+	next int
+}
+
+// Next returns the environment after the previous one.
+func (s *RoundRobinSampler) Next(n int) int {
+	i := s.next % n
+	s.next = i + 1
+	return i
+}
+
+// Observe does nothing.
+func (s *RoundRobinSampler) Observe(int, EpisodeStats) {}
+
+// RandomSampler picks an environment uniformly at random for every episode.
+type RandomSampler struct {
+	Rng *rand.Rand
+}
+
+// Next returns a random environment.
+func (s *RandomSampler) Next(n int) int {
+	return s.Rng.Intn(n)
+}
+
+// Observe does nothing.
+func (s *RandomSampler) Observe(int, EpisodeStats) {}
+
+// DefaultSamplerTemperature is the temperature of NewSampler's PerformanceSampler,
+// in percentage points of episode return.
+const DefaultSamplerTemperature = 10.0
+
+// PerformanceSampler favors the environments the agent does worst on: each is
+// picked with probability proportional to exp(-r/Temperature), r being an
+// exponential average of its episode returns in percent. Environments not yet
+// trained on count as r = 0.
+type PerformanceSampler struct {
+	Rng         *rand.Rand
+	Temperature float64
+	Decay       float64 // Weight of the latest return in the average
+
+	returns []float64
+}
+
+// NewPerformanceSampler creates a performance-weighted sampler.
+func NewPerformanceSampler(rng *rand.Rand, temperature float64) *PerformanceSampler {
+	return &PerformanceSampler{Rng: rng, Temperature: temperature, Decay: 0.2}
+}
+
+// Next draws an environment weighted by its average return.
+func (s *PerformanceSampler) Next(n int) int {
+	s.grow(n)
+	weights := s.Weights()[:n]
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	x := s.Rng.Float64() * total
+	for i, w := range weights {
+		if x < w {
+			return i
+		}
+		x -= w
+	}
+	return n - 1
+}
+
+// Observe updates the average return of environment i.
+func (s *PerformanceSampler) Observe(i int, stats EpisodeStats) {
+	s.grow(i + 1)
+	s.returns[i] += s.Decay * (stats.ReturnPct - s.returns[i])
+}
+
+// Weights returns the unnormalized sampling weight of every environment seen.
+func (s *PerformanceSampler) Weights() []float64 {
+	lo := math.Inf(1)
+	for _, r := range s.returns {
+		lo = math.Min(lo, r)
+	}
+	weights := make([]float64, len(s.returns))
+	for i, r := range s.returns {
+		// Relative to the worst, so the largest weight is 1 and none overflows
+		weights[i] = math.Exp(-(r - lo) / s.Temperature)
+	}
+	return weights
+}
+
+func (s *PerformanceSampler) grow(n int) {
+	for len(s.returns) < n {
+		s.returns = append(s.returns, 0)
+	}
+}
+
+// RunSampled trains on the trainers' environments for episodes in total, running
+// every episode on the trainer the sampler picks. The trainers normally share one
+// agent. It stops early when a trainer is stopped.
+func RunSampled(trainers []*Trainer, sampler Sampler, episodes int, reportInterval int) {
+	if len(trainers) == 0 {
+		return
+	}
+	if reportInterval <= 0 {
+		reportInterval = 100
+	}
+	for ep := 1; ep <= episodes; ep++ {
+		i := sampler.Next(len(trainers))
+		t := trainers[i]
+		if t.Stopped() {
+			return
+		}
+		stats := t.RunEpisode()
+		sampler.Observe(i, stats)
+		if ep%reportInterval == 0 {
+			t.report(stats)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package trainer
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRoundRobinSamplerCycles(t *testing.T) {
+	s := &RoundRobinSampler{}
+	for i := 0; i < 7; i++ {
+		if got := s.Next(3); got != i%3 {
+			t.Fatalf("episode %d: got %d, want %d", i, got, i%3)
+		}
+	}
+}
+
+func TestPerformanceSamplerFavorsWorst(t *testing.T) {
+	s := NewPerformanceSampler(rand.New(rand.NewSource(1)), DefaultSamplerTemperature)
+	for i := 0; i < 20; i++ {
+		s.Observe(0, EpisodeStats{ReturnPct: 50})
+		s.Observe(1, EpisodeStats{ReturnPct: -10})
+	}
+	counts := make([]int, 3)
+	for i := 0; i < 1000; i++ {
+		counts[s.Next(3)]++
+	}
+	if !(counts[1] > counts[2] && counts[2] > counts[0]) {
+		t.Errorf("picks %v, want the losing environment most and the winning one least", counts)
+	}
+}
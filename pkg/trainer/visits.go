@@ -0,0 +1,59 @@
+package trainer
+
+import (
+	"math"
+	"sync/atomic"
+
+	"github.com/kasaderos/rLportfolio/pkg/agent"
+)
+
+// Visits records the states training acted in. It is safe for concurrent use, so
+// parallel trainers can share one.
+type Visits struct {
+	visited []atomic.Bool
+	count   atomic.Int64
+}
+
+// NewVisits creates a record for states 0 to numStates-1.
+func NewVisits(numStates int) *Visits {
+	return &Visits{visited: make([]atomic.Bool, numStates)}
+}
+
+// Add records a visit of the state index; indices out of range are ignored.
+func (v *Visits) Add(index int) {
+	if index >= 0 && index < len(v.visited) && !v.visited[index].Swap(true) {
+		v.count.Add(1)
+	}
+}
+
+// Visited reports whether the state index was visited.
+func (v *Visits) Visited(index int) bool {
+	return index >= 0 && index < len(v.visited) && v.visited[index].Load()
+}
+
+// Count returns the number of distinct states visited.
+func (v *Visits) Count() int {
+	return int(v.count.Load())
+}
+
+// GreedyActions counts the greedy action of Q in every visited state and returns
+// the counts by action with the entropy of their distribution in bits: 0 when one
+// action is greedy everywhere, up to log2(agent.NumActions) when all are equally
+// common. An entropy that stopped changing suggests the policy has converged.
+func GreedyActions(Q [][]float64, visits *Visits) (counts []int, entropy float64) {
+	counts = make([]int, agent.NumActions)
+	total := 0
+	for index, values := range Q {
+		if visits.Visited(index) {
+			counts[agent.ArgMax(values)]++
+			total++
+		}
+	}
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(total)
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return counts, entropy
+}